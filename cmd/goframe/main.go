@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+/*
+  goframe 命令行工具
+  goframe new <AppName> [flags]：根据模板生成新应用
+*/
+
+const usage = `Usage:
+  goframe new <AppName> [flags]    生成新应用，goframe new -h 查看参数
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "new":
+		if err := runNew(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "goframe new: %s\n", err.Error())
+			os.Exit(1)
+		}
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var appNameRegexp = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// 模板参数
+type scaffold struct {
+	AppName     string
+	Module      string
+	ServerId    int
+	Port        int
+	MonitorPort int
+	CodeBase    int
+	Update      string
+
+	Mysql    bool
+	Mongo    bool
+	Redis    bool
+	Es       bool
+	RabbitMQ bool
+	Kafka    bool
+}
+
+// 是否注册存储组件（RabbitMQ 连接也作为存储组件）
+func (s scaffold) HasStorage() bool {
+	return s.Mysql || s.Mongo || s.Redis || s.Es || s.RabbitMQ
+}
+
+// 是否有MQ消费者
+func (s scaffold) HasMQ() bool {
+	return s.RabbitMQ || s.Kafka
+}
+
+// 生成的文件：路径 -> 模板
+func (s scaffold) files() map[string]string {
+	files := map[string]string{
+		"main.go":                               mainTemplate,
+		"makefile":                              makefileTemplate,
+		"appconfig/" + s.AppName + "Server.xml": serverXmlTemplate,
+		"apperrors/errno.go":                    errnoTemplate,
+		"appstorage/appstorage.go":              appstorageTemplate,
+		"apptask/apptask.go":                    apptaskTemplate,
+		"router/router.go":                      routerTemplate,
+		"router/api/api.go":                     apiTemplate,
+	}
+	if s.HasMQ() {
+		files["apptask/consumer.go"] = consumerTemplate
+	}
+	return files
+}
+
+func runNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	storages := fs.String("storage", "mysql,redis", "使用的存储，逗号分隔：mysql,mongo,redis,es")
+	mqs := fs.String("mq", "", "使用的MQ，逗号分隔：rabbitmq,kafka")
+	serverId := fs.Int("serverid", 0, "ServerId（必填）")
+	port := fs.Int("port", 0, "业务端口（必填）")
+	monitorPort := fs.Int("monitor-port", 0, "监控端口，默认为业务端口+1")
+	codeBase := fs.Int("code-base", 80000000, "错误码起始值")
+	module := fs.String("module", "", "应用的 import 路径，默认为小写的 AppName")
+	dir := fs.String("dir", "", "生成的目录，默认为 ./小写的 AppName")
+	force := fs.Bool("force", false, "目录不为空时覆盖已存在的文件")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: goframe new <AppName> [flags]")
+		fs.PrintDefaults()
+	}
+
+	// AppName 在参数之前或之后都可以
+	var appName string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		appName, args = args[0], args[1:]
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if appName == "" && fs.NArg() > 0 {
+		appName = fs.Arg(0)
+	}
+
+	if !appNameRegexp.MatchString(appName) {
+		return fmt.Errorf("AppName[%s] invalid", appName)
+	}
+	if *serverId <= 0 {
+		return errors.New("-serverid is required")
+	}
+	if *port <= 0 || *port > 65535 {
+		return errors.New("-port is required")
+	}
+	if *monitorPort == 0 {
+		*monitorPort = *port + 1
+	}
+
+	s := scaffold{
+		AppName:     appName,
+		Module:      *module,
+		ServerId:    *serverId,
+		Port:        *port,
+		MonitorPort: *monitorPort,
+		CodeBase:    *codeBase,
+		Update:      time.Now().Format("2006-1-2 15:04:05"),
+	}
+	if s.Module == "" {
+		s.Module = strings.ToLower(appName)
+	}
+	if err := s.setOptions(*storages, *mqs); err != nil {
+		return err
+	}
+
+	outDir := *dir
+	if outDir == "" {
+		outDir = strings.ToLower(appName)
+	}
+	if err := s.generate(outDir, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s generated in %s\n", appName, outDir)
+	fmt.Printf("  cd %s && go mod init %s && go mod tidy && make\n", outDir, s.Module)
+	return nil
+}
+
+// 解析存储和MQ参数
+func (s *scaffold) setOptions(storages, mqs string) error {
+	for _, name := range splitList(storages) {
+		switch name {
+		case "mysql":
+			s.Mysql = true
+		case "mongo":
+			s.Mongo = true
+		case "redis":
+			s.Redis = true
+		case "es":
+			s.Es = true
+		default:
+			return fmt.Errorf("storage[%s] unknown", name)
+		}
+	}
+
+	for _, name := range splitList(mqs) {
+		switch name {
+		case "rabbitmq":
+			s.RabbitMQ = true
+		case "kafka":
+			s.Kafka = true
+		default:
+			return fmt.Errorf("mq[%s] unknown", name)
+		}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// 生成全部文件，存在的文件只有 force 时覆盖
+func (s scaffold) generate(outDir string, force bool) error {
+	files := s.files()
+	if !force {
+		for name := range files {
+			if _, err := os.Stat(filepath.Join(outDir, name)); err == nil {
+				return fmt.Errorf("%s already exists, use -force to overwrite", filepath.Join(outDir, name))
+			}
+		}
+	}
+
+	for name, text := range files {
+		data, err := s.render(name, text)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(outDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 执行模板，go 文件格式化
+func (s scaffold) render(name, text string) ([]byte, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("template[%s]: %s", name, err.Error())
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, s); err != nil {
+		return nil, fmt.Errorf("template[%s]: %s", name, err.Error())
+	}
+
+	if !strings.HasSuffix(name, ".go") {
+		return buf.Bytes(), nil
+	}
+	data, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format[%s]: %s", name, err.Error())
+	}
+	return data, nil
+}
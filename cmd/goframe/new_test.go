@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_New(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goframe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outDir := filepath.Join(dir, "app2")
+	args := []string{"App2", "-serverid", "226037", "-port", "60820",
+		"-storage", "mysql,mongo,redis,es", "-mq", "rabbitmq,kafka", "-dir", outDir}
+	if err := runNew(args); err != nil {
+		t.Fatalf("runNew() Err: %s", err.Error())
+	}
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) Err: %s", name, err.Error())
+		}
+		return string(data)
+	}
+	if s := read("main.go"); !strings.Contains(s, `"app2/router"`) || !strings.Contains(s, "apptask.RegisterConsumers()") {
+		t.Errorf("main.go:\n%s", s)
+	}
+	if s := read("appconfig/App2Server.xml"); !strings.Contains(s, "<ServerId>226037</ServerId>") ||
+		!strings.Contains(s, "<MonitorPort>60821</MonitorPort>") || !strings.Contains(s, "<MgodbPool>") {
+		t.Errorf("App2Server.xml:\n%s", s)
+	}
+	if s := read("appstorage/appstorage.go"); !strings.Contains(s, "NewEsComponent") || !strings.Contains(s, "GetRabbitMQClient") {
+		t.Errorf("appstorage.go:\n%s", s)
+	}
+	if s := read("makefile"); !strings.Contains(s, "PROJECTNAME=App2") {
+		t.Errorf("makefile:\n%s", s)
+	}
+	_ = read("apptask/consumer.go")
+
+	// 已存在时不覆盖
+	if err := runNew(args); err == nil {
+		t.Error("runNew() want error when files exist")
+	}
+	if err := runNew(append(args, "-force")); err != nil {
+		t.Errorf("runNew(-force) Err: %s", err.Error())
+	}
+
+	// 不使用MQ时不生成消费者
+	outDir2 := filepath.Join(dir, "app3")
+	if err := runNew([]string{"App3", "-serverid", "1", "-port", "8080", "-storage", "", "-dir", outDir2}); err != nil {
+		t.Fatalf("runNew() Err: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Join(outDir2, "apptask/consumer.go")); !os.IsNotExist(err) {
+		t.Error("consumer.go should not be generated")
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+// 应用模板，参数见 scaffold；.go 文件生成后会格式化
+
+const mainTemplate = `package main
+
+import (
+	"{{.Module}}/appstorage"
+	"{{.Module}}/apptask"
+	"{{.Module}}/router"
+	"github.com/mutou1225/go-frame/frame/appengine"
+)
+
+const (
+	//Version 版本
+	Version = "010000"
+	//VersionEx 版本
+	VersionEx = "1.0.0"
+	//Update 版本
+	Update = "{{.Update}}"
+	//服务名
+	AppName = "{{.AppName}}"
+)
+
+func main() {
+	cli := &appengine.Cli{
+		AppName:   AppName,
+		Version:   Version,
+		VersionEx: VersionEx,
+		Update:    Update,
+		Setup: func() {
+			// app 路由
+			appengine.InitAppframe(router.InitAppRouter, nil, apptask.AppRegisterTasks)
+
+			// 存储组件
+			appstorage.RegisterStorage()
+{{- if .HasMQ}}
+
+			// 后台任务：MQ消费者
+			apptask.RegisterConsumers()
+{{- end}}
+		},
+	}
+
+	// 命令行：serve（默认，http服务和后台任务一起运行）、version、check-config、routes、cron list
+	// 配置文件默认为 /huishoubao/config/{{.AppName}}Server.xml，使用 -server-config 或 GOFRAME_SERVER_CONFIG 修改
+	cli.Execute()
+}
+`
+
+const errnoTemplate = `package apperrors
+
+import (
+	"github.com/mutou1225/go-frame/frame/errcode"
+)
+
+const (
+	RetSUCCESS  = 0
+	RetCodeBase = {{.CodeBase}}
+)
+
+var (
+	SUCCESS = errcode.AppError{ErrorCode: 0, ErrorInfo: "SUCCESS"}
+
+	// 公共错误码
+	INVALID_PARAMS      = errcode.AppError{ErrorCode: RetCodeBase + 100, ErrorInfo: "请求参数错误"}
+	DB_OPERATION_FAILED = errcode.AppError{ErrorCode: RetCodeBase + 101, ErrorInfo: "数据库操作失败"}
+
+	// 业务
+)
+`
+
+const appstorageTemplate = `package appstorage
+{{- if .HasStorage}}
+
+import (
+{{- if or .Mysql .Es .RabbitMQ}}
+	"github.com/mutou1225/go-frame/config"
+{{- end}}
+	"github.com/mutou1225/go-frame/frame/appengine"
+{{- if .RabbitMQ}}
+	"github.com/mutou1225/go-frame/implements/rabbitmq"
+{{- end}}
+{{- if .Mysql}}
+	"github.com/mutou1225/go-frame/implements/storage"
+{{- end}}
+)
+{{- end}}
+{{- if .RabbitMQ}}
+
+var (
+	RabbitMQ *appengine.RabbitMQComponent
+)
+{{- end}}
+
+// 注册存储组件，由框架按顺序初始化，退出时按相反顺序关闭
+func RegisterStorage() {
+{{- if not .HasStorage}}
+	// appengine.RegisterComponent(appengine.NewRedisComponent())
+{{- end}}
+{{- if .Mysql}}
+	// Mysql
+	appengine.RegisterComponent(appengine.NewMysqlComponent("mysql", storage.PriceMysql,
+		config.GetDBHost(), config.GetDBUser(), config.GetDBPassword(),
+		config.GetDBName(), config.GetDBPort()))
+{{- end}}
+{{- if .Mongo}}
+
+	// Mgo
+	appengine.RegisterComponent(appengine.NewMongoComponent())
+{{- end}}
+{{- if .Es}}
+
+	// es
+	appengine.RegisterComponent(appengine.NewEsComponent(config.GetESHost()))
+{{- end}}
+{{- if .Redis}}
+
+	// redis
+	appengine.RegisterComponent(appengine.NewRedisComponent())
+{{- end}}
+{{- if .RabbitMQ}}
+
+	// RabbitMQ
+	RabbitMQ = appengine.NewRabbitMQComponent("rabbitmq", config.GetRabbitMQEvaVhost())
+	appengine.RegisterComponent(RabbitMQ)
+{{- end}}
+}
+{{- if .RabbitMQ}}
+
+func GetRabbitMQClient() (*rabbitmq.RabbitMQ, error) {
+	return RabbitMQ.Client()
+}
+{{- end}}
+`
+
+const apptaskTemplate = `package apptask
+
+import (
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/implements/toolkit"
+	"github.com/mutou1225/go-frame/logger"
+)
+
+func funcTask() {
+	logger.PrintInfo("funcTask %s", toolkit.GetCurrentTime())
+}
+
+// 注册定时任务
+func AppRegisterTasks() []app.CronTask {
+	var tasksList = make([]app.CronTask, 0)
+
+	//添加定时任务
+	tasksList = append(tasksList, app.CronTask{Cron: "0 */5 * * * *", TaskFunc: funcTask})
+
+	return tasksList
+}
+`
+
+const consumerTemplate = `package apptask
+
+import (
+{{- if .RabbitMQ}}
+	"{{.Module}}/appstorage"
+{{- end}}
+{{- if .Kafka}}
+	"github.com/Shopify/sarama"
+	"github.com/mutou1225/go-frame/config"
+{{- end}}
+	"github.com/mutou1225/go-frame/frame/appengine"
+{{- if .RabbitMQ}}
+	"github.com/mutou1225/go-frame/implements/rabbitmq"
+{{- end}}
+	"github.com/mutou1225/go-frame/logger"
+{{- if .RabbitMQ}}
+	"github.com/streadway/amqp"
+{{- end}}
+)
+
+const (
+{{- if .RabbitMQ}}
+	mqExchangeName = "{{.AppName}}-exchange"
+	mqQueueName    = "{{.AppName}}-queue"
+{{- end}}
+{{- if .Kafka}}
+	kafkaGroupId = "{{.AppName}}"
+	kafkaTopic   = "{{.AppName}}-topic"
+{{- end}}
+)
+
+// 注册MQ消费者，和http服务一起运行，断开后自动重连
+func RegisterConsumers() {
+{{- if .RabbitMQ}}
+	appengine.RegisterMQConsumer(appengine.MQConsumer{
+		Name:       "rabbitmq-consumer",
+		Client:     appstorage.GetRabbitMQClient,
+		Receiver:   mqReceiver{},
+		ProcessCnt: 1,
+		Setup:      mqSetup,
+	})
+{{- end}}
+{{- if .Kafka}}
+	appengine.RegisterKafkaConsumer("kafka-consumer", config.GetKafkaHost(), kafkaGroupId,
+		kafkaHandler{}, []string{kafkaTopic})
+{{- end}}
+}
+{{- if .RabbitMQ}}
+
+type mqReceiver struct{}
+
+func (r mqReceiver) OnError(err error) {
+	logger.PrintError("MQOperation Err: %s", err.Error())
+}
+
+func (r mqReceiver) QueueName() string {
+	return mqQueueName
+}
+
+func (r mqReceiver) ConsumeName() string {
+	return "{{.AppName}}"
+}
+
+func (r mqReceiver) RouterKey() string {
+	return ""
+}
+
+// 处理消息，返回 true 时确认消息
+func (r mqReceiver) OnReceive(msgData []byte, workId int) bool {
+	logger.PrintInfo("OnReceive msg: %s", string(msgData))
+
+	// 业务逻辑操作 。。。
+
+	return true
+}
+
+// 创建exchange、queue并绑定
+func mqSetup(client *rabbitmq.RabbitMQ, channel *amqp.Channel) error {
+	if err := client.CreateExchange(channel, mqExchangeName, rabbitmq.MQKindFanout); err != nil {
+		logger.PrintError("rabbitmq.CreateExchange() Err: %s", err.Error())
+		return err
+	}
+
+	if err := client.CreateQueue(channel, mqQueueName); err != nil {
+		logger.PrintError("rabbitmq.CreateQueue() Err: %s", err.Error())
+		return err
+	}
+
+	if err := client.QueueBind(channel, mqQueueName, "", mqExchangeName); err != nil {
+		logger.PrintError("rabbitmq.QueueBind() Err: %s", err.Error())
+		return err
+	}
+	return nil
+}
+{{- end}}
+{{- if .Kafka}}
+
+type kafkaHandler struct{}
+
+func (kafkaHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
+func (kafkaHandler) Cleanup(_ sarama.ConsumerGroupSession) error { return nil }
+
+// 处理消息，处理完成后确认消息
+func (kafkaHandler) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		logger.PrintInfo("kafka topic:%s partition:%d offset:%d value:%s", msg.Topic, msg.Partition, msg.Offset, string(msg.Value))
+
+		// 业务逻辑操作 。。。
+
+		sess.MarkMessage(msg, "")
+	}
+	return nil
+}
+{{- end}}
+`
+
+const routerTemplate = `package router
+
+import (
+	"{{.Module}}/apperrors"
+	"{{.Module}}/router/api"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+)
+
+// 初始化路由
+func InitAppRouter(r *gin.Engine) {
+	invalid := app.WithInvalidParams(apperrors.INVALID_PARAMS)
+	apiG := r.Group("/{{.AppName}}")
+	{
+		app.POST(apiG, "/hello", api.HelloApi, invalid)
+	}
+}
+`
+
+const apiTemplate = `package api
+
+import (
+	"{{.Module}}/apperrors"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/logger"
+)
+
+// hello 请求参数
+type helloReq struct {
+	Name string ` + "`" + `json:"name" validate:"required,lte=64"` + "`" + `
+}
+
+// hello 响应参数
+type helloRsp struct {
+	Hello string ` + "`" + `json:"hello"` + "`" + `
+}
+
+// 通过 app.Handle 注册，参数解析、校验和响应由框架完成
+func HelloApi(c *gin.Context, req *helloReq) (interface{}, errcode.AppError) {
+	logger.PrintInfo("req: %+v", req)
+
+	return helloRsp{Hello: req.Name}, apperrors.SUCCESS
+}
+`
+
+const serverXmlTemplate = `<xml>
+    <Server>
+        <ServerId>{{.ServerId}}</ServerId>
+        <ServerName>{{.AppName}}</ServerName>
+        <ServerModel>1</ServerModel>
+        <ServerPort>{{.Port}}</ServerPort>
+        <MonitorPort>{{.MonitorPort}}</MonitorPort>
+        <MonitorAddr>0.0.0.0</MonitorAddr>
+        <LogFileName>{{.AppName}}</LogFileName>
+        <PidFile>/tmp/.{{.AppName}}.pid</PidFile>
+    </Server>
+{{- if .Mysql}}
+    <MysqlPool>
+        <PoolMin>3</PoolMin>
+        <PoolMax>5</PoolMax>
+        <Timeout>10</Timeout>
+        <ConnTimeout>3</ConnTimeout>
+    </MysqlPool>
+{{- end}}
+{{- if .Mongo}}
+    <MgodbPool>
+        <PoolMin>3</PoolMin>
+        <PoolMax>5</PoolMax>
+        <Timeout>10</Timeout>
+        <ConnTimeout>3</ConnTimeout>
+    </MgodbPool>
+{{- end}}
+{{- if .Redis}}
+    <RedisPool>
+        <PoolMin>1</PoolMin>
+        <PoolMax>5</PoolMax>
+        <IdleTime>300</IdleTime>
+        <ConnTimeout>3</ConnTimeout>
+    </RedisPool>
+{{- end}}
+    <Shutdown>
+        <StopAccepting>0</StopAccepting>
+        <DrainHTTP>3</DrainHTTP>
+        <StopConsumers>10</StopConsumers>
+        <StopCron>5</StopCron>
+        <CloseStorage>5</CloseStorage>
+        <FlushLogs>3</FlushLogs>
+    </Shutdown>
+</xml>
+`
+
+const makefileTemplate = `#include .env
+
+PROJECTNAME={{.AppName}}
+
+# Go related variables.
+GOBASE=$(shell pwd)
+GOBIN=$(GOBASE)
+GOFILES=$(wildcard *.go)
+
+# Redirect error output to a file, so we can show it in development mode.
+STDERR=/tmp/.$(PROJECTNAME)-stderr.txt
+
+# PID file will keep the process id of the server
+PID=/tmp/.$(PROJECTNAME).pid
+
+# Log file will keep the log of the server
+Log=/tmp/$(PROJECTNAME).log
+
+# Make is verbose in Linux. Make it silent.
+MAKEFLAGS += --silent
+
+## target: clean and build
+target: go-clean go-build
+
+## install: install to path
+install:
+
+## start: Start server
+start: start-server
+#	bash -c "trap 'make stop' EXIT; $(MAKE) compile start-server watch run='make compile start-server'"
+
+## stop: Stop server
+stop: stop-server
+
+## Restart: Stop AND Start server
+restart: restart-server
+
+## reload: Graceful restart, the new process inherits the listening sockets
+reload: reload-server
+
+start-server: stop-server
+	@echo "  >  $(PROJECTNAME) is available at $(ADDR)"
+	@sleep 1
+	@-$(GOBIN)/$(PROJECTNAME) > $(Log) 2>&1 & echo $$! > $(PID)
+	@cat $(PID) | sed "/^/s/^/  \>  PID: /"
+
+stop-server:
+	@-touch $(PID)
+	@-kill -15 ` + "`" + `cat $(PID)` + "`" + ` 2> /dev/null || true
+	@-rm $(PID)
+
+# watch: Run given command when code changes. e.g; make watch run="echo 'hey'"
+#watch:
+#	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) yolo -i . -e vendor -e bin -c "$(run)"
+
+
+restart-server: stop-server start-server
+
+# PidFile in the server config must be the same as $(PID)
+reload-server:
+	@-kill -USR2 ` + "`" + `cat $(PID)` + "`" + ` 2> /dev/null || $(MAKE) start-server
+	@sleep 1
+	@cat $(PID) | sed "/^/s/^/  \>  PID: /"
+
+## compile: Compile the binary.
+compile:
+	@-touch $(STDERR)
+	@-rm $(STDERR)
+	@-$(MAKE) -s go-compile 2> $(STDERR)
+	@cat $(STDERR) | sed -e '1s/.*/\nError:\n/'  | sed 's/make\[.*/ /' | sed "/^/s/^/     /" 1>&2
+
+# exec: Run given command, wrapped with custom GOPATH. e.g; make exec run="go test ./..."
+#exec:
+#	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) $(run)
+
+## clean: Clean build files. Runs ` + "`" + `go clean` + "`" + ` internally.
+clean: go-clean
+
+go-compile: go-clean go-get go-build
+
+go-build:
+	@echo "  >  Building binary..."
+	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) go build -o $(GOBIN)/$(PROJECTNAME) $(GOFILES)
+
+go-generate:
+	@echo "  >  Generating dependency files..."
+	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) go generate $(generate)
+
+go-get:
+	@echo "  >  Checking if there is any missing dependencies..."
+	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) go get $(get)
+
+go-install:
+	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) go install $(GOFILES)
+
+go-clean:
+	@echo "  >  Cleaning build cache"
+	@GOPATH=$(GOPATH) GOBIN=$(GOBIN) go clean
+
+.PHONY: help
+all: help
+help: makefile
+	@echo
+	@echo " Choose a command run in "$(PROJECTNAME)":"
+	@echo
+	@sed -n 's/^##//p' $< | column -t -s ':' |  sed -e 's/^/ /'
+	@echo
+`
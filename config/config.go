@@ -3,20 +3,11 @@ package config
 import (
 	"encoding/xml"
 	"fmt"
-	"github.com/mutou1225/go-frame/implements/watcher"
-	"io/ioutil"
 	"log"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 )
 
-var (
-	gConfig     = &xmlConfig{}
-	gConfigFile = ""
-)
-
 const (
 	configUpdateTime = 2 * time.Minute
 )
@@ -107,270 +98,210 @@ func InitConfig(configFile string) {
 	if configFile == "" {
 		configFile = "/huishoubao/config/tinyxml2/eva_pro_config.xml"
 	}
-	gConfigFile = configFile
 
-	file, err := os.Open(configFile)
-	if err != nil {
-		panic(fmt.Sprintf("open config error:%s", err.Error()))
-	}
-	defer file.Close() // 关闭文件
-
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
+	if err := defaultConfig.loadAppFile(configFile); err != nil {
 		panic(fmt.Sprintf("load config error:%s", err.Error()))
 	}
 
-	err = xml.Unmarshal(data, &gConfig)
-	if err != nil {
-		panic(fmt.Sprintf("load config error:%s", err.Error()))
-	}
-
-	log.Printf("Gconfig: %+v", gConfig)
+	log.Printf("Gconfig: %+v", defaultConfig.app)
 	log.Println(strings.Repeat("~", 37))
 
-	go updateConfig()
-}
-
-func updateConfig() {
-	w, err := watcher.FileWatcher(gConfigFile, configUpdateTime)
-	if err != nil {
-		log.Printf("updateConfig() Err: %s", err.Error())
-		return
-	}
-
-	for {
-		select {
-		case event := <-w.Event:
-			log.Println(event)
-			if file, err := os.Open(gConfigFile); err == nil {
-				if data, err := ioutil.ReadAll(file); err == nil {
-					tmpConfig := &xmlConfig{}
-					if err := xml.Unmarshal(data, tmpConfig); err == nil {
-						gConfig = tmpConfig
-						log.Printf("updateConfig: %+v", gConfig)
-					}
-				}
-				file.Close() // 关闭文件
-			}
-		case err := <-w.Error:
-			log.Printf("updateConfig() Err: %s", err.Error())
-		case <-w.Closed:
-			log.Printf("updateConfig() Closed")
-			return
-		}
-	}
+	go defaultConfig.watchAppFile()
 }
 
 // 获取全部配置信息
 func GetAllConfig() *xmlConfig {
-	return gConfig
+	return defaultConfig.GetAllConfig()
 }
 
 // 是不是开发测试环境
 func IsTest() bool {
-	return gConfig.IsTestEnv
+	return defaultConfig.IsTest()
 }
 
 // 环境标识
 func GetEnvironment() string {
-	return gConfig.Environment
+	return defaultConfig.GetEnvironment()
 }
 
 // 打印长度
 func GetPrintLen() int {
-	return gConfig.PrintLen
+	return defaultConfig.GetPrintLen()
 }
 
 // MysqlDB Host
 func GetDBHost() string {
-	return gConfig.MysqlDB.HostName
+	return defaultConfig.GetDBHost()
 }
 
 // MysqlDB UserName
 func GetDBUser() string {
-	return gConfig.MysqlDB.UserName
+	return defaultConfig.GetDBUser()
 }
 
 // MysqlDB Password
 func GetDBPassword() string {
-	return gConfig.MysqlDB.Password
+	return defaultConfig.GetDBPassword()
 }
 
 // MysqlDB Port
 func GetDBPort() int {
-	return gConfig.MysqlDB.Port
+	return defaultConfig.GetDBPort()
 }
 
 // MysqlDB DBName
 func GetDBName() string {
-	return gConfig.MysqlDB.DBName
+	return defaultConfig.GetDBName()
 }
 
 // MysqlDB IdleTimeout
 func GetDBIdleTimeout() int {
-	return gConfig.MysqlDB.IdleTimeout
+	return defaultConfig.GetDBIdleTimeout()
 }
 
 // HsbDB Host
 func GetHsbDBHost() string {
-	return gConfig.HsbDB.HostName
+	return defaultConfig.GetHsbDBHost()
 }
 
 // HsbDB UserName
 func GetHsbDBUser() string {
-	return gConfig.HsbDB.UserName
+	return defaultConfig.GetHsbDBUser()
 }
 
 // HsbDB Password
 func GetHsbDBPassword() string {
-	return gConfig.HsbDB.Password
+	return defaultConfig.GetHsbDBPassword()
 }
 
 // HsbDB Port
 func GetHsbDBPort() int {
-	return gConfig.HsbDB.Port
+	return defaultConfig.GetHsbDBPort()
 }
 
 // HsbDB DBName
 func GetHsbDBName() string {
-	return gConfig.HsbDB.DBName
+	return defaultConfig.GetHsbDBName()
 }
 
 // HsbDB IdleTimeout
 func GetHsbDBIdleTimeout() int {
-	return gConfig.HsbDB.IdleTimeout
+	return defaultConfig.GetHsbDBIdleTimeout()
 }
 
 // Mysql BiDB Host
 func GetBiDBHost() string {
-	return gConfig.BiDB.HostName
+	return defaultConfig.GetBiDBHost()
 }
 
 // Mysql BiDB UserName
 func GetBiDBUser() string {
-	return gConfig.BiDB.UserName
+	return defaultConfig.GetBiDBUser()
 }
 
 // Mysql BiDB Password
 func GetBiDBPassword() string {
-	return gConfig.BiDB.Password
+	return defaultConfig.GetBiDBPassword()
 }
 
 // Mysql BiDB Port
 func GetBiDBPort() int {
-	return gConfig.BiDB.Port
+	return defaultConfig.GetBiDBPort()
 }
 
 // Mysql BiDB DBName
 func GetBiDBName() string {
-	return gConfig.BiDB.DBName
+	return defaultConfig.GetBiDBName()
 }
 
 // Mysql BiDB IdleTimeout
 func GetBiDBIdleTimeout() int {
-	return gConfig.BiDB.IdleTimeout
+	return defaultConfig.GetBiDBIdleTimeout()
 }
 
 // Redis Host
 func GetRedisHost() string {
-	return gConfig.Redis.Host
+	return defaultConfig.GetRedisHost()
 }
 
 // Redis Auth
 func GetRedisAuth() string {
-	return gConfig.Redis.Auth
+	return defaultConfig.GetRedisAuth()
 }
 
 // Redis Port
 func GetRedisPort() int {
-	return gConfig.Redis.Port
+	return defaultConfig.GetRedisPort()
 }
 
 // RabbitMQ Host
 func GetRabbitMQHost() string {
-	return gConfig.RabbitMQ.Host
+	return defaultConfig.GetRabbitMQHost()
 }
 
 // RabbitMQ Port
 func GetRabbitMQPort() int {
-	return gConfig.RabbitMQ.Port
+	return defaultConfig.GetRabbitMQPort()
 }
 
 // RabbitMQ UserName
 func GetRabbitMQUser() string {
-	return gConfig.RabbitMQ.UserName
+	return defaultConfig.GetRabbitMQUser()
 }
 
 func GetRabbitMQPassword() string {
-	return gConfig.RabbitMQ.Password
+	return defaultConfig.GetRabbitMQPassword()
 }
 
 func GetRabbitMQEvaVhost() string {
-	return gConfig.RabbitMQ.EvaVhost
+	return defaultConfig.GetRabbitMQEvaVhost()
 }
 
 func GetMongoDBAdd() []string {
-	addr := make([]string, 0)
-	for _, confg := range gConfig.MongoDB {
-		addr = append(addr, confg.Host+":"+strconv.Itoa(confg.Port))
-	}
-	return addr
+	return defaultConfig.GetMongoDBAdd()
 }
 
 func GetMongoDBAddStr() string {
-	var addStr strings.Builder
-	for i, confg := range gConfig.MongoDB {
-		if i == 0 {
-			addStr.WriteString(fmt.Sprintf("%s:%d", confg.Host, confg.Port))
-		} else {
-			addStr.WriteString(fmt.Sprintf(",%s:%d", confg.Host, confg.Port))
-		}
-	}
-	return addStr.String()
+	return defaultConfig.GetMongoDBAddStr()
 }
 
 func GetMongoDBUsert() string {
-	for _, confg := range gConfig.MongoDB {
-		if confg.User != "" {
-			return confg.User
-		}
-	}
-	return ""
+	return defaultConfig.GetMongoDBUsert()
 }
 
 func GetMongoDBPasswd() string {
-	for _, confg := range gConfig.MongoDB {
-		if confg.User != "" {
-			return confg.Password
-		}
-	}
-	return ""
+	return defaultConfig.GetMongoDBPasswd()
 }
 
 func GetMongoDBName() string {
-	return gConfig.MongoDBCfg.DBName
+	return defaultConfig.GetMongoDBName()
 }
 
 func GetMongoDBReplica() string {
-	return gConfig.MongoDBCfg.Replica
+	return defaultConfig.GetMongoDBReplica()
 }
 
 // MysqlDB Host
 func GetESHost() string {
-	return gConfig.ElasticSearch.Host
+	return defaultConfig.GetESHost()
+}
+
+// Kafka Host
+func GetKafkaHost() string {
+	return defaultConfig.GetKafkaHost()
 }
 
 // 获取钉钉token
 func GetDingTalkOperate() string {
-	return gConfig.DingTalk.Operate
+	return defaultConfig.GetDingTalkOperate()
 }
 
 // 获取钉钉token
 func GetDingTalkOperateConf() string {
-	return gConfig.DingTalk.OperateConf
+	return defaultConfig.GetDingTalkOperateConf()
 }
 
 // 获取钉钉token
 func GetDingTalkDevelop() string {
-	return gConfig.DingTalk.Develop
+	return defaultConfig.GetDingTalkDevelop()
 }
@@ -0,0 +1,665 @@
+package config
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/implements/watcher"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+  配置实例：系统配置、服务配置、日志配置
+  包级的 GetXxx 函数使用默认实例（InitConfig、InitServerConfig、InitLogConfig 加载）
+  appengine.New 创建的应用可以使用 New、NewFromData 创建自己的配置实例
+*/
+
+var defaultConfig = &Config{
+	app:     &xmlConfig{},
+	server:  &xmlServerConfig{},
+	logConf: &GLogConfig,
+	caller:  make(map[string]string),
+	callee:  make(map[string]CalleeConfig),
+	other:   make(map[string]string),
+}
+
+type Config struct {
+	app           *xmlConfig
+	server        *xmlServerConfig
+	logConf       *xmlLogConfig
+	caller        map[string]string // Caller <id key>
+	callerProto   map[string]int    // Caller <id protocol>
+	callee        map[string]CalleeConfig
+	other         map[string]string // Other <k, v>
+	appFile       string
+	serverCfgFile string
+	// 配置文件重新加载时整体替换 app、server 和 map，不修改已有的实例
+	mutex sync.RWMutex
+
+	// 服务配置重新加载后执行
+	reloadHooks []func(c *Config)
+	hookMutex   sync.Mutex
+}
+
+// 默认配置实例
+func Default() *Config {
+	return defaultConfig
+}
+
+// 从文件创建配置实例，serverCfgFile 可以为空；不监控文件变更
+func New(configFile, serverCfgFile string) (*Config, error) {
+	c := newConfig()
+	if err := c.loadAppFile(configFile); err != nil {
+		return nil, err
+	}
+	if serverCfgFile != "" {
+		if err := c.loadServerFile(serverCfgFile); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// 从xml内容创建配置实例，用于测试
+func NewFromData(appData, serverData []byte) (*Config, error) {
+	c := newConfig()
+	if len(appData) > 0 {
+		if err := xml.Unmarshal(appData, c.app); err != nil {
+			return nil, err
+		}
+	}
+	if len(serverData) > 0 {
+		server := &xmlServerConfig{}
+		if err := xml.Unmarshal(serverData, server); err != nil {
+			return nil, err
+		}
+		c.setServer(server)
+	}
+	return c, nil
+}
+
+func newConfig() *Config {
+	return &Config{
+		app:         &xmlConfig{},
+		server:      &xmlServerConfig{},
+		logConf:     &xmlLogConfig{},
+		caller:      make(map[string]string),
+		callerProto: make(map[string]int),
+		callee:      make(map[string]CalleeConfig),
+		other:       make(map[string]string),
+	}
+}
+
+// 加载系统配置文件
+func (c *Config) loadAppFile(configFile string) error {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	tmpConfig := &xmlConfig{}
+	if err := xml.Unmarshal(data, tmpConfig); err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.app = tmpConfig
+	c.mutex.Unlock()
+	c.appFile = configFile
+	return nil
+}
+
+// 加载服务配置文件
+func (c *Config) loadServerFile(configFile string) error {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	tmpConfig := &xmlServerConfig{}
+	if err := xml.Unmarshal(data, tmpConfig); err != nil {
+		return err
+	}
+	c.serverCfgFile = configFile
+	c.setServer(tmpConfig)
+
+	log.Printf("gServerconfig: %+v", tmpConfig)
+	log.Println(strings.Repeat("~", 37))
+	return nil
+}
+
+// 加载日志配置文件
+func (c *Config) LoadLogConfig(configFile string) error {
+	data, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	tmpConfig := xmlLogConfig{}
+	if err := xml.Unmarshal(data, &tmpConfig); err != nil {
+		return err
+	}
+	*c.logConf = tmpConfig
+	return nil
+}
+
+// 监控系统配置文件，变更后重新加载
+func (c *Config) watchAppFile() {
+	c.watchFile(c.appFile, "updateConfig", func() error {
+		if err := c.loadAppFile(c.appFile); err != nil {
+			return err
+		}
+		log.Printf("updateConfig: %+v", c.appConf())
+		return nil
+	})
+}
+
+// 监控服务配置文件，变更后重新加载
+func (c *Config) watchServerFile() {
+	c.watchFile(c.serverCfgFile, "updateServerConfig", func() error {
+		if err := c.loadServerFile(c.serverCfgFile); err != nil {
+			return err
+		}
+		c.runReloadHooks()
+		return nil
+	})
+}
+
+// 服务配置文件重新加载后执行，只在监控文件变更的实例（InitServerConfig）生效
+func (c *Config) OnServerConfigReload(f func(c *Config)) {
+	c.hookMutex.Lock()
+	defer c.hookMutex.Unlock()
+	c.reloadHooks = append(c.reloadHooks, f)
+}
+
+func (c *Config) runReloadHooks() {
+	c.hookMutex.Lock()
+	hooks := make([]func(c *Config), len(c.reloadHooks))
+	copy(hooks, c.reloadHooks)
+	c.hookMutex.Unlock()
+
+	for _, f := range hooks {
+		f(c)
+	}
+}
+
+func (c *Config) watchFile(file, name string, reload func() error) {
+	if file == "" {
+		log.Printf("%s() Err: %s", name, errors.New("config file empty"))
+		return
+	}
+
+	w, err := watcher.FileWatcher(file, configUpdateTime)
+	if err != nil {
+		log.Printf("%s() Err: %s", name, err.Error())
+		return
+	}
+
+	for {
+		select {
+		case event := <-w.Event:
+			log.Println(event)
+			if err := reload(); err != nil {
+				log.Printf("%s() Err: %s", name, err.Error())
+			}
+		case err := <-w.Error:
+			log.Printf("%s() Err: %s", name, err.Error())
+		case <-w.Closed:
+			log.Printf("%s() Closed", name)
+			return
+		}
+	}
+}
+
+// 替换服务配置，并初始化可请求的server id、被调方信息、其他配置
+func (c *Config) setServer(server *xmlServerConfig) {
+	caller := make(map[string]string)
+	callerProto := make(map[string]int)
+	for _, v := range server.Caller {
+		caller[strconv.Itoa(v.Id)] = v.Key
+		if v.Protocol != 0 {
+			callerProto[strconv.Itoa(v.Id)] = v.Protocol
+		}
+	}
+	log.Println("Caller:", caller)
+	log.Println(strings.Repeat("~", 37))
+
+	callee := make(map[string]CalleeConfig)
+	for _, v := range server.Callee {
+		callee[strconv.Itoa(v.ServerId)] = v
+		callee[v.ServerName] = v
+		log.Printf("Callee: %+v", v)
+	}
+	log.Println(strings.Repeat("~", 37))
+
+	other := make(map[string]string)
+	for _, v := range server.Other {
+		other[v.Key] = v.Value
+		log.Printf("Other: %+v", v)
+	}
+	log.Println(strings.Repeat("~", 37))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.server = server
+	c.caller, c.callerProto, c.callee, c.other = caller, callerProto, callee, other
+}
+
+// 当前的系统配置
+func (c *Config) appConf() *xmlConfig {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.app
+}
+
+// 当前的服务配置
+func (c *Config) serverConf() *xmlServerConfig {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.server
+}
+
+// 获取日志配置
+func (c *Config) GetLogConfig() xmlLogConfig {
+	return *c.logConf
+}
+
+// 获取全部配置信息
+func (c *Config) GetAllConfig() *xmlConfig {
+	return c.appConf()
+}
+
+// 是不是开发测试环境
+func (c *Config) IsTest() bool {
+	return c.appConf().IsTestEnv
+}
+
+// 环境标识
+func (c *Config) GetEnvironment() string {
+	return c.appConf().Environment
+}
+
+// 打印长度
+func (c *Config) GetPrintLen() int {
+	return c.appConf().PrintLen
+}
+
+// MysqlDB Host
+func (c *Config) GetDBHost() string {
+	return c.appConf().MysqlDB.HostName
+}
+
+// MysqlDB UserName
+func (c *Config) GetDBUser() string {
+	return c.appConf().MysqlDB.UserName
+}
+
+// MysqlDB Password
+func (c *Config) GetDBPassword() string {
+	return c.appConf().MysqlDB.Password
+}
+
+// MysqlDB Port
+func (c *Config) GetDBPort() int {
+	return c.appConf().MysqlDB.Port
+}
+
+// MysqlDB DBName
+func (c *Config) GetDBName() string {
+	return c.appConf().MysqlDB.DBName
+}
+
+// MysqlDB IdleTimeout
+func (c *Config) GetDBIdleTimeout() int {
+	return c.appConf().MysqlDB.IdleTimeout
+}
+
+// HsbDB Host
+func (c *Config) GetHsbDBHost() string {
+	return c.appConf().HsbDB.HostName
+}
+
+// HsbDB UserName
+func (c *Config) GetHsbDBUser() string {
+	return c.appConf().HsbDB.UserName
+}
+
+// HsbDB Password
+func (c *Config) GetHsbDBPassword() string {
+	return c.appConf().HsbDB.Password
+}
+
+// HsbDB Port
+func (c *Config) GetHsbDBPort() int {
+	return c.appConf().HsbDB.Port
+}
+
+// HsbDB DBName
+func (c *Config) GetHsbDBName() string {
+	return c.appConf().HsbDB.DBName
+}
+
+// HsbDB IdleTimeout
+func (c *Config) GetHsbDBIdleTimeout() int {
+	return c.appConf().HsbDB.IdleTimeout
+}
+
+// Mysql BiDB Host
+func (c *Config) GetBiDBHost() string {
+	return c.appConf().BiDB.HostName
+}
+
+// Mysql BiDB UserName
+func (c *Config) GetBiDBUser() string {
+	return c.appConf().BiDB.UserName
+}
+
+// Mysql BiDB Password
+func (c *Config) GetBiDBPassword() string {
+	return c.appConf().BiDB.Password
+}
+
+// Mysql BiDB Port
+func (c *Config) GetBiDBPort() int {
+	return c.appConf().BiDB.Port
+}
+
+// Mysql BiDB DBName
+func (c *Config) GetBiDBName() string {
+	return c.appConf().BiDB.DBName
+}
+
+// Mysql BiDB IdleTimeout
+func (c *Config) GetBiDBIdleTimeout() int {
+	return c.appConf().BiDB.IdleTimeout
+}
+
+// Redis Host
+func (c *Config) GetRedisHost() string {
+	return c.appConf().Redis.Host
+}
+
+// Redis Auth
+func (c *Config) GetRedisAuth() string {
+	return c.appConf().Redis.Auth
+}
+
+// Redis Port
+func (c *Config) GetRedisPort() int {
+	return c.appConf().Redis.Port
+}
+
+// RabbitMQ Host
+func (c *Config) GetRabbitMQHost() string {
+	return c.appConf().RabbitMQ.Host
+}
+
+// RabbitMQ Port
+func (c *Config) GetRabbitMQPort() int {
+	return c.appConf().RabbitMQ.Port
+}
+
+// RabbitMQ UserName
+func (c *Config) GetRabbitMQUser() string {
+	return c.appConf().RabbitMQ.UserName
+}
+
+func (c *Config) GetRabbitMQPassword() string {
+	return c.appConf().RabbitMQ.Password
+}
+
+func (c *Config) GetRabbitMQEvaVhost() string {
+	return c.appConf().RabbitMQ.EvaVhost
+}
+
+func (c *Config) GetMongoDBAdd() []string {
+	addr := make([]string, 0)
+	for _, confg := range c.appConf().MongoDB {
+		addr = append(addr, confg.Host+":"+strconv.Itoa(confg.Port))
+	}
+	return addr
+}
+
+func (c *Config) GetMongoDBAddStr() string {
+	var addStr strings.Builder
+	for i, confg := range c.appConf().MongoDB {
+		if i == 0 {
+			addStr.WriteString(fmt.Sprintf("%s:%d", confg.Host, confg.Port))
+		} else {
+			addStr.WriteString(fmt.Sprintf(",%s:%d", confg.Host, confg.Port))
+		}
+	}
+	return addStr.String()
+}
+
+func (c *Config) GetMongoDBUsert() string {
+	for _, confg := range c.appConf().MongoDB {
+		if confg.User != "" {
+			return confg.User
+		}
+	}
+	return ""
+}
+
+func (c *Config) GetMongoDBPasswd() string {
+	for _, confg := range c.appConf().MongoDB {
+		if confg.User != "" {
+			return confg.Password
+		}
+	}
+	return ""
+}
+
+func (c *Config) GetMongoDBName() string {
+	return c.appConf().MongoDBCfg.DBName
+}
+
+func (c *Config) GetMongoDBReplica() string {
+	return c.appConf().MongoDBCfg.Replica
+}
+
+// MysqlDB Host
+func (c *Config) GetESHost() string {
+	return c.appConf().ElasticSearch.Host
+}
+
+// Kafka Host
+func (c *Config) GetKafkaHost() string {
+	return c.appConf().Kafka.Host
+}
+
+// 获取钉钉token
+func (c *Config) GetDingTalkOperate() string {
+	return c.appConf().DingTalk.Operate
+}
+
+// 获取钉钉token
+func (c *Config) GetDingTalkOperateConf() string {
+	return c.appConf().DingTalk.OperateConf
+}
+
+// 获取钉钉token
+func (c *Config) GetDingTalkDevelop() string {
+	return c.appConf().DingTalk.Develop
+}
+
+// 获取Caller的key
+func (c *Config) GetCallerKey(serId string) (key string, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	key, ok = c.caller[serId]
+	return
+}
+
+// 获取调用方配置的响应协议版本，没有配置时 ok 为 false
+func (c *Config) GetCallerProtocol(serId string) (protocol int, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	protocol, ok = c.callerProto[serId]
+	return
+}
+
+func (c *Config) GetOtherValue(key string) (value string, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	value, ok = c.other[key]
+	return
+}
+
+// 获取全部配置信息
+func (c *Config) GetAllSerConfig() *xmlServerConfig {
+	return c.serverConf()
+}
+
+// 获取本应用的 ServerId
+func (c *Config) GetServerId() int {
+	return c.serverConf().ServerConfig.ServerId
+}
+
+func (c *Config) GetServerIdStr() string {
+	return strconv.Itoa(c.serverConf().ServerConfig.ServerId)
+}
+
+// 获取本应用的 ServerName
+func (c *Config) GetServerName() string {
+	return c.serverConf().ServerConfig.ServerName
+}
+
+// 获取本应用的 ServerPort
+func (c *Config) GetServerPort() int {
+	return c.serverConf().ServerConfig.ServerPort
+}
+
+// 获取本应用的 MonitorPort
+func (c *Config) GetSerMonitorPort() int {
+	return c.serverConf().ServerConfig.MonitorPort
+}
+
+// 获取本应用的 MonitorAddr
+func (c *Config) GetSerMonitorAddr() string {
+	return c.serverConf().ServerConfig.MonitorAddr
+}
+
+// 获取本应用的 AdminToken
+func (c *Config) GetSerAdminToken() string {
+	return c.serverConf().ServerConfig.AdminToken
+}
+
+// 获取本应用的 LogFileName
+func (c *Config) GetSerLogFileName() string {
+	return c.serverConf().ServerConfig.LogFileName
+}
+
+// 获取本应用的 PidFile
+func (c *Config) GetSerPidFile() string {
+	return c.serverConf().ServerConfig.PidFile
+}
+
+// 获取本应用的 Mysql PoolMin
+func (c *Config) GetMysqlPoolMin() int {
+	return c.serverConf().MysqlPool.PoolMin
+}
+
+// 获取本应用的 Mysql PoolMax
+func (c *Config) GetMysqlPoolMax() int {
+	return c.serverConf().MysqlPool.PoolMax
+}
+
+// 获取本应用的 Mysql IdleTime
+func (c *Config) GetMysqlIdleTime() int {
+	return c.serverConf().MysqlPool.IdleTime
+}
+
+// 获取本应用的 Mysql ConnTime
+func (c *Config) GetMysqlConnTime() int {
+	return c.serverConf().MysqlPool.ConnTime
+}
+
+// 获取本应用的 Mgodb PoolMin
+func (c *Config) GetMgodbPoolMin() int {
+	return c.serverConf().MgodbPool.PoolMin
+}
+
+// 获取本应用的 Mgodb PoolMax
+func (c *Config) GetMgodbPoolMax() int {
+	return c.serverConf().MgodbPool.PoolMax
+}
+
+// 获取本应用的 Mgodb IdleTime
+func (c *Config) GetMgodbIdleTime() int {
+	return c.serverConf().MgodbPool.IdleTime
+}
+
+// 获取本应用的 Mgodb PoolMin
+func (c *Config) GetRedisPoolMin() int {
+	return c.serverConf().RedisPool.PoolMin
+}
+
+// 获取本应用的 Mgodb PoolMax
+func (c *Config) GetRedisPoolMax() int {
+	return c.serverConf().RedisPool.PoolMax
+}
+
+// 获取本应用的 Mgodb IdleTime
+func (c *Config) GetRedisIdleTime() int {
+	return c.serverConf().RedisPool.IdleTime
+}
+
+// 获取本应用的 Mgodb ConnTime
+func (c *Config) GetMgodbConnTime() int {
+	return c.serverConf().MgodbPool.ConnTime
+}
+
+// 获取退出各阶段的超时时间
+func (c *Config) GetShutdownConfig() ShutdownConfig {
+	return c.serverConf().Shutdown
+}
+
+// 获取业务端口的 TLS 配置
+func (c *Config) GetTLSConfig() TLSConfig {
+	return c.serverConf().TLS
+}
+
+// 获取维护模式的错误配置
+func (c *Config) GetMaintenanceConfig() MaintenanceConfig {
+	return c.serverConf().Maintenance
+}
+
+// 获取响应协议版本的配置
+func (c *Config) GetProtocolConfig() ProtocolConfig {
+	return c.serverConf().Protocol
+}
+
+// 获取响应压缩的配置
+func (c *Config) GetCompressConfig() CompressConfig {
+	return c.serverConf().Compress
+}
+
+// 获取 ETag 的配置
+func (c *Config) GetETagConfig() ETagConfig {
+	return c.serverConf().ETag
+}
+
+// 获取定时任务的配置
+func (c *Config) GetCronConfig() []CronTaskConfig {
+	return c.serverConf().Cron.Tasks
+}
+
+// 获取被调方信息
+func (c *Config) GetCalleeByServerId(serId string) (callss CalleeConfig, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	callss, ok = c.callee[serId]
+	return
+}
+
+// 获取被调方信息
+func (c *Config) GetCalleeByServerName(serName string) (callss CalleeConfig, ok bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	callss, ok = c.callee[serName]
+	return
+}
@@ -2,14 +2,17 @@ package config
 
 import (
 	"encoding/xml"
-	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
 	"strings"
 )
 
-var GLogConfig = xmlLogConfig{}
+// 默认的日志配置文件
+const DefaultLogConfigFile = "/huishoubao/config/GoAppLogConfig.xml"
+
+var (
+	GLogConfig    = xmlLogConfig{}
+	logConfigFile = DefaultLogConfigFile
+)
 
 type xmlLogConfig struct {
 	XMLName      xml.Name `xml:"xml"`
@@ -29,24 +32,23 @@ type xmlLog struct {
 	Suffix      string `xml:"Suffix"`
 }
 
-// 初始化系统配置文件
-func InitLogConfig() {
-	file, err := os.Open("/huishoubao/config/GoAppLogConfig.xml")
-	if err != nil {
-		fmt.Sprintf("open config error:%s", err.Error())
-		return
+// 设置日志配置文件，在 InitLogConfig 之前调用，为空时使用默认文件
+func SetLogConfigFile(file string) {
+	if file == "" {
+		file = DefaultLogConfigFile
 	}
-	defer file.Close() // 关闭文件
+	logConfigFile = file
+}
 
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		fmt.Sprintf("load config error:%s", err.Error())
-		return
-	}
+// 日志配置文件
+func GetLogConfigFile() string {
+	return logConfigFile
+}
 
-	err = xml.Unmarshal(data, &GLogConfig)
-	if err != nil {
-		fmt.Sprintf("load config error:%s", err.Error())
+// 初始化日志配置文件
+func InitLogConfig() {
+	if err := defaultConfig.LoadLogConfig(logConfigFile); err != nil {
+		log.Printf("load log config[%s] error:%s", logConfigFile, err.Error())
 		return
 	}
 
@@ -3,31 +3,25 @@ package config
 import (
 	"encoding/xml"
 	"fmt"
-	"github.com/mutou1225/go-frame/implements/watcher"
-	"io/ioutil"
 	"log"
-	"os"
-	"strconv"
-	"strings"
-)
-
-var (
-	gServerconfig     = &xmlServerConfig{}
-	gServerConfigFile = ""
-	serverCaller      *map[string]string //Caller <id key>
-	serverCallee      *map[string]CalleeConfig
-	serverOther       *map[string]string //Caller <k, v>
 )
 
 type xmlServerConfig struct {
-	XMLName      xml.Name       `xml:"xml"`
-	ServerConfig ServerConfig   `xml:"Server"`
-	MysqlPool    DBPoolConfig   `xml:"MysqlPool"`
-	MgodbPool    DBPoolConfig   `xml:"MgodbPool"`
-	RedisPool    DBPoolConfig   `xml:"RedisPool"`
-	Caller       []callerConfig `xml:"Caller"`
-	Callee       []CalleeConfig `xml:"Callee"`
-	Other        []OtherConfig  `xml:"Other"`
+	XMLName      xml.Name          `xml:"xml"`
+	ServerConfig ServerConfig      `xml:"Server"`
+	MysqlPool    DBPoolConfig      `xml:"MysqlPool"`
+	MgodbPool    DBPoolConfig      `xml:"MgodbPool"`
+	RedisPool    DBPoolConfig      `xml:"RedisPool"`
+	Caller       []callerConfig    `xml:"Caller"`
+	Callee       []CalleeConfig    `xml:"Callee"`
+	Other        []OtherConfig     `xml:"Other"`
+	Shutdown     ShutdownConfig    `xml:"Shutdown"`
+	TLS          TLSConfig         `xml:"TLS"`
+	Maintenance  MaintenanceConfig `xml:"Maintenance"`
+	Cron         CronConfig        `xml:"Cron"`
+	Protocol     ProtocolConfig    `xml:"Protocol"`
+	Compress     CompressConfig    `xml:"Compress"`
+	ETag         ETagConfig        `xml:"ETag"`
 }
 
 type ServerConfig struct {
@@ -36,7 +30,10 @@ type ServerConfig struct {
 	ServerModel string `xml:"ServerModel"`
 	ServerPort  int    `xml:"ServerPort"`
 	MonitorPort int    `xml:"MonitorPort"`
+	MonitorAddr string `xml:"MonitorAddr"` // 监控服务绑定的地址，默认 127.0.0.1
+	AdminToken  string `xml:"AdminToken"`  // 修改类运维接口的令牌，为空时禁止调用
 	LogFileName string `xml:"LogFileName"`
+	PidFile     string `xml:"PidFile"` // 启动完成后写入pid，平滑重启后为新进程的pid
 }
 
 type DBPoolConfig struct {
@@ -46,9 +43,89 @@ type DBPoolConfig struct {
 	ConnTime int `xml:"ConnTimeout"`
 }
 
+// 退出各阶段的超时时间（秒），0 使用默认值
+type ShutdownConfig struct {
+	StopAccepting int `xml:"StopAccepting"` // 摘除流量后等待的时间
+	DrainHTTP     int `xml:"DrainHTTP"`
+	StopConsumers int `xml:"StopConsumers"`
+	StopCron      int `xml:"StopCron"`
+	CloseStorage  int `xml:"CloseStorage"`
+	FlushLogs     int `xml:"FlushLogs"`
+}
+
+// 业务端口的 TLS、HTTP/2 配置，CertFile、KeyFile 都设置时开启 TLS（同时支持 h2）
+// 证书文件变更后自动重新加载
+type TLSConfig struct {
+	CertFile     string `xml:"CertFile"`
+	KeyFile      string `xml:"KeyFile"`
+	MinVersion   string `xml:"MinVersion"`   // 1.0、1.1、1.2、1.3，默认 1.2
+	ClientCAFile string `xml:"ClientCAFile"` // 设置后校验调用方的客户端证书（mTLS）
+	ClientAuth   string `xml:"ClientAuth"`   // require（默认）：必须提供证书；optional：提供证书时校验
+	H2C          int    `xml:"H2C"`          // 1：未开启 TLS 时支持 h2c（明文 HTTP/2）
+}
+
+// 维护模式下业务接口返回的错误，ErrorCode 为 0 时使用框架默认的错误
+type MaintenanceConfig struct {
+	ErrorCode int    `xml:"ErrorCode"`
+	ErrorInfo string `xml:"ErrorInfo"`
+}
+
+// 定时任务的配置，按任务名覆盖代码中的定时参数，服务配置重新加载后生效
+type CronConfig struct {
+	Tasks []CronTaskConfig `xml:"Task"`
+}
+
+type CronTaskConfig struct {
+	Name   string `xml:"Name"`   // 任务名，同 CronTask.TaskName()
+	Cron   string `xml:"Cron"`   // 定时参数，为空时使用代码中的
+	Enable *int   `xml:"Enable"` // 0：禁用，不配置时启用
+}
+
+// 是否启用
+func (t CronTaskConfig) Enabled() bool {
+	return t.Enable == nil || *t.Enable != 0
+}
+
+// 响应协议版本的协商：调用方没有配置 protocol 时，按请求的 _version 选择，都没有时使用 Default
+type ProtocolConfig struct {
+	Default  int                     `xml:"Default"` // 1、15、2，0 时为 2
+	Versions []ProtocolVersionConfig `xml:"Version"`
+}
+
+type ProtocolVersionConfig struct {
+	Value    string `xml:"Value"`    // 请求头的 _version
+	Protocol int    `xml:"Protocol"` // 响应的协议版本
+}
+
+// 响应压缩：按 Accept-Encoding 使用 gzip 或 deflate，不配置时开启
+type CompressConfig struct {
+	Enable       *int     `xml:"Enable"`      // 0：关闭
+	MinSize      int      `xml:"MinSize"`     // 响应体达到该字节数才压缩，默认 1024
+	Level        int      `xml:"Level"`       // 压缩级别 1-9，默认 6
+	ExcludePaths []string `xml:"ExcludePath"` // 不压缩的路径前缀
+}
+
+// 是否启用
+func (c CompressConfig) Enabled() bool {
+	return c.Enable == nil || *c.Enable != 0
+}
+
+// GET 请求的 ETag 和 If-None-Match，不配置时开启
+type ETagConfig struct {
+	Enable       *int     `xml:"Enable"`      // 0：关闭
+	MaxSize      int      `xml:"MaxSize"`     // 超过该字节数的响应不计算 ETag，默认 4MB
+	ExcludePaths []string `xml:"ExcludePath"` // 不计算 ETag 的路径前缀
+}
+
+// 是否启用
+func (c ETagConfig) Enabled() bool {
+	return c.Enable == nil || *c.Enable != 0
+}
+
 type callerConfig struct {
-	Id  int    `xml:"id"`
-	Key string `xml:"key"`
+	Id       int    `xml:"id"`
+	Key      string `xml:"key"`
+	Protocol int    `xml:"protocol"` // 响应的协议版本：1、15、2，0 时不指定
 }
 
 type CalleeConfig struct {
@@ -65,218 +142,182 @@ type OtherConfig struct {
 
 // 初始化app的依赖配置
 func InitServerConfig(configFile string) {
-	gServerConfigFile = configFile
 	if configFile == "" {
 		log.Println("InitServerConfig() Error! configFile Empty!")
 		return
 	}
 
-	file, err := os.Open(configFile)
-	if err != nil {
-		panic(fmt.Sprintf("open config error:%s", err.Error()))
-	}
-	defer file.Close() // 关闭文件
-
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
+	if err := defaultConfig.loadServerFile(configFile); err != nil {
 		panic(fmt.Sprintf("load config error:%s", err.Error()))
 	}
 
-	err = xml.Unmarshal(data, &gServerconfig)
-	if err != nil {
-		panic(fmt.Sprintf("load config error:%s", err.Error()))
-	}
-
-	initCallerInfo()
-	initCalleeInfo()
-	initOtherInfo()
-
-	log.Printf("gServerconfig: %+v", gServerconfig)
-	log.Println(strings.Repeat("~", 37))
-
-	go updateServerConfig()
-}
-
-func updateServerConfig() {
-	w, err := watcher.FileWatcher(gServerConfigFile, configUpdateTime)
-	if err != nil {
-		log.Printf("updateServerConfig() Err: %s", err.Error())
-		return
-	}
-
-	for {
-		select {
-		case event := <-w.Event:
-			log.Println(event)
-			if file, err := os.Open(gServerConfigFile); err == nil {
-				if data, err := ioutil.ReadAll(file); err == nil {
-					tmpConfig := &xmlServerConfig{}
-					if err := xml.Unmarshal(data, tmpConfig); err == nil {
-						gServerconfig = tmpConfig
-
-						initCallerInfo()
-						initCalleeInfo()
-						initOtherInfo()
-
-						log.Printf("gServerconfig: %+v", gServerconfig)
-						log.Println(strings.Repeat("~", 37))
-					}
-				}
-				file.Close() // 关闭文件
-			}
-		case err := <-w.Error:
-			log.Printf("updateServerConfig() Err: %s", err.Error())
-		case <-w.Closed:
-			log.Printf("updateServerConfig() Closed")
-			return
-		}
-	}
-}
-
-// 初始化可请求的server id
-func initCallerInfo() {
-	// 初始化
-	serverCallerTmp := make(map[string]string)
-	for _, caller := range gServerconfig.Caller {
-		serverCallerTmp[strconv.Itoa(caller.Id)] = caller.Key
-	}
-	serverCaller = &serverCallerTmp
-
-	log.Println("Caller:", serverCaller)
-	log.Println(strings.Repeat("~", 37))
-}
-
-// 初始化被调方信息
-func initCalleeInfo() {
-	// 初始化
-	serverCalleeTmp := make(map[string]CalleeConfig)
-	for _, callee := range gServerconfig.Callee {
-		serverCalleeTmp[strconv.Itoa(callee.ServerId)] = callee
-		serverCalleeTmp[callee.ServerName] = callee
-		log.Printf("Callee: %+v", callee)
-	}
-	serverCallee = &serverCalleeTmp
-	log.Println(strings.Repeat("~", 37))
-}
-
-func initOtherInfo() {
-	serverOtherTmp := make(map[string]string)
-	for _, other := range gServerconfig.Other {
-		serverOtherTmp[other.Key] = other.Value
-		log.Printf("Other: %+v", other)
-	}
-	serverOther = &serverOtherTmp
-	log.Println(strings.Repeat("~", 37))
+	go defaultConfig.watchServerFile()
 }
 
 // 获取Caller的key
 func GetCallerKey(serId string) (key string, ok bool) {
-	key, ok = (*serverCaller)[serId]
-	return
+	return defaultConfig.GetCallerKey(serId)
 }
 
 func GetOtherValue(key string) (value string, ok bool) {
-	value, ok = (*serverOther)[key]
-	return
+	return defaultConfig.GetOtherValue(key)
 }
 
 // 获取全部配置信息
 func GetAllSerConfig() *xmlServerConfig {
-	return gServerconfig
+	return defaultConfig.GetAllSerConfig()
 }
 
 // 获取本应用的 ServerId
 func GetServerId() int {
-	return gServerconfig.ServerConfig.ServerId
+	return defaultConfig.GetServerId()
 }
 
 func GetServerIdStr() string {
-	return strconv.Itoa(gServerconfig.ServerConfig.ServerId)
+	return defaultConfig.GetServerIdStr()
 }
 
 // 获取本应用的 ServerName
 func GetServerName() string {
-	return gServerconfig.ServerConfig.ServerName
+	return defaultConfig.GetServerName()
 }
 
 // 获取本应用的 ServerPort
 func GetServerPort() int {
-	return gServerconfig.ServerConfig.ServerPort
+	return defaultConfig.GetServerPort()
 }
 
 // 获取本应用的 MonitorPort
 func GetSerMonitorPort() int {
-	return gServerconfig.ServerConfig.MonitorPort
+	return defaultConfig.GetSerMonitorPort()
+}
+
+// 获取本应用的 MonitorAddr
+func GetSerMonitorAddr() string {
+	return defaultConfig.GetSerMonitorAddr()
+}
+
+// 获取本应用的 AdminToken
+func GetSerAdminToken() string {
+	return defaultConfig.GetSerAdminToken()
 }
 
 // 获取本应用的 LogFileName
 func GetSerLogFileName() string {
-	return gServerconfig.ServerConfig.LogFileName
+	return defaultConfig.GetSerLogFileName()
+}
+
+// 获取本应用的 PidFile
+func GetSerPidFile() string {
+	return defaultConfig.GetSerPidFile()
 }
 
 // 获取本应用的 Mysql PoolMin
 func GetMysqlPoolMin() int {
-	return gServerconfig.MysqlPool.PoolMin
+	return defaultConfig.GetMysqlPoolMin()
 }
 
 // 获取本应用的 Mysql PoolMax
 func GetMysqlPoolMax() int {
-	return gServerconfig.MysqlPool.PoolMax
+	return defaultConfig.GetMysqlPoolMax()
 }
 
 // 获取本应用的 Mysql IdleTime
 func GetMysqlIdleTime() int {
-	return gServerconfig.MysqlPool.IdleTime
+	return defaultConfig.GetMysqlIdleTime()
 }
 
 // 获取本应用的 Mysql ConnTime
 func GetMysqlConnTime() int {
-	return gServerconfig.MysqlPool.ConnTime
+	return defaultConfig.GetMysqlConnTime()
 }
 
 // 获取本应用的 Mgodb PoolMin
 func GetMgodbPoolMin() int {
-	return gServerconfig.MgodbPool.PoolMin
+	return defaultConfig.GetMgodbPoolMin()
 }
 
 // 获取本应用的 Mgodb PoolMax
 func GetMgodbPoolMax() int {
-	return gServerconfig.MgodbPool.PoolMax
+	return defaultConfig.GetMgodbPoolMax()
 }
 
 // 获取本应用的 Mgodb IdleTime
 func GetMgodbIdleTime() int {
-	return gServerconfig.MgodbPool.IdleTime
+	return defaultConfig.GetMgodbIdleTime()
 }
 
 // 获取本应用的 Mgodb PoolMin
 func GetRedisPoolMin() int {
-	return gServerconfig.RedisPool.PoolMin
+	return defaultConfig.GetRedisPoolMin()
 }
 
 // 获取本应用的 Mgodb PoolMax
 func GetRedisPoolMax() int {
-	return gServerconfig.RedisPool.PoolMax
+	return defaultConfig.GetRedisPoolMax()
 }
 
 // 获取本应用的 Mgodb IdleTime
 func GetRedisIdleTime() int {
-	return gServerconfig.RedisPool.IdleTime
+	return defaultConfig.GetRedisIdleTime()
 }
 
 // 获取本应用的 Mgodb ConnTime
 func GetMgodbConnTime() int {
-	return gServerconfig.MgodbPool.ConnTime
+	return defaultConfig.GetMgodbConnTime()
+}
+
+// 获取退出各阶段的超时时间
+func GetShutdownConfig() ShutdownConfig {
+	return defaultConfig.GetShutdownConfig()
+}
+
+// 获取业务端口的 TLS 配置
+func GetTLSConfig() TLSConfig {
+	return defaultConfig.GetTLSConfig()
+}
+
+// 获取维护模式的错误配置
+func GetMaintenanceConfig() MaintenanceConfig {
+	return defaultConfig.GetMaintenanceConfig()
+}
+
+// 获取响应协议版本的配置
+func GetProtocolConfig() ProtocolConfig {
+	return defaultConfig.GetProtocolConfig()
+}
+
+// 获取响应压缩的配置
+func GetCompressConfig() CompressConfig {
+	return defaultConfig.GetCompressConfig()
+}
+
+// 获取 ETag 的配置
+func GetETagConfig() ETagConfig {
+	return defaultConfig.GetETagConfig()
+}
+
+// 获取调用方配置的响应协议版本
+func GetCallerProtocol(serId string) (protocol int, ok bool) {
+	return defaultConfig.GetCallerProtocol(serId)
+}
+
+// 获取定时任务的配置
+func GetCronConfig() []CronTaskConfig {
+	return defaultConfig.GetCronConfig()
+}
+
+// 服务配置文件重新加载后执行
+func OnServerConfigReload(f func(c *Config)) {
+	defaultConfig.OnServerConfigReload(f)
 }
 
 // 获取被调方信息
 func GetCalleeByServerId(serId string) (callss CalleeConfig, ok bool) {
-	callss, ok = (*serverCallee)[serId]
-	return
+	return defaultConfig.GetCalleeByServerId(serId)
 }
 
 // 获取被调方信息
 func GetCalleeByServerName(serName string) (callss CalleeConfig, ok bool) {
-	callss, ok = (*serverCallee)[serName]
-	return
+	return defaultConfig.GetCalleeByServerName(serName)
 }
@@ -4,13 +4,13 @@ import (
 	"github.com/mutou1225/go-frame/frame/protocol"
 )
 
-// 获取调价方案列表请求参数
+// 获取调价方案列表请求参数，doc 标签是 OpenAPI 文档的字段说明
 type Test struct {
 	Id        string `json:"id" validate:"omitempty,numeric"`
-	Status    string `json:"status" validate:"omitempty,oneof=0 1"`      // 方案状态 1有效；0无效
-	Keyword   string `json:"keyword" validate:"omitempty,lte=64"`        // 搜索关键字 名称&ID
-	PageIndex string `json:"pageIndex" validate:"required,numeric"`      // 分页：页码
-	PageSize  string `json:"pageSize" validate:"required,numeric,lte=3"` // 分页：页数
+	Status    string `json:"status" validate:"omitempty,oneof=0 1" doc:"方案状态 1有效；0无效"`
+	Keyword   string `json:"keyword" validate:"omitempty,lte=64" doc:"搜索关键字 名称&ID"`
+	PageIndex string `json:"pageIndex" validate:"required,numrange=0~" doc:"分页：页码"`
+	PageSize  string `json:"pageSize" validate:"required,numrange=1~500" doc:"分页：页数"`
 }
 
 // 获取调价方案列表响应参数
@@ -41,11 +41,30 @@ type TestSet struct {
 	UserName  string `json:"userName" validate:"required,lte=100"`
 }
 
+// 导出产品请求参数
+type TestExport struct {
+	Id      string `json:"id" validate:"omitempty,numeric"`
+	Keyword string `json:"keyword" validate:"omitempty,lte=64" doc:"搜索关键字 名称&ID"`
+	Format  string `json:"format" validate:"omitempty,oneof=xlsx csv" doc:"文件格式，默认 xlsx"`
+}
+
+// 导出任务进度请求参数
+type TestExportStatus struct {
+	Id string `json:"id" validate:"required,lte=64" doc:"导出任务ID"`
+}
+
+// 导入产品的行，import 标签为表头的列名，和导出的列名相同
+type ProductImportRow struct {
+	ProductId   int    `json:"productId" import:"产品ID" validate:"required,gt=0"`
+	ProductName string `json:"productName" import:"产品名称" validate:"required,lte=64"`
+	PicId       string `json:"picId" import:"图片ID" validate:"omitempty,lte=64"`
+}
+
 /////
 type TestEs struct {
 	Id        string `json:"id" validate:"omitempty,numeric"`
-	Status    string `json:"status" validate:"omitempty,oneof=0 1"`      // 方案状态 1有效；0无效
-	Keyword   string `json:"keyword" validate:"omitempty,lte=64"`        // 搜索关键字 名称&ID
-	PageIndex string `json:"pageIndex" validate:"required,numeric"`      // 分页：页码
-	PageSize  string `json:"pageSize" validate:"required,numeric,lte=3"` // 分页：页数
+	Status    string `json:"status" validate:"omitempty,oneof=0 1" doc:"方案状态 1有效；0无效"`
+	Keyword   string `json:"keyword" validate:"omitempty,lte=64" doc:"搜索关键字 名称&ID"`
+	PageIndex string `json:"pageIndex" validate:"required,numrange=0~" doc:"分页：页码"`
+	PageSize  string `json:"pageSize" validate:"required,numrange=1~500" doc:"分页：页数"`
 }
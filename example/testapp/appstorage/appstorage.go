@@ -1,67 +1,37 @@
 package appstorage
 
 import (
-	"errors"
 	"github.com/mutou1225/go-frame/config"
-	es "github.com/mutou1225/go-frame/implements/elasticsearch"
+	"github.com/mutou1225/go-frame/frame/appengine"
 	"github.com/mutou1225/go-frame/implements/rabbitmq"
 	"github.com/mutou1225/go-frame/implements/storage"
-	"github.com/mutou1225/go-frame/logger"
 )
 
 var (
-	EvaluateToolsRMQ *rabbitmq.RabbitMQ
+	EvaluateToolsRMQ *appengine.RabbitMQComponent
 )
 
-func InitStorage() {
-	// 初始化Mysql
-	if err := storage.NewMysqlDB(storage.PriceMysql, config.GetDBHost(),
-		config.GetDBUser(), config.GetDBPassword(),
-		config.GetDBName(), config.GetDBPort(),
-		config.GetMysqlPoolMin(), config.GetMysqlPoolMax(),
-		config.GetMysqlIdleTime(), config.GetMysqlConnTime(), true); err != nil {
-		logger.PrintError("InitDB() error:%s", err.Error())
-	}
+// 注册存储组件，由框架按顺序初始化，退出时按相反顺序关闭
+func RegisterStorage() {
+	// Mysql
+	appengine.RegisterComponent(appengine.NewMysqlComponent("mysql-price", storage.PriceMysql,
+		config.GetDBHost(), config.GetDBUser(), config.GetDBPassword(),
+		config.GetDBName(), config.GetDBPort()), appengine.Optional())
 
-	// 连接Mgo
-	if err := storage.InitMongo(config.GetServerName(), config.GetMongoDBAddStr(),
-		config.GetMongoDBName(), config.GetMongoDBReplica(),
-		config.GetMongoDBUsert(), config.GetMongoDBPasswd(),
-		uint64(config.GetMgodbPoolMax()), uint64(config.GetMgodbPoolMin()),
-		int64(config.GetMgodbIdleTime()), config.GetMgodbConnTime()); err != nil {
-		logger.PrintError("InitMgoEx() error:%s", err.Error())
-	}
+	// Mgo
+	appengine.RegisterComponent(appengine.NewMongoComponent(), appengine.Optional())
 
 	// es
-	if err := es.InitEsClient(config.GetESHost()); err != nil {
-		logger.PrintPanic("InitEsClient() error:%s", err.Error())
-	}
+	appengine.RegisterComponent(appengine.NewEsComponent(config.GetESHost()))
 
-	if err := storage.NewRedisCon(); err != nil {
-		logger.PrintPanic("NewRedisCon() error:%s", err.Error())
-	}
+	// redis
+	appengine.RegisterComponent(appengine.NewRedisComponent())
 
-	if clientMQ, err := rabbitmq.NewRabbitMQ(
-		config.GetRabbitMQUser(),
-		config.GetRabbitMQPassword(),
-		config.GetRabbitMQHost(),
-		config.GetRabbitMQPort(),
-		config.GetRabbitMQEvaVhost()); err != nil {
-		logger.PrintError("rabbitmq.NewRabbitMQ() Err: %s", err.Error())
-	} else {
-		EvaluateToolsRMQ = clientMQ
-	}
+	// RabbitMQ
+	EvaluateToolsRMQ = appengine.NewRabbitMQComponent("rabbitmq-eva", config.GetRabbitMQEvaVhost())
+	appengine.RegisterComponent(EvaluateToolsRMQ, appengine.Optional())
 }
 
 func GetRabbitMQClient() (*rabbitmq.RabbitMQ, error) {
-	if EvaluateToolsRMQ == nil {
-		return nil, errors.New("MQ Client <nil>")
-	}
-	return EvaluateToolsRMQ, nil
-}
-
-func ExitStorage() {
-	storage.ExitDB()
-	storage.CloseMgo()
-	storage.CloseRedisCon()
+	return EvaluateToolsRMQ.Client()
 }
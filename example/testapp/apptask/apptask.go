@@ -1,21 +1,33 @@
 package apptask
 
 import (
+	"context"
 	"github.com/mutou1225/go-frame/frame/appengine/app"
 	"github.com/mutou1225/go-frame/implements/toolkit"
 	"github.com/mutou1225/go-frame/logger"
+	"time"
 )
 
 func funcTask() {
 	logger.PrintInfo("funcTask", toolkit.GetCurrentTime())
 }
 
+// ctx 在超时或锁丢失时取消
+func funcTaskCtx(ctx context.Context) error {
+	logger.PrintInfo("funcTaskCtx fence[%d] %s", app.FenceFromContext(ctx), toolkit.GetCurrentTime())
+	return ctx.Err()
+}
+
 // 注册定时任务
 func AppRegisterTasks() []app.CronTask {
 	var tasksList = make([]app.CronTask, 0)
 
 	//添加定时任务
-	tasksList = append(tasksList, app.CronTask{ "0 */5 * * * *", funcTask})
+	tasksList = append(tasksList, app.CronTask{Cron: "0 */5 * * * *", TaskFunc: funcTask})
+
+	// 多实例部署时只在一个实例执行，上次未结束时跳过
+	tasksList = append(tasksList, app.CronTask{Cron: "0 0 * * * *", Name: "TestApp.funcTaskCtx", Func: funcTaskCtx,
+		Timeout: 10 * time.Minute, Overlap: app.OverlapSkip, Lock: &app.TaskLock{}})
 
 	return tasksList
 }
@@ -0,0 +1,44 @@
+package apptask
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/frame/appengine"
+	"github.com/mutou1225/go-frame/implements/jobqueue"
+	"github.com/mutou1225/go-frame/logger"
+	"time"
+)
+
+const jobTypeNotify = "notify"
+
+// 延迟、重试任务队列
+var JobQueue *jobqueue.Queue
+
+type notifyJob struct {
+	OrderId string `json:"orderId"`
+}
+
+// 注册任务队列，需要在 RegisterStorage 之后（依赖 redis 组件）
+func RegisterJobQueue() {
+	JobQueue = appengine.NewJobQueue("TestApp", jobqueue.Workers(2), jobqueue.MaxRetries(3))
+	JobQueue.Register(jobTypeNotify, notifyHandler)
+	appengine.RegisterJobQueue(JobQueue, appengine.DependsOn("redis"))
+}
+
+// 30 分钟后通知，同一个订单只添加一次
+func EnqueueNotify(ctx context.Context, orderId string) error {
+	_, err := JobQueue.Enqueue(ctx, jobTypeNotify, notifyJob{OrderId: orderId},
+		jobqueue.Delay(30*time.Minute), jobqueue.Unique("notify:"+orderId, 0))
+	if err == jobqueue.ErrDuplicate {
+		return nil
+	}
+	return err
+}
+
+func notifyHandler(ctx context.Context, job *jobqueue.Job) error {
+	req := notifyJob{}
+	if err := job.Bind(&req); err != nil {
+		return err
+	}
+	logger.PrintInfo("notify order[%s] attempts[%d]", req.OrderId, job.Attempts)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/mutou1225/go-frame/example/testapp/appstorage"
 	"github.com/mutou1225/go-frame/example/testapp/apptask"
 	"github.com/mutou1225/go-frame/example/testapp/router"
+	"github.com/mutou1225/go-frame/example/testapp/service/model"
 	"github.com/mutou1225/go-frame/frame/appengine"
 )
 
@@ -19,21 +20,31 @@ const (
 )
 
 func main() {
-	//配置文件初始化
-	logName := AppName
-	configFile := "/huishoubao/config/tinyxml2/eva_pro_config.xml"
-	serverCfgFile := "/huishoubao/config/TestAppServer.xml"
+	cli := &appengine.Cli{
+		AppName:   AppName,
+		Version:   Version,
+		VersionEx: VersionEx,
+		Update:    Update,
+		Setup: func() {
+			// app 路由
+			appengine.InitAppframe(router.InitAppRouter, nil, apptask.AppRegisterTasks)
 
-	// 初始化系统框架
-	appengine.InitApplication(configFile, serverCfgFile, logName)
-	defer appengine.ExitApplication(appstorage.ExitStorage)
+			// 存储组件
+			appstorage.RegisterStorage()
 
-	// app 路由
-	appengine.InitAppframe(router.InitAppRouter, appstorage.InitStorage, apptask.AppRegisterTasks)
+			// 延迟、重试任务队列
+			apptask.RegisterJobQueue()
 
-	// 运行
-	appengine.RunApplication()
+			// 后台任务：MQ消费者
+			model.RegisterTestMQConsumer()
 
-	// 单独运行一些应用
-	// appengine.RunCustomProgram(model.TestMQFunc)
+			// 异步导出、批量导入
+			model.RegisterExporter()
+			model.RegisterImporter()
+		},
+	}
+
+	// 命令行：serve（默认，http服务和后台任务一起运行）、version、check-config、routes、cron list
+	// 配置文件默认为 /huishoubao/config/TestAppServer.xml，使用 -server-config 或 GOFRAME_SERVER_CONFIG 修改
+	cli.Execute()
 }
@@ -6,120 +6,110 @@ import (
 	"github.com/mutou1225/go-frame/example/testapp/appinterface"
 	"github.com/mutou1225/go-frame/example/testapp/service/model"
 	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/errcode"
 	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/logger"
-	"strconv"
 )
 
-func TestEsApi(c *gin.Context) {
-	var form protocol.SubsysReqBody
-	var formParam appinterface.TestEs
-	err := app.BindAndValid(c, &form, &formParam)
-	if err != nil {
-		app.JsonResponse(c, apperrors.INVALID_PARAMS, form.Head, nil, err.Error())
-		return
-	}
+// 处理函数通过 app.Handle 注册，参数解析、校验和响应由框架完成
 
+func TestEsApi(c *gin.Context, formParam *appinterface.TestEs) (interface{}, errcode.AppError) {
 	logger.PrintInfo("formParam: %+v", formParam)
 
-	result, total, err := model.TestEsModel(&formParam)
+	result, page, err := model.TestEsModel(formParam)
 	if err != nil {
-		app.JsonResponse(c, apperrors.GET_TEST_LIST_ERROR, form.Head, nil, err.Error())
-		return
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
-	reqData := appinterface.RespTest{}
-	reqData.TestList = result
-	reqData.Total.PageIndex = formParam.PageIndex
-	reqData.Total.PageSize  = formParam.PageSize
-	reqData.Total.Total = strconv.FormatInt(total, 10)
+	retData := appinterface.RespTest{}
+	retData.TestList = result
+	retData.Total = page.SubsysTotal()
 
-	app.JsonResponse(c, apperrors.SUCCESS, form.Head, nil)
+	return retData, apperrors.SUCCESS
 }
 
-func TestRedisGetApi(c *gin.Context) {
-	var form protocol.SubsysReqBody
-	var formParam appinterface.TestSet
-	err := app.BindAndValid(c, &form, &formParam)
+func TestRedisGetApi(c *gin.Context, formParam *appinterface.TestSet) (interface{}, errcode.AppError) {
+	logger.PrintInfo("formParam: %+v", formParam)
+
+	result, err := model.TestRedisGetModel(formParam)
 	if err != nil {
-		app.JsonResponse(c, apperrors.INVALID_PARAMS, form.Head, nil, err.Error())
-		return
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
+	return result, apperrors.SUCCESS
+}
+
+func TestRedisSetApi(c *gin.Context, formParam *appinterface.TestSet) (interface{}, errcode.AppError) {
 	logger.PrintInfo("formParam: %+v", formParam)
 
-	result, err := model.TestRedisGetModel(&formParam)
-	if err != nil {
-		app.JsonResponse(c, apperrors.GET_TEST_LIST_ERROR, form.Head, nil, err.Error())
-		return
+	if err := model.TestRedisSetModel(formParam); err != nil {
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
-	app.JsonResponse(c, apperrors.SUCCESS, form.Head, result)
+	return nil, apperrors.SUCCESS
 }
 
-func TestRedisSetApi(c *gin.Context) {
-	var form protocol.SubsysReqBody
-	var formParam appinterface.TestSet
-	err := app.BindAndValid(c, &form, &formParam)
+func TestMysqlGetApi(c *gin.Context, formParam *appinterface.Test) (interface{}, errcode.AppError) {
+	logger.PrintInfo("formParam: %+v", formParam)
+
+	result, page, err := model.TestMysqlGetModel(formParam)
 	if err != nil {
-		app.JsonResponse(c, apperrors.INVALID_PARAMS, form.Head, nil, err.Error())
-		return
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
+	retData := appinterface.RespTest{}
+	retData.Total = page.SubsysTotal()
+	retData.TestList = result
+
+	return retData, apperrors.SUCCESS
+}
+
+func TestMysqlExportApi(c *gin.Context, formParam *appinterface.TestExport) (interface{}, errcode.AppError) {
 	logger.PrintInfo("formParam: %+v", formParam)
 
-	if err := model.TestRedisSetModel(&formParam); err != nil {
-		app.JsonResponse(c, apperrors.GET_TEST_LIST_ERROR, form.Head, nil, err.Error())
-		return
+	progress, err := model.TestMysqlExportModel(formParam)
+	if err != nil {
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
-	app.JsonResponse(c, apperrors.SUCCESS, form.Head, nil)
+	return progress, apperrors.SUCCESS
 }
 
-func TestMysqlGetApi(c *gin.Context) {
-	var form protocol.SubsysReqBody
-	var formParam appinterface.Test
-	err := app.BindAndValid(c, &form, &formParam)
+func TestExportStatusApi(c *gin.Context, formParam *appinterface.TestExportStatus) (interface{}, errcode.AppError) {
+	progress, err := model.TestExportStatusModel(formParam)
 	if err != nil {
-		app.JsonResponse(c, apperrors.INVALID_PARAMS, form.Head, nil, err.Error())
-		return
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
-	logger.PrintInfo("formParam: %+v", formParam)
+	return progress, apperrors.SUCCESS
+}
 
-	result, total, err := model.TestMysqlGetModel(&formParam)
+// 上传文件（multipart 的 file 字段），不是 json 请求体，直接使用 gin 的处理函数
+func TestMysqlImportApi(c *gin.Context) {
+	progress, err := model.TestMysqlImportModel(c)
 	if err != nil {
-		app.JsonResponse(c, apperrors.GET_TEST_LIST_ERROR, form.Head, nil, err.Error())
+		app.Respond(c, apperrors.INVALID_PARAMS, protocol.SubsysHeader{}, nil, err.Error())
 		return
 	}
 
-	retData := appinterface.RespTest{}
-	retData.Total.Total = strconv.FormatInt(total, 10)
-	retData.Total.PageIndex = formParam.PageIndex
-	retData.Total.PageSize = formParam.PageSize
-	retData.TestList = result
-
-	app.JsonResponse(c, apperrors.SUCCESS, form.Head, retData)
+	app.Respond(c, apperrors.SUCCESS, protocol.SubsysHeader{}, progress)
 }
 
-func TestMysqlSetApi(c *gin.Context) {
-	var form protocol.SubsysReqBody
-	var formParam appinterface.TestInfo
-	err := app.BindAndValid(c, &form, &formParam)
+func TestImportStatusApi(c *gin.Context, formParam *appinterface.TestExportStatus) (interface{}, errcode.AppError) {
+	progress, err := model.TestImportStatusModel(formParam)
 	if err != nil {
-		app.JsonResponse(c, apperrors.INVALID_PARAMS, form.Head, nil, err.Error())
-		return
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
+	return progress, apperrors.SUCCESS
+}
+
+func TestMysqlSetApi(c *gin.Context, formParam *appinterface.TestInfo) (interface{}, errcode.AppError) {
 	logger.PrintInfo("formParam: %+v", formParam)
 
-	if err := model.TestMysqlSetModel(&formParam); err != nil {
-		app.JsonResponse(c, apperrors.GET_TEST_LIST_ERROR, form.Head, nil, err.Error())
-		return
+	if err := model.TestMysqlSetModel(formParam); err != nil {
+		return nil, errcode.CustomError(apperrors.GET_TEST_LIST_ERROR, err.Error())
 	}
 
-	app.JsonResponse(c, apperrors.SUCCESS, form.Head, nil)
+	return nil, apperrors.SUCCESS
 }
-
-
-
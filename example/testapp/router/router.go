@@ -2,16 +2,34 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/example/testapp/apperrors"
+	"github.com/mutou1225/go-frame/example/testapp/appinterface"
 	"github.com/mutou1225/go-frame/example/testapp/router/api"
+	"github.com/mutou1225/go-frame/example/testapp/service/model"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/export"
+	"github.com/mutou1225/go-frame/frame/importer"
 )
 
-// 初始化路由
+// 初始化路由，WithSummary、WithResponse 用于 OpenAPI 文档（监控服务 /admin/swagger）
 func InitAppRouter(r *gin.Engine) {
+	invalid := app.WithInvalidParams(apperrors.INVALID_PARAMS)
 	evaApiG := r.Group("/test")
 	{
-		evaApiG.POST("/test_redis_get", api.TestRedisGetApi)
-		evaApiG.POST("/test_redis_set", api.TestRedisSetApi)
-		evaApiG.POST("/test_es", api.TestEsApi)
-		evaApiG.POST("/test_mysql_get", api.TestMysqlGetApi)
+		app.POST(evaApiG, "/test_redis_get", api.TestRedisGetApi, invalid,
+			app.WithSummary("读取Redis"), app.WithResponse(appinterface.TestSet{}))
+		app.POST(evaApiG, "/test_redis_set", api.TestRedisSetApi, invalid, app.WithSummary("写入Redis"))
+		app.POST(evaApiG, "/test_es", api.TestEsApi, invalid,
+			app.WithSummary("ES查询列表"), app.WithResponse(appinterface.RespTest{}))
+		app.POST(evaApiG, "/test_mysql_get", api.TestMysqlGetApi, invalid,
+			app.WithSummary("MySQL查询列表"), app.WithResponse(appinterface.RespTest{}))
+		app.POST(evaApiG, "/test_mysql_export", api.TestMysqlExportApi, invalid,
+			app.WithSummary("MySQL异步导出"), app.WithResponse(export.Progress{}))
+		app.POST(evaApiG, "/test_export_status", api.TestExportStatusApi, invalid,
+			app.WithSummary("导出任务进度"), app.WithResponse(export.Progress{}))
+		evaApiG.GET("/test_export_progress", model.ProgressHub.SSE(model.ExportProgressTopics)...)
+		evaApiG.POST("/test_mysql_import", api.TestMysqlImportApi)
+		app.POST(evaApiG, "/test_import_status", api.TestImportStatusApi, invalid,
+			app.WithSummary("导入任务进度"), app.WithResponse(importer.Progress{}))
 	}
 }
@@ -1,42 +1,30 @@
 package dao
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
 	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/paging"
 	es "github.com/mutou1225/go-frame/implements/elasticsearch"
 	"github.com/mutou1225/go-frame/logger"
-	"fmt"
-	"io/ioutil"
 )
 
-type EsResq struct {
-	Hits struct {
-		Total int `json:"total"`
-		Hits  []struct {
-			StrId  string `json:"_id"`
-			Source struct {
-				ClassId     int    `json:"Fclass_id"`
-				ClassName   string `json:"Fclass_name"`
-				ProductId   int    `json:"Fproduct_id"`
-				ProductName string `json:"Fproduct_name"`
-				BrandId     int    `json:"Fbrand_id"`
-				BrandName   string `json:"Fbrand_name"`
-				PicId       string `json:"Fpic_id"`
-			} `json:"_source"`
-		} `json:"hits"`
-	} `json:"hits"`
+type EsProduct struct {
+	ClassId     int    `json:"Fclass_id"`
+	ClassName   string `json:"Fclass_name"`
+	ProductId   int    `json:"Fproduct_id"`
+	ProductName string `json:"Fproduct_name"`
+	BrandId     int    `json:"Fbrand_id"`
+	BrandName   string `json:"Fbrand_name"`
+	PicId       string `json:"Fpic_id"`
 }
 
-func GetInfoFromES(pageIndex, pageSize int) (*EsResq, error) {
+func GetInfoFromES(req paging.Request) ([]EsProduct, *paging.Page, error) {
 	esClient, err := es.GetEsClient(config.GetESHost())
 	if err != nil {
-		logger.PrintInfo("GetEsClient() Err: %s", err.Error())
+		logger.PrintError("GetEsClient() Err: %s", err.Error())
+		return nil, nil, err
 	}
 
-	var buf bytes.Buffer
 	query := map[string]interface{}{
 		"query": map[string]interface{}{
 			"bool": map[string]interface{}{
@@ -48,44 +36,16 @@ func GetInfoFromES(pageIndex, pageSize int) (*EsResq, error) {
 				},
 			},
 		},
+		"_source": []string{"Fproduct_id", "Fproduct_name", "Fbrand_id", "Fbrand_name", "Fclass_id", "Fclass_name", "Fpic_id"},
 	}
-	if err := json.NewEncoder(&buf).Encode(query); err != nil {
-		logger.PrintError("json.NewEncoder() Err: %s", err.Error())
-	}
-	logger.PrintInfo("buf: %s", buf)
 
-	// Perform the search request.
-	res, err := esClient.Search(
-		esClient.Search.WithContext(context.Background()),
-		esClient.Search.WithIndex("eva_platform_product"),
-		esClient.Search.WithFrom(pageIndex),
-		esClient.Search.WithSize(pageSize),
-		esClient.Search.WithSource("Fproduct_id", "Fproduct_name", "Fbrand_id", "Fbrand_name", "Fclass_id", "Fclass_name", "Fpic_id"),
-		esClient.Search.WithBody(&buf),
-		esClient.Search.WithTrackTotalHits(true),
-		esClient.Search.WithPretty(),
-	)
+	var list []EsProduct
+	page, err := paging.ES(context.Background(), esClient, "eva_platform_product", query, req, &list)
 	if err != nil {
 		logger.PrintError("es.Search() Err: %s", err.Error())
+		return nil, nil, err
 	}
-	defer res.Body.Close()
-
-	if res.StatusCode == 200 {
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			logger.PrintInfo("Err: %s", err.Error())
-			return nil, err
-		}
-
-		esResult := EsResq{}
-		if err = json.Unmarshal(body, &esResult); err != nil {
-			logger.PrintInfo("Err: %s", err.Error())
-			return nil, err
-		}
-		logger.PrintInfo("esResult: %+v", esResult)
-
-		return &esResult, nil
-	}
+	logger.PrintInfo("esResult: %+v", list)
 
-	return nil, errors.New(fmt.Sprintf("StatusCode: %d", res.StatusCode))
+	return list, page, nil
 }
@@ -3,6 +3,7 @@ package dao
 import (
 	"context"
 	"errors"
+	"github.com/mutou1225/go-frame/frame/paging"
 	"github.com/mutou1225/go-frame/implements/storage"
 	"github.com/mutou1225/go-frame/implements/toolkit"
 	"github.com/mutou1225/go-frame/logger"
@@ -10,9 +11,9 @@ import (
 )
 
 type TestSearch struct {
-	Id                  int
-	Keyword             string
-	PageIndex, PageSize int
+	Id      int
+	Keyword string
+	Page    paging.Request
 }
 
 type StPriceAdjPlan struct {
@@ -20,12 +21,12 @@ type StPriceAdjPlan struct {
 	Name string `bson:"Fname"`
 }
 
-func GetTestList(params TestSearch) ([]StPriceAdjPlan, int64, error) {
+func GetTestList(params TestSearch) ([]StPriceAdjPlan, *paging.Page, error) {
 	mgoColl := storage.MgoCollection{"base_price", "t_test"}
 	collection := mgoColl.GetMgoCollection()
 	if collection == nil {
 		logger.PrintError("get mongo session failed")
-		return nil, 0, errors.New("get mongo session failed")
+		return nil, nil, errors.New("get mongo session failed")
 	}
 
 	query := bson.M{}
@@ -51,34 +52,16 @@ func GetTestList(params TestSearch) ([]StPriceAdjPlan, int64, error) {
 	logger.PrintInfo("query: %+v", query)
 	logger.PrintInfo("field: %+v", field)
 
-	queryHandle := collection.Find(context.Background(), &query)
-
-	// 获取总数
-	total, err := queryHandle.Count()
-	if err != nil {
-		logger.PrintError("find monogo db failed:%s", err.Error())
-		return nil, 0, err
-	}
-	logger.PrintInfo("Mgo count: %d", total)
-
-	if total == 0 {
-		return []StPriceAdjPlan{}, 0, nil
-	}
-
-	// 查询信息
-	queryHandle.Select(field).Sort("-Fid")
-	if params.PageSize > 0 {
-		queryHandle.Skip(int64(params.PageIndex * params.PageSize)).Limit(int64(params.PageSize))
-	}
+	queryHandle := collection.Find(context.Background(), &query).Select(field).Sort("-Fid")
 
 	var result []StPriceAdjPlan
-	err = queryHandle.All(&result)
+	page, err := paging.Qmgo(queryHandle, params.Page, &result)
 	if err != nil {
 		logger.PrintError("find monogo db failed:%s", err.Error())
-		return nil, 0, err
+		return nil, nil, err
 	}
 
 	logger.PrintInfo("Mgo result: %+v", result)
 
-	return result, total, nil
+	return result, page, nil
 }
@@ -2,42 +2,54 @@ package dao
 
 import (
 	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/frame/paging"
 	"github.com/mutou1225/go-frame/implements/storage"
 	"github.com/mutou1225/go-frame/logger"
-	"fmt"
+	"gorm.io/gorm"
 )
 
 const TTProduct = "t_product"
 
+// export 标签为导出的列名
 type TProduct struct {
-	ProductId   int    `gorm:"primary_key;column:Fproduct_id"` // 产品ID
-	ProductName string `gorm:"column:Fproduct_name"`
-	ClassId     int    `gorm:"column:Fclass_id"`
-	BrandId     int    `gorm:"column:Fbrand_id"`
+	ProductId   int    `gorm:"primary_key;column:Fproduct_id" export:"产品ID"` // 产品ID
+	ProductName string `gorm:"column:Fproduct_name" export:"产品名称"`
+	ClassId     int    `gorm:"column:Fclass_id" export:"品类ID"`
+	BrandId     int    `gorm:"column:Fbrand_id" export:"品牌ID"`
 	PicId       string `gorm:"column:Fpic_id"`
 }
 
 type ProductSearch struct {
-	Id        int
-	Status    int
-	Keyword   string
-	PageIndex int
-	PageSize  int
+	Id      int
+	Status  int
+	Keyword string
+	Page    paging.Request
+}
+
+func GetInfoFromMysql(search *ProductSearch) ([]TProduct, *paging.Page, error) {
+	db, err := ProductQuery(search)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var retData []TProduct
+	page, err := paging.Gorm(db, search.Page, &retData)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return retData, page, nil
 }
 
-func GetInfoFromMysql(search *ProductSearch) ([]TProduct, int64, error) {
+// 按条件查询产品，用于分页和导出
+func ProductQuery(search *ProductSearch) (*gorm.DB, error) {
 	mysqlDB := storage.GetDBHandle(storage.PriceMysql)
 	if mysqlDB == nil {
 		logger.PrintError("get mysql handle failed")
-		return nil, 0, errors.New("get mysql handle failed")
+		return nil, errors.New("get mysql handle failed")
 	}
 
-	condition := make(map[string]interface{})
-	if search.Id > 0 {
-		condition["Fproduct_id"] = search.Id
-	}
-
-	var retData []TProduct
 	db := mysqlDB.Table(TTProduct).Select("Fproduct_id, Fproduct_name, Fclass_id, Fbrand_id, Fpic_id")
 
 	if search.Id > 0 {
@@ -48,21 +60,7 @@ func GetInfoFromMysql(search *ProductSearch) ([]TProduct, int64, error) {
 		db = db.Where("Fproduct_name LIKE ?", fmt.Sprintf("%%%s%%", search.Keyword))
 	}
 
-	var count int64
-	err := storage.GetDBError(db.Count(&count))
-	if err != nil {
-		return nil, 0, err
-	} else if count == 0 {
-		return retData, 0, nil
-	}
-
-	if search.PageSize > 0 {
-		db = db.Offset(search.PageSize*search.PageIndex).Limit(search.PageSize)
-	}
-
-	db = db.Scan(&retData)
-
-	return retData, count, storage.GetDBError(db)
+	return db, nil
 }
 
 func SetInfoFromMysql(info *TProduct) error {
@@ -3,31 +3,28 @@ package model
 import (
 	"github.com/mutou1225/go-frame/example/testapp/appinterface"
 	"github.com/mutou1225/go-frame/example/testapp/service/dao"
-	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/paging"
 	"strconv"
 )
 
-func TestEsModel(params *appinterface.TestEs) ([]appinterface.TestInfo, int64, error) {
-	pageIndex := app.FormatPageIndex(&params.PageIndex)
-	pageSize := app.FormatPageSize(&params.PageSize)
-
-	esResq, err := dao.GetInfoFromES(pageIndex, pageSize)
+func TestEsModel(params *appinterface.TestEs) ([]appinterface.TestInfo, *paging.Page, error) {
+	list, page, err := dao.GetInfoFromES(paging.Parse(params.PageIndex, params.PageSize))
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, err
 	}
 
 	retList := []appinterface.TestInfo{}
-	for _, info := range esResq.Hits.Hits {
+	for _, info := range list {
 		retList = append(retList, appinterface.TestInfo{
-			ClassId:     strconv.Itoa(info.Source.ClassId),
-			ClassName:   info.Source.ClassName,
-			ProductId:   strconv.Itoa(info.Source.ProductId),
-			ProductName: info.Source.ProductName,
-			BrandId:     strconv.Itoa(info.Source.BrandId),
-			BrandName:   info.Source.BrandName,
-			PicId:       info.Source.PicId,
+			ClassId:     strconv.Itoa(info.ClassId),
+			ClassName:   info.ClassName,
+			ProductId:   strconv.Itoa(info.ProductId),
+			ProductName: info.ProductName,
+			BrandId:     strconv.Itoa(info.BrandId),
+			BrandName:   info.BrandName,
+			PicId:       info.PicId,
 		})
 	}
 
-	return retList, int64(esResq.Hits.Total), nil
+	return retList, page, nil
 }
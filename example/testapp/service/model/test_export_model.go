@@ -0,0 +1,54 @@
+package model
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/example/testapp/appinterface"
+	"github.com/mutou1225/go-frame/example/testapp/service/dao"
+	"github.com/mutou1225/go-frame/frame/appengine"
+	"github.com/mutou1225/go-frame/frame/export"
+	"github.com/mutou1225/go-frame/frame/stream"
+	"github.com/mutou1225/go-frame/implements/toolkit"
+	"time"
+)
+
+// 导出进度的推送，topic 为 export:<id>
+var ProgressHub = stream.NewHub("progress")
+
+// 异步导出，文件保留 3 天，进度推送到 ProgressHub
+var Exporter = export.New(export.Workers(2), export.Retention(72*time.Hour),
+	export.OnProgress(func(p export.Progress) {
+		ProgressHub.Publish("export:"+p.Id, stream.Event{Event: p.Status, Data: p})
+	}))
+
+// 注册导出组件，需要在 RegisterStorage 之后（依赖 mysql 组件）
+func RegisterExporter() {
+	appengine.RegisterHub(ProgressHub)
+	appengine.RegisterExporter(Exporter, appengine.DependsOn("mysql-price"))
+}
+
+// SSE 订阅导出进度：GET /test/test_export_progress?id=xxx（签名参数见 middleware.StreamSignQuery）
+func ExportProgressTopics(c *gin.Context) []string {
+	return []string{"export:" + c.Query("id")}
+}
+
+// 添加导出任务，返回下载地址和进度页面
+func TestMysqlExportModel(params *appinterface.TestExport) (*export.Progress, error) {
+	db, err := dao.ProductQuery(&dao.ProductSearch{
+		Id:      toolkit.StrAtoi(params.Id),
+		Keyword: params.Keyword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return Exporter.Enqueue(export.Job{
+		Name:   "product",
+		Format: export.Format(params.Format),
+		Row:    dao.TProduct{},
+		Source: export.Gorm(db.Order("Fproduct_id")),
+	})
+}
+
+func TestExportStatusModel(params *appinterface.TestExportStatus) (*export.Progress, error) {
+	return Exporter.Get(params.Id)
+}
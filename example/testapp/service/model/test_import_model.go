@@ -0,0 +1,53 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/example/testapp/appinterface"
+	"github.com/mutou1225/go-frame/example/testapp/service/dao"
+	"github.com/mutou1225/go-frame/frame/appengine"
+	"github.com/mutou1225/go-frame/frame/importer"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"gorm.io/gorm"
+)
+
+// 批量导入，单个文件最多 5 万行
+var Importer = importer.New(importer.Workers(1), importer.MaxRows(50000))
+
+// 注册导入组件，需要在 RegisterStorage 之后（依赖 mysql 组件）
+func RegisterImporter() {
+	appengine.RegisterImporter(Importer, appengine.DependsOn("mysql-price"))
+}
+
+// 保存上传的文件并添加导入任务，返回进度页面
+func TestMysqlImportModel(c *gin.Context) (*importer.Progress, error) {
+	db := storage.GetDBHandle(storage.PriceMysql)
+	if db == nil {
+		return nil, errors.New("get mysql handle failed")
+	}
+
+	return Importer.EnqueueUpload(c, "file", importer.Job{
+		Name:      "product",
+		Row:       appinterface.ProductImportRow{},
+		BatchSize: 200,
+		DB:        db,
+		Handler:   importProducts,
+	})
+}
+
+func TestImportStatusModel(params *appinterface.TestExportStatus) (*importer.Progress, error) {
+	return Importer.Get(params.Id)
+}
+
+// 每批在一个事务中更新，出错时整批回滚，写入错误报告
+func importProducts(ctx context.Context, tx *gorm.DB, rows interface{}) error {
+	for _, row := range rows.([]appinterface.ProductImportRow) {
+		err := tx.Table(dao.TTProduct).Where("Fproduct_id = ?", row.ProductId).
+			Updates(map[string]interface{}{"Fproduct_name": row.ProductName, "Fpic_id": row.PicId}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package model
 import (
 	"encoding/json"
 	"github.com/mutou1225/go-frame/example/testapp/appstorage"
+	"github.com/mutou1225/go-frame/frame/appengine"
 	"github.com/mutou1225/go-frame/implements/rabbitmq"
 	"github.com/mutou1225/go-frame/logger"
+	"github.com/streadway/amqp"
 	"sync"
 )
 
@@ -31,6 +33,37 @@ func (r testMQ) RouterKey() string {
 	return ""
 }
 
+// 创建exchange、queue并绑定
+func testMQSetup(client *rabbitmq.RabbitMQ, channel *amqp.Channel) error {
+	if err := client.CreateExchange(channel, testExchangeName, rabbitmq.MQKindFanout); err != nil {
+		logger.PrintError("rabbitmq.CreateExchange() Err: %s", err.Error())
+		return err
+	}
+
+	if err := client.CreateQueue(channel, testQueueName); err != nil {
+		logger.PrintError("rabbitmq.CreateQueue() Err: %s", err.Error())
+		return err
+	}
+
+	if err := client.QueueBind(channel, testQueueName, "", testExchangeName); err != nil {
+		logger.PrintError("rabbitmq.QueueBind() Err: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 注册MQ消费者，和http服务一起运行，断开后自动重连
+func RegisterTestMQConsumer() {
+	appengine.RegisterMQConsumer(appengine.MQConsumer{
+		Name:       "test-mq-consumer",
+		Client:     appstorage.GetRabbitMQClient,
+		Receiver:   testMQ{},
+		ProcessCnt: 2,
+		Setup:      testMQSetup,
+	})
+}
+
+// 自定义程序方式运行
 func TestMQFunc(wg *sync.WaitGroup, endChan chan struct{}) {
 	defer wg.Done()
 
@@ -46,18 +79,7 @@ func TestMQFunc(wg *sync.WaitGroup, endChan chan struct{}) {
 		return
 	}
 
-	if err := client.CreateExchange(channel, testExchangeName, rabbitmq.MQKindFanout); err != nil {
-		logger.PrintError("rabbitmq.CreateExchange() Err: %s", err.Error())
-		return
-	}
-
-	if err := client.CreateQueue(channel, testQueueName); err != nil {
-		logger.PrintError("rabbitmq.CreateQueue() Err: %s", err.Error())
-		return
-	}
-
-	if err := client.QueueBind(channel, testQueueName, "", testExchangeName); err != nil {
-		logger.PrintError("rabbitmq.CreateExchange() Err: %s", err.Error())
+	if err := testMQSetup(client, channel); err != nil {
 		return
 	}
 
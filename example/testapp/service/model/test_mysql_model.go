@@ -3,24 +3,23 @@ package model
 import (
 	"github.com/mutou1225/go-frame/example/testapp/appinterface"
 	"github.com/mutou1225/go-frame/example/testapp/service/dao"
-	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/paging"
 	"github.com/mutou1225/go-frame/implements/toolkit"
 	"strconv"
 )
 
-func TestMysqlGetModel(params *appinterface.Test) ([]appinterface.TestInfo, int64, error) {
+func TestMysqlGetModel(params *appinterface.Test) ([]appinterface.TestInfo, *paging.Page, error) {
 
 	search := &dao.ProductSearch{
 		Id        : toolkit.StrAtoi(params.Id),
 		Status    : toolkit.StrAtoi(params.Status),
 		Keyword   : params.Keyword,
-		PageIndex : app.FormatPageIndex(&params.PageIndex),
-		PageSize  : app.FormatPageIndex(&params.PageSize),
+		Page      : paging.Parse(params.PageIndex, params.PageSize),
 	}
 
-	retData, total, err := dao.GetInfoFromMysql(search)
+	retData, page, err := dao.GetInfoFromMysql(search)
 	if err != nil {
-		return nil, 0, err
+		return nil, nil, err
 	}
 
 	testList := []appinterface.TestInfo{}
@@ -36,7 +35,7 @@ func TestMysqlGetModel(params *appinterface.Test) ([]appinterface.TestInfo, int6
 		})
 	}
 
-	return testList, total, nil
+	return testList, page, nil
 }
 
 func TestMysqlSetModel(params *appinterface.TestInfo) error {
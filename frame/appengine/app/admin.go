@@ -0,0 +1,364 @@
+package app
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/logger"
+	"html"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+  运维接口，只在监控服务提供
+  GET    /admin/buildinfo     版本信息和运行时间
+  GET    /admin/loglevel      当前的日志级别
+  POST   /admin/loglevel      修改日志级别：{"level":"debug","package":"包路径，为空时全局","minutes":30}，到期自动恢复
+  DELETE /admin/loglevel      恢复日志级别：?package=包路径，为空时全局
+  GET    /admin/maintenance   维护模式状态
+  POST   /admin/maintenance   开启或关闭维护模式：{"enable":true,"errCode":0,"errInfo":""}
+  GET    /admin/cron                  定时任务列表、状态和下次执行时间
+  GET    /admin/cron/:name            定时任务的执行历史
+  POST   /admin/cron/:name/pause      暂停定时任务（只对当前实例有效）
+  POST   /admin/cron/:name/resume     恢复定时任务
+  POST   /admin/cron/:name/trigger    手动触发定时任务，异步执行
+  GET    /admin/openapi.json  业务接口的 OpenAPI 文档：?protocol=1|15|2 未指定协议版本的路由使用的版本
+  GET    /admin/swagger       Swagger UI，静态文件从 SwaggerUIAssets 加载
+  修改类接口（POST、DELETE）需要请求头 X-Admin-Token 或 Authorization: Bearer 为服务配置的 AdminToken，没有配置时拒绝调用
+  维护模式下业务接口返回配置的错误（errCode > 服务配置的 Maintenance > ERROR_MAINTENANCE），健康检查和运维接口不受影响
+*/
+
+const (
+	// 请求上下文中保存日志、配置实例的 key
+	LoggerContextKey = "goframe.logger"
+	ConfigContextKey = "goframe.config"
+	// 请求上下文中保存业务路由的 key，值为 func() *gin.Engine，http 服务未启动时返回 nil
+	RouterContextKey = "goframe.router"
+
+	// 修改日志级别默认的恢复时间
+	defaultLevelMinutes = 30
+)
+
+// 版本信息
+type BuildInfo struct {
+	AppName   string `json:"appName"`
+	Version   string `json:"version"`
+	VersionEx string `json:"versionEx"`
+	Update    string `json:"update"`
+	GoVersion string `json:"goVersion"`
+	Module    string `json:"module,omitempty"`
+	Hostname  string `json:"hostname"`
+	Pid       int    `json:"pid"`
+	StartTime string `json:"startTime"`
+	Uptime    string `json:"uptime"`
+	UptimeSec int64  `json:"uptimeSeconds"`
+}
+
+var (
+	startTime      = time.Now()
+	buildInfo      BuildInfo
+	buildInfoMutex sync.RWMutex
+)
+
+// 设置应用的版本信息，appengine.Cli 启动时设置
+func SetBuildInfo(appName, version, versionEx, update string) {
+	buildInfoMutex.Lock()
+	defer buildInfoMutex.Unlock()
+
+	buildInfo.AppName = appName
+	buildInfo.Version = version
+	buildInfo.VersionEx = versionEx
+	buildInfo.Update = update
+}
+
+// 版本信息和运行时间
+func GetBuildInfo() BuildInfo {
+	buildInfoMutex.RLock()
+	info := buildInfo
+	buildInfoMutex.RUnlock()
+
+	info.GoVersion = runtime.Version()
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Module = bi.Main.Path + "@" + bi.Main.Version
+	}
+	info.Hostname, _ = os.Hostname()
+	info.Pid = os.Getpid()
+	info.StartTime = startTime.Format("2006-01-02 15:04:05")
+	uptime := time.Since(startTime)
+	info.Uptime = uptime.Truncate(time.Second).String()
+	info.UptimeSec = int64(uptime / time.Second)
+	return info
+}
+
+// 注册运维接口
+func registerAdminRoute(r *gin.Engine) {
+	admin := r.Group("/admin")
+	{
+		admin.GET("/buildinfo", BuildInfoApi)
+		admin.GET("/loglevel", GetLogLevelApi)
+		admin.POST("/loglevel", AdminAuth(), SetLogLevelApi)
+		admin.DELETE("/loglevel", AdminAuth(), ResetLogLevelApi)
+		admin.GET("/maintenance", GetMaintenanceApi)
+		admin.POST("/maintenance", AdminAuth(), SetMaintenanceApi)
+		admin.GET("/cron", CronTasksApi)
+		admin.GET("/cron/:name", CronTaskApi)
+		admin.POST("/cron/:name/pause", AdminAuth(), PauseCronTaskApi)
+		admin.POST("/cron/:name/resume", AdminAuth(), ResumeCronTaskApi)
+		admin.POST("/cron/:name/trigger", AdminAuth(), TriggerCronTaskApi)
+		admin.GET("/openapi.json", OpenAPIApi)
+		admin.GET("/swagger", SwaggerApi)
+	}
+}
+
+// 修改类运维接口的鉴权，令牌为服务配置的 AdminToken，没有配置时拒绝全部请求
+func AdminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := configFromContext(c).GetSerAdminToken()
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin token not configured"})
+			return
+		}
+
+		reqToken := c.GetHeader("X-Admin-Token")
+		if auth := c.GetHeader("Authorization"); reqToken == "" && strings.HasPrefix(auth, "Bearer ") {
+			reqToken = strings.TrimPrefix(auth, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) != 1 {
+			loggerFromContext(c).PrintError("admin auth failed path[%s] ip[%s]", c.Request.URL.Path, c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// 请求所属应用的日志实例，没有时使用默认实例
+func loggerFromContext(c *gin.Context) *logger.Logger {
+	if v, ok := c.Get(LoggerContextKey); ok {
+		if l, ok := v.(*logger.Logger); ok && l != nil {
+			return l
+		}
+	}
+	return logger.Default()
+}
+
+// 请求所属应用的配置实例，没有时使用默认实例
+func configFromContext(c *gin.Context) *config.Config {
+	if v, ok := c.Get(ConfigContextKey); ok {
+		if conf, ok := v.(*config.Config); ok && conf != nil {
+			return conf
+		}
+	}
+	return config.Default()
+}
+
+// 业务接口的维护模式状态
+func maintenanceState(c *gin.Context) (bool, errcode.AppError) {
+	return healthRegistry(c).Maintenance()
+}
+
+// 版本信息
+func BuildInfoApi(c *gin.Context) {
+	c.JSON(http.StatusOK, GetBuildInfo())
+}
+
+// 当前的日志级别
+func GetLogLevelApi(c *gin.Context) {
+	c.JSON(http.StatusOK, loggerFromContext(c).Levels())
+}
+
+type logLevelReq struct {
+	Level   string `json:"level" binding:"required"`
+	Package string `json:"package"`
+	Minutes int    `json:"minutes"`
+}
+
+// 修改日志级别，minutes 分钟后恢复，默认 30 分钟
+func SetLogLevelApi(c *gin.Context) {
+	req := logLevelReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Minutes <= 0 {
+		req.Minutes = defaultLevelMinutes
+	}
+
+	l := loggerFromContext(c)
+	if err := l.SetPackageLevel(req.Package, req.Level, time.Duration(req.Minutes)*time.Minute); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	l.PrintInfo("SetLogLevel package[%s] level[%s] minutes[%d]", req.Package, req.Level, req.Minutes)
+	log.Printf("SetLogLevel package[%s] level[%s] minutes[%d]", req.Package, req.Level, req.Minutes)
+	c.JSON(http.StatusOK, l.Levels())
+}
+
+// 恢复为配置文件的日志级别
+func ResetLogLevelApi(c *gin.Context) {
+	pkg := c.Query("package")
+	l := loggerFromContext(c)
+	if err := l.ResetLevel(pkg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	l.PrintInfo("ResetLogLevel package[%s]", pkg)
+	log.Printf("ResetLogLevel package[%s]", pkg)
+	c.JSON(http.StatusOK, l.Levels())
+}
+
+type maintenanceReq struct {
+	Enable  bool   `json:"enable"`
+	ErrCode int    `json:"errCode"`
+	ErrInfo string `json:"errInfo"`
+}
+
+type maintenanceRsp struct {
+	Maintenance bool   `json:"maintenance"`
+	ErrCode     int    `json:"errCode,omitempty"`
+	ErrInfo     string `json:"errInfo,omitempty"`
+}
+
+// 维护模式状态
+func GetMaintenanceApi(c *gin.Context) {
+	on, appErr := healthRegistry(c).Maintenance()
+	rsp := maintenanceRsp{Maintenance: on}
+	if on {
+		rsp.ErrCode, rsp.ErrInfo = appErr.ErrorCode, appErr.ErrorInfo
+	}
+	c.JSON(http.StatusOK, rsp)
+}
+
+// 开启或关闭维护模式
+func SetMaintenanceApi(c *gin.Context) {
+	req := maintenanceReq{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	appErr := maintenanceError(configFromContext(c))
+	if req.ErrCode != 0 {
+		appErr = errcode.AppError{ErrorCode: req.ErrCode, ErrorInfo: req.ErrInfo}
+	}
+	healthRegistry(c).SetMaintenance(req.Enable, appErr)
+
+	loggerFromContext(c).PrintInfo("SetMaintenance enable[%t] err[%d %s]", req.Enable, appErr.ErrorCode, appErr.ErrorInfo)
+	log.Printf("SetMaintenance enable[%t] err[%d %s]", req.Enable, appErr.ErrorCode, appErr.ErrorInfo)
+	GetMaintenanceApi(c)
+}
+
+// 服务配置的维护模式错误，没有配置时使用 ERROR_MAINTENANCE
+func maintenanceError(conf *config.Config) errcode.AppError {
+	if mc := conf.GetMaintenanceConfig(); mc.ErrorCode != 0 {
+		return errcode.AppError{ErrorCode: mc.ErrorCode, ErrorInfo: mc.ErrorInfo}
+	}
+	return errcode.ERROR_MAINTENANCE
+}
+
+// 定时任务列表
+func CronTasksApi(c *gin.Context) {
+	c.JSON(http.StatusOK, CronTasks())
+}
+
+// 定时任务的状态和执行历史
+func CronTaskApi(c *gin.Context) {
+	status, err := GetCronTask(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// 暂停定时任务
+func PauseCronTaskApi(c *gin.Context) {
+	cronTaskAction(c, "PauseCronTask", PauseCronTask)
+}
+
+// 恢复定时任务
+func ResumeCronTaskApi(c *gin.Context) {
+	cronTaskAction(c, "ResumeCronTask", ResumeCronTask)
+}
+
+// 手动触发定时任务
+func TriggerCronTaskApi(c *gin.Context) {
+	cronTaskAction(c, "TriggerCronTask", TriggerCronTask)
+}
+
+func cronTaskAction(c *gin.Context, action string, f func(name string) error) {
+	name := c.Param("name")
+	if err := f(name); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	loggerFromContext(c).PrintInfo("%s name[%s]", action, name)
+	log.Printf("%s name[%s]", action, name)
+	CronTaskApi(c)
+}
+
+// 业务接口的 OpenAPI 文档
+func OpenAPIApi(c *gin.Context) {
+	var router *gin.Engine
+	if v, ok := c.Get(RouterContextKey); ok {
+		if f, ok := v.(func() *gin.Engine); ok && f != nil {
+			router = f()
+		}
+	}
+	if router == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "http server not started"})
+		return
+	}
+
+	opts := OpenAPIOptions{}
+	if v := c.Query("protocol"); v != "" {
+		pType, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid protocol: " + v})
+			return
+		}
+		opts.Protocol = protocol.ProtocolType(pType)
+	}
+	c.JSON(http.StatusOK, OpenAPI(router, opts))
+}
+
+// Swagger UI 的静态文件地址，内网环境可以改为内部的镜像
+var SwaggerUIAssets = "https://unpkg.com/swagger-ui-dist@3"
+
+const swaggerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<link rel="stylesheet" href="%[2]s/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="%[2]s/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`
+
+// Swagger UI，读取同目录的 openapi.json
+func SwaggerApi(c *gin.Context) {
+	title := GetBuildInfo().AppName + " API"
+	c.Data(http.StatusOK, "text/html; charset=utf-8",
+		[]byte(fmt.Sprintf(swaggerPage, html.EscapeString(title), SwaggerUIAssets)))
+}
@@ -0,0 +1,89 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/health"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_Maintenance(t *testing.T) {
+	registry := health.NewRegistry()
+	registry.SetReady(true)
+	conf, err := config.NewFromData(nil, []byte(`<xml><Server><AdminToken>secret</AdminToken></Server></xml>`))
+	if err != nil {
+		t.Fatalf("NewFromData Err: %s", err.Error())
+	}
+	inject := func(c *gin.Context) {
+		c.Set(HealthContextKey, registry)
+		c.Set(ConfigContextKey, conf)
+		c.Next()
+	}
+
+	r := InitRouter(inject)
+	r.POST("/order/list", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	monitor := InitMonitorRouter(inject)
+
+	do := func(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Admin-Token", "secret")
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(monitor, http.MethodPost, "/admin/maintenance", `{"enable":true}`); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `"errCode":5010`) {
+		t.Fatalf("SetMaintenanceApi: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(r, http.MethodPost, "/order/list", `{}`); w.Code != http.StatusServiceUnavailable ||
+		!strings.Contains(w.Body.String(), `"_errCode":"5010"`) {
+		t.Errorf("business route in maintenance: %d %s", w.Code, w.Body.String())
+	}
+	if w := do(r, http.MethodGet, "/readyz", ""); w.Code != http.StatusOK {
+		t.Errorf("readyz in maintenance: %d", w.Code)
+	}
+
+	do(monitor, http.MethodPost, "/admin/maintenance", `{"enable":false}`)
+	if on, _ := registry.Maintenance(); on {
+		t.Error("maintenance not disabled")
+	}
+
+	if w := do(monitor, http.MethodGet, "/admin/buildinfo", ""); w.Code != http.StatusOK ||
+		!strings.Contains(w.Body.String(), `"uptimeSeconds"`) {
+		t.Errorf("BuildInfoApi: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func Test_AdminAuth(t *testing.T) {
+	do := func(token, header string) int {
+		conf, _ := config.NewFromData(nil, []byte(`<xml><Server><AdminToken>`+token+`</AdminToken></Server></xml>`))
+		monitor := InitMonitorRouter(func(c *gin.Context) {
+			c.Set(ConfigContextKey, conf)
+			c.Next()
+		})
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/admin/cron/none/trigger", nil)
+		if header != "" {
+			req.Header.Set("Authorization", "Bearer "+header)
+		}
+		monitor.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 没有配置令牌时拒绝
+	if code := do("", ""); code != http.StatusForbidden {
+		t.Errorf("no token configured: %d", code)
+	}
+	if code := do("secret", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: %d", code)
+	}
+	if code := do("secret", "secret"); code != http.StatusNotFound {
+		t.Errorf("valid token: %d", code)
+	}
+}
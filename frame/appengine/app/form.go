@@ -1,18 +1,13 @@
 package app
 
 import (
-	"errors"
-	"github.com/go-playground/validator/v10"
+	"github.com/mutou1225/go-frame/frame/middleware"
 	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/implements/toolkit"
 	"github.com/mutou1225/go-frame/logger"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-
-	"github.com/go-playground/locales/zh"
-	ut "github.com/go-playground/universal-translator"
-	translations "github.com/go-playground/validator/v10/translations/zh"
 )
 
 const (
@@ -20,49 +15,29 @@ const (
 	DOWNLOAD_URL_PATH       = "http://baseprice.huishoubao.com.cn/download/"
 )
 
-//
+// 解析请求体，_param 解析到 formParam，校验失败时返回 ValidationErrors（ValidationData 转换为响应的 _data）
+// 请求体按 Content-Type 解析（middleware.RequestCodec），protobuf 的 formParam 需要是 proto.Message
 func BindAndValid(c *gin.Context, form *protocol.SubsysReqBody, formParam interface{}) error {
 	form.Param = formParam
-	if err := c.ShouldBindJSON(form); err != nil {
+	if codec := middleware.RequestCodec(c); codec != protocol.JSONCodec {
+		body, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		if err := codec.Unmarshal(body, form); err != nil {
+			return err
+		}
+	} else if err := c.ShouldBindJSON(form); err != nil {
 		return err
 	}
 
 	// 设置时间戳，用于计算监控的耗时
 	form.Head.Timestamp = strconv.FormatInt(toolkit.GetNanoTimeStamp(), 10)
 
-	validate := validator.New()
-	err := validate.Struct(form)
-	if err != nil {
-		//验证器注册翻译器
-		uni := ut.New(zh.New())
-		trans, _ := uni.GetTranslator("zh")
-		translations.RegisterDefaultTranslations(validate, trans)
-
-		for _, verr := range err.(validator.ValidationErrors) {
-			return errors.New(verr.Translate(trans))
-		}
-	}
-
-	return nil
-}
-
-func ValidatorStruct(s interface{}) error {
-	validate := validator.New()
-	err := validate.Struct(s)
-	if err != nil {
-		//验证器注册翻译器
-		uni := ut.New(zh.New())
-		trans, _ := uni.GetTranslator("zh")
-		translations.RegisterDefaultTranslations(validate, trans)
-
-		for _, verr := range err.(validator.ValidationErrors) {
-			return errors.New(verr.Translate(trans))
-		}
-	}
-
-	return nil
+	return Validate(c, form)
 }
 
+// 新代码使用 paging.Parse
 func FormatPageIndex(pageIndex *string) int {
 	if *pageIndex == "" {
 		logger.PrintInfo("pageIndex empty")
@@ -72,13 +47,14 @@ func FormatPageIndex(pageIndex *string) int {
 
 	i, e := strconv.Atoi(*pageIndex)
 	if e != nil {
-		logger.PrintInfo("FormatPageIndex() Error! index: %s, err: %s", pageIndex, e.Error())
+		logger.PrintInfo("FormatPageIndex() Error! index: %s, err: %s", *pageIndex, e.Error())
 		*pageIndex = "0"
 		return 0
 	}
 	return i
 }
 
+// 新代码使用 paging.Parse
 func FormatPageSize(pageSize *string) int {
 	if *pageSize == "" {
 		logger.PrintInfo("pageSize empty")
@@ -88,7 +64,7 @@ func FormatPageSize(pageSize *string) int {
 
 	i, e := strconv.Atoi(*pageSize)
 	if e != nil {
-		logger.PrintInfo("FormatPageIndex() Error! index: %s, err: %s", pageSize, e.Error())
+		logger.PrintInfo("FormatPageIndex() Error! index: %s, err: %s", *pageSize, e.Error())
 		*pageSize = "10"
 		return 10
 	}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"fmt"
+	"github.com/mutou1225/go-frame/logger"
+	"net"
+	"os"
+	"sync"
+)
+
+/*
+  平滑重启（只支持 Linux 等类 Unix 系统，其他系统 Listen 直接监听，不能平滑重启）
+  1、父进程收到 SIGUSR2 后启动新的子进程，并把监听的 socket 传给子进程
+  2、子进程直接使用继承的 socket，启动完成后给父进程发送 SIGTERM
+  3、父进程按正常退出流程等待运行中的请求结束后退出
+  重启期间 socket 一直处于监听状态，新连接在子进程启动完成前排队等待，不会被拒绝
+*/
+
+const (
+	// 继承的监听地址，按 fd 顺序，逗号分隔
+	envGracefulListeners = "GOFRAME_GRACEFUL_LISTENERS"
+	// 父进程pid，子进程启动完成后通知父进程退出
+	envGracefulParent = "GOFRAME_GRACEFUL_PARENT"
+	// 继承的 fd 从3开始（0、1、2为标准输入输出）
+	inheritFdStart = 3
+)
+
+// 监听的 socket 属于进程，多个应用共用，按地址区分
+var (
+	listeners      = make(map[string]*net.TCPListener)
+	listenerAddrs  []string
+	inherited      map[string]*net.TCPListener
+	listenersMutex sync.Mutex
+	inheritOnce    sync.Once
+)
+
+// 监听tcp地址，平滑重启的子进程优先使用父进程传递的 socket
+func Listen(addr string) (net.Listener, error) {
+	inheritOnce.Do(loadInherited)
+
+	listenersMutex.Lock()
+	defer listenersMutex.Unlock()
+
+	if _, ok := listeners[addr]; ok {
+		return nil, fmt.Errorf("addr[%s] already listened", addr)
+	}
+
+	ln, ok := inherited[addr]
+	if ok {
+		delete(inherited, addr)
+		logger.PrintInfo("Listen addr[%s] inherited from parent", addr)
+	} else {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		ln = l.(*net.TCPListener)
+	}
+
+	listeners[addr] = ln
+	listenerAddrs = append(listenerAddrs, addr)
+	return ln, nil
+}
+
+// 是否为平滑重启启动的子进程
+func IsGracefulChild() bool {
+	return os.Getenv(envGracefulParent) != ""
+}
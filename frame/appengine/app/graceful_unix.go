@@ -0,0 +1,160 @@
+//go:build !windows
+// +build !windows
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/logger"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// 正在启动子进程
+var restarting int32
+
+// 解析父进程传递的 socket
+func loadInherited() {
+	loadInheritedFrom(os.Getenv(envGracefulListeners), inheritFdStart)
+}
+
+// value 为逗号分隔的监听地址，对应从 fdStart 开始的 fd
+func loadInheritedFrom(value string, fdStart int) {
+	inherited = make(map[string]*net.TCPListener)
+	if value == "" {
+		return
+	}
+
+	for i, addr := range strings.Split(value, ",") {
+		fd := uintptr(fdStart + i)
+		file := os.NewFile(fd, addr)
+		l, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			logger.PrintError("net.FileListener(%s) Err: %s", addr, err.Error())
+			continue
+		}
+		if tl, ok := l.(*net.TCPListener); ok {
+			inherited[addr] = tl
+		} else {
+			_ = l.Close()
+		}
+	}
+}
+
+// 启动子进程并传递全部监听的 socket，返回子进程pid
+// 同时只能有一个子进程在启动中
+func StartChildProcess() (int, error) {
+	if !atomic.CompareAndSwapInt32(&restarting, 0, 1) {
+		return 0, errors.New("graceful restart already in progress")
+	}
+
+	pid, err := startChildProcess()
+	if err != nil {
+		atomic.StoreInt32(&restarting, 0)
+	}
+	return pid, err
+}
+
+func startChildProcess() (int, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	listenersMutex.Lock()
+	files := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	addrs := make([]string, 0, len(listenerAddrs))
+	for _, addr := range listenerAddrs {
+		file, err := listeners[addr].File()
+		if err != nil {
+			listenersMutex.Unlock()
+			closeFiles(files[inheritFdStart:])
+			return 0, fmt.Errorf("listener[%s] File(): %s", addr, err.Error())
+		}
+		files = append(files, file)
+		addrs = append(addrs, addr)
+	}
+	listenersMutex.Unlock()
+	defer closeFiles(files[inheritFdStart:])
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, e := range os.Environ() {
+		if strings.HasPrefix(e, envGracefulListeners+"=") || strings.HasPrefix(e, envGracefulParent+"=") {
+			continue
+		}
+		env = append(env, e)
+	}
+	env = append(env,
+		fmt.Sprintf("%s=%s", envGracefulListeners, strings.Join(addrs, ",")),
+		fmt.Sprintf("%s=%d", envGracefulParent, os.Getpid()))
+
+	wd, _ := os.Getwd()
+	process, err := os.StartProcess(execPath, os.Args, &os.ProcAttr{
+		Dir:   wd,
+		Env:   env,
+		Files: files,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 子进程启动失败时允许再次重启
+	go func() {
+		state, err := process.Wait()
+		atomic.StoreInt32(&restarting, 0)
+		if err != nil {
+			logger.PrintError("child process[%d] Wait Err: %s", process.Pid, err.Error())
+		} else {
+			logger.PrintError("child process[%d] exit: %s", process.Pid, state.String())
+		}
+	}()
+
+	logger.PrintInfo("graceful restart, child process[%d] listeners%v", process.Pid, addrs)
+	log.Printf("graceful restart, child process[%d] listeners%v", process.Pid, addrs)
+	return process.Pid, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// 子进程启动完成，关闭未使用的继承 socket，并通知父进程退出
+// 需要在全部 Listen 之后调用
+func NotifyParentReady() {
+	inheritOnce.Do(loadInherited)
+
+	listenersMutex.Lock()
+	for addr, ln := range inherited {
+		logger.PrintInfo("inherited listener[%s] unused, close", addr)
+		_ = ln.Close()
+		delete(inherited, addr)
+	}
+	listenersMutex.Unlock()
+
+	value := os.Getenv(envGracefulParent)
+	if value == "" {
+		return
+	}
+	_ = os.Unsetenv(envGracefulParent)
+
+	ppid, err := strconv.Atoi(value)
+	if err != nil || ppid != os.Getppid() {
+		logger.PrintError("graceful parent[%s] is not ppid[%d], skip notify", value, os.Getppid())
+		return
+	}
+
+	if err := syscall.Kill(ppid, syscall.SIGTERM); err != nil {
+		logger.PrintError("notify parent[%d] Err: %s", ppid, err.Error())
+		return
+	}
+	logger.PrintInfo("graceful restart ready, notify parent[%d] exit", ppid)
+}
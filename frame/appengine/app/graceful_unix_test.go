@@ -0,0 +1,63 @@
+//go:build !windows
+// +build !windows
+
+package app
+
+import (
+	"net"
+	"sync"
+	"testing"
+)
+
+// 平滑重启的子进程：先 Listen 使用继承的 socket，再 NotifyParentReady 关闭未使用的 socket
+func Test_ListenInherited(t *testing.T) {
+	parent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := parent.Addr().String()
+	file, err := parent.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 父进程退出后只有继承的 fd
+	parent.Close()
+
+	listenersMutex.Lock()
+	listeners = make(map[string]*net.TCPListener)
+	listenerAddrs = nil
+	listenersMutex.Unlock()
+	inheritOnce = sync.Once{}
+	inheritOnce.Do(func() {
+		loadInheritedFrom(addr, int(file.Fd()))
+	})
+	if len(inherited) != 1 {
+		t.Fatalf("inherited: %v", inherited)
+	}
+
+	ln, err := Listen(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	NotifyParentReady()
+	if len(inherited) != 0 {
+		t.Errorf("inherited: %v", inherited)
+	}
+
+	// 继承的 socket 仍然可以接受连接
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.Close()
+
+	if _, err := Listen(addr); err == nil {
+		t.Error("listen twice")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build windows
+// +build windows
+
+package app
+
+import (
+	"errors"
+	"net"
+)
+
+// 不支持平滑重启，没有继承的 socket
+func loadInherited() {
+	inherited = make(map[string]*net.TCPListener)
+}
+
+func StartChildProcess() (int, error) {
+	return 0, errors.New("graceful restart not supported on windows")
+}
+
+func NotifyParentReady() {}
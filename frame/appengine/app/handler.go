@@ -0,0 +1,253 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/implements/opentracing"
+	"reflect"
+)
+
+/*
+  业务处理函数的适配，去掉解析参数、校验、响应的重复代码：
+  func(c *gin.Context, param *T) (resp interface{}, err errcode.AppError)
+  func(c *gin.Context, param *T) (resp interface{}, err error)
+  请求体的 _param 解析到 *T 并校验，失败时返回 INVALID_PARAMS（WithInvalidParams 修改），_data 为全部字段的错误
+  返回的 err 为 AppError 时按错误码响应；为其他 error 时返回 ERROR_SERVER_ERROR（WithErrorMapper 修改）
+  响应的协议版本由 WithProtocol 指定，不指定时按调用方、_version 协商（middleware.NegotiateProtocol），
+  错误码、接口名、处理函数写入 tracing
+  函数签名不对时在注册路由时 panic
+  注册的处理函数记录参数、响应类型，用于打印路由和生成 OpenAPI 文档
+*/
+
+var (
+	ginContextType = reflect.TypeOf((*gin.Context)(nil))
+	appErrorType   = reflect.TypeOf(errcode.AppError{})
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+
+	// Handle 返回的处理函数都是 typedHandler.serve 的方法值，代码地址相同
+	typedServePC = reflect.ValueOf((&typedHandler{}).serve).Pointer()
+)
+
+// 请求上下文中查找处理函数的 key，值为 **typedHandler，lookupTypedHandler 使用
+const typedHandlerContextKey = "goframe.typedHandler"
+
+// 适配参数
+type HandlerOption func(*typedHandler)
+
+// 响应的协议版本，不指定时协商；使用 POST 注册时中间件返回的错误也使用该版本（middleware.Protocol）
+func WithProtocol(pType protocol.ProtocolType) HandlerOption {
+	return func(h *typedHandler) {
+		h.protocol = pType
+	}
+}
+
+// 响应不压缩（middleware.Compress），使用 POST 注册时生效（middleware.WithoutCompress）
+func WithoutCompress() HandlerOption {
+	return func(h *typedHandler) {
+		h.noCompress = true
+	}
+}
+
+// GET 响应不计算 ETag（middleware.ETag），使用 POST 注册时生效（middleware.WithoutETag）
+func WithoutETag() HandlerOption {
+	return func(h *typedHandler) {
+		h.noETag = true
+	}
+}
+
+// 参数错误时返回的错误码，默认 errcode.INVALID_PARAMS
+func WithInvalidParams(err errcode.AppError) HandlerOption {
+	return func(h *typedHandler) {
+		h.invalidParams = err
+	}
+}
+
+// 处理函数返回的 error（非 AppError）转换为错误码，默认 ERROR_SERVER_ERROR 加错误信息
+func WithErrorMapper(f func(err error) errcode.AppError) HandlerOption {
+	return func(h *typedHandler) {
+		h.errorMapper = f
+	}
+}
+
+// 接口说明，用于 OpenAPI 文档，默认为处理函数名
+func WithSummary(summary string) HandlerOption {
+	return func(h *typedHandler) {
+		h.summary = summary
+	}
+}
+
+// 响应数据的类型，用于 OpenAPI 文档；处理函数返回 interface{} 时需要指定，如 WithResponse(appinterface.RespTest{})
+func WithResponse(v interface{}) HandlerOption {
+	return func(h *typedHandler) {
+		h.respType = reflect.TypeOf(v)
+	}
+}
+
+type typedHandler struct {
+	name          string
+	summary       string
+	fn            reflect.Value
+	paramType     reflect.Type          // T
+	respType      reflect.Type          // 响应数据的类型，未知时为 nil
+	protocol      protocol.ProtocolType // 0 时协商
+	noCompress    bool
+	noETag        bool
+	invalidParams errcode.AppError
+	errorMapper   func(err error) errcode.AppError
+	handlerFunc   gin.HandlerFunc
+}
+
+// 把业务处理函数转换为 gin.HandlerFunc
+// 路由选项（WithProtocol 对中间件的错误、WithoutCompress、WithoutETag）需要使用 POST 注册，或在路由中加入对应的中间件
+func Handle(f interface{}, opts ...HandlerOption) gin.HandlerFunc {
+	return newHandle(f, opts...).handlerFunc
+}
+
+// 注册 POST 路由，f 同 Handle，路由选项转换为路由中间件
+func POST(r gin.IRoutes, path string, f interface{}, opts ...HandlerOption) gin.IRoutes {
+	h := newHandle(f, opts...)
+	chain := make(gin.HandlersChain, 0, 4)
+	if h.protocol != 0 {
+		chain = append(chain, middleware.Protocol(h.protocol))
+	}
+	if h.noCompress {
+		chain = append(chain, middleware.WithoutCompress())
+	}
+	if h.noETag {
+		chain = append(chain, middleware.WithoutETag())
+	}
+	return r.POST(path, append(chain, h.handlerFunc)...)
+}
+
+func newHandle(f interface{}, opts ...HandlerOption) *typedHandler {
+	h, err := newTypedHandler(f)
+	if err != nil {
+		panic(fmt.Sprintf("app.Handle(%s): %s", FuncName(f), err.Error()))
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.protocol != 0 && !h.protocol.Valid() {
+		panic(fmt.Sprintf("app.Handle(%s): invalid protocol %d", FuncName(f), h.protocol))
+	}
+
+	h.handlerFunc = h.serve
+	return h
+}
+
+func newTypedHandler(f interface{}) (*typedHandler, error) {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, errors.New("handler must be a func")
+	}
+
+	t := v.Type()
+	if t.NumIn() != 2 || t.In(0) != ginContextType {
+		return nil, errors.New("handler must be func(*gin.Context, *T) (interface{}, errcode.AppError|error)")
+	}
+	if t.In(1).Kind() != reflect.Ptr || t.In(1).Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("param %s must be a pointer to struct", t.In(1))
+	}
+	if t.NumOut() != 2 || (t.Out(1) != appErrorType && t.Out(1) != errorType) {
+		return nil, errors.New("handler must return (interface{}, errcode.AppError|error)")
+	}
+
+	var respType reflect.Type
+	if t.Out(0).Kind() != reflect.Interface {
+		respType = t.Out(0)
+	}
+
+	return &typedHandler{
+		name:          FuncName(f),
+		respType:      respType,
+		fn:            v,
+		paramType:     t.In(1).Elem(),
+		invalidParams: errcode.INVALID_PARAMS,
+		errorMapper:   defaultErrorMapper,
+	}, nil
+}
+
+func (h *typedHandler) serve(c *gin.Context) {
+	if v, ok := c.Get(typedHandlerContextKey); ok {
+		if p, ok := v.(**typedHandler); ok {
+			*p = h
+			return
+		}
+	}
+
+	tracing := opentracing.FromContext(c)
+	tracing.SetTag("handler", h.name)
+
+	var form protocol.SubsysReqBody
+	param := reflect.New(h.paramType)
+	if err := BindAndValid(c, &form, param.Interface()); err != nil {
+		h.respond(c, h.invalidParams, form.Head, ValidationData(err), err.Error())
+		return
+	}
+	tracing.SetTag("interface", form.Head.Interface)
+	tracing.SetTag("caller", form.Head.CallServiceId)
+
+	out := h.fn.Call([]reflect.Value{reflect.ValueOf(c), param})
+	var resp interface{}
+	if !isNil(out[0]) {
+		resp = out[0].Interface()
+	}
+
+	appErr := errcode.SUCCESS
+	switch err := out[1].Interface().(type) {
+	case errcode.AppError:
+		if err.ErrorCode != errcode.RetCodeSuccess {
+			appErr = err
+		}
+	case error:
+		appErr = h.errorMapper(err)
+	}
+	h.respond(c, appErr, form.Head, resp)
+}
+
+// 按协议版本响应
+func (h *typedHandler) respond(c *gin.Context, err errcode.AppError, head protocol.SubsysHeader, data interface{}, errInfo ...string) {
+	if h.protocol != 0 {
+		writeResponse(c, h.protocol, err, head, data, errInfo...)
+		return
+	}
+	Respond(c, err, head, data, errInfo...)
+}
+
+// error 中有 AppError 时使用它的错误码，否则返回 ERROR_SERVER_ERROR
+func defaultErrorMapper(err error) errcode.AppError {
+	var appErr errcode.AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	var pErr *errcode.AppError
+	if errors.As(err, &pErr) && pErr != nil {
+		return *pErr
+	}
+	return errcode.CustomError(errcode.ERROR_SERVER_ERROR, err.Error())
+}
+
+// Handle 注册的处理函数，其他函数返回 nil
+// 按代码地址确认是 serve 后，使用只有 typedHandlerContextKey 的上下文调用，serve 写入自己后返回
+func lookupTypedHandler(f gin.HandlerFunc) *typedHandler {
+	if f == nil || reflect.ValueOf(f).Pointer() != typedServePC {
+		return nil
+	}
+	var h *typedHandler
+	c := &gin.Context{}
+	c.Set(typedHandlerContextKey, &h)
+	f(c)
+	return h
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testHandlerParam struct {
+	Name string `json:"name" validate:"required,lte=8"`
+}
+
+type testHandlerResp struct {
+	Hello string `json:"hello"`
+}
+
+func testHandler(c *gin.Context, param *testHandlerParam) (interface{}, errcode.AppError) {
+	if param.Name == "bad" {
+		return nil, errcode.ERROR_DATA_NOT_EXIST
+	}
+	return &testHandlerResp{Hello: param.Name}, errcode.SUCCESS
+}
+
+func testErrorHandler(c *gin.Context, param *testHandlerParam) (interface{}, error) {
+	if param.Name == "wrap" {
+		return nil, errcode.NewErrorByError(errcode.ERROR_SIGN)
+	}
+	return nil, errors.New("db down")
+}
+
+func Test_Handle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	POST(r, "/v2", testHandler)
+	POST(r, "/v1", testHandler, WithProtocol(protocol.ProtocolV1))
+	POST(r, "/err", testErrorHandler)
+
+	head := `"_head":{"_callerServiceId":"1","_groupNo":"1","_interface":"test","_invokeId":"1","_msgType":"request","_timestamps":"1","_version":"0.01"}`
+	tests := []struct {
+		path, param string
+		want        []string
+	}{
+		{"/v2", `{"name":"go"}`, []string{`"_errCode":"0"`, `"hello":"go"`, `"_msgType":"response"`}},
+		{"/v2", `{"name":"toolongname"}`, []string{`"_errCode":"400"`, `"_data":{"errors":[{"field":"_param.name","rule":"lte"`}},
+		{"/v2", `{"name":"bad"}`, []string{`"_errCode":"5001"`}},
+		{"/v1", `{"name":"go"}`, []string{`"_body"`, `"_retcode":"0"`}},
+		{"/err", `{"name":"x"}`, []string{`"_errCode":"500"`, `db down`}},
+		{"/err", `{"name":"wrap"}`, []string{`"_errCode":"5004"`}},
+	}
+	for _, v := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, v.path, strings.NewReader(`{`+head+`,"_param":`+v.param+`}`)))
+		for _, s := range v.want {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("%s %s: %s, want %s", v.path, v.param, w.Body.String(), s)
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Handle with bad signature want panic")
+		}
+	}()
+	Handle(func(c *gin.Context, name string) {})
+}
+
+func testPbHandler(c *gin.Context, param *wrapperspb.StringValue) (interface{}, error) {
+	return wrapperspb.String("hello " + param.Value), nil
+}
+
+func Test_HandleCodec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	POST(r, "/v2", testHandler)
+	POST(r, "/pb", testPbHandler)
+
+	head := protocol.SubsysHeader{CallServiceId: "1", GroupNo: "1", Interface: "test", InvokeId: "1", MsgType: "request", Timestamp: "1", Version: "0.01"}
+	do := func(path string, codec protocol.Codec, param interface{}) *httptest.ResponseRecorder {
+		body, err := codec.Marshal(protocol.SubsysReqBody{Head: head, Param: param})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", codec.ContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// msgpack 和 JSON 的字段、校验相同
+	var resp testHandlerResp
+	rsp := protocol.SubsysRspBody{Rsp: &protocol.SubsysCommonRsp{Data: &resp}}
+	w := do("/v2", protocol.MsgPackCodec, testHandlerParam{Name: "go"})
+	if err := protocol.MsgPackCodec.Unmarshal(w.Body.Bytes(), &rsp); err != nil || rsp.Rsp.RetCode != "0" || resp.Hello != "go" {
+		t.Errorf("msgpack: %v %+v %+v", err, rsp.Rsp, resp)
+	}
+	w = do("/v2", protocol.MsgPackCodec, testHandlerParam{Name: "toolongname"})
+	rsp = protocol.SubsysRspBody{}
+	if err := protocol.MsgPackCodec.Unmarshal(w.Body.Bytes(), &rsp); err != nil || rsp.Rsp.RetCode != "400" {
+		t.Errorf("msgpack invalid: %v %+v", err, rsp.Rsp)
+	}
+
+	// protobuf 的 _param、_data 为 message；参数不是 message 时返回参数错误
+	data := &wrapperspb.StringValue{}
+	rsp = protocol.SubsysRspBody{Rsp: &protocol.SubsysCommonRsp{Data: data}}
+	w = do("/pb", protocol.ProtobufCodec, wrapperspb.String("go"))
+	if err := protocol.ProtobufCodec.Unmarshal(w.Body.Bytes(), &rsp); err != nil || rsp.Rsp.RetCode != "0" || data.Value != "hello go" || rsp.Head.MsgType != "response" {
+		t.Errorf("protobuf: %v %+v %v", err, rsp.Rsp, data)
+	}
+	w = do("/v2", protocol.ProtobufCodec, nil)
+	rsp = protocol.SubsysRspBody{}
+	if err := protocol.ProtobufCodec.Unmarshal(w.Body.Bytes(), &rsp); err != nil || rsp.Rsp.RetCode != "400" {
+		t.Errorf("protobuf invalid: %v %+v", err, rsp.Rsp)
+	}
+}
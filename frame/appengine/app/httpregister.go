@@ -3,17 +3,15 @@ package app
 import (
 	"context"
 	"fmt"
-	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"github.com/mutou1225/go-frame/config"
 	"github.com/mutou1225/go-frame/frame/middleware"
-	"github.com/mutou1225/go-frame/frame/servermux"
 	"github.com/mutou1225/go-frame/logger"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"log"
+	"net"
 	"net/http"
-	_ "net/http/pprof"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"io"
@@ -34,44 +32,32 @@ func RegisterHttpRoute() *gin.Engine {
 }
 */
 
-// 初始化Router
-func InitRouter() *gin.Engine {
+// 初始化Router，middlewares 在全部路由之前执行（appengine 用于注入应用实例）
+func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 
 	// 初始化gin
-	gin.DefaultWriter = io.MultiWriter(os.Stdout, &AccessInfoLogger{})
-	gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, &AccessErrLogger{})
-
-	if config.IsTest() {
-		gin.SetMode(gin.DebugMode)
-	} else {
-		gin.SetMode(gin.ReleaseMode)
-	}
+	setGinMode()
 
 	r := gin.Default()
+	r.Use(middlewares...)
 
-	if config.IsTest() {
-		pprof.Register(r)
-	}
-
+	// metrics、expvar、pprof 等运维接口只在监控服务（MonitorPort）提供
 	//r.GET("/", app.IndexApi)
 	r.GET("/ping", PingApi)
-	r.GET("/stats", func(c *gin.Context) {
-		c.JSON(http.StatusOK, middleware.StatsReport())
-	})
+	r.GET("/healthz", HealthzApi)
+	r.GET("/readyz", ReadyzApi)
 
 	r.GET("/favicon.ico", func(c *gin.Context) {
 		c.Status(http.StatusOK)
 		//c.File("/etc/nginx/favicon.ico")
 	})
 
-	apiDebug := r.Group("/debug")
-	{
-		apiDebug.GET("/vars", servermux.ExpvarHandler)
-		apiDebug.GET("/metrics", servermux.MetricsHandler)
-		apiDebug.GET("/heartbeat", Heartbeat)
-	}
-
+	// 维护模式只影响之后注册的业务接口
+	r.Use(middleware.Maintenance(maintenanceState))
 	r.Use(middleware.RequestStats())
+	// 压缩在 ETag 之前，ETag 按未压缩的内容计算
+	r.Use(middleware.Compress())
+	r.Use(middleware.ETag())
 	//r.Use(middleware.InitContext())
 	r.Use(middleware.ThrowPanic())
 	r.Use(middleware.TimeoutMiddleware(3 * time.Minute))
@@ -81,23 +67,17 @@ func InitRouter() *gin.Engine {
 	return r
 }
 
-/*
-// 初始化Monitor
-func InitMonitor(port int, handler *http.ServeMux) {
-	if port != 0 {
-		go func() {
-			addr := "0.0.0.0:" + strconv.Itoa(port)
-			logger.PrintInfo("App run monitor server addr: %v", addr)
-			log.Printf("App run monitor server addr: %v", addr)
-			err := http.ListenAndServe(addr, handler)
-			if err != nil {
-				logger.PrintError("App run monitor server err: %v", err)
-				log.Printf("App run monitor server err: %v", err)
-			}
-		}()
+// gin 的运行模式和日志输出
+func setGinMode() {
+	gin.DefaultWriter = io.MultiWriter(os.Stdout, &AccessInfoLogger{})
+	gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, &AccessErrLogger{})
+
+	if config.IsTest() {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
 	}
 }
-*/
 
 type AccessInfoLogger struct{}
 
@@ -115,38 +95,79 @@ func (a *AccessErrLogger) Write(p []byte) (n int, err error) {
 	return 0, nil
 }
 
-func StartServer(router *gin.Engine, appPort int) {
+// 创建http服务，由 appengine 在退出时调用 server.Shutdown()
+// 根据服务配置的 TLS 开启 TLS（同时支持 h2）或 h2c
+func NewHttpServer(router *gin.Engine, appPort int, tlsConf config.TLSConfig) (*http.Server, error) {
 	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", appPort),
-		Handler: router,
+		Addr:        fmt.Sprintf(":%d", appPort),
+		Handler:     router,
+		ConnContext: connContext,
 	}
-	logger.PrintInfo("App run monitor server addr[%s]", server.Addr)
-
-	go QuitServer(server)
 
-	err := server.ListenAndServe()
-	if err != nil {
-		logger.PrintInfo("App Run Err: %s", err.Error())
-		log.Printf("App Run Err: %s", err.Error())
+	if TLSEnabled(tlsConf) {
+		tlsConfig, closeFunc, err := NewTLSConfig(tlsConf)
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = tlsConfig
+		server.RegisterOnShutdown(closeFunc)
+	} else if tlsConf.H2C == 1 {
+		server.Handler = h2c.NewHandler(router, &http2.Server{})
 	}
+
+	return server, nil
 }
 
-func QuitServer(server *http.Server) {
-	quitChan := make(chan os.Signal)
-	signal.Notify(quitChan, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+type connContextKey struct{}
 
-	sig := <-quitChan
-	logger.PrintInfo("got a signal: %v", sig)
-	signal.Stop(quitChan)
+// 请求上下文中保存连接，流式响应（SSE）用于设置写超时
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, c)
+}
 
-	now := time.Now()
-	cxt, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// HTTP/1.x 请求的连接，HTTP/2 的多个请求共用连接，返回 nil
+func RequestConn(r *http.Request) net.Conn {
+	if r.ProtoMajor != 1 {
+		return nil
+	}
+	conn, _ := r.Context().Value(connContextKey{}).(net.Conn)
+	return conn
+}
 
-	if err := server.Shutdown(cxt); err != nil {
-		logger.PrintInfo("server.Shutdown() Err: ", err.Error())
+// 监听http服务的地址，平滑重启的子进程使用父进程传递的 socket
+// 需要在 NotifyParentReady 之前调用，否则继承的 socket 会被关闭
+func ListenServer(server *http.Server) (net.Listener, error) {
+	listener, err := Listen(server.Addr)
+	if err != nil {
+		logger.PrintError("App Listen Err: %s", err.Error())
+		log.Printf("App Listen Err: %s", err.Error())
+		return nil, err
 	}
+	return listener, nil
+}
 
-	// 看看实际退出所耗费的时间
-	logger.PrintInfo("QuitServer: %v", time.Since(now))
+// 在已监听的 listener 上运行http服务，直到 server.Shutdown() 被调用
+func Serve(server *http.Server, listener net.Listener) {
+	logger.PrintInfo("App run server addr[%s] tls[%v]", server.Addr, server.TLSConfig != nil)
+
+	var err error
+	if server.TLSConfig != nil {
+		// 证书由 TLSConfig 提供
+		err = server.ServeTLS(listener, "", "")
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		logger.PrintInfo("App Run Err: %s", err.Error())
+		log.Printf("App Run Err: %s", err.Error())
+	}
+}
+
+// 监听并运行http服务，直到 server.Shutdown() 被调用
+func StartServer(server *http.Server) {
+	listener, err := ListenServer(server)
+	if err != nil {
+		return
+	}
+	Serve(server, listener)
 }
@@ -1,8 +1,45 @@
 package app
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/health"
+	"net/http"
+)
+
+// 请求上下文中保存健康检查注册表的 key
+const HealthContextKey = "goframe.health"
+
+// 请求所属应用的健康检查注册表，没有时使用默认注册表
+func healthRegistry(c *gin.Context) *health.Registry {
+	if v, ok := c.Get(HealthContextKey); ok {
+		if registry, ok := v.(*health.Registry); ok && registry != nil {
+			return registry
+		}
+	}
+	return health.Default()
+}
 
 // 心跳包
 func Heartbeat(c *gin.Context) {
-	return
+	HealthzApi(c)
+}
+
+// 存活检查：Critical 的依赖检查失败时返回 503
+func HealthzApi(c *gin.Context) {
+	report, ok := healthRegistry(c).Liveness(c.Request.Context())
+	if ok {
+		c.JSON(http.StatusOK, report)
+	} else {
+		c.JSON(http.StatusServiceUnavailable, report)
+	}
+}
+
+// 就绪检查：任意依赖检查失败、未启动完成或正在退出时返回 503
+func ReadyzApi(c *gin.Context) {
+	report, ok := healthRegistry(c).Readiness(c.Request.Context())
+	if ok {
+		c.JSON(http.StatusOK, report)
+	} else {
+		c.JSON(http.StatusServiceUnavailable, report)
+	}
 }
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-contrib/pprof"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/servermux"
+	"github.com/mutou1225/go-frame/logger"
+	"log"
+	"net/http"
+	"sync"
+)
+
+/*
+  监控服务：metrics、expvar、pprof、健康检查等运维接口
+  使用独立的端口（MonitorPort）和绑定地址（MonitorAddr），不经过业务中间件
+*/
+
+var (
+	monitorRoutes      = make([]func(r *gin.Engine), 0)
+	monitorRoutesMutex sync.Mutex
+)
+
+// 注册监控服务的路由，需要在监控服务启动前调用
+func RegisterMonitorRoute(f func(r *gin.Engine)) {
+	monitorRoutesMutex.Lock()
+	defer monitorRoutesMutex.Unlock()
+
+	monitorRoutes = append(monitorRoutes, f)
+}
+
+// 初始化监控服务的Router，middlewares 在全部路由之前执行
+func InitMonitorRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
+	setGinMode()
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(middlewares...)
+
+	// pprof 所有环境都开启，只对监控端口开放
+	pprof.Register(r)
+
+	r.GET("/healthz", HealthzApi)
+	r.GET("/readyz", ReadyzApi)
+	r.GET("/metrics", servermux.MetricsHandler)
+	r.GET("/stats", func(c *gin.Context) {
+		c.JSON(http.StatusOK, middleware.StatsReport())
+	})
+
+	// 日志级别、维护模式、版本信息
+	registerAdminRoute(r)
+
+	apiDebug := r.Group("/debug")
+	{
+		apiDebug.GET("/vars", servermux.ExpvarHandler)
+		apiDebug.GET("/metrics", servermux.MetricsHandler)
+		apiDebug.GET("/heartbeat", Heartbeat)
+	}
+
+	monitorRoutesMutex.Lock()
+	defer monitorRoutesMutex.Unlock()
+	for _, f := range monitorRoutes {
+		f(r)
+	}
+
+	return r
+}
+
+type MonitorServer struct {
+	server      *http.Server
+	middlewares []gin.HandlerFunc
+}
+
+// 监控服务默认绑定的地址，对外开放时配置 MonitorAddr 为 0.0.0.0
+const DefaultMonitorAddr = "127.0.0.1"
+
+// addr 为空时绑定 DefaultMonitorAddr
+func NewMonitorServer(addr string, port int, middlewares ...gin.HandlerFunc) *MonitorServer {
+	if addr == "" {
+		addr = DefaultMonitorAddr
+	}
+	return &MonitorServer{
+		server: &http.Server{
+			Addr: fmt.Sprintf("%s:%d", addr, port),
+		},
+		middlewares: middlewares,
+	}
+}
+
+// 启动监控服务，端口绑定失败时返回错误
+func (m *MonitorServer) Start() error {
+	m.server.Handler = InitMonitorRouter(m.middlewares...)
+
+	listener, err := Listen(m.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	logger.PrintInfo("App run monitor server addr[%s]", m.server.Addr)
+	log.Printf("App run monitor server addr[%s]", m.server.Addr)
+
+	go func() {
+		if err := m.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.PrintError("App run monitor server err: %v", err)
+			log.Printf("App run monitor server err: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// 退出监控服务，等待运行中的请求结束
+func (m *MonitorServer) Stop(ctx context.Context) error {
+	return m.server.Shutdown(ctx)
+}
@@ -0,0 +1,568 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  根据业务路由生成 OpenAPI 3 文档
+  app.Handle 注册的路由：请求体为 {"_head":SubsysHeader,"_param":T}，T 的字段按 json 标签命名，
+  validate 标签转换为约束：required、oneof、len、min/gte、max/lte、gt、lt、numeric、number、email、url、uuid，
+  以及自定义的 mobile、idcard、price、numrange；
+  字段说明使用 doc 标签，如 `json:"status" validate:"oneof=0 1" doc:"方案状态 1有效；0无效"`
+  响应按路由指定的协议版本（WithProtocol、middleware.Protocol）包装，没有指定时为 OpenAPIOptions.Protocol：
+  V2 _data{_errStr,_data,_errCode,_ret}，V15 _data{_data,_retcode,_ret,_retinfo}，V1 _body{_data,_retcode,_ret,_retinfo}
+  响应数据的类型为处理函数的返回类型，返回 interface{} 时使用 WithResponse
+  其他业务路由只有通用的请求、响应格式；框架的路由（ping、healthz 等）不输出
+*/
+
+const (
+	openAPIVersion = "3.0.3"
+	// 框架的处理函数，不输出到文档
+	frameworkPkgPrefix = "github.com/mutou1225/go-frame/frame/appengine/app."
+)
+
+// 生成文档的参数
+type OpenAPIOptions struct {
+	// 默认为应用名、版本号
+	Title       string
+	Version     string
+	Description string
+	// 没有指定协议版本的路由使用的版本（实际按调用方协商），默认 ProtocolV2
+	Protocol protocol.ProtocolType
+}
+
+// OpenAPI 文档
+type OpenAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Tags       []OpenAPITag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPITag struct {
+	Name string `json:"name"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas"`
+}
+
+type OpenAPIOperation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// JSON Schema，空的 Schema 表示任意类型
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Description          string                    `json:"description,omitempty"`
+	Enum                 []interface{}             `json:"enum,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	MinLength            *int64                    `json:"minLength,omitempty"`
+	MaxLength            *int64                    `json:"maxLength,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Maximum              *float64                  `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool                      `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool                      `json:"exclusiveMaximum,omitempty"`
+	MinItems             *int64                    `json:"minItems,omitempty"`
+	MaxItems             *int64                    `json:"maxItems,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	headerType     = reflect.TypeOf(protocol.SubsysHeader{})
+)
+
+// 生成业务路由的 OpenAPI 文档
+func OpenAPI(r *gin.Engine, opts OpenAPIOptions) *OpenAPIDoc {
+	info := GetBuildInfo()
+	if opts.Title == "" {
+		opts.Title = info.AppName
+	}
+	if opts.Title == "" {
+		opts.Title = "goframe"
+	}
+	if opts.Version == "" {
+		opts.Version = info.Version
+	}
+	if opts.Version == "" {
+		opts.Version = "1.0.0"
+	}
+	if opts.Protocol == 0 {
+		opts.Protocol = protocol.ProtocolV2
+	}
+
+	g := &openAPIGen{
+		doc: &OpenAPIDoc{
+			OpenAPI: openAPIVersion,
+			Info:    OpenAPIInfo{Title: opts.Title, Version: opts.Version, Description: opts.Description},
+			Paths:   make(map[string]map[string]*OpenAPIOperation),
+			Components: OpenAPIComponents{
+				Schemas: make(map[string]*OpenAPISchema),
+			},
+		},
+		names: make(map[reflect.Type]string),
+		types: make(map[string]reflect.Type),
+	}
+
+	chains := routeChains(r)
+	tags := make(map[string]bool)
+	for _, v := range r.Routes() {
+		h := lookupTypedHandler(v.HandlerFunc)
+		if h == nil && strings.HasPrefix(v.Handler, frameworkPkgPrefix) {
+			continue
+		}
+
+		op := g.operation(v, h, chainNames(chains[v.Method+" "+v.Path]), opts.Protocol)
+		p, params := openAPIPath(v.Path)
+		op.Parameters = params
+		if tag := pathTag(v.Path); tag != "" {
+			op.Tags = []string{tag}
+			tags[tag] = true
+		}
+
+		if g.doc.Paths[p] == nil {
+			g.doc.Paths[p] = make(map[string]*OpenAPIOperation)
+		}
+		g.doc.Paths[p][strings.ToLower(v.Method)] = op
+	}
+
+	for tag := range tags {
+		g.doc.Tags = append(g.doc.Tags, OpenAPITag{Name: tag})
+	}
+	sort.Slice(g.doc.Tags, func(i, j int) bool { return g.doc.Tags[i].Name < g.doc.Tags[j].Name })
+	return g.doc
+}
+
+type openAPIGen struct {
+	doc *OpenAPIDoc
+	// 结构体在 components 中的名字
+	names map[reflect.Type]string
+	types map[string]reflect.Type
+}
+
+// 一个路由的接口说明，chain 为处理链的函数名
+func (g *openAPIGen) operation(v gin.RouteInfo, h *typedHandler, chain []string, pType protocol.ProtocolType) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     shortFuncName(v.Handler),
+		Description: "handler: " + shortFuncName(v.Handler),
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	if h != nil && h.protocol != 0 {
+		pType = h.protocol
+	} else if p, ok := middleware.RouteProtocol(chain); ok {
+		pType = p
+	}
+
+	var param, data *OpenAPISchema
+	if h != nil {
+		op.Summary, op.Description = h.name, "handler: "+h.name
+		if h.summary != "" {
+			op.Summary = h.summary
+		}
+		param = g.schema(h.paramType)
+		if h.respType != nil {
+			data = g.schema(h.respType)
+		}
+	}
+
+	switch {
+	case h != nil:
+		op.RequestBody = jsonRequest(g.request(param))
+	case v.Method == "POST" || v.Method == "PUT" || v.Method == "PATCH":
+		op.RequestBody = jsonRequest(g.request(&OpenAPISchema{Type: "object"}))
+	}
+
+	if data == nil {
+		data = &OpenAPISchema{}
+	}
+	op.Responses["200"] = &OpenAPIResponse{
+		Description: "_ret 为 0 时成功，否则为错误码",
+		Content:     map[string]OpenAPIMediaType{"application/json": {Schema: g.response(data, pType)}},
+	}
+	return op
+}
+
+// 请求体 {"_head":SubsysHeader,"_param":T}
+func (g *openAPIGen) request(param *OpenAPISchema) *OpenAPISchema {
+	return &OpenAPISchema{
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"_head":  g.schema(headerType),
+			"_param": param,
+		},
+		Required: []string{"_head", "_param"},
+	}
+}
+
+// 按协议版本包装响应数据
+func (g *openAPIGen) response(data *OpenAPISchema, pType protocol.ProtocolType) *OpenAPISchema {
+	str := func() *OpenAPISchema { return &OpenAPISchema{Type: "string"} }
+
+	body, field := &OpenAPISchema{Type: "object"}, "_data"
+	switch pType {
+	case protocol.ProtocolV1, protocol.ProtocolV15:
+		body.Properties = map[string]*OpenAPISchema{"_data": data, "_retcode": str(), "_ret": str(), "_retinfo": str()}
+		body.Required = []string{"_data", "_retcode", "_ret", "_retinfo"}
+		if pType == protocol.ProtocolV1 {
+			field = "_body"
+		}
+	default:
+		body.Properties = map[string]*OpenAPISchema{"_errStr": str(), "_data": data, "_errCode": str(), "_ret": str()}
+		body.Required = []string{"_errStr", "_data", "_errCode", "_ret"}
+	}
+
+	return &OpenAPISchema{
+		Type: "object",
+		Properties: map[string]*OpenAPISchema{
+			"_head": g.schema(headerType),
+			field:   body,
+		},
+		Required: []string{"_head", field},
+	}
+}
+
+func jsonRequest(s *OpenAPISchema) *OpenAPIRequestBody {
+	return &OpenAPIRequestBody{
+		Required: true,
+		Content:  map[string]OpenAPIMediaType{"application/json": {Schema: s}},
+	}
+}
+
+// Go 类型转换为 Schema，有名字的结构体放到 components 中
+func (g *openAPIGen) schema(t reflect.Type) *OpenAPISchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &OpenAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		zero := float64(0)
+		return &OpenAPISchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+	// interface{} 等
+	return &OpenAPISchema{}
+}
+
+// 结构体放到 components，返回名字；先登记名字，结构体引用自己时不会递归
+func (g *openAPIGen) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := path.Base(t.PkgPath()) + "." + t.Name()
+	if _, ok := g.types[name]; ok {
+		// 不同包路径的同名包
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + t.Name()
+	}
+	g.names[t], g.types[name] = name, t
+	g.doc.Components.Schemas[name] = g.structSchema(t)
+	return name
+}
+
+func (g *openAPIGen) structSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	g.addFields(s, t)
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+// 字段规则和 encoding/json 一致：json 标签为 - 时忽略，没有名字的嵌入结构体展开
+func (g *openAPIGen) addFields(s *OpenAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.addFields(s, ft)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var fs *OpenAPISchema
+		if strings.Contains(opts, "string") && isScalarKind(ft.Kind()) {
+			fs = &OpenAPISchema{Type: "string"}
+		} else {
+			fs = g.schema(f.Type)
+		}
+		fs.Description = f.Tag.Get("doc")
+		if applyValidateTag(fs, ft, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// validate 标签转换为约束，返回是否必填
+func applyValidateTag(s *OpenAPISchema, t reflect.Type, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		if rule == "dive" {
+			// dive 之后的规则作用于元素
+			if s.Items != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+				elem := t.Elem()
+				for elem.Kind() == reflect.Ptr {
+					elem = elem.Elem()
+				}
+				applyValidateTag(s.Items, elem, strings.Join(rules[i+1:], ","))
+			}
+			break
+		}
+		if strings.Contains(rule, "|") {
+			continue
+		}
+
+		key, value := rule, ""
+		if idx := strings.Index(rule, "="); idx >= 0 {
+			key, value = rule[:idx], rule[idx+1:]
+		}
+		switch key {
+		case "required":
+			required = true
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				s.Enum = append(s.Enum, enumValue(s.Type, v))
+			}
+		case "len":
+			setBound(s, value, true, false)
+			setBound(s, value, false, false)
+		case "min", "gte":
+			setBound(s, value, true, false)
+		case "max", "lte":
+			setBound(s, value, false, false)
+		case "gt":
+			setBound(s, value, true, true)
+		case "lt":
+			setBound(s, value, false, true)
+		case "numeric":
+			s.Pattern = `^[-+]?[0-9]+(\.[0-9]+)?$`
+		case "number":
+			s.Pattern = `^[0-9]+$`
+		case "alpha":
+			s.Pattern = `^[a-zA-Z]+$`
+		case "alphanum":
+			s.Pattern = `^[a-zA-Z0-9]+$`
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid":
+			s.Format = "uuid"
+		case "mobile":
+			s.Pattern = mobileRegexp.String()
+		case "idcard":
+			s.Pattern = `^([0-9]{15}|[0-9]{17}[0-9Xx])$`
+		case "price":
+			if s.Type == "string" {
+				s.Pattern = priceRegexp.String()
+			}
+		case "numrange":
+			if min, max, ok := parseNumRange(value); ok {
+				if s.Type == "string" {
+					s.Pattern = `^[-+]?[0-9]+(\.[0-9]+)?$`
+					s.Description = strings.TrimSpace(s.Description + " 范围：" + value)
+				} else {
+					if !math.IsInf(min, 0) {
+						s.Minimum = &min
+					}
+					if !math.IsInf(max, 0) {
+						s.Maximum = &max
+					}
+				}
+			}
+		case "datetime":
+			s.Description = strings.TrimSpace(s.Description + " 格式：" + value)
+		}
+	}
+	return required
+}
+
+// 约束的含义和 validator 一致：字符串为长度，数组为元素个数，数字为值
+func setBound(s *OpenAPISchema, value string, lower, exclusive bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return
+	}
+
+	switch s.Type {
+	case "string", "array":
+		size := int64(n)
+		if exclusive && lower {
+			size++
+		} else if exclusive {
+			size--
+		}
+		switch {
+		case s.Type == "string" && lower:
+			s.MinLength = &size
+		case s.Type == "string":
+			s.MaxLength = &size
+		case lower:
+			s.MinItems = &size
+		default:
+			s.MaxItems = &size
+		}
+	case "integer", "number":
+		if lower {
+			s.Minimum, s.ExclusiveMinimum = &n, exclusive
+		} else {
+			s.Maximum, s.ExclusiveMaximum = &n, exclusive
+		}
+	}
+}
+
+// oneof 的值按字段类型输出
+func enumValue(typ, v string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// gin 的路径参数 :id、*path 转换为 {id}、{path}
+func openAPIPath(p string) (string, []OpenAPIParameter) {
+	var params []OpenAPIParameter
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, OpenAPIParameter{
+				Name:     seg[1:],
+				In:       "path",
+				Required: true,
+				Schema:   &OpenAPISchema{Type: "string"},
+			})
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+// 路径的第一段作为分组
+func pathTag(p string) string {
+	p = strings.TrimPrefix(p, "/")
+	if idx := strings.Index(p, "/"); idx >= 0 {
+		return p[:idx]
+	}
+	return ""
+}
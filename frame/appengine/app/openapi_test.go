@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testOpenAPIParam struct {
+	Status   string   `json:"status" validate:"omitempty,oneof=0 1" doc:"状态"`
+	Keyword  string   `json:"keyword" validate:"required,lte=64"`
+	Count    int      `json:"count" validate:"gte=1,lte=100"`
+	Ids      []string `json:"ids" validate:"max=10,dive,numeric"`
+	internal string
+}
+
+type testOpenAPIResp struct {
+	List []testOpenAPIResp `json:"list"`
+}
+
+func Test_OpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/ping", PingApi)
+	POST(r, "/test/v2", func(c *gin.Context, param *testOpenAPIParam) (*testOpenAPIResp, error) {
+		return nil, nil
+	}, WithSummary("测试"), WithProtocol(protocol.ProtocolV2))
+	POST(r, "/test/v1", testHandler, WithProtocol(protocol.ProtocolV1), WithResponse(testHandlerResp{}))
+	r.POST("/raw/:id", gin.WrapH(http.NotFoundHandler()))
+
+	doc := OpenAPI(r, OpenAPIOptions{Title: "test", Protocol: protocol.ProtocolV15})
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(data)
+
+	if _, ok := doc.Paths["/ping"]; ok {
+		t.Error("framework route /ping in doc")
+	}
+	for _, want := range []string{
+		`"summary":"测试"`,
+		`"status":{"type":"string","description":"状态","enum":["0","1"]}`,
+		`"keyword":{"type":"string","maxLength":64}`,
+		`"count":{"type":"integer","format":"int32","minimum":1,"maximum":100}`,
+		`"ids":{"type":"array","maxItems":10,"items":{"type":"string","pattern":`,
+		`"required":["keyword"]`,
+		`"$ref":"#/components/schemas/app.testOpenAPIResp"`,
+		`"$ref":"#/components/schemas/protocol.SubsysHeader"`,
+		`"/raw/{id}":{"post":{"tags":["raw"]`,
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("doc want %s", want)
+		}
+	}
+	if strings.Contains(s, "internal") {
+		t.Error("unexported field in doc")
+	}
+
+	// 响应按协议版本包装
+	rsp := func(p string) *OpenAPISchema {
+		return doc.Paths[p]["post"].Responses["200"].Content["application/json"].Schema
+	}
+	if body := rsp("/test/v2").Properties["_data"]; body == nil || body.Properties["_errCode"] == nil {
+		t.Errorf("/test/v2 response: %+v", rsp("/test/v2"))
+	}
+	if body := rsp("/test/v1").Properties["_body"]; body == nil || body.Properties["_data"].Ref == "" {
+		t.Errorf("/test/v1 response: %+v", rsp("/test/v1"))
+	}
+	if body := rsp("/raw/{id}").Properties["_data"]; body == nil || body.Properties["_retcode"] == nil {
+		t.Errorf("/raw response: %+v", rsp("/raw/{id}"))
+	}
+
+	// 路由打印业务处理函数名
+	for _, v := range Routes(r) {
+		if v.Path == "/test/v1" && v.Handler != "app.testHandler" {
+			t.Errorf("route handler %s", v.Handler)
+		}
+	}
+
+	// 监控服务的接口
+	m := gin.New()
+	m.Use(func(c *gin.Context) {
+		c.Set(RouterContextKey, func() *gin.Engine { return r })
+	})
+	m.GET("/admin/openapi.json", OpenAPIApi)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/openapi.json?protocol=1", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"openapi":"3.0.3"`) {
+		t.Errorf("OpenAPIApi: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/gin-gonic/gin"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/middleware"
 	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/implements/opentracing"
 	"github.com/mutou1225/go-frame/implements/toolkit"
@@ -14,47 +15,28 @@ import (
 	"time"
 )
 
+// 接口响应数据结构封装，协议版本按路由、调用方、_version 协商（middleware.NegotiateProtocol）
+func Respond(ctx *gin.Context, err errcode.AppError, rspHead protocol.SubsysHeader, data interface{}, errInfo ...string) {
+	writeResponse(ctx, middleware.NegotiateProtocol(ctx, &rspHead), err, rspHead, data, errInfo...)
+}
+
 // 接口响应数据结构封装
 func JsonResponse(ctx *gin.Context, err errcode.AppError, rspHead protocol.SubsysHeader, data interface{}, errInfo ...string) {
-	errStr := ""
-	if len(errInfo) > 0 {
-		errStr = " " + fmt.Sprint(errInfo)
-	}
-
-	// 设置Head参数
-	reqTimestamp := rspHead.Timestamp
-	rspHead.MsgType = "response"
-	rspHead.Timestamp = strconv.FormatInt(toolkit.GetTimeStamp(), 10)
-
-	// 组装响应报文和发送
-	dataInfo := protocol.SubsysCommonRsp{
-		RetMsg:  err.ErrorInfo + errStr,
-		Data:    data,
-		RetCode: strconv.Itoa(err.ErrorCode),
-		Ret:     strconv.Itoa(err.ErrorCode),
-	}
-	respDate := protocol.SubsysRspBody{Head: &rspHead, Rsp: &dataInfo}
-	ctx.JSON(http.StatusOK, respDate)
-
-	// 计算耗时，推送监控统计
-	reqTime, _ := strconv.ParseInt(reqTimestamp, 10, 64)
-	tconsum := float32(toolkit.GetNanoTimeStamp()-reqTime) / float32(time.Millisecond)
-
-	// 打印响应报文
-	var json = jsoniter.ConfigCompatibleWithStandardLibrary
-	respData, _ := json.Marshal(respDate)
-	logger.PrintInfo("%soutPacket %s[%.3fms]%s %s", logger.Red, logger.DarkGreen, tconsum, logger.Reset, respData)
-
-	tracing := opentracing.GetOpenTracing()
-	if err.ErrorCode != 0 {
-		tracing.SetTag("error", strconv.Itoa(err.ErrorCode))
-		tracing.SetTag("error.kind", err.ErrorInfo)
-	}
-	tracing.Dump()
+	writeResponse(ctx, protocol.ProtocolV2, err, rspHead, data, errInfo...)
 }
 
 // 接口响应数据结构封装(二层协议V1版本)
 func JsonResponseV1(ctx *gin.Context, err errcode.AppError, rspHead protocol.SubsysHeader, data interface{}, errInfo ...string) {
+	writeResponse(ctx, protocol.ProtocolV1, err, rspHead, data, errInfo...)
+}
+
+// 接口响应数据结构封装(二层协议V1.5版本)
+func JsonResponseV15(ctx *gin.Context, err errcode.AppError, rspHead protocol.SubsysHeader, data interface{}, errInfo ...string) {
+	writeResponse(ctx, protocol.ProtocolV15, err, rspHead, data, errInfo...)
+}
+
+// 按协议版本组装响应报文和发送
+func writeResponse(ctx *gin.Context, pType protocol.ProtocolType, err errcode.AppError, rspHead protocol.SubsysHeader, data interface{}, errInfo ...string) {
 	errStr := ""
 	if len(errInfo) > 0 {
 		errStr = " " + fmt.Sprint(errInfo)
@@ -65,26 +47,29 @@ func JsonResponseV1(ctx *gin.Context, err errcode.AppError, rspHead protocol.Sub
 	rspHead.MsgType = "response"
 	rspHead.Timestamp = strconv.FormatInt(toolkit.GetTimeStamp(), 10)
 
-	// 组装响应报文和发送
-	dataInfo := protocol.SubsysCommonRspV1{
-		RetMsg:  err.ErrorInfo + errStr,
-		Data:    data,
-		RetCode: strconv.Itoa(err.ErrorCode),
-		Ret:     strconv.Itoa(err.ErrorCode),
+	// GET 请求的 ETag 不包括时间戳，数据不变时返回 304；不同编码的 ETag 不同
+	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	if middleware.ETagActive(ctx) {
+		keyHead := rspHead
+		keyHead.Timestamp = ""
+		key, _ := json.Marshal(protocol.NewRspBody(pType, &keyHead, err.ErrorCode, err.ErrorInfo+errStr, data))
+		middleware.SetETagKey(ctx, append([]byte(middleware.ResponseCodec(ctx).Name()), key...))
 	}
-	respDate := protocol.SubsysRspBodyV1{Head: &rspHead, Rsp: &dataInfo}
-	ctx.JSON(http.StatusOK, respDate)
+
+	// 组装响应报文，按协商的编码发送（middleware.ResponseCodec）
+	respDate := protocol.NewRspBody(pType, &rspHead, err.ErrorCode, err.ErrorInfo+errStr, data)
+	middleware.Render(ctx, http.StatusOK, respDate)
 
 	// 计算耗时，推送监控统计
 	reqTime, _ := strconv.ParseInt(reqTimestamp, 10, 64)
 	tconsum := float32(toolkit.GetNanoTimeStamp()-reqTime) / float32(time.Millisecond)
 
 	// 打印响应报文
-	var json = jsoniter.ConfigCompatibleWithStandardLibrary
 	respData, _ := json.Marshal(respDate)
 	logger.PrintInfo("%soutPacket %s[%.3fms]%s %s", logger.Red, logger.DarkGreen, tconsum, logger.Reset, respData)
 
-	tracing := opentracing.GetOpenTracing()
+	tracing := opentracing.FromContext(ctx)
+	tracing.SetTag("protocol", pType.String())
 	if err.ErrorCode != 0 {
 		tracing.SetTag("error", strconv.Itoa(err.ErrorCode))
 		tracing.SetTag("error.kind", err.ErrorInfo)
@@ -100,7 +85,7 @@ func JsonResponseOther(ctx *gin.Context, httpCode int, err errcode.AppError, dat
 		"data": data,
 	})
 
-	opentracing.GetOpenTracing().Dump()
+	opentracing.FromContext(ctx).Dump()
 }
 
 // 接口响应数据结构封装
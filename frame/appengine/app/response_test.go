@@ -0,0 +1,93 @@
+package app
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func testPanicHandler(c *gin.Context, param *testHandlerParam) (interface{}, error) {
+	panic("boom")
+}
+
+func Test_Respond(t *testing.T) {
+	conf, err := config.NewFromData(nil, []byte(`<xml>
+<Caller><id>116006</id><key>k</key><protocol>15</protocol></Caller>
+<Protocol><Version><Value>0.02</Value><Protocol>1</Protocol></Version></Protocol>
+</xml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(ConfigContextKey, conf)
+	}, middleware.ThrowPanic())
+	POST(r, "/auto", testHandler)
+	POST(r, "/v15", testHandler, WithProtocol(protocol.ProtocolV15))
+	POST(r, "/panic", testPanicHandler, WithProtocol(protocol.ProtocolV1))
+	r.POST("/raw", func(c *gin.Context) {
+		Respond(c, errcode.SUCCESS, protocol.SubsysHeader{Version: c.Query("version")}, nil)
+	})
+
+	head := func(caller, version string) string {
+		return `{"_head":{"_callerServiceId":"` + caller + `","_groupNo":"1","_interface":"test","_invokeId":"1",` +
+			`"_msgType":"request","_timestamps":"1","_version":"` + version + `"},"_param":{"name":"go"}}`
+	}
+	tests := []struct {
+		path, body string
+		want       []string
+	}{
+		{"/auto", head("1", "0.01"), []string{`"_data":{"_errStr"`, `"_errCode":"0"`}},
+		{"/auto", head("1", "1.0"), []string{`"_body":{`, `"_retcode":"0"`}},
+		{"/auto", head("1", "0.02"), []string{`"_body":{`}},
+		{"/auto", head("116006", "1.0"), []string{`"_data":{"_data"`, `"_retcode":"0"`}},
+		{"/v15", head("1", "2.0"), []string{`"_data":{"_data"`, `"_retinfo":"SUCCESS"`}},
+		{"/panic", head("1", "2.0"), []string{`"_body":{`, `"_retcode":"500"`}},
+		{"/raw?version=v1.5", "{}", []string{`"_data":{"_data"`, `"_retcode":"0"`}},
+	}
+	for _, v := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, v.path, strings.NewReader(v.body)))
+		for _, s := range v.want {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("%s %s: %s, want %s", v.path, v.body, w.Body.String(), s)
+			}
+		}
+	}
+}
+
+func Test_RespondETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(middleware.Compress(), middleware.ETag())
+	r.GET("/list", func(c *gin.Context) {
+		JsonResponse(c, errcode.SUCCESS, protocol.SubsysHeader{}, []string{"a", "b"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/list", nil))
+	etag := w.Header().Get("ETag")
+	// 按不包括时间戳的内容计算，不是响应体的摘要
+	sum := sha1.Sum(w.Body.Bytes())
+	if etag == "" || etag == `W/"`+hex.EncodeToString(sum[:])+`"` {
+		t.Fatalf("etag: %s %s", etag, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/list", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("304: %d %s", w.Code, w.Body.String())
+	}
+}
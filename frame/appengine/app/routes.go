@@ -0,0 +1,133 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// 路由信息：处理函数和执行的中间件
+type RouteInfo struct {
+	Method      string
+	Path        string
+	Handler     string
+	Middlewares []string
+}
+
+// 获取注册的路由，按路径、方法排序
+func Routes(r *gin.Engine) []RouteInfo {
+	chains := routeChains(r)
+
+	routes := make([]RouteInfo, 0)
+	for _, v := range r.Routes() {
+		info := RouteInfo{
+			Method:  v.Method,
+			Path:    v.Path,
+			Handler: routeHandlerName(v),
+		}
+		if chain := chains[v.Method+" "+v.Path]; len(chain) > 1 {
+			for _, pc := range chain[:len(chain)-1] {
+				info.Middlewares = append(info.Middlewares, funcName(pc))
+			}
+		}
+		routes = append(routes, info)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// gin 的 Routes() 只有最后的处理函数，中间件通过反射读取路由树
+// 路由树的结构不符合时返回空，只是不打印中间件
+func routeChains(r *gin.Engine) map[string][]uintptr {
+	chains := make(map[string][]uintptr)
+
+	trees := reflect.ValueOf(r).Elem().FieldByName("trees")
+	if !trees.IsValid() || trees.Kind() != reflect.Slice {
+		return chains
+	}
+	for i := 0; i < trees.Len(); i++ {
+		method := trees.Index(i).FieldByName("method")
+		root := trees.Index(i).FieldByName("root")
+		if method.Kind() != reflect.String || root.Kind() != reflect.Ptr {
+			return chains
+		}
+		walkRouteTree(method.String(), "", root, chains)
+	}
+	return chains
+}
+
+// 路径的拼接和 gin 的 Routes() 一致
+func walkRouteTree(method, path string, n reflect.Value, chains map[string][]uintptr) {
+	if n.IsNil() {
+		return
+	}
+	n = n.Elem()
+
+	nodePath, handlers, children := n.FieldByName("path"), n.FieldByName("handlers"), n.FieldByName("children")
+	if nodePath.Kind() != reflect.String || handlers.Kind() != reflect.Slice || children.Kind() != reflect.Slice {
+		return
+	}
+
+	path += nodePath.String()
+	if handlers.Len() > 0 {
+		chain := make([]uintptr, handlers.Len())
+		for i := range chain {
+			chain[i] = handlers.Index(i).Pointer()
+		}
+		chains[method+" "+path] = chain
+	}
+	for i := 0; i < children.Len(); i++ {
+		walkRouteTree(method, path, children.Index(i), chains)
+	}
+}
+
+// 处理链的函数名（包含包路径），同 gin 的 HandlerNames
+func chainNames(chain []uintptr) []string {
+	names := make([]string, 0, len(chain))
+	for _, pc := range chain {
+		if f := runtime.FuncForPC(pc); f != nil {
+			names = append(names, f.Name())
+		}
+	}
+	return names
+}
+
+// Handle 注册的路由使用业务处理函数名
+func routeHandlerName(v gin.RouteInfo) string {
+	if h := lookupTypedHandler(v.HandlerFunc); h != nil {
+		return h.name
+	}
+	return shortFuncName(v.Handler)
+}
+
+// 函数名，不包含包路径
+func FuncName(f interface{}) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return "nil"
+	}
+	return funcName(v.Pointer())
+}
+
+func funcName(pc uintptr) string {
+	if f := runtime.FuncForPC(pc); f != nil {
+		return shortFuncName(f.Name())
+	}
+	return "unknown"
+}
+
+// 去掉包路径：github.com/gin-gonic/gin.LoggerWithConfig.func1 -> gin.LoggerWithConfig.func1
+func shortFuncName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -1,22 +1,182 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/implements/storage"
 	"github.com/mutou1225/go-frame/logger"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/robfig/cron/v3"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+/*
+  定时任务
+  每个任务有名字（默认为函数名），支持超时、重叠策略、panic 恢复和分布式锁（TaskLock）
+  记录最近 cronHistorySize 次执行的时间、耗时和错误，指标：
+  goframe_cron_runs_total{task,status}、goframe_cron_duration_seconds{task}、goframe_cron_running{task}
+  监控服务的 /admin/cron 查看、暂停、恢复、手动触发任务，暂停只对当前实例有效
+  服务配置 <Cron><Task> 按任务名覆盖定时参数或禁用任务，配置文件重新加载后 ReloadCronTask 添加、删除或修改定时
+  定时器、任务和配置是进程内唯一的，多个应用时只有 ClaimCron 成功的应用运行定时任务
+*/
+
 const (
 	Cron = "0 */10 * * * *"
+
+	// 保存的执行历史条数
+	cronHistorySize = 20
+
+	triggerCron   = "cron"
+	triggerManual = "manual"
 )
 
-var (
-	crontab *cron.Cron
+// 执行结果
+const (
+	CronStatusOK      = "ok"
+	CronStatusError   = "error"
+	CronStatusPanic   = "panic"
+	CronStatusTimeout = "timeout"
+	CronStatusSkipped = "skipped"
 )
 
+// 上次执行未结束时的策略
+type OverlapPolicy int
+
+const (
+	OverlapAllow OverlapPolicy = iota // 同时执行（默认）
+	OverlapSkip                       // 跳过本次
+	OverlapQueue                      // 等上次结束后执行
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	default:
+		return "allow"
+	}
+}
+
 type CronTask struct {
 	Cron     string // 定时参数的格式 Second | Minute | Hour | Dom | Month | Week
 	TaskFunc func()
+	// 任务名，默认为锁名或函数名，用于管理接口、指标和日志，不能重复
+	Name string
+	// 支持 ctx 的任务函数，设置后代替 TaskFunc；ctx 在超时、锁丢失时取消
+	Func func(ctx context.Context) error
+	// 超时时间，0 不限制；TaskFunc 不能取消，超时只记录结果
+	Timeout time.Duration
+	// 上次执行未结束时的策略
+	Overlap OverlapPolicy
+	// 分布式锁，设置后每次触发只在一个实例执行，nil 时每个实例都执行
+	Lock *TaskLock
+}
+
+// 任务名
+func (t CronTask) TaskName() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if t.Lock != nil && t.Lock.Name != "" {
+		return t.Lock.Name
+	}
+	if t.Func != nil {
+		return FuncName(t.Func)
+	}
+	return FuncName(t.TaskFunc)
+}
+
+// 一次执行的记录
+type CronRun struct {
+	Trigger   string  `json:"trigger"`
+	StartTime string  `json:"startTime"`
+	CostMs    float64 `json:"costMs"`
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	Fence     int64   `json:"fence,omitempty"`
+}
+
+// 任务状态
+type CronTaskStatus struct {
+	Name     string    `json:"name"`
+	Cron     string    `json:"cron"`    // 生效的定时参数
+	Enabled  bool      `json:"enabled"` // 是否已加入定时器，配置禁用或定时参数错误时为 false
+	Overlap  string    `json:"overlap"`
+	Timeout  string    `json:"timeout,omitempty"`
+	Lock     bool      `json:"lock"`
+	Paused   bool      `json:"paused"`
+	Running  int32     `json:"running"`
+	NextRun  string    `json:"nextRun,omitempty"`
+	LastRun  *CronRun  `json:"lastRun,omitempty"`
+	Runs     int64     `json:"runs"`
+	Failures int64     `json:"failures"`
+	History  []CronRun `json:"history,omitempty"`
+}
+
+type cronEntry struct {
+	name    string
+	task    CronTask
+	id      cron.EntryID // 0 表示未加入定时器
+	spec    string       // 生效的定时参数
+	paused  int32
+	running int32
+	// OverlapSkip：是否正在执行
+	busy int32
+	// OverlapQueue：依次执行
+	queue sync.Mutex
+
+	// 任务 ctx 的上级，停止定时器时取消
+	ctx context.Context
+
+	mutex    sync.Mutex
+	history  []CronRun
+	runs     int64
+	failures int64
+}
+
+var (
+	crontab     *cron.Cron
+	cronEntries = make(map[string]*cronEntry)
+	cronOrder   = make([]*cronEntry, 0)
+	cronMutex   sync.RWMutex
+	cronConf    = config.Default()
+	// 运行定时任务的应用，为空时没有应用占用
+	cronOwner string
+	// 取消全部任务的 ctx
+	cronCancel context.CancelFunc = func() {}
+	// 正在停止，不再接受手动触发
+	cronStopping bool
+	// 手动触发的执行，定时触发的由定时器等待
+	cronManual sync.WaitGroup
+	// 定时参数的解析，同 cron.WithSeconds()
+	cronParser     = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+	errNoSuchJob   = errors.New("cron task not found")
+	errCronStopped = errors.New("cron stopped")
+
+	cronRunsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_cron_runs_total",
+		Help: "Runs of cron tasks by status.",
+	}, []string{"task", "status"})
+	cronDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "goframe_cron_duration_seconds",
+		Help:    "Duration of cron task runs.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600},
+	}, []string{"task"})
+	cronRunning = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "goframe_cron_running",
+		Help: "Running cron task runs.",
+	}, []string{"task"})
+)
+
+func init() {
+	prometheus.MustRegister(cronRunsCounter, cronDuration, cronRunning)
 }
 
 // 开启定时任务
@@ -26,37 +186,436 @@ func StartCronTask(taskList []CronTask) error {
 		return nil
 	}
 
-	go func() {
-		// New crontab
-		crontab = cron.New(cron.WithSeconds(), cron.WithLogger(CronLog{}))
-		if crontab == nil {
-			logger.PrintError("cron.New() Fail!")
-			return
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	// New crontab
+	crontab = cron.New(cron.WithSeconds(), cron.WithLogger(CronLog{}))
+	cronEntries = make(map[string]*cronEntry)
+	cronOrder = make([]*cronEntry, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cronCancel, cronStopping = cancel, false
+
+	// 添加定时任务
+	confs := cronTaskConfigs()
+	for _, task := range taskList {
+		if task.Func == nil && task.TaskFunc == nil {
+			logger.PrintError("crontab task[%s] err[TaskFunc nil]", task.Cron)
+			continue
+		}
+
+		e := &cronEntry{name: task.TaskName(), task: task, ctx: ctx, history: make([]CronRun, 0, cronHistorySize)}
+		if _, ok := cronEntries[e.name]; ok {
+			logger.PrintError("crontab task[%s] err[duplicate name]", e.name)
+			continue
 		}
-		// 添加定时任务
-		for _, tFunc := range taskList {
-			if id, err := crontab.AddFunc(tFunc.Cron, tFunc.TaskFunc); err != nil {
-				logger.PrintError("crontab AddFunc[%d] err[%s]", id, err.Error())
+		cronEntries[e.name] = e
+		cronOrder = append(cronOrder, e)
+
+		tc, ok := confs[e.name]
+		e.schedule(tc, ok)
+		delete(confs, e.name)
+	}
+	for name := range confs {
+		logger.PrintError("crontab config task[%s] err[not registered]", name)
+	}
+
+	// 启动定时器
+	crontab.Start()
+	logger.PrintInfo("crontab Start ...")
+
+	return nil
+}
+
+// 设置定时任务使用的配置实例，默认为 config.Default()；应用使用 ClaimCron
+func SetCronConfig(conf *config.Config) {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+	cronConf = conf
+}
+
+// 应用占用定时任务，设置定时任务使用的配置实例和分布式锁使用的 Redis（为 nil 时不修改）
+// 已经被其他应用占用时返回错误，不修改配置和 Redis
+func ClaimCron(owner string, conf *config.Config, redis func() (*storage.RedisOpt, error)) error {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if cronOwner != "" && cronOwner != owner {
+		return fmt.Errorf("cron already owned by app[%s]", cronOwner)
+	}
+	cronOwner = owner
+	cronConf = conf
+	if redis != nil {
+		SetCronRedis(redis)
+	}
+	return nil
+}
+
+// 应用释放定时任务（定时器已经停止），之后其他应用可以占用
+func ReleaseCron(owner string) {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if owner != "" && cronOwner == owner {
+		cronOwner = ""
+	}
+}
+
+// 按服务配置重新设置定时任务：添加启用的、删除禁用的、修改定时参数变化的
+func ReloadCronTask() {
+	cronMutex.Lock()
+	defer cronMutex.Unlock()
+
+	if crontab == nil {
+		return
+	}
+
+	confs := cronTaskConfigs()
+	for _, e := range cronOrder {
+		tc, ok := confs[e.name]
+		e.schedule(tc, ok)
+		delete(confs, e.name)
+	}
+	for name := range confs {
+		logger.PrintError("crontab config task[%s] err[not registered]", name)
+	}
+	logger.PrintInfo("crontab Reload ...")
+}
+
+// 解析定时参数，格式 Second | Minute | Hour | Dom | Month | Week，或 @every 1h 等
+func ParseCron(spec string) (cron.Schedule, error) {
+	return cronParser.Parse(spec)
+}
+
+// 服务配置中的定时任务，调用方持有 cronMutex
+func cronTaskConfigs() map[string]config.CronTaskConfig {
+	confs := make(map[string]config.CronTaskConfig)
+	if cronConf == nil {
+		return confs
+	}
+	for _, v := range cronConf.GetCronConfig() {
+		confs[v.Name] = v
+	}
+	return confs
+}
+
+// 按配置加入、移出定时器或修改定时参数，ok 表示有配置；调用方持有 cronMutex
+// 配置的定时参数错误时使用代码中的
+func (e *cronEntry) schedule(tc config.CronTaskConfig, ok bool) {
+	spec, enabled := e.task.Cron, true
+	if ok {
+		enabled = tc.Enabled()
+		if tc.Cron != "" {
+			if _, err := ParseCron(tc.Cron); err != nil {
+				logger.PrintError("crontab config task[%s] cron[%s] err[%s]", e.name, tc.Cron, err.Error())
+			} else {
+				spec = tc.Cron
 			}
 		}
-		// 启动定时器
-		crontab.Start()
-		logger.PrintInfo("crontab Start ...")
-		select {}
+	}
+
+	if !enabled {
+		if e.id != 0 {
+			crontab.Remove(e.id)
+			e.id = 0
+			logger.PrintInfo("CronTask[%s] disabled", e.name)
+		}
+		e.spec = spec
+		return
+	}
+	if e.id != 0 && spec == e.spec {
+		return
+	}
+
+	sched, err := ParseCron(spec)
+	if err != nil {
+		logger.PrintError("crontab AddFunc[%s] cron[%s] err[%s]", e.name, spec, err.Error())
+		return
+	}
+	if e.id != 0 {
+		crontab.Remove(e.id)
+	}
+	c := crontab
+	e.id = crontab.Schedule(sched, cron.FuncJob(func() {
+		e.run(triggerCron, e.activation(c))
+	}))
+	e.spec = spec
+	logger.PrintInfo("CronTask[%s] cron[%s]", e.name, spec)
+}
+
+// 本次定时触发的时间（秒），所有实例相同，用于分布式锁
+// 定时器先启动任务再记录 Prev，Entry 在定时器的协程中返回快照，此时 Prev 已经是本次触发的时间
+func (e *cronEntry) activation(c *cron.Cron) int64 {
+	cronMutex.RLock()
+	id := e.id
+	cronMutex.RUnlock()
+
+	if prev := c.Entry(id).Prev; !prev.IsZero() {
+		return prev.Unix()
+	}
+	// 已经移出定时器
+	return time.Now().Round(time.Second).Unix()
+}
+
+// 停止定时任务，最多等待 2s
+func StopCronTask() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_ = StopCronTaskContext(ctx)
+}
+
+// 停止定时任务，等待执行中的任务（包括手动触发的）结束
+// ctx 结束时取消任务的 ctx 并返回 ctx.Err()，不再等待
+func StopCronTaskContext(ctx context.Context) error {
+	cronMutex.Lock()
+	c, cancel := crontab, cronCancel
+	cronStopping = true
+	cronMutex.Unlock()
+	defer cancel()
+
+	if c == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		<-c.Stop().Done()
+		cronManual.Wait()
+		close(done)
 	}()
 
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.PrintError("Cron stop err[%s], cancel running tasks", ctx.Err().Error())
+		return ctx.Err()
+	}
+	logger.PrintInfo("Cron was done")
 	return nil
 }
 
-func StopCronTask() {
-	if crontab != nil {
-		ctx := crontab.Stop()
-		select {
-		case <-ctx.Done():
-		case <-time.After(2 * time.Second):
+// 全部任务的状态，按注册顺序
+func CronTasks() []CronTaskStatus {
+	cronMutex.RLock()
+	defer cronMutex.RUnlock()
+
+	list := make([]CronTaskStatus, 0, len(cronOrder))
+	for _, e := range cronOrder {
+		list = append(list, e.status(false))
+	}
+	return list
+}
+
+// 任务的状态和执行历史
+func GetCronTask(name string) (CronTaskStatus, error) {
+	cronMutex.RLock()
+	defer cronMutex.RUnlock()
+
+	e, ok := cronEntries[name]
+	if !ok {
+		return CronTaskStatus{}, errNoSuchJob
+	}
+	return e.status(true), nil
+}
+
+// 暂停任务，定时触发时跳过，手动触发不受影响
+func PauseCronTask(name string) error {
+	e, err := getCronEntry(name)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&e.paused, 1)
+	logger.PrintInfo("CronTask[%s] paused", name)
+	return nil
+}
+
+// 恢复任务
+func ResumeCronTask(name string) error {
+	e, err := getCronEntry(name)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&e.paused, 0)
+	logger.PrintInfo("CronTask[%s] resumed", name)
+	return nil
+}
+
+// 手动触发任务，异步执行，遵守重叠策略和分布式锁
+func TriggerCronTask(name string) error {
+	cronMutex.RLock()
+	e, ok := cronEntries[name]
+	if !ok {
+		cronMutex.RUnlock()
+		return errNoSuchJob
+	}
+	if cronStopping {
+		cronMutex.RUnlock()
+		return errCronStopped
+	}
+	cronManual.Add(1)
+	cronMutex.RUnlock()
+
+	logger.PrintInfo("CronTask[%s] triggered", name)
+	go func() {
+		defer cronManual.Done()
+		e.run(triggerManual, 0)
+	}()
+	return nil
+}
+
+func getCronEntry(name string) (*cronEntry, error) {
+	cronMutex.RLock()
+	defer cronMutex.RUnlock()
+
+	if e, ok := cronEntries[name]; ok {
+		return e, nil
+	}
+	return nil, errNoSuchJob
+}
+
+// 执行一次任务，tick 为定时触发的时间（秒），用于分布式锁
+func (e *cronEntry) run(trigger string, tick int64) {
+	if trigger == triggerCron && atomic.LoadInt32(&e.paused) == 1 {
+		cronRunsCounter.WithLabelValues(e.name, CronStatusSkipped).Inc()
+		logger.PrintInfo("CronTask[%s] paused, skipped", e.name)
+		return
+	}
+
+	switch e.task.Overlap {
+	case OverlapSkip:
+		if !atomic.CompareAndSwapInt32(&e.busy, 0, 1) {
+			cronRunsCounter.WithLabelValues(e.name, CronStatusSkipped).Inc()
+			logger.PrintInfo("CronTask[%s] still running, skipped", e.name)
+			return
 		}
+		defer atomic.StoreInt32(&e.busy, 0)
+	case OverlapQueue:
+		e.queue.Lock()
+		defer e.queue.Unlock()
 	}
-	logger.PrintInfo("Cron was done")
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if e.task.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(e.ctx, e.task.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(e.ctx)
+	}
+	defer cancel()
+
+	record := CronRun{Trigger: trigger}
+	if e.task.Lock != nil {
+		lease := obtainTaskLock(e.name, *e.task.Lock, tick, cancel)
+		if lease == nil {
+			return
+		}
+		defer lease.release()
+		record.Fence = lease.lock.Fence()
+		ctx = context.WithValue(ctx, fenceContextKey{}, record.Fence)
+	}
+
+	atomic.AddInt32(&e.running, 1)
+	cronRunning.WithLabelValues(e.name).Inc()
+	start := time.Now()
+
+	err, panicked := e.call(ctx)
+	cost := time.Since(start)
+
+	cronRunning.WithLabelValues(e.name).Dec()
+	atomic.AddInt32(&e.running, -1)
+
+	record.StartTime = start.Format("2006-01-02 15:04:05")
+	record.CostMs = float64(cost) / float64(time.Millisecond)
+	switch {
+	case panicked:
+		record.Status = CronStatusPanic
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		record.Status = CronStatusTimeout
+	case err != nil:
+		record.Status = CronStatusError
+	case e.task.Timeout > 0 && cost > e.task.Timeout:
+		record.Status = CronStatusTimeout
+		err = fmt.Errorf("exceeded timeout %s", e.task.Timeout)
+	default:
+		record.Status = CronStatusOK
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	e.addHistory(record)
+
+	cronRunsCounter.WithLabelValues(e.name, record.Status).Inc()
+	cronDuration.WithLabelValues(e.name).Observe(cost.Seconds())
+	if record.Status == CronStatusOK {
+		logger.PrintInfo("CronTask[%s] %s ok, cost[%.3fms]", e.name, trigger, record.CostMs)
+	} else {
+		logger.PrintError("CronTask[%s] %s %s, cost[%.3fms] Err: %s", e.name, trigger, record.Status, record.CostMs, record.Error)
+	}
+}
+
+// 执行任务函数，panic 时返回错误
+func (e *cronEntry) call(ctx context.Context) (err error, panicked bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			err, panicked = fmt.Errorf("panic: %s", errcode.GetSystemPanic(p)), true
+		}
+	}()
+
+	if e.task.Func != nil {
+		return e.task.Func(ctx), false
+	}
+	e.task.TaskFunc()
+	return nil, false
+}
+
+func (e *cronEntry) addHistory(record CronRun) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.runs++
+	if record.Status != CronStatusOK {
+		e.failures++
+	}
+	if len(e.history) >= cronHistorySize {
+		e.history = e.history[1:]
+	}
+	e.history = append(e.history, record)
+}
+
+func (e *cronEntry) status(withHistory bool) CronTaskStatus {
+	s := CronTaskStatus{
+		Name:    e.name,
+		Cron:    e.spec,
+		Enabled: e.id != 0,
+		Overlap: e.task.Overlap.String(),
+		Lock:    e.task.Lock != nil,
+		Paused:  atomic.LoadInt32(&e.paused) == 1,
+		Running: atomic.LoadInt32(&e.running),
+	}
+	if e.task.Timeout > 0 {
+		s.Timeout = e.task.Timeout.String()
+	}
+	if crontab != nil && e.id != 0 {
+		if next := crontab.Entry(e.id).Next; !next.IsZero() {
+			s.NextRun = next.Format("2006-01-02 15:04:05")
+		}
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	s.Runs, s.Failures = e.runs, e.failures
+	if n := len(e.history); n > 0 {
+		last := e.history[n-1]
+		s.LastRun = &last
+	}
+	if withHistory {
+		// 最近的在前
+		s.History = make([]CronRun, 0, len(e.history))
+		for i := len(e.history) - 1; i >= 0; i-- {
+			s.History = append(s.History, e.history[i])
+		}
+	}
+	return s
 }
 
 type CronLog struct{}
@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"github.com/mutou1225/go-frame/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+  定时任务的分布式锁
+  每次触发先用 Redis 加锁，只有加锁成功的实例执行，同一次触发（按秒取整的触发时间）只执行一次
+  执行期间每 TTL/3 续期，续期失败超过 TTL 或锁被其他实例持有时认为锁丢失，取消 ctx
+  加锁结果打印日志，并计数到 goframe_cron_lock_total{task,result}：acquired、skipped、lost、error
+*/
+
+const (
+	cronLockPrefix = "goframe:cron:"
+	defaultLockTTL = 30 * time.Second
+)
+
+// 定时任务的分布式锁
+type TaskLock struct {
+	// 锁名，所有实例相同，不同任务不能重复；默认为任务名
+	Name string
+	// 锁的租约，默认 30s，执行期间自动续期
+	TTL time.Duration
+}
+
+type fenceContextKey struct{}
+
+var (
+	cronRedis      = storage.GetRedisCon
+	cronRedisMutex sync.RWMutex
+
+	cronLockCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_cron_lock_total",
+		Help: "Distributed lock results of cron tasks.",
+	}, []string{"task", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(cronLockCounter)
+}
+
+// 设置定时任务加锁使用的 Redis，默认使用 storage.GetRedisCon；应用使用 ClaimCron
+func SetCronRedis(f func() (*storage.RedisOpt, error)) {
+	cronRedisMutex.Lock()
+	defer cronRedisMutex.Unlock()
+	cronRedis = f
+}
+
+func getCronRedis() (*storage.RedisOpt, error) {
+	cronRedisMutex.RLock()
+	defer cronRedisMutex.RUnlock()
+	return cronRedis()
+}
+
+// 持有锁时的加锁序号（fence），每次加锁成功递增，没有加锁时返回 0
+func FenceFromContext(ctx context.Context) int64 {
+	if fence, ok := ctx.Value(fenceContextKey{}).(int64); ok {
+		return fence
+	}
+	return 0
+}
+
+// 任务持有的锁
+type taskLease struct {
+	name      string
+	lock      *storage.RedisLock
+	stop      chan struct{}
+	lost      chan struct{}
+	leaseDone chan struct{}
+}
+
+// 加锁，tick 为 0 时不限制同一次触发（手动触发）；加锁失败时返回 nil
+// 加锁成功后开始续期，锁丢失时调用 cancel
+func obtainTaskLock(name string, conf TaskLock, tick int64, cancel context.CancelFunc) *taskLease {
+	lockName := conf.Name
+	if lockName == "" {
+		lockName = name
+	}
+	ttl := conf.TTL
+	if ttl <= 0 {
+		ttl = defaultLockTTL
+	}
+
+	r, err := getCronRedis()
+	if err != nil {
+		cronLockCounter.WithLabelValues(name, "error").Inc()
+		logger.PrintError("CronTask[%s] lock Err: %s", name, err.Error())
+		return nil
+	}
+
+	lock, err := r.ObtainTickLock(context.Background(), cronLockPrefix+lockName, ttl, tick)
+	if err == storage.ErrLockNotObtained {
+		cronLockCounter.WithLabelValues(name, "skipped").Inc()
+		logger.PrintInfo("CronTask[%s] lock skipped, run by other instance", name)
+		return nil
+	} else if err != nil {
+		cronLockCounter.WithLabelValues(name, "error").Inc()
+		logger.PrintError("CronTask[%s] lock Err: %s", name, err.Error())
+		return nil
+	}
+	cronLockCounter.WithLabelValues(name, "acquired").Inc()
+	logger.PrintInfo("CronTask[%s] lock acquired, fence[%d]", name, lock.Fence())
+
+	l := &taskLease{
+		name:      name,
+		lock:      lock,
+		stop:      make(chan struct{}),
+		lost:      make(chan struct{}),
+		leaseDone: make(chan struct{}),
+	}
+	go func() {
+		defer close(l.leaseDone)
+		l.keep(ttl, cancel)
+	}()
+	return l
+}
+
+// 执行期间续期，锁丢失时关闭 lost 并取消任务的 ctx
+func (l *taskLease) keep(ttl time.Duration, cancel context.CancelFunc) {
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	lastRefresh := time.Now()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		err := l.lock.Refresh(context.Background())
+		if err == nil {
+			lastRefresh = time.Now()
+			continue
+		}
+		if err != storage.ErrLockLost && time.Since(lastRefresh) < ttl {
+			logger.PrintError("CronTask[%s] lock refresh Err: %s", l.name, err.Error())
+			continue
+		}
+
+		cronLockCounter.WithLabelValues(l.name, "lost").Inc()
+		logger.PrintError("CronTask[%s] lock lost, fence[%d]: %s", l.name, l.lock.Fence(), err.Error())
+		log.Printf("CronTask[%s] lock lost, fence[%d]: %s", l.name, l.lock.Fence(), err.Error())
+		close(l.lost)
+		cancel()
+		return
+	}
+}
+
+// 停止续期并释放锁，续期时已经丢失的不再释放
+func (l *taskLease) release() {
+	close(l.stop)
+	<-l.leaseDone
+
+	select {
+	case <-l.lost:
+		return
+	default:
+	}
+
+	if err := l.lock.Release(context.Background()); err == storage.ErrLockLost {
+		cronLockCounter.WithLabelValues(l.name, "lost").Inc()
+		logger.PrintError("CronTask[%s] lock lost before release, fence[%d]", l.name, l.lock.Fence())
+		log.Printf("CronTask[%s] lock lost before release, fence[%d]", l.name, l.lock.Fence())
+	} else if err != nil {
+		logger.PrintError("CronTask[%s] lock release Err: %s", l.name, err.Error())
+	}
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 定时任务加锁使用 miniredis
+func newTestCronRedis(t *testing.T) (*storage.RedisOpt, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	port, _ := strconv.Atoi(mr.Port())
+	s := storage.NewStorage(nil, nil, nil)
+	if err := s.NewRedisConByInfo(mr.Host(), "", port, 0, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.CloseRedisCon)
+	SetCronRedis(s.GetRedisCon)
+	t.Cleanup(func() { SetCronRedis(storage.GetRedisCon) })
+
+	r, err := s.GetRedisCon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, mr
+}
+
+func Test_CronTaskLock(t *testing.T) {
+	// Redis 不可用时不执行任务
+	SetCronRedis(func() (*storage.RedisOpt, error) {
+		return nil, errors.New("Redis Con <nil>")
+	})
+	defer SetCronRedis(storage.GetRedisCon)
+
+	before := testutil.ToFloat64(cronLockCounter.WithLabelValues("test", "error"))
+	run := false
+	e := &cronEntry{name: "test", ctx: context.Background(), task: CronTask{Cron: Cron, Lock: &TaskLock{}, Func: func(ctx context.Context) error {
+		run = true
+		return nil
+	}}}
+	e.run(triggerCron, 1)
+	if run {
+		t.Error("task run without lock")
+	}
+	if n := testutil.ToFloat64(cronLockCounter.WithLabelValues("test", "error")) - before; n != 1 {
+		t.Errorf("goframe_cron_lock_total{result=error}: %v", n)
+	}
+}
+
+func Test_ObtainTickLock(t *testing.T) {
+	r, _ := newTestCronRedis(t)
+	ctx := context.Background()
+
+	l1, err := r.ObtainTickLock(ctx, "tick", time.Minute, 100)
+	if err != nil {
+		t.Fatalf("ObtainTickLock Err: %s", err.Error())
+	}
+	// 持有期间其他实例不能加锁
+	if _, err := r.ObtainTickLock(ctx, "tick", time.Minute, 101); err != storage.ErrLockNotObtained {
+		t.Errorf("ObtainTickLock held: %v", err)
+	}
+	if err := l1.Release(ctx); err != nil {
+		t.Fatalf("Release Err: %s", err.Error())
+	}
+
+	// 同一次触发释放后也不能再次加锁
+	if _, err := r.ObtainTickLock(ctx, "tick", time.Minute, 100); err != storage.ErrLockNotObtained {
+		t.Errorf("ObtainTickLock same tick: %v", err)
+	}
+	l2, err := r.ObtainTickLock(ctx, "tick", time.Minute, 101)
+	if err != nil {
+		t.Fatalf("ObtainTickLock next tick Err: %s", err.Error())
+	}
+	if l2.Fence() <= l1.Fence() {
+		t.Errorf("fence %d after %d", l2.Fence(), l1.Fence())
+	}
+}
+
+func Test_RedisLockStaleToken(t *testing.T) {
+	r, mr := newTestCronRedis(t)
+	ctx := context.Background()
+
+	stale, err := r.ObtainLock(ctx, "stale", time.Second)
+	if err != nil {
+		t.Fatalf("ObtainLock Err: %s", err.Error())
+	}
+	// 过期后被其他实例获得
+	mr.FastForward(2 * time.Second)
+	holder, err := r.ObtainLock(ctx, "stale", time.Minute)
+	if err != nil {
+		t.Fatalf("ObtainLock expired Err: %s", err.Error())
+	}
+
+	if err := stale.Refresh(ctx); err != storage.ErrLockLost {
+		t.Errorf("Refresh stale: %v", err)
+	}
+	if err := stale.Release(ctx); err != storage.ErrLockLost {
+		t.Errorf("Release stale: %v", err)
+	}
+	// 新持有者不受影响
+	if err := holder.Refresh(ctx); err != nil {
+		t.Errorf("Refresh holder: %v", err)
+	}
+	if err := holder.Release(ctx); err != nil {
+		t.Errorf("Release holder: %v", err)
+	}
+}
+
+func Test_TaskLeaseLost(t *testing.T) {
+	_, mr := newTestCronRedis(t)
+
+	before := testutil.ToFloat64(cronLockCounter.WithLabelValues("lease", "lost"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lease := obtainTaskLock("lease", TaskLock{TTL: 30 * time.Millisecond}, 0, cancel)
+	if lease == nil {
+		t.Fatal("obtainTaskLock failed")
+	}
+
+	// 锁被删除后续期返回 ErrLockLost，取消任务的 ctx
+	mr.Del(cronLockPrefix + "lease")
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("task ctx not cancelled after lock lost")
+	}
+	lease.release()
+	if n := testutil.ToFloat64(cronLockCounter.WithLabelValues("lease", "lost")) - before; n != 1 {
+		t.Errorf("goframe_cron_lock_total{result=lost}: %v", n)
+	}
+}
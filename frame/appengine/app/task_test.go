@@ -0,0 +1,207 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/robfig/cron/v3"
+	"testing"
+	"time"
+)
+
+func Test_CronTask(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	tasks := []CronTask{
+		{Cron: "@every 1h", Name: "slow", Overlap: OverlapSkip, Func: func(ctx context.Context) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}},
+		{Cron: "@every 1h", Name: "panic", TaskFunc: func() { panic("test") }},
+		{Cron: "@every 1h", Name: "timeout", Timeout: 10 * time.Millisecond, Func: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{Cron: "@every 1h", Name: "error", Func: func(ctx context.Context) error { return errors.New("test") }},
+		{Cron: "@every 1h", Name: "error", TaskFunc: func() {}},
+		{Cron: "invalid", Name: "invalid", TaskFunc: func() {}},
+	}
+	if err := StartCronTask(tasks); err != nil {
+		t.Fatalf("StartCronTask Err: %s", err.Error())
+	}
+	defer StopCronTask()
+
+	if list := CronTasks(); len(list) != 5 || list[4].Enabled {
+		t.Fatalf("CronTasks: %+v", list)
+	}
+
+	// 上次未结束时跳过
+	slow, _ := getCronEntry("slow")
+	go slow.run(triggerCron, 0)
+	<-started
+	slow.run(triggerCron, 0)
+	close(release)
+	for i := 0; i < 100; i++ {
+		if s, _ := GetCronTask("slow"); s.Runs == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s, _ := GetCronTask("slow"); s.Runs != 1 || s.LastRun.Status != CronStatusOK || s.NextRun == "" {
+		t.Errorf("slow: %+v", s)
+	}
+
+	for name, status := range map[string]string{"panic": CronStatusPanic, "timeout": CronStatusTimeout, "error": CronStatusError} {
+		e, _ := getCronEntry(name)
+		e.run(triggerCron, 0)
+		s, _ := GetCronTask(name)
+		if s.Failures != 1 || len(s.History) != 1 || s.History[0].Status != status || s.History[0].Error == "" {
+			t.Errorf("%s: %+v", name, s)
+		}
+	}
+
+	// 暂停后定时触发跳过，手动触发执行
+	if err := PauseCronTask("error"); err != nil {
+		t.Fatalf("PauseCronTask Err: %s", err.Error())
+	}
+	e, _ := getCronEntry("error")
+	e.run(triggerCron, 0)
+	if s, _ := GetCronTask("error"); !s.Paused || s.Runs != 1 {
+		t.Errorf("paused: %+v", s)
+	}
+	e.run(triggerManual, 0)
+	if s, _ := GetCronTask("error"); s.Runs != 2 || s.History[0].Trigger != triggerManual {
+		t.Errorf("manual: %+v", s)
+	}
+	_ = ResumeCronTask("error")
+
+	if err := TriggerCronTask("none"); err == nil {
+		t.Error("TriggerCronTask unknown task want error")
+	}
+}
+
+func Test_StopCronTask(t *testing.T) {
+	started := make(chan struct{}, 1)
+	tasks := []CronTask{
+		{Cron: "@every 1h", Name: "block", Func: func(ctx context.Context) error {
+			started <- struct{}{}
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+	}
+	if err := StartCronTask(tasks); err != nil {
+		t.Fatalf("StartCronTask Err: %s", err.Error())
+	}
+	if err := TriggerCronTask("block"); err != nil {
+		t.Fatalf("TriggerCronTask Err: %s", err.Error())
+	}
+	<-started
+
+	// 手动触发的任务未结束，ctx 超时后取消任务
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := StopCronTaskContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("StopCronTaskContext: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if s, _ := GetCronTask("block"); s.Runs == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s, _ := GetCronTask("block"); s.Runs != 1 || s.LastRun.Status != CronStatusError {
+		t.Errorf("block: %+v", s)
+	}
+	if err := TriggerCronTask("block"); err == nil {
+		t.Error("TriggerCronTask after stop want error")
+	}
+}
+
+func Test_CronTaskConfig(t *testing.T) {
+	conf, err := config.NewFromData(nil, []byte(`<xml><Cron>
+	<Task><Name>hourly</Name><Cron>@every 2h</Cron></Task>
+	<Task><Name>daily</Name><Enable>0</Enable></Task>
+</Cron></xml>`))
+	if err != nil {
+		t.Fatalf("NewFromData Err: %s", err.Error())
+	}
+	SetCronConfig(conf)
+	defer SetCronConfig(config.Default())
+
+	tasks := []CronTask{
+		{Cron: "@every 1h", Name: "hourly", TaskFunc: func() {}},
+		{Cron: "@every 24h", Name: "daily", TaskFunc: func() {}},
+	}
+	if err := StartCronTask(tasks); err != nil {
+		t.Fatalf("StartCronTask Err: %s", err.Error())
+	}
+	defer StopCronTask()
+
+	check := func(name, cron string, enabled bool) {
+		t.Helper()
+		if s, _ := GetCronTask(name); s.Cron != cron || s.Enabled != enabled || (s.NextRun != "") != enabled {
+			t.Errorf("%s: %+v", name, s)
+		}
+	}
+	check("hourly", "@every 2h", true)
+	check("daily", "@every 24h", false)
+
+	// 重新加载：定时参数错误时使用代码中的，启用 daily
+	conf, _ = config.NewFromData(nil, []byte(`<xml><Cron>
+	<Task><Name>hourly</Name><Cron>invalid</Cron></Task>
+	<Task><Name>daily</Name><Cron>@every 12h</Cron><Enable>1</Enable></Task>
+</Cron></xml>`))
+	SetCronConfig(conf)
+	ReloadCronTask()
+	check("hourly", "@every 1h", true)
+	check("daily", "@every 12h", true)
+	if n := len(crontab.Entries()); n != 2 {
+		t.Errorf("crontab entries: %d", n)
+	}
+}
+
+// 多个应用时只有一个可以占用定时任务
+func Test_ClaimCron(t *testing.T) {
+	confA, confB := config.Default(), &config.Config{}
+	if err := ClaimCron("app-a", confA, nil); err != nil {
+		t.Fatalf("ClaimCron Err: %s", err.Error())
+	}
+	defer SetCronConfig(config.Default())
+
+	if err := ClaimCron("app-b", confB, nil); err == nil || cronConf != confA {
+		t.Fatalf("ClaimCron app-b: %v", err)
+	}
+	ReleaseCron("app-b")
+	if err := ClaimCron("app-a", confA, nil); err != nil {
+		t.Fatalf("ClaimCron again Err: %s", err.Error())
+	}
+
+	ReleaseCron("app-a")
+	if err := ClaimCron("app-b", confB, nil); err != nil || cronConf != confB {
+		t.Fatalf("ClaimCron after release: %v", err)
+	}
+	ReleaseCron("app-b")
+}
+
+// 分布式锁的 tick 为定时触发的时间，任务启动延迟时也不变
+func Test_CronActivation(t *testing.T) {
+	c := cron.New(cron.WithSeconds())
+	e := &cronEntry{name: "activation"}
+	ticks := make(chan int64, 1)
+	var fired time.Time
+	cronMutex.Lock()
+	e.id = c.Schedule(cron.Every(time.Second), cron.FuncJob(func() {
+		fired = time.Now()
+		time.Sleep(700 * time.Millisecond)
+		ticks <- e.activation(c)
+	}))
+	cronMutex.Unlock()
+	c.Start()
+	defer c.Stop()
+
+	tick := <-ticks
+	if tick != fired.Truncate(time.Second).Unix() {
+		t.Errorf("tick: %d, fired: %s", tick, fired)
+	}
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/implements/watcher"
+	"github.com/mutou1225/go-frame/logger"
+	"io/ioutil"
+	"log"
+	"sync"
+	"time"
+
+	rwatcher "github.com/radovskyb/watcher"
+)
+
+/*
+  业务端口的 TLS
+  证书、客户端CA文件变更后自动重新加载，不需要重启；加载失败时继续使用旧的证书
+*/
+
+// 证书文件的检查间隔
+const certWatchInterval = 10 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// 是否开启 TLS
+func TLSEnabled(conf config.TLSConfig) bool {
+	return conf.CertFile != "" && conf.KeyFile != ""
+}
+
+// 检查 TLS 配置和证书，不监控证书文件
+func CheckTLSConfig(conf config.TLSConfig) error {
+	_, err := newCertReloader(conf)
+	return err
+}
+
+// 证书热加载
+type certReloader struct {
+	conf       config.TLSConfig
+	minVersion uint16
+	clientAuth tls.ClientAuthType
+	mutex      sync.RWMutex
+	tlsConfig  *tls.Config
+	watchers   []*rwatcher.Watcher
+}
+
+func newCertReloader(conf config.TLSConfig) (*certReloader, error) {
+	r := &certReloader{
+		conf:       conf,
+		minVersion: tls.VersionTLS12,
+		clientAuth: tls.NoClientCert,
+	}
+
+	if conf.MinVersion != "" {
+		v, ok := tlsVersions[conf.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls MinVersion[%s] invalid", conf.MinVersion)
+		}
+		r.minVersion = v
+	}
+
+	if conf.ClientCAFile != "" {
+		switch conf.ClientAuth {
+		case "", "require":
+			r.clientAuth = tls.RequireAndVerifyClientCert
+		case "optional":
+			r.clientAuth = tls.VerifyClientCertIfGiven
+		default:
+			return nil, fmt.Errorf("tls ClientAuth[%s] invalid", conf.ClientAuth)
+		}
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// 加载证书和客户端CA
+func (r *certReloader) load() error {
+	cert, err := tls.LoadX509KeyPair(r.conf.CertFile, r.conf.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   r.minVersion,
+		ClientAuth:   r.clientAuth,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+
+	if r.conf.ClientCAFile != "" {
+		caData, err := ioutil.ReadFile(r.conf.ClientCAFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return errors.New("client ca file has no certificate")
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	r.mutex.Lock()
+	r.tlsConfig = tlsConfig
+	r.mutex.Unlock()
+	return nil
+}
+
+// 监控证书文件，变更后重新加载
+func (r *certReloader) watch() {
+	files := []string{r.conf.CertFile, r.conf.KeyFile}
+	if r.conf.ClientCAFile != "" {
+		files = append(files, r.conf.ClientCAFile)
+	}
+
+	for _, file := range files {
+		w, err := watcher.FileWatcher(file, certWatchInterval)
+		if err != nil {
+			logger.PrintError("FileWatcher(%s) Err: %s", file, err.Error())
+			continue
+		}
+		r.watchers = append(r.watchers, w)
+
+		go func(file string, w *rwatcher.Watcher) {
+			for {
+				select {
+				case <-w.Event:
+					if err := r.load(); err != nil {
+						logger.PrintError("tls reload[%s] Err: %s, keep the old certificate", file, err.Error())
+						log.Printf("tls reload[%s] Err: %s", file, err.Error())
+					} else {
+						logger.PrintInfo("tls reload[%s] OK", file)
+					}
+				case err := <-w.Error:
+					logger.PrintError("tls watcher[%s] Err: %s", file, err.Error())
+				case <-w.Closed:
+					return
+				}
+			}
+		}(file, w)
+	}
+}
+
+func (r *certReloader) close() {
+	for _, w := range r.watchers {
+		w.Close()
+	}
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return &r.tlsConfig.Certificates[0], nil
+}
+
+// 每个连接使用最新的证书配置
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.tlsConfig, nil
+}
+
+// 创建支持证书热加载的 tls.Config，退出时调用返回的 close 停止监控证书文件
+func NewTLSConfig(conf config.TLSConfig) (*tls.Config, func(), error) {
+	r, err := newCertReloader(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.watch()
+
+	tlsConfig := &tls.Config{
+		MinVersion:         r.minVersion,
+		NextProtos:         []string{"h2", "http/1.1"},
+		GetCertificate:     r.getCertificate,
+		GetConfigForClient: r.getConfigForClient,
+	}
+	return tlsConfig, r.close, nil
+}
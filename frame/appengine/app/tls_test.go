@@ -0,0 +1,99 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/mutou1225/go-frame/config"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 生成自签名证书
+func writeTestCert(t *testing.T, dir, cn string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.crt"), certPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.key"), keyPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_CertReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeTestCert(t, dir, "old")
+	r, err := newCertReloader(config.TLSConfig{
+		CertFile:   filepath.Join(dir, "server.crt"),
+		KeyFile:    filepath.Join(dir, "server.key"),
+		MinVersion: "1.2",
+	})
+	if err != nil {
+		t.Fatalf("newCertReloader() Err: %s", err.Error())
+	}
+
+	commonName := func() string {
+		conf, _ := r.getConfigForClient(nil)
+		cert, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.MinVersion != tls.VersionTLS12 {
+			t.Errorf("MinVersion: %x", conf.MinVersion)
+		}
+		return cert.Subject.CommonName
+	}
+	if cn := commonName(); cn != "old" {
+		t.Errorf("CommonName: %s, want old", cn)
+	}
+
+	writeTestCert(t, dir, "new")
+	if err := r.load(); err != nil {
+		t.Fatalf("load() Err: %s", err.Error())
+	}
+	if cn := commonName(); cn != "new" {
+		t.Errorf("CommonName: %s, want new", cn)
+	}
+
+	// 加载失败时保留旧证书
+	_ = ioutil.WriteFile(filepath.Join(dir, "server.key"), []byte("bad"), 0600)
+	if err := r.load(); err == nil {
+		t.Error("load() want error")
+	}
+	if cn := commonName(); cn != "new" {
+		t.Errorf("CommonName: %s, want new", cn)
+	}
+}
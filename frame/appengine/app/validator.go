@@ -0,0 +1,278 @@
+package app
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+  参数校验：校验器和翻译只创建一次，返回全部字段的错误
+  字段名使用 json 标签，错误信息按 Accept-Language 选择中文（默认）或英文
+  自定义规则：
+  mobile            手机号码
+  idcard            身份证号码（18 位校验码，或 15 位）
+  price             价格，非负数，最多两位小数
+  numrange=1~500    数字字符串的范围，可以只有一边：numrange=1~、numrange=~500
+  其他规则使用 RegisterValidation 在启动前注册
+*/
+
+var (
+	mobileRegexp = regexp.MustCompile(`^1[3-9][0-9]{9}$`)
+	priceRegexp  = regexp.MustCompile(`^[0-9]+(\.[0-9]{1,2})?$`)
+	idCard15     = regexp.MustCompile(`^[0-9]{15}$`)
+	idCard18     = regexp.MustCompile(`^[0-9]{17}[0-9Xx]$`)
+
+	validatorOnce sync.Once
+	validate      *validator.Validate
+	translator    *ut.UniversalTranslator
+)
+
+// 字段的校验错误
+type FieldError struct {
+	Field   string `json:"field"` // json 字段路径，如 _param.pageSize
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// 全部字段的校验错误
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, v := range e {
+		msgs = append(msgs, v.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// 参数错误时响应的 _data
+type InvalidParamsData struct {
+	Errors ValidationErrors `json:"errors"`
+}
+
+// 校验错误转换为响应的 _data，不是校验错误时返回 nil
+func ValidationData(err error) interface{} {
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		return &InvalidParamsData{Errors: verrs}
+	}
+	return nil
+}
+
+// 注册自定义规则和中英文的错误信息，{0} 为字段名、{1} 为参数；需要在启动前注册
+func RegisterValidation(tag string, fn validator.Func, zhMsg, enMsg string) error {
+	v, uni := getValidator()
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	for locale, msg := range map[string]string{"zh": zhMsg, "en": enMsg} {
+		trans, _ := uni.GetTranslator(locale)
+		if err := registerTranslation(v, trans, tag, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按请求的 Accept-Language 校验结构体，c 为 nil 时使用中文
+func Validate(c *gin.Context, s interface{}) error {
+	v, uni := getValidator()
+	if err := v.Struct(s); err != nil {
+		return translateErrors(err, requestTranslator(c, uni))
+	}
+	return nil
+}
+
+// 校验结构体，错误信息为中文
+func ValidatorStruct(s interface{}) error {
+	return Validate(nil, s)
+}
+
+func getValidator() (*validator.Validate, *ut.UniversalTranslator) {
+	validatorOnce.Do(func() {
+		v := validator.New()
+		v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+			name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			return name
+		})
+
+		uni := ut.New(zh.New(), zh.New(), en.New())
+		zhTrans, _ := uni.GetTranslator("zh")
+		enTrans, _ := uni.GetTranslator("en")
+		_ = zhTranslations.RegisterDefaultTranslations(v, zhTrans)
+		_ = enTranslations.RegisterDefaultTranslations(v, enTrans)
+
+		rules := []struct {
+			tag   string
+			fn    validator.Func
+			zhMsg string
+			enMsg string
+		}{
+			{"mobile", validMobile, "{0}必须是有效的手机号码", "{0} must be a valid mobile number"},
+			{"idcard", validIDCard, "{0}必须是有效的身份证号码", "{0} must be a valid ID card number"},
+			{"price", validPrice, "{0}必须是有效的价格，最多两位小数", "{0} must be a valid price with at most 2 decimals"},
+			{"numrange", validNumRange, "{0}必须是{1}范围内的数字", "{0} must be a number in range {1}"},
+		}
+		for _, r := range rules {
+			_ = v.RegisterValidation(r.tag, r.fn)
+			_ = registerTranslation(v, zhTrans, r.tag, r.zhMsg)
+			_ = registerTranslation(v, enTrans, r.tag, r.enMsg)
+		}
+
+		validate, translator = v, uni
+	})
+	return validate, translator
+}
+
+func registerTranslation(v *validator.Validate, trans ut.Translator, tag, msg string) error {
+	return v.RegisterTranslation(tag, trans, func(ut ut.Translator) error {
+		return ut.Add(tag, msg, true)
+	}, func(ut ut.Translator, fe validator.FieldError) string {
+		t, err := ut.T(fe.Tag(), fe.Field(), fe.Param())
+		if err != nil {
+			return fe.Error()
+		}
+		return t
+	})
+}
+
+// Accept-Language 中第一个支持的语言，默认中文
+func requestTranslator(c *gin.Context, uni *ut.UniversalTranslator) ut.Translator {
+	if c != nil && c.Request != nil {
+		for _, lang := range strings.Split(c.GetHeader("Accept-Language"), ",") {
+			lang = strings.ToLower(strings.TrimSpace(strings.SplitN(lang, ";", 2)[0]))
+			lang = strings.SplitN(strings.ReplaceAll(lang, "_", "-"), "-", 2)[0]
+			if trans, ok := uni.GetTranslator(lang); ok && lang != "" {
+				return trans
+			}
+		}
+	}
+	trans, _ := uni.GetTranslator("zh")
+	return trans
+}
+
+// 校验错误转换为 ValidationErrors，字段路径去掉最外层的结构体名
+func translateErrors(err error, trans ut.Translator) error {
+	verrs, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	ret := make(ValidationErrors, 0, len(verrs))
+	for _, v := range verrs {
+		field := v.Namespace()
+		if idx := strings.Index(field, "."); idx >= 0 {
+			field = field[idx+1:]
+		}
+		ret = append(ret, FieldError{
+			Field:   field,
+			Rule:    v.Tag(),
+			Param:   v.Param(),
+			Message: v.Translate(trans),
+		})
+	}
+	return ret
+}
+
+func validMobile(fl validator.FieldLevel) bool {
+	return mobileRegexp.MatchString(fl.Field().String())
+}
+
+// 18 位身份证号码的最后一位为校验码
+func validIDCard(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	if idCard15.MatchString(s) {
+		return true
+	}
+	if !idCard18.MatchString(s) {
+		return false
+	}
+
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(s[17:])[0]
+}
+
+func validPrice(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	switch field.Kind() {
+	case reflect.String:
+		return priceRegexp.MatchString(field.String())
+	case reflect.Float32, reflect.Float64:
+		f := field.Float() * 100
+		return f >= 0 && math.Abs(f-math.Round(f)) < 1e-6
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return field.Int() >= 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// 参数为 min~max，数字字符串或数字类型的字段
+func validNumRange(fl validator.FieldLevel) bool {
+	min, max, ok := parseNumRange(fl.Param())
+	if !ok {
+		panic("invalid numrange param: " + fl.Param())
+	}
+
+	var n float64
+	field := fl.Field()
+	switch field.Kind() {
+	case reflect.String:
+		var err error
+		if n, err = strconv.ParseFloat(field.String(), 64); err != nil {
+			return false
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = field.Float()
+	default:
+		return false
+	}
+	return n >= min && n <= max
+}
+
+// 解析 min~max，缺少的一边为无穷
+func parseNumRange(param string) (min, max float64, ok bool) {
+	parts := strings.Split(param, "~")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	min, max = math.Inf(-1), math.Inf(1)
+	var err error
+	if parts[0] != "" {
+		if min, err = strconv.ParseFloat(parts[0], 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	if parts[1] != "" {
+		if max, err = strconv.ParseFloat(parts[1], 64); err != nil {
+			return 0, 0, false
+		}
+	}
+	return min, max, true
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testValidateParam struct {
+	Mobile    string  `json:"mobile" validate:"required,mobile"`
+	IdCard    string  `json:"idCard" validate:"omitempty,idcard"`
+	Price     string  `json:"price" validate:"omitempty,price"`
+	Amount    float64 `json:"amount" validate:"price"`
+	PageSize  string  `json:"pageSize" validate:"required,numrange=1~500"`
+	PageIndex string  `json:"pageIndex" validate:"omitempty,numrange=0~"`
+}
+
+func Test_Validate(t *testing.T) {
+	valid := testValidateParam{Mobile: "13800138000", IdCard: "11010519491231002X", Price: "12.50", Amount: 9.99,
+		PageSize: "20", PageIndex: "0"}
+	if err := ValidatorStruct(&valid); err != nil {
+		t.Errorf("ValidatorStruct(valid) Err: %s", err.Error())
+	}
+
+	invalid := testValidateParam{Mobile: "12800138000", IdCard: "110105194912310021", Price: "1.234", Amount: 0.001,
+		PageSize: "501", PageIndex: "-1"}
+	err := ValidatorStruct(&invalid)
+	verrs, ok := err.(ValidationErrors)
+	if !ok || len(verrs) != 6 {
+		t.Fatalf("ValidatorStruct(invalid): %v", err)
+	}
+	if verrs[0].Field != "mobile" || verrs[0].Rule != "mobile" || verrs[0].Message != "mobile必须是有效的手机号码" {
+		t.Errorf("FieldError: %+v", verrs[0])
+	}
+	if verrs[4].Field != "pageSize" || verrs[4].Param != "1~500" {
+		t.Errorf("FieldError: %+v", verrs[4])
+	}
+	if data, ok := ValidationData(err).(*InvalidParamsData); !ok || len(data.Errors) != 6 {
+		t.Errorf("ValidationData: %v", ValidationData(err))
+	}
+
+	// 按 Accept-Language 选择语言
+	gin.SetMode(gin.TestMode)
+	for lang, want := range map[string]string{
+		"":                      "pageSize为必填字段",
+		"en-US,en;q=0.9":        "pageSize is a required field",
+		"fr-FR,zh-CN;q=0.8":     "pageSize为必填字段",
+		"ja,en-GB;q=0.9,zh;q=1": "pageSize is a required field",
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		c.Request.Header.Set("Accept-Language", lang)
+		err := Validate(c, &testValidateParam{Mobile: "13800138000"})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Accept-Language[%s]: %v, want %s", lang, err, want)
+		}
+	}
+}
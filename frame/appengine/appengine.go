@@ -1,46 +1,71 @@
 package appengine
 
 import (
+	"context"
 	"github.com/gin-gonic/gin"
 	cfg "github.com/mutou1225/go-frame/config"
 	"github.com/mutou1225/go-frame/frame/appengine/app"
 	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/health"
+	"github.com/mutou1225/go-frame/frame/stream"
 	"github.com/mutou1225/go-frame/implements/opentracing"
+	"github.com/mutou1225/go-frame/implements/storage"
 	"github.com/mutou1225/go-frame/logger"
+	"io/ioutil"
 	"log"
 	"os"
-	"os/signal"
 	"runtime"
+	"strconv"
 	"sync"
-	"syscall"
 )
 
-// Application ...
-type Application struct {
-	Name      string
-	waitGroup *sync.WaitGroup
-	//Type           int32
-	//LoggerRootPath string
-	//SetupVars      func() error
-}
-
-// ListenerApplication ...
-type WEBApplication struct {
-	Application
+// App 应用实例，使用 New 创建，或使用包级函数操作默认实例
+type App struct {
+	Name           string
 	AppPort        int
 	MonitorEndPort int
-	// 监控使用的http server
-	// MonitorMux *http.ServeMux
+	// 监控服务绑定的地址
+	MonitorAddr string
 	// RegisterHttpRoute 定义HTTP router
 	RegisterHttpRoute func(r *gin.Engine)
 	// 系统定时任务
 	RegisterTasks func() []app.CronTask
 	// 应用存储的初始化
 	AppStorageInit func()
+
+	// 应用持有的配置、日志、追踪、存储、健康检查
+	conf    *cfg.Config
+	log     *logger.Logger
+	tracer  *opentracing.OpenTracing
+	storage *storage.Storage
+	health  *health.Registry
+	// 注册的组件：存储、MQ、定时任务、自定义程序
+	components *componentRegistry
+	// 退出协调，唯一监听退出信号的地方
+	shutdown *shutdownCoordinator
+	// 自定义程序的序号，用于组件名
+	programSeq int
+	// 定时任务只注册一次
+	tasksOnce sync.Once
+	// 启动完成只通知一次
+	readyOnce sync.Once
+	// 运行中的业务路由，用于监控服务生成 OpenAPI 文档
+	router      *gin.Engine
+	routerMutex sync.RWMutex
+	// 推送的 Hub，http 服务 Shutdown 开始时断开连接
+	hubs []*stream.Hub
 }
 
+// Application 旧版本的应用类型
+// Deprecated: 使用 App
+type Application = App
+
+// WEBApplication 旧版本的应用类型，字段（Name、AppPort、RegisterHttpRoute 等）都在 App 中
+// Deprecated: 使用 App
+type WEBApplication = App
+
 var (
-	application WEBApplication
+	application = newDefaultApp()
 )
 
 // 初始化整个系统
@@ -71,151 +96,299 @@ func InitApplication(configFile, serverCfgFile, appName string) {
 }
 
 // 系统退出的释放操作
+// 按阶段退出组件，appExitFunc 在 PhaseCloseStorage 阶段执行，可以为 nil
+// 已经收到退出信号时，等待退出完成
 func ExitApplication(appExitFunc func()) {
-	app.StopCronTask()
-	appExitFunc()
-
-	// 打印系统最后退出
-	logger.ExitLogger()
+	application.Exit(appExitFunc)
 }
 
 // InitAppframe
+// appDbInit 可以为 nil，推荐使用 RegisterComponent 注册存储组件
 func InitAppframe(appHttpRoute func(r *gin.Engine), appDbInit func(), taskFunc func() []app.CronTask) {
-	application.AppPort = cfg.GetServerPort()
-	application.MonitorEndPort = cfg.GetSerMonitorPort()
-	//application.MonitorMux = server_mux.NewServerMux()
-	application.RegisterHttpRoute = appHttpRoute
-	application.RegisterTasks = taskFunc
-	application.AppStorageInit = appDbInit
+	application.InitAppframe(appHttpRoute, appDbInit, taskFunc)
+}
+
+// 统一运行：http服务和后台任务（MQ/Kafka消费者、Worker、自定义程序）在同一个进程中运行
+// 收到退出信号后按阶段统一退出；没有注册http路由时只运行后台任务
+func Run() {
+	application.Run()
 }
 
 // RunApplication
 func RunApplication() {
-	defer func() {
-		if err := recover(); err != nil {
-			log.Printf("RunApplication() Err: %s", errcode.GetSystemPanic(err))
-			logger.PrintError("RunApplication() Err: %s", errcode.GetSystemPanic(err))
-		}
-	}()
+	application.RunApplication()
+}
 
-	if application.Name == "" {
-		log.Print("Application name can't not be empty")
-		logger.PrintError("Application name can't not be empty")
-		application.Name = "unknown-app"
-	}
+// 启动自定义程序，推荐使用 RegisterProgram + Run 和http服务一起运行
+func RunCustomProgram(program ...func(*sync.WaitGroup, chan struct{})) {
+	application.RunCustomProgram(program...)
+}
 
-	// 检测是否有端口
-	if application.AppPort == 0 && application.RegisterHttpRoute != nil {
-		logger.PrintPanic("App Port is 0!")
+// 获取app名
+func AppGetName() string {
+	return application.Name
+}
+
+// 设置app名
+func appSetName(name string) {
+	application.Name = name
+}
+
+// 退出应用，appExitFunc 在 PhaseCloseStorage 阶段执行，可以为 nil
+func (a *App) Exit(appExitFunc func()) {
+	if appExitFunc != nil {
+		a.OnShutdown(PhaseCloseStorage, "appExitFunc", func(ctx context.Context) error {
+			appExitFunc()
+			return nil
+		})
 	}
 
-	// 设置服务器监视器
-	//app.InitMonitor(application.MonitorEndPort, application.MonitorMux)
+	a.Shutdown()
+}
 
-	// 初始化存储
-	if application.AppStorageInit != nil {
-		application.AppStorageInit()
+// 使用配置的端口初始化应用，appDbInit 可以为 nil
+func (a *App) InitAppframe(appHttpRoute func(r *gin.Engine), appDbInit func(), taskFunc func() []app.CronTask) {
+	a.AppPort = a.conf.GetServerPort()
+	a.MonitorEndPort = a.conf.GetSerMonitorPort()
+	a.MonitorAddr = a.conf.GetSerMonitorAddr()
+	a.RegisterHttpRoute = appHttpRoute
+	a.RegisterTasks = taskFunc
+	a.AppStorageInit = appDbInit
+
+	// 监控服务最先启动、最后退出
+	a.registerMonitor()
+
+	if appDbInit != nil {
+		a.RegisterComponent(&FuncComponent{
+			ComponentName: "appstorage",
+			InitFunc: func(ctx context.Context) error {
+				appDbInit()
+				return nil
+			},
+		})
 	}
+}
 
-	// 运行定时任务
-	if application.RegisterTasks != nil {
-		err := app.StartCronTask(application.RegisterTasks())
-		if err != nil {
-			log.Printf("StartCronTask err[%s]", err.Error())
-			logger.PrintError("StartCronTask err[%s]", err.Error())
-		}
+// 注册监控服务组件
+func (a *App) registerMonitor() {
+	if a.MonitorEndPort == 0 {
+		a.Logger().PrintInfo("MonitorPort is 0, monitor server disabled")
+		return
+	} else if a.MonitorEndPort == a.AppPort {
+		a.Logger().PrintError("MonitorPort[%d] is the same as AppPort, monitor server disabled", a.MonitorEndPort)
+		return
 	}
 
-	// 初始化gin
-	if application.AppPort != 0 {
-		router := app.InitRouter()
-		if router == nil {
-			logger.PrintPanic("App InitRouter() nil !")
+	monitor := app.NewMonitorServer(a.MonitorAddr, a.MonitorEndPort, a.injectContext())
+	a.RegisterComponent(&FuncComponent{
+		ComponentName: "monitor",
+		StartFunc: func(ctx context.Context) error {
+			return monitor.Start()
+		},
+		StopFunc: monitor.Stop,
+		Phase:    PhaseFlushLogs,
+	})
+}
+
+// 启动全部组件，定时任务最后注册，保证在其他组件之后启动
+// RunApplication 和 RunCustomProgram 同时使用时，只启动还未启动的组件
+func (a *App) startComponents() {
+	a.shutdown.watchSignals(a.components)
+
+	a.tasksOnce.Do(func() {
+		if a.RegisterTasks != nil {
+			// 定时任务是进程内唯一的，只有一个应用可以运行；分布式锁使用应用的 Redis
+			var redis func() (*storage.RedisOpt, error)
+			if a.storage != nil {
+				redis = a.storage.GetRedisCon
+			}
+			if err := app.ClaimCron(a.Name, a.conf, redis); err != nil {
+				a.Logger().PrintError("RegisterTasks ignored, Err: %s", err.Error())
+				return
+			}
+			// 服务配置重新加载后更新定时参数
+			a.conf.OnServerConfigReload(func(*cfg.Config) {
+				app.ReloadCronTask()
+			})
+			a.RegisterComponent(newCronComponent(a.Name, a.RegisterTasks))
 		}
+	})
 
-		// 检测是否有路由
-		if application.RegisterHttpRoute == nil {
-			logger.PrintPanic("App RegisterHttpRoute nil ??")
-		} else {
-			// 加载app的路由
-			application.RegisterHttpRoute(router)
+	if err := a.components.startAll(); err != nil {
+		log.Printf("startComponents() Err: %s", err.Error())
+		a.Logger().PrintPanic("startComponents() Err: %s", err.Error())
+	}
+}
+
+// 统一运行：http服务和后台任务在同一个进程中运行，直到退出
+func (a *App) Run() {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("Run() Err: %s", errcode.GetSystemPanic(err))
+			a.Logger().PrintError("Run() Err: %s", errcode.GetSystemPanic(err))
 		}
+	}()
 
-		app.StartServer(router, application.AppPort)
+	a.checkAppName()
 
+	// 检测是否有端口
+	if a.AppPort == 0 && a.RegisterHttpRoute != nil {
+		a.Logger().PrintPanic("App Port is 0!")
+	}
+
+	// 启动组件：存储、后台任务、定时任务等
+	a.startComponents()
+
+	if a.RegisterHttpRoute != nil {
+		a.runHttpServer()
 	} else {
-		log.Printf("AppPort Err: %d", application.AppPort)
+		a.setReady()
+		<-a.ShutdownStarted()
 	}
 
-	log.Print("Application RunApplication() Exit.")
+	log.Print("Application Run() Exit.")
 }
 
-// 启动自定义程序
-func RunCustomProgram(program ...func(*sync.WaitGroup, chan struct{})) {
+// RunApplication
+func (a *App) RunApplication() {
 	defer func() {
 		if err := recover(); err != nil {
-			log.Printf("RunCustomProgram() Err: %s", errcode.GetSystemPanic(err))
-			logger.PrintError("RunCustomProgram() Err: %s", errcode.GetSystemPanic(err))
+			log.Printf("RunApplication() Err: %s", errcode.GetSystemPanic(err))
+			a.Logger().PrintError("RunApplication() Err: %s", errcode.GetSystemPanic(err))
 		}
 	}()
 
-	if application.Name == "" {
-		log.Print("Application name can't not be empty")
-		logger.PrintError("Application name can't not be empty")
-		application.Name = "unknown-app"
+	a.checkAppName()
+
+	// 检测是否有端口
+	if a.AppPort == 0 && a.RegisterHttpRoute != nil {
+		a.Logger().PrintPanic("App Port is 0!")
 	}
 
-	// 设置服务器监视器
-	//app.InitMonitor(application.MonitorEndPort, application.MonitorMux)
+	// 启动组件：存储、定时任务等
+	a.startComponents()
 
-	// 初始化存储
-	if application.AppStorageInit != nil {
-		application.AppStorageInit()
+	if a.AppPort != 0 {
+		a.runHttpServer()
+	} else {
+		log.Printf("AppPort Err: %d", a.AppPort)
 	}
 
-	// 运行定时任务
-	if application.RegisterTasks != nil {
-		err := app.StartCronTask(application.RegisterTasks())
-		if err != nil {
-			logger.PrintError("StartCronTask err[%s]", err.Error())
-		}
+	log.Print("Application RunApplication() Exit.")
+}
+
+// 初始化gin，应用实例在全部路由之前注入请求上下文
+func (a *App) newRouter() *gin.Engine {
+	router := app.InitRouter(a.injectContext())
+	if router == nil {
+		a.Logger().PrintPanic("App InitRouter() nil !")
 	}
 
-	// 运行程序
-	endChanList := make([]chan struct{}, len(program))
-	application.waitGroup = &sync.WaitGroup{}
-	for i, cfunc := range program {
-		application.waitGroup.Add(1)
-		endChanList[i] = make(chan struct{})
-		go cfunc(application.waitGroup, endChanList[i])
+	// 检测是否有路由
+	if a.RegisterHttpRoute == nil {
+		a.Logger().PrintPanic("App RegisterHttpRoute nil ??")
+	} else {
+		// 加载app的路由
+		a.RegisterHttpRoute(router)
 	}
+	return router
+}
 
-	go signalQuitApp(endChanList)
-	application.waitGroup.Wait()
+// 运行http服务，退出时在 PhaseDrainHTTP 阶段等待请求结束
+func (a *App) runHttpServer() {
+	router := a.newRouter()
+	a.routerMutex.Lock()
+	a.router = router
+	a.routerMutex.Unlock()
 
-	log.Print("Application Exit.")
-	logger.PrintInfo("Application RunCustomProgram() Exit.")
+	server, err := app.NewHttpServer(router, a.AppPort, a.conf.GetTLSConfig())
+	if err != nil {
+		a.Logger().PrintPanic("App NewHttpServer() Err: %s", err.Error())
+	}
+	a.OnShutdown(PhaseDrainHTTP, "http", server.Shutdown)
+	// SSE、WebSocket 连接不会自行结束，Shutdown 开始时断开
+	a.routerMutex.RLock()
+	for _, h := range a.hubs {
+		server.RegisterOnShutdown(h.Shutdown)
+	}
+	a.routerMutex.RUnlock()
+
+	// 先监听（平滑重启的子进程使用继承的 socket），再通知就绪（父进程退出、关闭未使用的继承 socket）
+	listener, err := app.ListenServer(server)
+	if err != nil {
+		return
+	}
+	a.setReady()
+	app.Serve(server, listener)
 }
 
-func signalQuitApp (endChanList []chan struct{}) {
-	quitChan := make(chan os.Signal)
-	signal.Notify(quitChan, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+// 运行中的业务路由，http 服务未启动时返回 nil
+func (a *App) httpRouter() *gin.Engine {
+	a.routerMutex.RLock()
+	defer a.routerMutex.RUnlock()
+	return a.router
+}
+
+// 启动自定义程序，等待退出信号，或者全部程序自行结束
+func (a *App) RunCustomProgram(program ...func(*sync.WaitGroup, chan struct{})) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("RunCustomProgram() Err: %s", errcode.GetSystemPanic(err))
+			a.Logger().PrintError("RunCustomProgram() Err: %s", errcode.GetSystemPanic(err))
+		}
+	}()
+
+	a.checkAppName()
+
+	// 自定义程序以组件方式注册，由 ExitApplication 统一退出
+	programList := a.registerPrograms(program...)
 
-	sig := <-quitChan
+	// 启动组件：存储、定时任务、自定义程序
+	a.startComponents()
 
-	for _, end := range endChanList {
-		end <- struct{}{}
+	// 同时使用 RunApplication 时，http 服务监听后再就绪，避免关闭还未使用的继承 socket
+	if a.RegisterHttpRoute == nil {
+		a.setReady()
 	}
 
-	logger.PrintInfo("got a signal: %v app ending", sig)
+	// 等待退出信号，或者全部程序自行结束
+	allDone := make(chan struct{})
+	go func() {
+		for _, p := range programList {
+			<-p.Done()
+		}
+		close(allDone)
+	}()
+	select {
+	case <-a.ShutdownStarted():
+	case <-allDone:
+		a.Logger().PrintInfo("all program done, app ending")
+	}
+
+	log.Print("Application Exit.")
+	a.Logger().PrintInfo("Application RunCustomProgram() Exit.")
 }
 
-// 获取app名
-func AppGetName() string {
-	return application.Name
+// 启动完成：readyz 可用，写入pid文件，平滑重启的子进程通知父进程退出；只执行一次
+// 需要在全部监听（http 服务、监控服务）之后调用
+func (a *App) setReady() {
+	a.readyOnce.Do(func() {
+		a.health.SetReady(true)
+
+		if pidFile := a.conf.GetSerPidFile(); pidFile != "" {
+			if err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+				a.Logger().PrintError("write pid file[%s] Err: %s", pidFile, err.Error())
+			}
+		}
+
+		app.NotifyParentReady()
+	})
 }
 
-// 设置app名
-func appSetName(name string) {
-	application.Name = name
+func (a *App) checkAppName() {
+	if a.Name == "" {
+		log.Print("Application name can't not be empty")
+		a.Logger().PrintError("Application name can't not be empty")
+		a.Name = "unknown-app"
+	}
 }
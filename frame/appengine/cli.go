@@ -0,0 +1,340 @@
+package appengine
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	cfg "github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+/*
+  命令行：main.go 不再写死配置文件路径
+  <app> [serve]        初始化并运行应用（默认）
+  <app> version        打印版本信息
+  <app> check-config   检查配置文件
+  <app> routes         打印http路由和中间件，-monitor 打印监控服务的路由
+  <app> cron list      打印定时任务和下次执行时间
+  <app> openapi        输出业务接口的 OpenAPI 文档，-o 写入文件，-protocol 未指定协议版本的路由使用的版本
+  配置文件使用参数 -config、-server-config、-log-config，或环境变量 GOFRAME_CONFIG、GOFRAME_SERVER_CONFIG、
+  GOFRAME_LOG_CONFIG，参数优先；routes、cron list、openapi 不加载配置，也不连接存储
+*/
+
+const (
+	EnvConfigFile       = "GOFRAME_CONFIG"
+	EnvServerConfigFile = "GOFRAME_SERVER_CONFIG"
+	EnvLogConfigFile    = "GOFRAME_LOG_CONFIG"
+
+	defaultConfigFile = "/huishoubao/config/tinyxml2/eva_pro_config.xml"
+)
+
+const cliUsage = `Usage:
+  %[1]s [serve] [flags]       运行应用
+  %[1]s version               打印版本信息
+  %[1]s check-config [flags]  检查配置文件
+  %[1]s routes [-monitor]     打印http路由和中间件
+  %[1]s cron list             打印定时任务
+  %[1]s openapi [-o file]     输出 OpenAPI 文档
+`
+
+// 应用的命令行
+type Cli struct {
+	AppName   string
+	Version   string
+	VersionEx string
+	Update    string
+
+	// 默认的配置文件，参数和环境变量都没有设置时使用；为空时使用框架的默认路径
+	ConfigFile    string
+	ServerCfgFile string
+	LogConfigFile string
+
+	// 注册路由、存储、任务等（InitAppframe、RegisterComponent 等），serve 时在初始化配置之后执行
+	Setup func()
+	// 退出时执行，同 ExitApplication 的参数，可以为 nil
+	ExitFunc func()
+
+	out io.Writer
+}
+
+// 配置文件
+type cliFiles struct {
+	config string
+	server string
+	log    string
+}
+
+// 解析命令行并执行，结束后退出进程
+func (c *Cli) Execute() {
+	os.Exit(c.execute(os.Args[1:]))
+}
+
+// 返回进程的退出码
+func (c *Cli) execute(args []string) int {
+	if c.out == nil {
+		c.out = os.Stdout
+	}
+
+	cmd := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "serve":
+		err = c.serve(args)
+	case "version":
+		c.version()
+	case "check-config":
+		err = c.checkConfig(args)
+	case "routes":
+		err = c.routes(args)
+	case "cron":
+		if len(args) == 0 || args[0] != "list" {
+			err = errors.New("usage: cron list")
+			break
+		}
+		err = c.cronList()
+	case "openapi":
+		err = c.openAPI(args)
+	case "help":
+		fmt.Fprintf(c.out, cliUsage, c.AppName)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n"+cliUsage, cmd, c.AppName)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", c.AppName, cmd, err.Error())
+		return 1
+	}
+	return 0
+}
+
+// 配置文件参数，默认值依次为环境变量、Cli 的配置、框架的默认路径
+func (c *Cli) parseFiles(name string, args []string) (*cliFiles, error) {
+	serverCfgFile := c.ServerCfgFile
+	if serverCfgFile == "" {
+		serverCfgFile = "/huishoubao/config/" + c.AppName + "Server.xml"
+	}
+
+	files := &cliFiles{}
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.StringVar(&files.config, "config", envOr(EnvConfigFile, c.ConfigFile, defaultConfigFile), "系统配置文件")
+	fs.StringVar(&files.server, "server-config", envOr(EnvServerConfigFile, serverCfgFile), "服务配置文件")
+	fs.StringVar(&files.log, "log-config", envOr(EnvLogConfigFile, c.LogConfigFile, cfg.DefaultLogConfigFile), "日志配置文件")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// 第一个不为空的值，环境变量优先
+func envOr(env string, values ...string) string {
+	if v := os.Getenv(env); v != "" {
+		return v
+	}
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// 初始化并运行应用，直到退出
+func (c *Cli) serve(args []string) error {
+	files, err := c.parseFiles("serve", args)
+	if err != nil {
+		return err
+	}
+
+	cfg.SetLogConfigFile(files.log)
+	app.SetBuildInfo(c.AppName, c.Version, c.VersionEx, c.Update)
+	InitApplication(files.config, files.server, c.AppName)
+	defer ExitApplication(c.ExitFunc)
+
+	if c.Setup != nil {
+		c.Setup()
+	}
+	Run()
+	return nil
+}
+
+// 打印版本信息
+func (c *Cli) version() {
+	fmt.Fprintf(c.out, "%s\nVersion: %s\nVersionEx: %s\nUpdate: %s\nGo: %s %s/%s\n",
+		c.AppName, c.Version, c.VersionEx, c.Update, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// 加载全部配置文件并检查服务配置，有错误时返回
+func (c *Cli) checkConfig(args []string) error {
+	files, err := c.parseFiles("check-config", args)
+	if err != nil {
+		return err
+	}
+
+	conf, err := cfg.New(files.config, files.server)
+	if err != nil {
+		return fmt.Errorf("load config[%s, %s]: %s", files.config, files.server, err.Error())
+	}
+	if err := conf.LoadLogConfig(files.log); err != nil {
+		return fmt.Errorf("load log config[%s]: %s", files.log, err.Error())
+	}
+
+	fmt.Fprintf(c.out, "config:        %s\nserver-config: %s\nlog-config:    %s\n", files.config, files.server, files.log)
+	fmt.Fprintf(c.out, "ServerId: %d, ServerName: %s, ServerPort: %d, MonitorPort: %d, TLS: %t\n",
+		conf.GetServerId(), conf.GetServerName(), conf.GetServerPort(), conf.GetSerMonitorPort(),
+		app.TLSEnabled(conf.GetTLSConfig()))
+
+	problems := configProblems(conf)
+	for _, v := range problems {
+		fmt.Fprintf(c.out, "ERROR: %s\n", v)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d error(s)", len(problems))
+	}
+	fmt.Fprintln(c.out, "OK")
+	return nil
+}
+
+// 服务配置的错误
+func configProblems(conf *cfg.Config) []string {
+	problems := make([]string, 0)
+	if conf.GetServerId() == 0 {
+		problems = append(problems, "ServerId is 0")
+	}
+	if conf.GetServerName() == "" {
+		problems = append(problems, "ServerName empty")
+	}
+	if conf.GetSerLogFileName() == "" {
+		problems = append(problems, "LogFileName empty")
+	}
+	if port := conf.GetServerPort(); port < 0 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("ServerPort[%d] invalid", port))
+	}
+	if port := conf.GetSerMonitorPort(); port < 0 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("MonitorPort[%d] invalid", port))
+	} else if port != 0 && port == conf.GetServerPort() {
+		problems = append(problems, fmt.Sprintf("MonitorPort[%d] is the same as ServerPort", port))
+	}
+	if tlsConf := conf.GetTLSConfig(); app.TLSEnabled(tlsConf) {
+		if err := app.CheckTLSConfig(tlsConf); err != nil {
+			problems = append(problems, "TLS: "+err.Error())
+		}
+	}
+	if conf.GetLogConfig().LogConfig.LogFilePath == "" {
+		problems = append(problems, "log config Log.FilePath empty")
+	}
+	return problems
+}
+
+// 打印http路由，每个路由打印执行的中间件
+func (c *Cli) routes(args []string) error {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	monitor := fs.Bool("monitor", false, "打印监控服务的路由")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if c.Setup != nil {
+		c.Setup()
+	}
+
+	// 路由只注册不启动，不输出 gin 的调试信息
+	gin.SetMode(gin.ReleaseMode)
+	var router *gin.Engine
+	if *monitor {
+		router = app.InitMonitorRouter(application.injectContext())
+	} else if application.RegisterHttpRoute != nil {
+		router = application.newRouter()
+	} else {
+		return errors.New("no http route registered")
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tPATH\tHANDLER\tMIDDLEWARES")
+	for _, v := range app.Routes(router) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Method, v.Path, v.Handler, strings.Join(v.Middlewares, " -> "))
+	}
+	return w.Flush()
+}
+
+// 输出业务接口的 OpenAPI 文档
+func (c *Cli) openAPI(args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	output := fs.String("o", "", "输出文件，默认为标准输出")
+	pType := fs.Int("protocol", int(protocol.ProtocolV2), "未指定协议版本的路由使用的版本：1、15、2")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if c.Setup != nil {
+		c.Setup()
+	}
+	if application.RegisterHttpRoute == nil {
+		return errors.New("no http route registered")
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	app.SetBuildInfo(c.AppName, c.Version, c.VersionEx, c.Update)
+	doc := app.OpenAPI(application.newRouter(), app.OpenAPIOptions{Protocol: protocol.ProtocolType(*pType)})
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		_, err = c.out.Write(data)
+		return err
+	}
+	return ioutil.WriteFile(*output, data, 0644)
+}
+
+// 打印定时任务和下次执行时间，有错误的定时参数时返回错误
+func (c *Cli) cronList() error {
+	if c.Setup != nil {
+		c.Setup()
+	}
+	if application.RegisterTasks == nil {
+		fmt.Fprintln(c.out, "no cron task registered")
+		return nil
+	}
+
+	invalid := 0
+	now := time.Now()
+	w := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CRON\tNEXT\tTASK")
+	for _, task := range application.RegisterTasks() {
+		next := ""
+		if schedule, err := app.ParseCron(task.Cron); err != nil {
+			next = "invalid: " + err.Error()
+			invalid++
+		} else {
+			next = schedule.Next(now).Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", task.Cron, next, task.TaskName())
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid cron", invalid)
+	}
+	return nil
+}
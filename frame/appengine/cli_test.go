@@ -0,0 +1,128 @@
+package appengine
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testGroupAuth(c *gin.Context) {
+	c.Next()
+}
+
+func testOrderList(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+func testCronTask() {}
+
+func Test_Cli(t *testing.T) {
+	out := &bytes.Buffer{}
+	cli := &Cli{
+		AppName:   "CliApp",
+		Version:   "010000",
+		VersionEx: "1.0.0",
+		Update:    "2021-2-19 17:46:00",
+		Setup: func() {
+			application.RegisterHttpRoute = func(r *gin.Engine) {
+				order := r.Group("/order", testGroupAuth)
+				order.POST("/list", testOrderList)
+			}
+			application.RegisterTasks = func() []app.CronTask {
+				return []app.CronTask{{Cron: "0 */10 * * * *", TaskFunc: testCronTask}}
+			}
+		},
+		out: out,
+	}
+	defer func() {
+		application.RegisterHttpRoute = nil
+		application.RegisterTasks = nil
+	}()
+
+	if code := cli.execute([]string{"version"}); code != 0 || !strings.Contains(out.String(), "VersionEx: 1.0.0") {
+		t.Errorf("version: %d %s", code, out.String())
+	}
+
+	out.Reset()
+	if code := cli.execute([]string{"routes"}); code != 0 {
+		t.Fatalf("routes: %d", code)
+	}
+	var line string
+	for _, v := range strings.Split(out.String(), "\n") {
+		if strings.Contains(v, "/order/list") {
+			line = v
+		}
+	}
+	if !strings.Contains(line, "appengine.testOrderList") || !strings.Contains(line, "appengine.testGroupAuth") ||
+		!strings.Contains(line, "middleware.CheckCallSign") {
+		t.Errorf("routes:\n%s", out.String())
+	}
+
+	out.Reset()
+	if code := cli.execute([]string{"cron", "list"}); code != 0 || !strings.Contains(out.String(), "appengine.testCronTask") {
+		t.Errorf("cron list: %d %s", code, out.String())
+	}
+	cli.Setup = func() {
+		application.RegisterTasks = func() []app.CronTask {
+			return []app.CronTask{{Cron: "*/10 * * *", TaskFunc: testCronTask}}
+		}
+	}
+	if code := cli.execute([]string{"cron", "list"}); code != 1 {
+		t.Errorf("cron list invalid: %d, want 1", code)
+	}
+
+	if code := cli.execute([]string{"unknown"}); code != 2 {
+		t.Errorf("unknown: %d, want 2", code)
+	}
+}
+
+func Test_CliCheckConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+	configFile := write("config.xml", "<xml><IsTest>true</IsTest></xml>")
+	logFile := write("log.xml", fmt.Sprintf("<xml><Log><FilePath>%s</FilePath></Log></xml>", dir))
+	serverXml := "<xml><Server><ServerId>1</ServerId><ServerName>CliApp</ServerName><ServerPort>8080</ServerPort>" +
+		"<MonitorPort>%d</MonitorPort><LogFileName>CliApp</LogFileName></Server></xml>"
+	serverFile := write("server.xml", fmt.Sprintf(serverXml, 8081))
+
+	// 参数优先于环境变量
+	os.Setenv(EnvConfigFile, filepath.Join(dir, "none.xml"))
+	defer os.Unsetenv(EnvConfigFile)
+	os.Setenv(EnvServerConfigFile, serverFile)
+	defer os.Unsetenv(EnvServerConfigFile)
+
+	out := &bytes.Buffer{}
+	cli := &Cli{AppName: "CliApp", out: out}
+	if code := cli.execute([]string{"check-config", "-config", configFile, "-log-config", logFile}); code != 0 {
+		t.Errorf("check-config: %d %s", code, out.String())
+	}
+
+	if code := cli.execute([]string{"check-config", "-log-config", logFile}); code != 1 {
+		t.Errorf("check-config config from env: %d, want 1", code)
+	}
+
+	out.Reset()
+	write("server.xml", fmt.Sprintf(serverXml, 8080))
+	if code := cli.execute([]string{"check-config", "-config", configFile, "-log-config", logFile}); code != 1 ||
+		!strings.Contains(out.String(), "the same as ServerPort") {
+		t.Errorf("check-config same port: %d %s", code, out.String())
+	}
+}
@@ -0,0 +1,410 @@
+package appengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/health"
+	"github.com/mutou1225/go-frame/logger"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStartTimeout = 30 * time.Second
+	defaultStopTimeout  = 5 * time.Second
+)
+
+// Component 框架组件
+// 存储、MQ、定时任务、自定义程序等统一以组件的方式注册到 appengine
+// 启动：按依赖顺序依次 Init，全部成功后再依次 Start
+// 退出：在所属的退出阶段按启动的相反顺序 Stop，每个组件有独立的超时时间
+type Component interface {
+	Name() string
+	Init(ctx context.Context) error
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+	Health(ctx context.Context) error
+}
+
+// 组件注册参数
+type ComponentOption func(*componentEntry)
+
+// 依赖的组件名，被依赖的组件先启动、后退出
+func DependsOn(names ...string) ComponentOption {
+	return func(e *componentEntry) {
+		e.deps = append(e.deps, names...)
+	}
+}
+
+// Init + Start 的超时时间
+func WithStartTimeout(d time.Duration) ComponentOption {
+	return func(e *componentEntry) {
+		e.startTimeout = d
+	}
+}
+
+// Stop 的超时时间
+func WithStopTimeout(d time.Duration) ComponentOption {
+	return func(e *componentEntry) {
+		e.stopTimeout = d
+	}
+}
+
+// 启动失败只打印日志，不中断应用启动
+func Optional() ComponentOption {
+	return func(e *componentEntry) {
+		e.optional = true
+	}
+}
+
+// 组件在哪个退出阶段 Stop，默认 PhaseCloseStorage
+func StopInPhase(phase ShutdownPhase) ComponentOption {
+	return func(e *componentEntry) {
+		e.phase = phase
+	}
+}
+
+// 组件实现该接口时使用返回的退出阶段，StopInPhase 可以覆盖
+type phaseComponent interface {
+	StopPhase() ShutdownPhase
+}
+
+type componentEntry struct {
+	comp         Component
+	deps         []string
+	startTimeout time.Duration
+	stopTimeout  time.Duration
+	optional     bool
+	phase        ShutdownPhase
+	active       bool // Init 成功，退出时需要 Stop
+}
+
+type componentRegistry struct {
+	mutex sync.Mutex
+	// startAll 依次执行，Init、Start 时不持有 mutex，不阻塞注册和健康检查
+	startMutex     sync.Mutex
+	entries        []*componentEntry
+	active         []*componentEntry // 已初始化的组件，按启动顺序
+	healthRegistry *health.Registry  // 组件初始化后注册健康检查
+	log            *logger.Logger    // nil 时使用默认日志
+}
+
+func newComponentRegistry() *componentRegistry {
+	return &componentRegistry{healthRegistry: health.Default()}
+}
+
+func (r *componentRegistry) getLogger() *logger.Logger {
+	if r.log != nil {
+		return r.log
+	}
+	return logger.Default()
+}
+
+// 注册组件
+func (r *componentRegistry) register(c Component, opts ...ComponentOption) error {
+	if c == nil || c.Name() == "" {
+		return errors.New("component name empty")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, e := range r.entries {
+		if e.comp.Name() == c.Name() {
+			return fmt.Errorf("component[%s] already registered", c.Name())
+		}
+	}
+
+	entry := &componentEntry{
+		comp:         c,
+		startTimeout: defaultStartTimeout,
+		stopTimeout:  defaultStopTimeout,
+		phase:        PhaseCloseStorage,
+	}
+	if pc, ok := c.(phaseComponent); ok && pc.StopPhase() != 0 {
+		entry.phase = pc.StopPhase()
+	}
+	for _, opt := range opts {
+		opt(entry)
+	}
+	r.entries = append(r.entries, entry)
+
+	return nil
+}
+
+// 按依赖关系排序，依赖相同时保持注册顺序
+func (r *componentRegistry) sortEntries() ([]*componentEntry, error) {
+	index := make(map[string]*componentEntry, len(r.entries))
+	for _, e := range r.entries {
+		index[e.comp.Name()] = e
+	}
+
+	const (
+		_ = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(r.entries))
+	ordered := make([]*componentEntry, 0, len(r.entries))
+
+	var visit func(e *componentEntry, path []string) error
+	visit = func(e *componentEntry, path []string) error {
+		name := e.comp.Name()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("component dependency cycle: %s -> %s", strings.Join(path, " -> "), name)
+		}
+
+		state[name] = visiting
+		for _, dep := range e.deps {
+			depEntry, ok := index[dep]
+			if !ok {
+				return fmt.Errorf("component[%s] depends on unknown component[%s]", name, dep)
+			}
+			if err := visit(depEntry, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, e)
+		return nil
+	}
+
+	for _, e := range r.entries {
+		if err := visit(e, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// 启动全部组件
+// 非 Optional 组件失败时返回错误，已初始化的组件在退出时 Stop
+func (r *componentRegistry) startAll() error {
+	r.startMutex.Lock()
+	defer r.startMutex.Unlock()
+
+	pending, err := r.pendingEntries()
+	if err != nil {
+		return err
+	}
+
+	// Init
+	inited := make([]*componentEntry, 0, len(pending))
+	for _, e := range pending {
+		now := time.Now()
+		err := runWithTimeout(e.startTimeout, e.comp.Init)
+		if err != nil {
+			r.getLogger().PrintError("Component[%s] Init Err: %s [%v]", e.comp.Name(), err.Error(), time.Since(now))
+			log.Printf("Component[%s] Init Err: %s", e.comp.Name(), err.Error())
+			if e.optional {
+				continue
+			}
+			return fmt.Errorf("component[%s] init: %s", e.comp.Name(), err.Error())
+		}
+		r.getLogger().PrintInfo("Component[%s] Init OK [%v]", e.comp.Name(), time.Since(now))
+		r.healthRegistry.Register(e.comp.Name(), e.comp.Health)
+		r.mutex.Lock()
+		e.active = true
+		r.active = append(r.active, e)
+		r.mutex.Unlock()
+		inited = append(inited, e)
+	}
+
+	// Start
+	for _, e := range inited {
+		now := time.Now()
+		err := runWithTimeout(e.startTimeout, e.comp.Start)
+		if err != nil {
+			r.getLogger().PrintError("Component[%s] Start Err: %s [%v]", e.comp.Name(), err.Error(), time.Since(now))
+			log.Printf("Component[%s] Start Err: %s", e.comp.Name(), err.Error())
+			if e.optional {
+				continue
+			}
+			return fmt.Errorf("component[%s] start: %s", e.comp.Name(), err.Error())
+		}
+		r.getLogger().PrintInfo("Component[%s] Start OK [%v]", e.comp.Name(), time.Since(now))
+	}
+
+	return nil
+}
+
+// 按依赖顺序排列的未启动的组件
+func (r *componentRegistry) pendingEntries() ([]*componentEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ordered, err := r.sortEntries()
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]*componentEntry, 0, len(ordered))
+	for _, e := range ordered {
+		if !e.active {
+			pending = append(pending, e)
+		}
+	}
+	return pending, nil
+}
+
+// 按退出阶段依次退出全部组件
+func (r *componentRegistry) stopAll() {
+	for _, phase := range shutdownPhases {
+		r.stopPhase(context.Background(), phase)
+	}
+}
+
+// 按启动的相反顺序退出属于 phase 的组件
+// 单个组件的超时时间不超过 ctx 的截止时间
+func (r *componentRegistry) stopPhase(ctx context.Context, phase ShutdownPhase) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	remain := make([]*componentEntry, 0, len(r.active))
+	for i := len(r.active) - 1; i >= 0; i-- {
+		e := r.active[i]
+		if e.phase != phase {
+			remain = append(remain, e)
+			continue
+		}
+
+		now := time.Now()
+		stopCtx, cancel := context.WithTimeout(ctx, e.stopTimeout)
+		err := runWithContext(stopCtx, e.comp.Stop)
+		cancel()
+		if err != nil {
+			r.getLogger().PrintError("Component[%s] Stop Err: %s [%v]", e.comp.Name(), err.Error(), time.Since(now))
+			log.Printf("Component[%s] Stop Err: %s", e.comp.Name(), err.Error())
+		} else {
+			r.getLogger().PrintInfo("Component[%s] Stop OK [%v]", e.comp.Name(), time.Since(now))
+		}
+		r.healthRegistry.Unregister(e.comp.Name())
+		e.active = false
+	}
+
+	// remain 为逆序，恢复启动顺序
+	r.active = r.active[:0]
+	for i := len(remain) - 1; i >= 0; i-- {
+		r.active = append(r.active, remain[i])
+	}
+}
+
+// 获取已初始化组件的健康状态，key为组件名
+func (r *componentRegistry) health(ctx context.Context) map[string]error {
+	r.mutex.Lock()
+	active := make([]*componentEntry, len(r.active))
+	copy(active, r.active)
+	r.mutex.Unlock()
+
+	result := make(map[string]error, len(active))
+	for _, e := range active {
+		result[e.comp.Name()] = e.comp.Health(ctx)
+	}
+	return result
+}
+
+// 在超时时间内执行，组件不响应ctx时也能按时返回
+func runWithTimeout(timeout time.Duration, f func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return runWithContext(ctx, f)
+}
+
+// 在ctx结束前执行，f不响应ctx时也能按时返回
+func runWithContext(ctx context.Context, f func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fmt.Errorf("panic: %s", errcode.GetSystemPanic(err))
+			}
+		}()
+		done <- f(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timeout: %v", ctx.Err())
+	}
+}
+
+// FuncComponent 使用函数定义组件，未设置的函数视为空操作
+type FuncComponent struct {
+	ComponentName string
+	InitFunc      func(ctx context.Context) error
+	StartFunc     func(ctx context.Context) error
+	StopFunc      func(ctx context.Context) error
+	HealthFunc    func(ctx context.Context) error
+	// 退出阶段，为 0 时使用 PhaseCloseStorage
+	Phase ShutdownPhase
+}
+
+func (f *FuncComponent) Name() string {
+	return f.ComponentName
+}
+
+func (f *FuncComponent) Init(ctx context.Context) error {
+	if f.InitFunc == nil {
+		return nil
+	}
+	return f.InitFunc(ctx)
+}
+
+func (f *FuncComponent) Start(ctx context.Context) error {
+	if f.StartFunc == nil {
+		return nil
+	}
+	return f.StartFunc(ctx)
+}
+
+func (f *FuncComponent) Stop(ctx context.Context) error {
+	if f.StopFunc == nil {
+		return nil
+	}
+	return f.StopFunc(ctx)
+}
+
+func (f *FuncComponent) Health(ctx context.Context) error {
+	if f.HealthFunc == nil {
+		return nil
+	}
+	return f.HealthFunc(ctx)
+}
+
+func (f *FuncComponent) StopPhase() ShutdownPhase {
+	return f.Phase
+}
+
+// 注册组件，名字为空或重复时返回错误，不注册
+func RegisterComponent(c Component, opts ...ComponentOption) error {
+	return application.RegisterComponent(c, opts...)
+}
+
+// 获取已初始化组件的健康状态
+func ComponentsHealth(ctx context.Context) map[string]error {
+	return application.ComponentsHealth(ctx)
+}
+
+// 注册组件，名字为空或重复时打印错误并返回，不注册
+func (a *App) RegisterComponent(c Component, opts ...ComponentOption) error {
+	if err := a.components.register(c, opts...); err != nil {
+		a.Logger().PrintError("RegisterComponent() Err: %s", err.Error())
+		return err
+	}
+	return nil
+}
+
+// 获取已初始化组件的健康状态
+func (a *App) ComponentsHealth(ctx context.Context) map[string]error {
+	return a.components.health(ctx)
+}
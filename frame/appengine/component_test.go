@@ -0,0 +1,103 @@
+package appengine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestComponent(name string, record *[]string) *FuncComponent {
+	return &FuncComponent{
+		ComponentName: name,
+		StartFunc: func(ctx context.Context) error {
+			*record = append(*record, "start:"+name)
+			return nil
+		},
+		StopFunc: func(ctx context.Context) error {
+			*record = append(*record, "stop:"+name)
+			return nil
+		},
+	}
+}
+
+func Test_ComponentOrder(t *testing.T) {
+	var record []string
+	r := newComponentRegistry()
+	_ = r.register(newTestComponent("cron", &record), DependsOn("mysql", "redis"))
+	_ = r.register(newTestComponent("mysql", &record))
+	_ = r.register(newTestComponent("redis", &record), DependsOn("mysql"))
+
+	if err := r.startAll(); err != nil {
+		t.Fatalf("startAll() Err: %s", err.Error())
+	}
+	r.stopAll()
+
+	want := "start:mysql,start:redis,start:cron,stop:cron,stop:redis,stop:mysql"
+	if got := strings.Join(record, ","); got != want {
+		t.Errorf("order: %s, want: %s", got, want)
+	}
+}
+
+func Test_ComponentStartUnlocked(t *testing.T) {
+	r := newComponentRegistry()
+	started, release := make(chan struct{}), make(chan struct{})
+	_ = r.register(&FuncComponent{ComponentName: "slow", StartFunc: func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	}})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- r.startAll()
+	}()
+	<-started
+
+	// 启动中的组件不阻塞健康检查和注册
+	if h := r.health(context.Background()); len(h) != 1 {
+		t.Errorf("health: %v", h)
+	}
+	if err := r.register(newTestComponent("later", new([]string))); err != nil {
+		t.Errorf("register Err: %s", err.Error())
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("startAll() Err: %s", err.Error())
+	}
+	if err := r.startAll(); err != nil || len(r.health(context.Background())) != 2 {
+		t.Errorf("startAll() later: %v %v", err, r.health(context.Background()))
+	}
+}
+
+func Test_ComponentCycle(t *testing.T) {
+	var record []string
+	r := newComponentRegistry()
+	_ = r.register(newTestComponent("a", &record), DependsOn("b"))
+	_ = r.register(newTestComponent("b", &record), DependsOn("a"))
+
+	if err := r.startAll(); err == nil {
+		t.Error("startAll() want cycle error")
+	}
+}
+
+func Test_ComponentStopTimeout(t *testing.T) {
+	r := newComponentRegistry()
+	_ = r.register(&FuncComponent{
+		ComponentName: "slow",
+		StopFunc: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	}, WithStopTimeout(50*time.Millisecond))
+
+	if err := r.startAll(); err != nil {
+		t.Fatalf("startAll() Err: %s", err.Error())
+	}
+
+	now := time.Now()
+	r.stopAll()
+	if time.Since(now) > 500*time.Millisecond {
+		t.Errorf("stopAll() not timeout: %v", time.Since(now))
+	}
+}
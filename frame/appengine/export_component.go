@@ -0,0 +1,30 @@
+package appengine
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/frame/export"
+)
+
+// 导出组件，启动后开始执行导出任务，在 PhaseStopConsumers 阶段等待执行中的任务结束
+func NewExportComponent(e *export.Exporter) Component {
+	return &FuncComponent{
+		ComponentName: "export",
+		Phase:         PhaseStopConsumers,
+		StartFunc: func(ctx context.Context) error {
+			e.Start()
+			return nil
+		},
+		StopFunc:   e.Stop,
+		HealthFunc: e.Ping,
+	}
+}
+
+// 注册导出组件
+func RegisterExporter(e *export.Exporter, opts ...ComponentOption) {
+	application.RegisterExporter(e, opts...)
+}
+
+// 注册导出组件
+func (a *App) RegisterExporter(e *export.Exporter, opts ...ComponentOption) {
+	a.RegisterComponent(NewExportComponent(e), opts...)
+}
@@ -0,0 +1,30 @@
+package appengine
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/frame/importer"
+)
+
+// 导入组件，启动后开始执行导入任务，在 PhaseStopConsumers 阶段等待执行中的任务结束
+func NewImportComponent(im *importer.Importer) Component {
+	return &FuncComponent{
+		ComponentName: "import",
+		Phase:         PhaseStopConsumers,
+		StartFunc: func(ctx context.Context) error {
+			im.Start()
+			return nil
+		},
+		StopFunc:   im.Stop,
+		HealthFunc: im.Ping,
+	}
+}
+
+// 注册导入组件
+func RegisterImporter(im *importer.Importer, opts ...ComponentOption) {
+	application.RegisterImporter(im, opts...)
+}
+
+// 注册导入组件
+func (a *App) RegisterImporter(im *importer.Importer, opts ...ComponentOption) {
+	a.RegisterComponent(NewImportComponent(im), opts...)
+}
@@ -0,0 +1,206 @@
+package appengine
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	cfg "github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/health"
+	"github.com/mutou1225/go-frame/implements/opentracing"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"github.com/mutou1225/go-frame/logger"
+)
+
+/*
+  应用实例
+  New 创建的应用持有自己的配置、日志、追踪、存储和健康检查，同一进程可以运行多个应用（如测试）
+  包级函数（InitApplication、InitAppframe、Run 等）使用默认实例，默认实例使用各包的默认实例
+  接口中通过 FromContext(c) 获取请求所属的应用
+*/
+
+// 请求上下文中保存应用实例的 key
+const appContextKey = "goframe.app"
+
+type Option func(*App) error
+
+// 应用名
+func WithName(name string) Option {
+	return func(a *App) error {
+		a.Name = name
+		return nil
+	}
+}
+
+// 使用已创建的配置实例
+func WithConfig(conf *cfg.Config) Option {
+	return func(a *App) error {
+		a.conf = conf
+		return nil
+	}
+}
+
+// 从文件加载配置，serverCfgFile 可以为空
+func WithConfigFiles(configFile, serverCfgFile string) Option {
+	return func(a *App) error {
+		conf, err := cfg.New(configFile, serverCfgFile)
+		if err != nil {
+			return err
+		}
+		a.conf = conf
+		return nil
+	}
+}
+
+// 使用已创建的日志实例，不设置时使用配置创建
+func WithLogger(l *logger.Logger) Option {
+	return func(a *App) error {
+		a.log = l
+		return nil
+	}
+}
+
+// 使用已创建的追踪实例，不设置时使用服务名创建
+func WithTracer(t *opentracing.OpenTracing) Option {
+	return func(a *App) error {
+		a.tracer = t
+		return nil
+	}
+}
+
+// 使用已创建的存储实例，不设置时使用应用的配置、日志、追踪创建
+func WithStorage(s *storage.Storage) Option {
+	return func(a *App) error {
+		a.storage = s
+		return nil
+	}
+}
+
+// http 路由
+func WithHttpRoute(f func(r *gin.Engine)) Option {
+	return func(a *App) error {
+		a.RegisterHttpRoute = f
+		return nil
+	}
+}
+
+// 系统定时任务
+func WithTasks(f func() []app.CronTask) Option {
+	return func(a *App) error {
+		a.RegisterTasks = f
+		return nil
+	}
+}
+
+// 默认实例，依赖的配置、日志等在 InitApplication 后才可用，所以日志、追踪每次从各包获取
+func newDefaultApp() *App {
+	a := &App{
+		conf:    cfg.Default(),
+		storage: storage.DefaultStorage(),
+		health:  health.Default(),
+	}
+	a.components = newComponentRegistry()
+	a.shutdown = newShutdownCoordinator()
+	return a
+}
+
+// 创建应用实例，端口、监控服务使用配置中的 Server
+func New(opts ...Option) (*App, error) {
+	a := &App{}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			return nil, err
+		}
+	}
+
+	if a.Name == "" {
+		return nil, errors.New("app name empty")
+	}
+	if a.conf == nil {
+		return nil, errors.New("app config empty")
+	}
+	if a.log == nil {
+		a.log = logger.NewLogger(a.Name, a.conf.GetSerLogFileName(), a.conf)
+	}
+	if a.tracer == nil {
+		a.tracer = opentracing.New(a.conf.GetServerName(), a.log)
+	}
+	if a.storage == nil {
+		a.storage = storage.NewStorage(a.conf, a.log, a.tracer)
+	}
+	a.health = health.NewRegistry()
+
+	a.components = newComponentRegistry()
+	a.components.healthRegistry = a.health
+	a.components.log = a.log
+
+	a.shutdown = newShutdownCoordinator()
+	a.shutdown.conf = a.conf
+	a.shutdown.health = a.health
+	a.shutdown.log = a.log
+
+	a.AppPort = a.conf.GetServerPort()
+	a.MonitorEndPort = a.conf.GetSerMonitorPort()
+	a.MonitorAddr = a.conf.GetSerMonitorAddr()
+	a.registerMonitor()
+
+	return a, nil
+}
+
+// 配置实例
+func (a *App) Config() *cfg.Config {
+	return a.conf
+}
+
+// 日志实例
+func (a *App) Logger() *logger.Logger {
+	if a.log != nil {
+		return a.log
+	}
+	return logger.Default()
+}
+
+// 追踪实例
+func (a *App) Tracer() *opentracing.OpenTracing {
+	if a.tracer != nil {
+		return a.tracer
+	}
+	return opentracing.GetOpenTracing()
+}
+
+// 存储实例
+func (a *App) Storage() *storage.Storage {
+	return a.storage
+}
+
+// 健康检查注册表
+func (a *App) Health() *health.Registry {
+	return a.health
+}
+
+// 把应用实例注入请求上下文，在全部路由之前执行
+func (a *App) injectContext() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(appContextKey, a)
+		c.Set(opentracing.ContextKey, a.Tracer())
+		c.Set(app.HealthContextKey, a.health)
+		c.Set(app.LoggerContextKey, a.Logger())
+		c.Set(app.ConfigContextKey, a.conf)
+		c.Set(app.RouterContextKey, a.httpRouter)
+		c.Next()
+	}
+}
+
+// 获取请求所属的应用，不是应用路由的请求返回默认实例
+func FromContext(c *gin.Context) *App {
+	if v, ok := c.Get(appContextKey); ok {
+		if a, ok := v.(*App); ok && a != nil {
+			return a
+		}
+	}
+	return application
+}
+
+// 默认应用实例
+func Default() *App {
+	return application
+}
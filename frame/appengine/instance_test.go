@@ -0,0 +1,134 @@
+package appengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	cfg "github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newTestApp(t *testing.T, dir, name string) *App {
+	logFile := filepath.Join(dir, name+".xml")
+	logData := fmt.Sprintf("<xml><Log><FilePath>%s</FilePath><LogLevel>info</LogLevel><Suffix>log</Suffix></Log>"+
+		"<report><FilePath>%s</FilePath><Suffix>log.report</Suffix></report>"+
+		"<Zipkin><FilePath>%s</FilePath><Suffix>log.zipkin</Suffix></Zipkin></xml>", dir, dir, dir)
+	if err := ioutil.WriteFile(logFile, []byte(logData), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	serverData := fmt.Sprintf("<xml><Server><ServerId>1</ServerId><ServerName>%s</ServerName>"+
+		"<LogFileName>%s</LogFileName></Server></xml>", name, name)
+	conf, err := cfg.NewFromData([]byte("<xml><IsTest>true</IsTest></xml>"), []byte(serverData))
+	if err != nil {
+		t.Fatalf("NewFromData() Err: %s", err.Error())
+	}
+	if err := conf.LoadLogConfig(logFile); err != nil {
+		t.Fatalf("LoadLogConfig() Err: %s", err.Error())
+	}
+
+	a, err := New(WithName(name), WithConfig(conf), WithHttpRoute(func(r *gin.Engine) {}))
+	if err != nil {
+		t.Fatalf("New() Err: %s", err.Error())
+	}
+	return a
+}
+
+func Test_MultiApp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := newTestApp(t, dir, "app-a")
+	b := newTestApp(t, dir, "app-b")
+
+	if a.Storage() == b.Storage() || a.Storage() == storage.DefaultStorage() {
+		t.Error("Storage() shared between apps")
+	}
+	if a.Tracer().ServerName != "app-a" || b.Tracer().ServerName != "app-b" {
+		t.Errorf("Tracer() ServerName: %s, %s", a.Tracer().ServerName, b.Tracer().ServerName)
+	}
+
+	// 组件和健康检查只属于注册的应用
+	a.RegisterComponent(&FuncComponent{ComponentName: "mysql", HealthFunc: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}})
+	if err := a.RegisterComponent(&FuncComponent{ComponentName: "mysql"}); err == nil {
+		t.Error("RegisterComponent() duplicate want error")
+	}
+	for _, x := range []*App{a, b} {
+		if err := x.components.startAll(); err != nil {
+			t.Fatalf("startAll() Err: %s", err.Error())
+		}
+		x.health.SetReady(true)
+	}
+
+	readyz := func(x *App) int {
+		w := httptest.NewRecorder()
+		x.newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+	if code := readyz(a); code != http.StatusServiceUnavailable {
+		t.Errorf("app-a readyz: %d, want 503", code)
+	}
+	if code := readyz(b); code != http.StatusOK {
+		t.Errorf("app-b readyz: %d, want 200", code)
+	}
+
+	// 接口通过 FromContext 获取所属应用
+	for _, x := range []*App{a, b} {
+		r := gin.New()
+		r.Use(x.injectContext())
+		r.GET("/name", func(c *gin.Context) {
+			c.String(http.StatusOK, FromContext(c).Config().GetServerName())
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/name", nil))
+		if w.Body.String() != x.Name {
+			t.Errorf("FromContext() name: %s, want %s", w.Body.String(), x.Name)
+		}
+	}
+
+	a.Shutdown()
+	if b.health.IsShuttingDown() || !a.health.IsShuttingDown() {
+		t.Error("Shutdown() affects other app")
+	}
+	b.Shutdown()
+}
+
+// 有 http 服务时 RunCustomProgram 不就绪，等待 http 服务监听后就绪
+func Test_RunCustomProgramReady(t *testing.T) {
+	dir, err := ioutil.TempDir("", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	program := func(wg *sync.WaitGroup, end chan struct{}) {
+		wg.Done()
+	}
+	a := newTestApp(t, dir, "app-http")
+	a.RunCustomProgram(program)
+	if a.health.IsReady() {
+		t.Error("ready before http server listen")
+	}
+
+	b := newTestApp(t, dir, "app-program")
+	b.RegisterHttpRoute = nil
+	b.RunCustomProgram(program)
+	if !b.health.IsReady() {
+		t.Error("program only app not ready")
+	}
+	a.Shutdown()
+	b.Shutdown()
+}
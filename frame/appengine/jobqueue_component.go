@@ -0,0 +1,40 @@
+package appengine
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/implements/jobqueue"
+)
+
+// 创建任务队列，使用应用的 Redis（需要注册 Redis 组件）
+func NewJobQueue(name string, opts ...jobqueue.Option) *jobqueue.Queue {
+	return application.NewJobQueue(name, opts...)
+}
+
+// 创建任务队列，使用应用的 Redis（需要注册 Redis 组件）
+func (a *App) NewJobQueue(name string, opts ...jobqueue.Option) *jobqueue.Queue {
+	return jobqueue.New(name, a.storage.GetRedisCon, opts...)
+}
+
+// 任务队列组件，启动后开始执行任务，在 PhaseStopConsumers 阶段等待执行中的任务结束
+func NewJobQueueComponent(q *jobqueue.Queue) Component {
+	return &FuncComponent{
+		ComponentName: "jobqueue-" + q.Name(),
+		Phase:         PhaseStopConsumers,
+		StartFunc: func(ctx context.Context) error {
+			q.Start()
+			return nil
+		},
+		StopFunc:   q.Stop,
+		HealthFunc: q.Ping,
+	}
+}
+
+// 注册任务队列组件，处理函数需要在启动前注册（Queue.Register）
+func RegisterJobQueue(q *jobqueue.Queue, opts ...ComponentOption) {
+	application.RegisterJobQueue(q, opts...)
+}
+
+// 注册任务队列组件，处理函数需要在启动前注册（Queue.Register）
+func (a *App) RegisterJobQueue(q *jobqueue.Queue, opts ...ComponentOption) {
+	a.RegisterComponent(NewJobQueueComponent(q), opts...)
+}
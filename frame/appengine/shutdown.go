@@ -0,0 +1,305 @@
+package appengine
+
+import (
+	"context"
+	cfg "github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/health"
+	"github.com/mutou1225/go-frame/logger"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+/*
+  应用退出
+  只有这里监听退出信号，收到信号后按阶段依次退出，每个阶段有独立的超时时间
+  阶段超时后不再等待，直接进入下一阶段；退出过程中再次收到信号则强制退出
+*/
+
+// 退出阶段，按定义顺序执行
+type ShutdownPhase int
+
+const (
+	PhaseStopAccepting ShutdownPhase = iota + 1 // 摘除流量：readyz 返回不可用，等待负载均衡摘除（阶段时间为等待时间，默认不等待）
+	PhaseDrainHTTP                              // 等待运行中的http请求结束
+	PhaseStopConsumers                          // 退出MQ消费者、自定义程序
+	PhaseStopCron                               // 退出定时任务
+	PhaseCloseStorage                           // 关闭存储连接
+	PhaseFlushLogs                              // 退出监控服务，刷新日志
+)
+
+var shutdownPhases = []ShutdownPhase{
+	PhaseStopAccepting,
+	PhaseDrainHTTP,
+	PhaseStopConsumers,
+	PhaseStopCron,
+	PhaseCloseStorage,
+	PhaseFlushLogs,
+}
+
+// 各阶段默认超时时间，可以通过服务配置 Shutdown 或 SetShutdownTimeout 修改
+var defaultPhaseTimeout = map[ShutdownPhase]time.Duration{
+	PhaseStopAccepting: 0,
+	PhaseDrainHTTP:     3 * time.Second,
+	PhaseStopConsumers: 10 * time.Second,
+	PhaseStopCron:      5 * time.Second,
+	PhaseCloseStorage:  5 * time.Second,
+	PhaseFlushLogs:     3 * time.Second,
+}
+
+func (p ShutdownPhase) String() string {
+	switch p {
+	case PhaseStopAccepting:
+		return "StopAccepting"
+	case PhaseDrainHTTP:
+		return "DrainHTTP"
+	case PhaseStopConsumers:
+		return "StopConsumers"
+	case PhaseStopCron:
+		return "StopCron"
+	case PhaseCloseStorage:
+		return "CloseStorage"
+	case PhaseFlushLogs:
+		return "FlushLogs"
+	default:
+		return "Unknown"
+	}
+}
+
+type shutdownHook struct {
+	name string
+	f    func(ctx context.Context) error
+}
+
+type shutdownCoordinator struct {
+	mutex      sync.Mutex
+	hooks      map[ShutdownPhase][]shutdownHook
+	timeouts   map[ShutdownPhase]time.Duration
+	nextPhase  int // 下一个要执行的阶段下标
+	startOnce  sync.Once
+	signalOnce sync.Once
+	started    chan struct{}
+	done       chan struct{}
+	conf       *cfg.Config
+	health     *health.Registry
+	log        *logger.Logger // nil 时使用默认日志，退出完成后关闭
+}
+
+func newShutdownCoordinator() *shutdownCoordinator {
+	s := &shutdownCoordinator{
+		hooks:    make(map[ShutdownPhase][]shutdownHook),
+		timeouts: make(map[ShutdownPhase]time.Duration),
+		started:  make(chan struct{}),
+		done:     make(chan struct{}),
+		conf:     cfg.Default(),
+		health:   health.Default(),
+	}
+	return s
+}
+
+func (s *shutdownCoordinator) getLogger() *logger.Logger {
+	if s.log != nil {
+		return s.log
+	}
+	return logger.Default()
+}
+
+// 注册退出函数，同一阶段按注册顺序执行
+// 该阶段已经执行过时，立即在阶段超时时间内执行
+func (s *shutdownCoordinator) onShutdown(phase ShutdownPhase, name string, f func(ctx context.Context) error) {
+	s.mutex.Lock()
+	passed := false
+	for i := 0; i < s.nextPhase; i++ {
+		if shutdownPhases[i] == phase {
+			passed = true
+		}
+	}
+	if !passed {
+		s.hooks[phase] = append(s.hooks[phase], shutdownHook{name: name, f: f})
+	}
+	s.mutex.Unlock()
+
+	if passed {
+		s.runHook(phase, shutdownHook{name: name, f: f}, s.hookTimeout(phase))
+	}
+}
+
+func (s *shutdownCoordinator) setTimeout(phase ShutdownPhase, d time.Duration) {
+	s.mutex.Lock()
+	s.timeouts[phase] = d
+	s.mutex.Unlock()
+}
+
+// 阶段超时时间：SetShutdownTimeout > 服务配置 > 默认值
+func (s *shutdownCoordinator) phaseTimeout(phase ShutdownPhase) time.Duration {
+	s.mutex.Lock()
+	d, ok := s.timeouts[phase]
+	s.mutex.Unlock()
+	if ok {
+		return d
+	}
+
+	conf := s.conf.GetShutdownConfig()
+	seconds := map[ShutdownPhase]int{
+		PhaseStopAccepting: conf.StopAccepting,
+		PhaseDrainHTTP:     conf.DrainHTTP,
+		PhaseStopConsumers: conf.StopConsumers,
+		PhaseStopCron:      conf.StopCron,
+		PhaseCloseStorage:  conf.CloseStorage,
+		PhaseFlushLogs:     conf.FlushLogs,
+	}
+	if seconds[phase] > 0 {
+		return time.Duration(seconds[phase]) * time.Second
+	}
+	return defaultPhaseTimeout[phase]
+}
+
+// 阶段内退出函数、组件的超时时间
+// StopAccepting 的阶段时间为等待负载均衡摘除实例的时间，等待之后执行该阶段的退出函数，超时时间为 defaultStopTimeout
+func (s *shutdownCoordinator) hookTimeout(phase ShutdownPhase) time.Duration {
+	if phase == PhaseStopAccepting {
+		return defaultStopTimeout
+	}
+	return s.phaseTimeout(phase)
+}
+
+// 监听退出信号，只监听一次
+// 第一次收到信号开始退出，第二次收到信号强制退出
+// 类 Unix 系统收到 SIGUSR2 时平滑重启，见 watchRestart
+func (s *shutdownCoordinator) watchSignals(components *componentRegistry) {
+	s.signalOnce.Do(func() {
+		s.watchRestart()
+
+		quitChan := make(chan os.Signal, 2)
+		signal.Notify(quitChan, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+
+		go func() {
+			sig := <-quitChan
+			s.getLogger().PrintInfo("got a signal: %v, app shutdown ...", sig)
+			log.Printf("got a signal: %v, app shutdown ...", sig)
+			go s.shutdown(components)
+
+			sig = <-quitChan
+			s.getLogger().PrintError("got a second signal: %v, force exit", sig)
+			log.Printf("got a second signal: %v, force exit", sig)
+			os.Exit(1)
+		}()
+	})
+}
+
+// 执行退出，只执行一次，其他调用者等待退出完成
+func (s *shutdownCoordinator) shutdown(components *componentRegistry) {
+	s.startOnce.Do(func() {
+		close(s.started)
+		s.run(components)
+		close(s.done)
+	})
+	<-s.done
+}
+
+func (s *shutdownCoordinator) run(components *componentRegistry) {
+	begin := time.Now()
+	// readyz 立即不可用，之后再执行各阶段
+	s.health.SetShuttingDown()
+	for i, phase := range shutdownPhases {
+		if phase == PhaseStopAccepting {
+			s.waitStopAccepting(s.phaseTimeout(phase))
+		}
+		timeout := s.hookTimeout(phase)
+
+		s.mutex.Lock()
+		s.nextPhase = i + 1
+		hooks := make([]shutdownHook, len(s.hooks[phase]))
+		copy(hooks, s.hooks[phase])
+		s.mutex.Unlock()
+
+		now := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		for _, h := range hooks {
+			s.runHookContext(ctx, phase, h)
+		}
+		components.stopPhase(ctx, phase)
+		cancel()
+		s.getLogger().PrintInfo("Shutdown phase[%s] done [%v]", phase, time.Since(now))
+	}
+
+	s.getLogger().PrintInfo("Shutdown done [%v]", time.Since(begin))
+	log.Printf("Shutdown done [%v]", time.Since(begin))
+
+	// 打印系统最后退出
+	if s.log != nil {
+		s.log.Close()
+	} else {
+		logger.ExitLogger()
+	}
+}
+
+// 等待负载均衡摘除流量，为 0 时不等待
+func (s *shutdownCoordinator) waitStopAccepting(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	<-timer.C
+	s.getLogger().PrintInfo("Shutdown phase[%s] wait readiness [%v]", PhaseStopAccepting, d)
+}
+
+func (s *shutdownCoordinator) runHook(phase ShutdownPhase, h shutdownHook, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	s.runHookContext(ctx, phase, h)
+}
+
+func (s *shutdownCoordinator) runHookContext(ctx context.Context, phase ShutdownPhase, h shutdownHook) {
+	now := time.Now()
+	if err := runWithContext(ctx, h.f); err != nil {
+		s.getLogger().PrintError("Shutdown phase[%s] hook[%s] Err: %s [%v]", phase, h.name, err.Error(), time.Since(now))
+		log.Printf("Shutdown phase[%s] hook[%s] Err: %s", phase, h.name, err.Error())
+	} else {
+		s.getLogger().PrintInfo("Shutdown phase[%s] hook[%s] OK [%v]", phase, h.name, time.Since(now))
+	}
+}
+
+// 注册退出函数，在 phase 阶段执行，ctx 在阶段超时后结束
+func OnShutdown(phase ShutdownPhase, name string, f func(ctx context.Context) error) {
+	application.OnShutdown(phase, name, f)
+}
+
+// 设置退出阶段的超时时间，优先于服务配置
+func SetShutdownTimeout(phase ShutdownPhase, d time.Duration) {
+	application.SetShutdownTimeout(phase, d)
+}
+
+// 开始退出时关闭
+func ShutdownStarted() <-chan struct{} {
+	return application.ShutdownStarted()
+}
+
+// 退出应用，已经在退出时等待退出完成
+func Shutdown() {
+	application.Shutdown()
+}
+
+// 注册退出函数，在 phase 阶段执行，ctx 在阶段超时后结束
+func (a *App) OnShutdown(phase ShutdownPhase, name string, f func(ctx context.Context) error) {
+	a.shutdown.onShutdown(phase, name, f)
+}
+
+// 设置退出阶段的超时时间，优先于服务配置
+func (a *App) SetShutdownTimeout(phase ShutdownPhase, d time.Duration) {
+	a.shutdown.setTimeout(phase, d)
+}
+
+// 开始退出时关闭
+func (a *App) ShutdownStarted() <-chan struct{} {
+	return a.shutdown.started
+}
+
+// 退出应用，已经在退出时等待退出完成
+func (a *App) Shutdown() {
+	a.shutdown.shutdown(a.components)
+}
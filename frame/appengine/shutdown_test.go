@@ -0,0 +1,99 @@
+package appengine
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/frame/health"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_ShutdownPhases(t *testing.T) {
+	var mutex sync.Mutex
+	var record []string
+	add := func(s string) {
+		mutex.Lock()
+		record = append(record, s)
+		mutex.Unlock()
+	}
+
+	r := newComponentRegistry()
+	_ = r.register(&FuncComponent{ComponentName: "mysql", StopFunc: func(ctx context.Context) error {
+		add("mysql")
+		return nil
+	}})
+	_ = r.register(&FuncComponent{ComponentName: "cron", Phase: PhaseStopCron, StopFunc: func(ctx context.Context) error {
+		add("cron")
+		return nil
+	}})
+	_ = r.register(NewProgramComponent("consumer", func(wg *sync.WaitGroup, end chan struct{}) {
+		defer wg.Done()
+		<-end
+		add("consumer")
+	}))
+	if err := r.startAll(); err != nil {
+		t.Fatalf("startAll() Err: %s", err.Error())
+	}
+
+	s := newShutdownCoordinator()
+	s.setTimeout(PhaseDrainHTTP, 50*time.Millisecond)
+	s.onShutdown(PhaseFlushLogs, "logs", func(ctx context.Context) error {
+		add("logs")
+		return nil
+	})
+	s.onShutdown(PhaseDrainHTTP, "http", func(ctx context.Context) error {
+		add("http")
+		time.Sleep(time.Second) // 阶段超时后不再等待
+		return nil
+	})
+
+	now := time.Now()
+	go s.shutdown(r)
+	s.shutdown(r)
+	if time.Since(now) > time.Second {
+		t.Errorf("shutdown() cost: %v", time.Since(now))
+	}
+
+	want := "http,consumer,cron,mysql,logs"
+	if got := strings.Join(record, ","); got != want {
+		t.Errorf("order: %s, want: %s", got, want)
+	}
+
+	// 已经执行过的阶段，注册后立即执行
+	s.onShutdown(PhaseCloseStorage, "late", func(ctx context.Context) error {
+		add("late")
+		return nil
+	})
+	if record[len(record)-1] != "late" {
+		t.Errorf("late hook not run: %v", record)
+	}
+}
+
+// 开始退出时 readyz 立即不可用，StopAccepting 按配置的时间等待，退出函数不受等待时间影响
+func Test_ShutdownStopAccepting(t *testing.T) {
+	for _, wait := range []time.Duration{0, 50 * time.Millisecond} {
+		s := newShutdownCoordinator()
+		s.health = health.NewRegistry()
+		s.health.SetReady(true)
+		s.setTimeout(PhaseStopAccepting, wait)
+
+		var hookErr error
+		s.onShutdown(PhaseStopAccepting, "deregister", func(ctx context.Context) error {
+			if s.health.IsReady() {
+				t.Error("ready during StopAccepting")
+			}
+			hookErr = ctx.Err()
+			return nil
+		})
+
+		now := time.Now()
+		s.shutdown(newComponentRegistry())
+		if cost := time.Since(now); cost < wait || cost > wait+time.Second {
+			t.Errorf("wait %v: cost %v", wait, cost)
+		}
+		if hookErr != nil {
+			t.Errorf("wait %v: hook ctx %v", wait, hookErr)
+		}
+	}
+}
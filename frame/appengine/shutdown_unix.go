@@ -0,0 +1,28 @@
+//go:build !windows
+// +build !windows
+
+package appengine
+
+import (
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 收到 SIGUSR2 时平滑重启：启动子进程，子进程启动完成后通知本进程退出
+func (s *shutdownCoordinator) watchRestart() {
+	restartChan := make(chan os.Signal, 1)
+	signal.Notify(restartChan, syscall.SIGUSR2)
+
+	go func() {
+		for range restartChan {
+			s.getLogger().PrintInfo("got a signal: SIGUSR2, graceful restart ...")
+			if _, err := app.StartChildProcess(); err != nil {
+				s.getLogger().PrintError("graceful restart Err: %s", err.Error())
+				log.Printf("graceful restart Err: %s", err.Error())
+			}
+		}
+	}()
+}
@@ -0,0 +1,7 @@
+//go:build windows
+// +build windows
+
+package appengine
+
+// 不支持平滑重启
+func (s *shutdownCoordinator) watchRestart() {}
@@ -0,0 +1,167 @@
+package appengine
+
+import (
+	"context"
+	"errors"
+	"github.com/mutou1225/go-frame/config"
+	es "github.com/mutou1225/go-frame/implements/elasticsearch"
+	"github.com/mutou1225/go-frame/implements/rabbitmq"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"sync"
+)
+
+// Mysql 组件，连接池参数使用服务配置的 MysqlPool
+func NewMysqlComponent(name string, myType storage.MysqlType, host, user, pwd, database string, port int) Component {
+	return application.NewMysqlComponent(name, myType, host, user, pwd, database, port)
+}
+
+// Mysql 组件，连接保存在应用的存储实例
+func (a *App) NewMysqlComponent(name string, myType storage.MysqlType, host, user, pwd, database string, port int) Component {
+	return &FuncComponent{
+		ComponentName: name,
+		InitFunc: func(ctx context.Context) error {
+			return a.storage.NewMysqlDB(myType, host, user, pwd, database, port,
+				a.conf.GetMysqlPoolMin(), a.conf.GetMysqlPoolMax(),
+				a.conf.GetMysqlIdleTime(), a.conf.GetMysqlConnTime(), true)
+		},
+		StopFunc: func(ctx context.Context) error {
+			a.storage.CloseDB(myType)
+			return nil
+		},
+		HealthFunc: func(ctx context.Context) error {
+			return a.storage.PingDB(ctx, myType)
+		},
+	}
+}
+
+// Mongo 组件，使用系统配置的 MongoDB 和服务配置的 MgodbPool
+func NewMongoComponent() Component {
+	return &FuncComponent{
+		ComponentName: "mongo",
+		InitFunc: func(ctx context.Context) error {
+			return storage.InitMongo(config.GetServerName(), config.GetMongoDBAddStr(),
+				config.GetMongoDBName(), config.GetMongoDBReplica(),
+				config.GetMongoDBUsert(), config.GetMongoDBPasswd(),
+				uint64(config.GetMgodbPoolMax()), uint64(config.GetMgodbPoolMin()),
+				int64(config.GetMgodbIdleTime()), config.GetMgodbConnTime())
+		},
+		StopFunc: func(ctx context.Context) error {
+			storage.CloseMgo()
+			return nil
+		},
+		HealthFunc: storage.PingMongo,
+	}
+}
+
+// Redis 组件，使用系统配置的 REDIS 和服务配置的 RedisPool
+func NewRedisComponent() Component {
+	return application.NewRedisComponent()
+}
+
+// Redis 组件，连接保存在应用的存储实例
+func (a *App) NewRedisComponent() Component {
+	return &FuncComponent{
+		ComponentName: "redis",
+		InitFunc: func(ctx context.Context) error {
+			return a.storage.NewRedisCon()
+		},
+		StopFunc: func(ctx context.Context) error {
+			a.storage.CloseRedisCon()
+			return nil
+		},
+		HealthFunc: func(ctx context.Context) error {
+			redisCon, err := a.storage.GetRedisCon()
+			if err != nil {
+				return err
+			}
+			return redisCon.Ping(ctx)
+		},
+	}
+}
+
+// ElasticSearch 组件
+func NewEsComponent(host string) Component {
+	return &FuncComponent{
+		ComponentName: "elasticsearch",
+		InitFunc: func(ctx context.Context) error {
+			return es.InitEsClient(host)
+		},
+		StopFunc: func(ctx context.Context) error {
+			es.CloseEsClient(host)
+			return nil
+		},
+		HealthFunc: func(ctx context.Context) error {
+			return es.PingEsClient(ctx, host)
+		},
+	}
+}
+
+// RabbitMQ 组件，使用系统配置的 RABBITMQ
+type RabbitMQComponent struct {
+	name   string
+	vhost  string
+	mutex  sync.RWMutex
+	client *rabbitmq.RabbitMQ
+}
+
+func NewRabbitMQComponent(name, vhost string) *RabbitMQComponent {
+	return &RabbitMQComponent{name: name, vhost: vhost}
+}
+
+func (r *RabbitMQComponent) Name() string {
+	return r.name
+}
+
+func (r *RabbitMQComponent) Init(ctx context.Context) error {
+	client, err := rabbitmq.NewRabbitMQ(
+		config.GetRabbitMQUser(),
+		config.GetRabbitMQPassword(),
+		config.GetRabbitMQHost(),
+		config.GetRabbitMQPort(),
+		r.vhost)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	r.client = client
+	r.mutex.Unlock()
+	return nil
+}
+
+func (r *RabbitMQComponent) Start(ctx context.Context) error {
+	return nil
+}
+
+func (r *RabbitMQComponent) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.client != nil {
+		r.client.Close()
+		r.client = nil
+	}
+	return nil
+}
+
+func (r *RabbitMQComponent) Health(ctx context.Context) error {
+	client, err := r.Client()
+	if err != nil {
+		return err
+	}
+	if client.IsClosed() {
+		return errors.New("MQ Connection closed")
+	}
+	return nil
+}
+
+// 获取MQ连接
+func (r *RabbitMQComponent) Client() (*rabbitmq.RabbitMQ, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if r.client == nil {
+		return nil, errors.New("MQ Client <nil>")
+	}
+	return r.client, nil
+}
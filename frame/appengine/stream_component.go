@@ -0,0 +1,28 @@
+package appengine
+
+import (
+	"github.com/mutou1225/go-frame/frame/stream"
+)
+
+// 推送组件，http 服务 Shutdown 开始时断开全部连接，在 PhaseDrainHTTP 阶段等待连接的协程结束
+func NewHubComponent(h *stream.Hub) Component {
+	return &FuncComponent{
+		ComponentName: "stream-" + h.Name(),
+		Phase:         PhaseDrainHTTP,
+		StopFunc:      h.Close,
+		HealthFunc:    h.Ping,
+	}
+}
+
+// 注册推送组件，需要在 RunApplication 之前注册
+func RegisterHub(h *stream.Hub, opts ...ComponentOption) {
+	application.RegisterHub(h, opts...)
+}
+
+// 注册推送组件，需要在 RunApplication 之前注册
+func (a *App) RegisterHub(h *stream.Hub, opts ...ComponentOption) {
+	a.routerMutex.Lock()
+	a.hubs = append(a.hubs, h)
+	a.routerMutex.Unlock()
+	a.RegisterComponent(NewHubComponent(h), opts...)
+}
@@ -0,0 +1,95 @@
+package appengine
+
+import (
+	"context"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"sync"
+)
+
+// 定时任务组件
+func NewCronComponent(taskFunc func() []app.CronTask) Component {
+	return newCronComponent("", taskFunc)
+}
+
+// 应用的定时任务组件，停止后释放 owner 占用的定时任务
+func newCronComponent(owner string, taskFunc func() []app.CronTask) Component {
+	return &FuncComponent{
+		ComponentName: "crontab",
+		Phase:         PhaseStopCron,
+		StartFunc: func(ctx context.Context) error {
+			return app.StartCronTask(taskFunc())
+		},
+		StopFunc: func(ctx context.Context) error {
+			err := app.StopCronTaskContext(ctx)
+			app.ReleaseCron(owner)
+			return err
+		},
+	}
+}
+
+// 自定义程序组件
+// program 收到 endChan 信号后退出，并调用 wg.Done()
+type programComponent struct {
+	name    string
+	program func(*sync.WaitGroup, chan struct{})
+	endChan chan struct{}
+	done    chan struct{}
+	started bool
+}
+
+func NewProgramComponent(name string, program func(*sync.WaitGroup, chan struct{})) Component {
+	return &programComponent{
+		name:    name,
+		program: program,
+	}
+}
+
+func (p *programComponent) Name() string {
+	return p.name
+}
+
+func (p *programComponent) Init(ctx context.Context) error {
+	p.endChan = make(chan struct{})
+	p.done = make(chan struct{})
+	return nil
+}
+
+func (p *programComponent) Start(ctx context.Context) error {
+	p.started = true
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go p.program(wg, p.endChan)
+	go func() {
+		wg.Wait()
+		close(p.done)
+	}()
+	return nil
+}
+
+func (p *programComponent) Stop(ctx context.Context) error {
+	close(p.endChan)
+	if !p.started {
+		return nil
+	}
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *programComponent) Health(ctx context.Context) error {
+	return nil
+}
+
+// 自定义程序（MQ消费者等）在停止消费阶段退出
+func (p *programComponent) StopPhase() ShutdownPhase {
+	return PhaseStopConsumers
+}
+
+// 程序是否已经退出
+func (p *programComponent) Done() <-chan struct{} {
+	return p.done
+}
@@ -0,0 +1,257 @@
+package appengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Shopify/sarama"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/implements/kafka"
+	"github.com/mutou1225/go-frame/implements/rabbitmq"
+	"github.com/mutou1225/go-frame/logger"
+	"github.com/streadway/amqp"
+	"log"
+	"sync"
+	"time"
+)
+
+/*
+  后台任务：MQ消费者、Kafka消费者、自定义 func(ctx) 任务
+  以组件方式注册，和http服务在同一个进程中运行，在 PhaseStopConsumers 阶段统一退出
+  任务出错或 panic 后按退避时间重启，ctx 结束后不再重启
+*/
+
+const (
+	defaultRestartMin = time.Second
+	defaultRestartMax = time.Minute
+)
+
+// 后台任务，ctx 结束时需要尽快返回
+// 返回nil表示任务正常结束，不再重启
+type Worker func(ctx context.Context) error
+
+type WorkerOption func(*workerComponent)
+
+// 重启的退避时间，从 min 开始每次翻倍，最大 max
+func WithRestartBackoff(min, max time.Duration) WorkerOption {
+	return func(w *workerComponent) {
+		w.backoffMin = min
+		w.backoffMax = max
+	}
+}
+
+// 出错后不重启
+func NoRestart() WorkerOption {
+	return func(w *workerComponent) {
+		w.noRestart = true
+	}
+}
+
+type workerComponent struct {
+	name       string
+	worker     Worker
+	backoffMin time.Duration
+	backoffMax time.Duration
+	noRestart  bool
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func newWorkerComponent(name string, worker Worker, opts ...WorkerOption) *workerComponent {
+	w := &workerComponent{
+		name:       name,
+		worker:     worker,
+		backoffMin: defaultRestartMin,
+		backoffMax: defaultRestartMax,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+func (w *workerComponent) Name() string {
+	return w.name
+}
+
+func (w *workerComponent) Init(ctx context.Context) error {
+	return nil
+}
+
+func (w *workerComponent) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	w.done = make(chan struct{})
+	go w.supervise(runCtx)
+	return nil
+}
+
+func (w *workerComponent) Stop(ctx context.Context) error {
+	if w.cancel == nil {
+		return nil
+	}
+	w.cancel()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 任务一直重启时不影响就绪，只在日志中体现
+func (w *workerComponent) Health(ctx context.Context) error {
+	return nil
+}
+
+func (w *workerComponent) StopPhase() ShutdownPhase {
+	return PhaseStopConsumers
+}
+
+// 运行任务，出错后按退避时间重启
+func (w *workerComponent) supervise(ctx context.Context) {
+	defer close(w.done)
+
+	backoff := w.backoffMin
+	restarts := 0
+	for {
+		now := time.Now()
+		err := w.runOnce(ctx)
+		if ctx.Err() != nil {
+			logger.PrintInfo("Worker[%s] exit", w.name)
+			return
+		}
+		if err == nil {
+			logger.PrintInfo("Worker[%s] finished", w.name)
+			return
+		}
+
+		if w.noRestart {
+			logger.PrintError("Worker[%s] Err: %s", w.name, err.Error())
+			log.Printf("Worker[%s] Err: %s", w.name, err.Error())
+			return
+		}
+
+		// 运行时间超过最大退避时间，认为已经恢复过
+		if time.Since(now) > w.backoffMax {
+			backoff = w.backoffMin
+		}
+		restarts++
+		logger.PrintError("Worker[%s] Err: %s, restart[%d] after %v", w.name, err.Error(), restarts, backoff)
+		log.Printf("Worker[%s] Err: %s, restart[%d] after %v", w.name, err.Error(), restarts, backoff)
+
+		select {
+		case <-ctx.Done():
+			logger.PrintInfo("Worker[%s] exit", w.name)
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > w.backoffMax {
+			backoff = w.backoffMax
+		}
+	}
+}
+
+func (w *workerComponent) runOnce(ctx context.Context) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %s", errcode.GetSystemPanic(p))
+		}
+	}()
+
+	return w.worker(ctx)
+}
+
+// 注册后台任务
+func RegisterWorker(name string, worker Worker, opts ...WorkerOption) {
+	application.RegisterWorker(name, worker, opts...)
+}
+
+// 注册后台任务
+func (a *App) RegisterWorker(name string, worker Worker, opts ...WorkerOption) {
+	a.RegisterComponent(newWorkerComponent(name, worker, opts...))
+}
+
+// RabbitMQ 消费者
+type MQConsumer struct {
+	Name string
+	// 获取连接，如 RabbitMQComponent.Client
+	Client     func() (*rabbitmq.RabbitMQ, error)
+	Receiver   rabbitmq.MQConsume
+	ProcessCnt int
+	// 消费前的准备：创建exchange、queue并绑定，可以为 nil
+	Setup func(client *rabbitmq.RabbitMQ, channel *amqp.Channel) error
+}
+
+// 注册 RabbitMQ 消费者，channel 断开后重新连接消费
+func RegisterMQConsumer(c MQConsumer, opts ...WorkerOption) {
+	application.RegisterMQConsumer(c, opts...)
+}
+
+// 注册 RabbitMQ 消费者，channel 断开后重新连接消费
+func (a *App) RegisterMQConsumer(c MQConsumer, opts ...WorkerOption) {
+	a.RegisterWorker(c.Name, func(ctx context.Context) error {
+		if c.Client == nil || c.Receiver == nil {
+			return errors.New("mq consumer client or receiver nil")
+		}
+
+		client, err := c.Client()
+		if err != nil {
+			return err
+		}
+
+		channel, err := client.ConnAndChannel()
+		if err != nil {
+			return err
+		}
+		defer channel.Close()
+
+		if c.Setup != nil {
+			if err := c.Setup(client, channel); err != nil {
+				return err
+			}
+		}
+
+		processCnt := c.ProcessCnt
+		if processCnt <= 0 {
+			processCnt = 1
+		}
+		return client.ConsumeQueueContext(ctx, channel, c.Receiver, processCnt)
+	}, opts...)
+}
+
+// 注册 Kafka 消费组消费者
+func RegisterKafkaConsumer(name, host, groupID string, handler sarama.ConsumerGroupHandler, topics []string, opts ...WorkerOption) {
+	application.RegisterKafkaConsumer(name, host, groupID, handler, topics, opts...)
+}
+
+// 注册 Kafka 消费组消费者
+func (a *App) RegisterKafkaConsumer(name, host, groupID string, handler sarama.ConsumerGroupHandler, topics []string, opts ...WorkerOption) {
+	a.RegisterWorker(name, func(ctx context.Context) error {
+		return kafka.ConsumeGroupContext(ctx, host, groupID, handler, topics...)
+	}, opts...)
+}
+
+// 注册自定义程序，program 收到 endChan 信号后退出，并调用 wg.Done()
+func RegisterProgram(program ...func(*sync.WaitGroup, chan struct{})) {
+	application.RegisterProgram(program...)
+}
+
+// 注册自定义程序，program 收到 endChan 信号后退出，并调用 wg.Done()
+func (a *App) RegisterProgram(program ...func(*sync.WaitGroup, chan struct{})) {
+	a.registerPrograms(program...)
+}
+
+func (a *App) registerPrograms(program ...func(*sync.WaitGroup, chan struct{})) []*programComponent {
+	programList := make([]*programComponent, 0, len(program))
+	for _, cfunc := range program {
+		a.programSeq++
+		p := NewProgramComponent(fmt.Sprintf("program-%d", a.programSeq), cfunc).(*programComponent)
+		a.RegisterComponent(p)
+		programList = append(programList, p)
+	}
+	return programList
+}
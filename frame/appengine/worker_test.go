@@ -0,0 +1,37 @@
+package appengine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_WorkerRestart(t *testing.T) {
+	var runs int32
+	w := newWorkerComponent("worker", func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 2 {
+			panic("worker panic")
+		}
+		if atomic.LoadInt32(&runs) < 4 {
+			return errors.New("worker err")
+		}
+		<-ctx.Done()
+		return nil
+	}, WithRestartBackoff(10*time.Millisecond, 20*time.Millisecond))
+
+	if err := w.Start(context.Background()); err != nil {
+		t.Fatalf("Start() Err: %s", err.Error())
+	}
+	time.Sleep(200 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n != 4 {
+		t.Errorf("runs: %d, want 4", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.Stop(ctx); err != nil {
+		t.Errorf("Stop() Err: %s", err.Error())
+	}
+}
@@ -45,6 +45,7 @@ var (
 	ERROR_LOST_SIGN_DATA           = AppError{ErrorCode: 5007, ErrorInfo: "没有签名数据"}
 	RetCode_ERR_CACHE_INIT         = AppError{ErrorCode: 5008, ErrorInfo: "redis初始化失败"}
 	ERROR_LIMINT                   = AppError{ErrorCode: 5009, ErrorInfo: "请求过快"}
+	ERROR_MAINTENANCE              = AppError{ErrorCode: 5010, ErrorInfo: "服务维护中"}
 )
 
 // 自定义失败：错误码不变，在原错误信息的基础上，增加自定义错误信息
@@ -0,0 +1,452 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/internal/jobrunner"
+	"github.com/mutou1225/go-frame/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"html"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/*
+  异步导出：接口中添加导出任务，立即返回下载地址和进度页面，任务在后台逐行写入 xlsx 或 csv 文件
+  导出目录中每个任务有：
+  <id>.xlsx / <id>.csv   导出的文件，写入时为 .part，完成后改名，未完成的文件不能下载
+  <id>.json              进度（Progress），Exporter.Get 读取
+  <id>.html              进度页面，未完成时自动刷新，完成后显示下载链接
+  目录由 nginx/CDN 提供下载（默认 app.EXPORT_IMPORT_FILE_PATH、app.DOWNLOAD_URL_PATH）
+  任务在进程内执行（队列、并发、退出和清理见 internal/jobrunner），进程退出时未完成的任务标记为失败，需要重新导出
+  超过保留时间（默认 72 小时）的任务文件定时删除，只删除有进度记录的文件
+  指标：goframe_export_jobs_total{status}、goframe_export_rows_total
+*/
+
+type Format string
+
+const (
+	FormatXlsx Format = "xlsx"
+	FormatCSV  Format = "csv"
+)
+
+// 任务状态
+const (
+	StatusPending = "pending"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+const (
+	defaultWorkers          = 2
+	defaultQueueSize        = 100
+	defaultRetention        = 72 * time.Hour
+	defaultCleanupInterval  = time.Hour
+	defaultProgressInterval = time.Second
+)
+
+var (
+	// 等待中的任务太多
+	ErrQueueFull = errors.New("export: queue full")
+	// 已经停止
+	ErrStopped = errors.New("export: stopped")
+	// 任务不存在
+	ErrNotFound = errors.New("export: job not found")
+
+	jobsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_export_jobs_total",
+		Help: "Export jobs by status.",
+	}, []string{"status"})
+	rowsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "goframe_export_rows_total",
+		Help: "Rows written by export jobs.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(jobsCounter, rowsCounter)
+}
+
+// 导出任务
+type Job struct {
+	// 文件名前缀，只保留字母、数字、_、-
+	Name string
+	// 默认 xlsx
+	Format Format
+	// 行结构体（值或指针），export 标签为列名
+	Row    interface{}
+	Source Opener
+}
+
+// 任务进度
+type Progress struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Format Format `json:"format"`
+	Status string `json:"status"`
+	// 已导出的行数、总行数（数据源不能返回总数时为 0）
+	Rows  int64 `json:"rows"`
+	Total int64 `json:"total"`
+	// 下载地址，完成后可以下载
+	URL string `json:"url"`
+	// 进度页面
+	StatusURL string    `json:"statusUrl"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Option func(*Exporter)
+
+// 导出目录
+func Dir(dir string) Option {
+	return func(e *Exporter) {
+		e.conf.Dir = dir
+	}
+}
+
+// 导出目录对应的下载地址
+func URLPrefix(url string) Option {
+	return func(e *Exporter) {
+		e.urlPrefix = url
+	}
+}
+
+// 同时执行的任务数
+func Workers(n int) Option {
+	return func(e *Exporter) {
+		e.conf.Workers = n
+	}
+}
+
+// 等待中的任务数上限
+func QueueSize(n int) Option {
+	return func(e *Exporter) {
+		e.conf.QueueSize = n
+	}
+}
+
+// 任务文件的保留时间，为 0 时不删除
+func Retention(d time.Duration) Option {
+	return func(e *Exporter) {
+		e.conf.Retention = d
+	}
+}
+
+// 检查过期文件的间隔
+func CleanupInterval(d time.Duration) Option {
+	return func(e *Exporter) {
+		e.conf.CleanupInterval = d
+	}
+}
+
+// 更新进度的间隔
+func ProgressInterval(d time.Duration) Option {
+	return func(e *Exporter) {
+		e.progressInterval = d
+	}
+}
+
+// 进度更新时调用（如推送到 stream.Hub），在 Enqueue 和执行任务的协程中同步调用，不能阻塞
+func OnProgress(f func(Progress)) Option {
+	return func(e *Exporter) {
+		e.onProgress = f
+	}
+}
+
+type task struct {
+	e        *Exporter
+	job      Job
+	columns  []column
+	rowType  reflect.Type
+	progress Progress
+}
+
+type Exporter struct {
+	conf             jobrunner.Config
+	urlPrefix        string
+	progressInterval time.Duration
+	onProgress       func(Progress)
+
+	runner *jobrunner.Runner
+}
+
+// 创建导出，需要 Start 后才开始执行任务
+func New(opts ...Option) *Exporter {
+	e := &Exporter{
+		conf: jobrunner.Config{
+			Name:            "Export",
+			Dir:             app.EXPORT_IMPORT_FILE_PATH,
+			Workers:         defaultWorkers,
+			QueueSize:       defaultQueueSize,
+			Retention:       defaultRetention,
+			CleanupInterval: defaultCleanupInterval,
+			ErrQueueFull:    ErrQueueFull,
+			ErrStopped:      ErrStopped,
+			ErrNotFound:     ErrNotFound,
+			StopError:       errors.New("服务退出，请重新导出"),
+		},
+		urlPrefix:        app.DOWNLOAD_URL_PATH,
+		progressInterval: defaultProgressInterval,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if !strings.HasSuffix(e.urlPrefix, "/") {
+		e.urlPrefix += "/"
+	}
+	e.conf.Remove = e.remove
+	e.runner = jobrunner.New(e.conf)
+	return e
+}
+
+// 添加导出任务，返回的进度中有下载地址和进度页面
+func (e *Exporter) Enqueue(job Job) (*Progress, error) {
+	if job.Row == nil || job.Source == nil {
+		return nil, errors.New("export: job row or source nil")
+	}
+	if job.Format == "" {
+		job.Format = FormatXlsx
+	}
+	if job.Format != FormatXlsx && job.Format != FormatCSV {
+		return nil, fmt.Errorf("export: unknown format %s", job.Format)
+	}
+	columns, err := parseColumns(reflect.TypeOf(job.Row))
+	if err != nil {
+		return nil, err
+	}
+	rowType := reflect.TypeOf(job.Row)
+	for rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+
+	name, id := e.runner.NewID(job.Name, "export")
+	now := time.Now()
+	t := &task{e: e, job: job, columns: columns, rowType: rowType, progress: Progress{
+		Id:        id,
+		Name:      name,
+		Format:    job.Format,
+		Status:    StatusPending,
+		URL:       e.urlPrefix + id + "." + string(job.Format),
+		StatusURL: e.urlPrefix + id + ".html",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+
+	if err := os.MkdirAll(e.conf.Dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := e.writeProgress(&t.progress); err != nil {
+		return nil, err
+	}
+
+	// 放入队列后进度由执行的协程修改
+	p := t.progress
+	if err := e.runner.Enqueue(t); err != nil {
+		e.removeFiles(id, job.Format)
+		return nil, err
+	}
+
+	jobsCounter.WithLabelValues(StatusPending).Inc()
+	logger.PrintInfo("Export job[%s] enqueued, format[%s]", id, job.Format)
+	return &p, nil
+}
+
+// 读取任务进度，可以读取其他进程添加的任务（共用导出目录）
+func (e *Exporter) Get(id string) (*Progress, error) {
+	p := &Progress{}
+	if err := e.runner.ReadProgress(id, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// 启动执行任务和清理过期文件的协程
+func (e *Exporter) Start() {
+	e.runner.Start()
+}
+
+// 不再接收任务，等待执行中的任务完成，ctx 结束时取消执行中的任务；等待中的任务标记为失败
+func (e *Exporter) Stop(ctx context.Context) error {
+	return e.runner.Stop(ctx)
+}
+
+// 检查导出目录是否可用
+func (e *Exporter) Ping(ctx context.Context) error {
+	if err := e.runner.Ping(ctx); err != nil {
+		return fmt.Errorf("export: %s", err.Error())
+	}
+	return nil
+}
+
+func (t *task) ID() string {
+	return t.progress.Id
+}
+
+func (t *task) Run(ctx context.Context) error {
+	return t.e.export(ctx, t)
+}
+
+func (t *task) Finish(err error, cost time.Duration) {
+	if err != nil {
+		t.e.fail(t, err)
+		return
+	}
+
+	t.progress.Status = StatusDone
+	if err := t.e.writeProgress(&t.progress); err != nil {
+		logger.PrintError("Export job[%s] progress Err: %s", t.progress.Id, err.Error())
+	}
+	jobsCounter.WithLabelValues(StatusDone).Inc()
+	logger.PrintInfo("Export job[%s] done, rows[%d], cost[%.3fms]", t.progress.Id, t.progress.Rows,
+		float64(cost)/float64(time.Millisecond))
+}
+
+func (e *Exporter) fail(t *task, err error) {
+	t.progress.Status = StatusFailed
+	t.progress.Error = err.Error()
+	if werr := e.writeProgress(&t.progress); werr != nil {
+		logger.PrintError("Export job[%s] progress Err: %s", t.progress.Id, werr.Error())
+	}
+	jobsCounter.WithLabelValues(StatusFailed).Inc()
+	logger.PrintError("Export job[%s] failed, rows[%d] Err: %s", t.progress.Id, t.progress.Rows, err.Error())
+	log.Printf("Export job[%s] failed Err: %s", t.progress.Id, err.Error())
+}
+
+// 写入 .part 文件，完成后改名
+func (e *Exporter) export(ctx context.Context, t *task) (err error) {
+	t.progress.Status = StatusRunning
+	if err := e.writeProgress(&t.progress); err != nil {
+		return err
+	}
+
+	src, err := t.job.Source(ctx)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	totaler, _ := src.(Totaler)
+
+	file := filepath.Join(e.conf.Dir, t.progress.Id+"."+string(t.progress.Format))
+	part := file + ".part"
+	f, err := os.Create(part)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+		if err != nil {
+			os.Remove(part)
+		}
+	}()
+
+	w, err := newWriter(t.progress.Format, f)
+	if err != nil {
+		return err
+	}
+	if err := w.write(columnTitles(t.columns)); err != nil {
+		return err
+	}
+
+	cells := make([]cell, 0, len(t.columns))
+	last := time.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row := reflect.New(t.rowType)
+		ok, err := src.Next(ctx, row.Interface())
+		if err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+		if err := w.write(rowCells(t.columns, row, cells)); err != nil {
+			return err
+		}
+		t.progress.Rows++
+		rowsCounter.Inc()
+
+		if time.Since(last) >= e.progressInterval {
+			last = time.Now()
+			if totaler != nil {
+				t.progress.Total = totaler.Total()
+			}
+			if err := e.writeProgress(&t.progress); err != nil {
+				logger.PrintError("Export job[%s] progress Err: %s", t.progress.Id, err.Error())
+			}
+		}
+	}
+
+	if err := w.close(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		f = nil
+		return err
+	}
+	f = nil
+	if totaler != nil {
+		t.progress.Total = totaler.Total()
+	}
+	if t.progress.Total < t.progress.Rows {
+		t.progress.Total = t.progress.Rows
+	}
+	return os.Rename(part, file)
+}
+
+// 写入进度和进度页面
+func (e *Exporter) writeProgress(p *Progress) error {
+	p.UpdatedAt = time.Now()
+
+	var desc string
+	switch p.Status {
+	case StatusPending:
+		desc = "导出任务排队中..."
+	case StatusRunning:
+		desc = fmt.Sprintf("正在导出：已导出 %d 行", p.Rows)
+		if p.Total > 0 {
+			desc += fmt.Sprintf("，共 %d 行", p.Total)
+		}
+	case StatusDone:
+		desc = fmt.Sprintf(`导出完成，共 %d 行，<a href="%s">点击下载</a>`, p.Rows, html.EscapeString(p.URL))
+	default:
+		desc = "导出失败：" + html.EscapeString(p.Error)
+	}
+	reload := p.Status == StatusPending || p.Status == StatusRunning
+	if err := e.runner.WriteProgress(p.Id, p, desc, reload); err != nil {
+		return err
+	}
+	if e.onProgress != nil {
+		e.onProgress(*p)
+	}
+	return nil
+}
+
+func (e *Exporter) removeFiles(id string, format Format) {
+	e.runner.RemoveFiles(id+"."+string(format), id+"."+string(format)+".part", id+".html", id+".json")
+}
+
+// 删除过期任务的文件，进度中没有格式的不是导出任务
+func (e *Exporter) remove(id string) bool {
+	p, err := e.Get(id)
+	if err != nil || p.Format == "" {
+		return false
+	}
+	e.removeFiles(id, p.Format)
+	return true
+}
+
+// 删除更新时间超过保留时间的任务文件，返回删除的任务数；执行中的任务每次写入都会更新时间，不会被删除
+func (e *Exporter) Cleanup(now time.Time) int {
+	return e.runner.Cleanup(now)
+}
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBase struct {
+	Id int64 `export:"ID"`
+}
+
+type testRow struct {
+	testBase
+	Name    string     `export:"名称"`
+	Price   float64    `export:"价格"`
+	Created time.Time  `export:"创建时间,layout=2006-01-02"`
+	Updated *time.Time `export:"更新时间"`
+	Serial  int64      `export:"序列号"`
+	Remark  string
+}
+
+func Test_Writer(t *testing.T) {
+	columns, err := parseColumns(reflect.TypeOf(testRow{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := []string{}
+	for _, c := range columns {
+		titles = append(titles, c.title)
+	}
+	if strings.Join(titles, ",") != "ID,名称,价格,创建时间,更新时间,序列号" {
+		t.Errorf("columns: %v", titles)
+	}
+	if columnName(0) != "A" || columnName(25) != "Z" || columnName(26) != "AA" || columnName(701) != "ZZ" {
+		t.Errorf("columnName")
+	}
+
+	row := testRow{testBase{1}, "=cmd <a&b>", 9.9, time.Date(2021, 10, 1, 0, 0, 0, 0, time.Local), nil, 1234567890123456789, "x"}
+
+	// csv
+	var buf bytes.Buffer
+	w, _ := newWriter(FormatCSV, &buf)
+	_ = w.write(columnTitles(columns))
+	_ = w.write(rowCells(columns, reflect.ValueOf(&row), nil))
+	_ = w.close()
+	if want := "\xEF\xBB\xBFID,名称,价格,创建时间,更新时间,序列号\n1,'=cmd <a&b>,9.9,2021-10-01,,1234567890123456789\n"; buf.String() != want {
+		t.Errorf("csv: %q", buf.String())
+	}
+
+	// xlsx
+	buf.Reset()
+	w, _ = newWriter(FormatXlsx, &buf)
+	_ = w.write(columnTitles(columns))
+	_ = w.write(rowCells(columns, reflect.ValueOf(&row), nil))
+	_ = w.close()
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			data, _ := ioutil.ReadAll(r)
+			sheet = string(data)
+		}
+	}
+	for _, want := range []string{
+		`<row r="1"><c r="A1" t="inlineStr"><is><t xml:space="preserve">ID</t></is></c>`,
+		`<c r="A2"><v>1</v></c>`,
+		`<c r="B2" t="inlineStr"><is><t xml:space="preserve">=cmd &lt;a&amp;b&gt;</t></is></c>`,
+		`<c r="C2"><v>9.9</v></c>`,
+		`<c r="F2" t="inlineStr"><is><t xml:space="preserve">1234567890123456789</t></is></c>`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet want %s", want)
+		}
+	}
+	if strings.Contains(sheet, `r="E2"`) {
+		t.Error("nil cell written")
+	}
+}
+
+func Test_Exporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	e := New(Dir(dir), URLPrefix("http://cdn/download"), ProgressInterval(0), Retention(time.Hour))
+	rows := []*testRow{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	p, err := e.Enqueue(Job{Name: "商品/product", Format: FormatCSV, Row: &testRow{}, Source: Slice(rows)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != StatusPending || !strings.HasPrefix(p.URL, "http://cdn/download/product-") || !strings.HasSuffix(p.URL, ".csv") {
+		t.Errorf("enqueue: %+v", p)
+	}
+	if _, err := e.Enqueue(Job{Row: struct{ A int }{}, Source: Slice(rows)}); err == nil {
+		t.Error("row without export column")
+	}
+
+	e.Start()
+	var got *Progress
+	for i := 0; i < 100; i++ {
+		if got, err = e.Get(p.Id); err == nil && got.Status == StatusDone {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got == nil || got.Status != StatusDone || got.Rows != 3 || got.Total != 3 {
+		t.Fatalf("progress: %+v %v", got, err)
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(dir, p.Id+".csv"))
+	if strings.Count(string(data), "\n") != 4 {
+		t.Errorf("csv: %s", data)
+	}
+	if page, _ := ioutil.ReadFile(filepath.Join(dir, p.Id+".html")); !strings.Contains(string(page), p.URL) {
+		t.Errorf("status page: %s", page)
+	}
+	if _, err := e.Get("../" + p.Id); err != ErrNotFound {
+		t.Errorf("Get invalid id: %v", err)
+	}
+
+	// 过期文件
+	if n := e.Cleanup(time.Now()); n != 0 {
+		t.Errorf("cleanup fresh: %d", n)
+	}
+	_ = ioutil.WriteFile(filepath.Join(dir, "other.json"), []byte("{}"), 0644)
+	if n := e.Cleanup(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Errorf("cleanup: %d", n)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || filepath.Base(files[0]) != "other.json" {
+		t.Errorf("files after cleanup: %v", files)
+	}
+
+	// 退出后不再接收任务
+	if err := e.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Enqueue(Job{Row: testRow{}, Source: Slice(rows)}); err != ErrStopped {
+		t.Errorf("enqueue after stop: %v", err)
+	}
+}
+
+func Test_ExporterStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 未启动时等待中的任务在退出时标记为失败
+	e := New(Dir(dir), QueueSize(1))
+	p, err := e.Enqueue(Job{Row: testRow{}, Source: Slice([]testRow{{}})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Enqueue(Job{Row: testRow{}, Source: Slice([]testRow{{}})}); err != ErrQueueFull {
+		t.Errorf("queue full: %v", err)
+	}
+	_ = e.Stop(context.Background())
+	if got, _ := e.Get(p.Id); got == nil || got.Status != StatusFailed || got.Error == "" {
+		t.Errorf("pending after stop: %+v", got)
+	}
+}
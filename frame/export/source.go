@@ -0,0 +1,198 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/elastic/go-elasticsearch/v6"
+	"github.com/mutou1225/go-frame/frame/paging"
+	"github.com/qiniu/qmgo"
+	"gorm.io/gorm"
+	"reflect"
+)
+
+// 数据源，逐行读取，不把全部数据读到内存
+type Source interface {
+	// 读取下一行到 row（行结构体的指针），没有更多数据时返回 false
+	Next(ctx context.Context, row interface{}) (bool, error)
+	Close() error
+}
+
+// 能返回总行数的数据源，用于显示进度
+type Totaler interface {
+	Total() int64
+}
+
+// 在导出任务执行时打开数据源，ctx 在应用退出时结束
+type Opener func(ctx context.Context) (Source, error)
+
+type gormSource struct {
+	db    *gorm.DB
+	rows  *sql.Rows
+	total int64
+}
+
+// MySQL：db 为已经加上条件、排序的查询，使用数据库游标逐行读取
+func Gorm(db *gorm.DB) Opener {
+	return func(ctx context.Context) (Source, error) {
+		db := db.WithContext(ctx)
+		s := &gormSource{db: db}
+		if err := db.Session(&gorm.Session{}).Count(&s.total).Error; err != nil {
+			return nil, err
+		}
+		rows, err := db.Rows()
+		if err != nil {
+			return nil, err
+		}
+		s.rows = rows
+		return s, nil
+	}
+}
+
+func (s *gormSource) Next(ctx context.Context, row interface{}) (bool, error) {
+	if !s.rows.Next() {
+		return false, s.rows.Err()
+	}
+	return true, s.db.ScanRows(s.rows, row)
+}
+
+func (s *gormSource) Close() error {
+	return s.rows.Close()
+}
+
+func (s *gormSource) Total() int64 {
+	return s.total
+}
+
+type qmgoSource struct {
+	cursor qmgo.CursorI
+	total  int64
+}
+
+// Mongo：find 按 ctx 创建查询（加上条件、排序、字段），使用游标逐行读取
+// export.Qmgo(func(ctx context.Context) qmgo.QueryI { return coll.Find(ctx, filter).Sort("-Fid") })
+func Qmgo(find func(ctx context.Context) qmgo.QueryI) Opener {
+	return func(ctx context.Context) (Source, error) {
+		total, err := find(ctx).Count()
+		if err != nil {
+			return nil, err
+		}
+		cursor := find(ctx).Cursor()
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+		return &qmgoSource{cursor: cursor, total: total}, nil
+	}
+}
+
+func (s *qmgoSource) Next(ctx context.Context, row interface{}) (bool, error) {
+	if !s.cursor.Next(row) {
+		return false, s.cursor.Err()
+	}
+	return true, nil
+}
+
+func (s *qmgoSource) Close() error {
+	return s.cursor.Close()
+}
+
+func (s *qmgoSource) Total() int64 {
+	return s.total
+}
+
+type esSource struct {
+	client *elasticsearch.Client
+	index  string
+	body   map[string]interface{}
+	batch  int
+
+	list   reflect.Value
+	pos    int
+	cursor string
+	more   bool
+	total  int64
+}
+
+// Elasticsearch：使用 search_after 分批读取，body 中需要有 sort（最后一个排序字段的值唯一），batch 为每批的数量
+func ES(client *elasticsearch.Client, index string, body map[string]interface{}, batch int) Opener {
+	return func(ctx context.Context) (Source, error) {
+		if _, ok := body["sort"]; !ok {
+			return nil, errors.New("export: es source needs sort")
+		}
+		if batch <= 0 {
+			batch = paging.MaxPageSize
+		}
+		return &esSource{client: client, index: index, body: body, batch: batch, more: true}, nil
+	}
+}
+
+func (s *esSource) Next(ctx context.Context, row interface{}) (bool, error) {
+	if !s.list.IsValid() || s.pos >= s.list.Elem().Len() {
+		if !s.more {
+			return false, nil
+		}
+
+		// 按行的类型读取下一批
+		s.list = reflect.New(reflect.SliceOf(reflect.TypeOf(row).Elem()))
+		s.pos = 0
+		req := paging.Request{PageSize: s.batch, Cursor: s.cursor}
+		page, err := paging.ESSearchAfter(ctx, s.client, s.index, s.body, req, s.list.Interface())
+		if err != nil {
+			return false, err
+		}
+		s.cursor, s.more, s.total = page.NextCursor, page.HasMore, page.Total
+		if s.list.Elem().Len() == 0 {
+			return false, nil
+		}
+	}
+
+	reflect.ValueOf(row).Elem().Set(s.list.Elem().Index(s.pos))
+	s.pos++
+	return true, nil
+}
+
+func (s *esSource) Close() error {
+	return nil
+}
+
+func (s *esSource) Total() int64 {
+	return s.total
+}
+
+type sliceSource struct {
+	list reflect.Value
+	pos  int
+}
+
+// 内存中的数据，list 为行结构体的切片（或切片指针）
+func Slice(list interface{}) Opener {
+	return func(ctx context.Context) (Source, error) {
+		v := reflect.Indirect(reflect.ValueOf(list))
+		if v.Kind() != reflect.Slice {
+			return nil, errors.New("export: list must be a slice")
+		}
+		return &sliceSource{list: v}, nil
+	}
+}
+
+func (s *sliceSource) Next(ctx context.Context, row interface{}) (bool, error) {
+	if s.pos >= s.list.Len() {
+		return false, nil
+	}
+	dst := reflect.ValueOf(row).Elem()
+	src := s.list.Index(s.pos)
+	if src.Kind() == reflect.Ptr && dst.Kind() != reflect.Ptr {
+		src = src.Elem()
+	}
+	dst.Set(src)
+	s.pos++
+	return true, nil
+}
+
+func (s *sliceSource) Close() error {
+	return nil
+}
+
+func (s *sliceSource) Total() int64 {
+	return int64(s.list.Len())
+}
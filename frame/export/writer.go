@@ -0,0 +1,356 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  列：行结构体中有 export 标签的字段，按字段顺序输出，嵌入的结构体展开
+  ProductName string    `export:"产品名称"`
+  CreatedAt   time.Time `export:"创建时间,layout=2006-01-02"`
+  时间默认格式 2006-01-02 15:04:05，零值为空；超过 15 位的整数按文本输出，避免 Excel 丢失精度
+  xlsx 直接按 OOXML 格式流式写入，不在内存中保存整个表格；csv 带 UTF-8 BOM，Excel 可以直接打开
+*/
+
+const (
+	defaultTimeLayout = "2006-01-02 15:04:05"
+
+	// xlsx 单个工作表的最大行数（包含表头）、单元格的最大字符数
+	xlsxMaxRows     = 1048576
+	xlsxMaxCellChar = 32767
+)
+
+// 超过 xlsx 的最大行数，需要分开导出或使用 csv
+var ErrTooManyRows = errors.New("export: too many rows for xlsx")
+
+type column struct {
+	title  string
+	index  []int
+	layout string
+}
+
+type cell struct {
+	value string
+	// 数字单元格
+	num bool
+}
+
+// 解析行结构体的列
+func parseColumns(t reflect.Type) ([]column, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("export: row %s not struct", t)
+	}
+
+	var columns []column
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(append([]int{}, index...), i)
+			tag, ok := f.Tag.Lookup("export")
+			if !ok {
+				ft := f.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if f.Anonymous && ft.Kind() == reflect.Struct {
+					walk(ft, idx)
+				}
+				continue
+			}
+			if tag == "-" || f.PkgPath != "" {
+				continue
+			}
+
+			parts := strings.Split(tag, ",")
+			col := column{title: parts[0], index: idx, layout: defaultTimeLayout}
+			if col.title == "" {
+				col.title = f.Name
+			}
+			for _, opt := range parts[1:] {
+				if strings.HasPrefix(opt, "layout=") {
+					col.layout = strings.TrimPrefix(opt, "layout=")
+				}
+			}
+			columns = append(columns, col)
+		}
+	}
+	walk(t, nil)
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("export: row %s has no export column", t)
+	}
+	return columns, nil
+}
+
+func columnTitles(columns []column) []cell {
+	cells := make([]cell, 0, len(columns))
+	for _, c := range columns {
+		cells = append(cells, cell{value: c.title})
+	}
+	return cells
+}
+
+// 行结构体转换为单元格
+func rowCells(columns []column, row reflect.Value, cells []cell) []cell {
+	row = reflect.Indirect(row)
+	cells = cells[:0]
+	for _, c := range columns {
+		cells = append(cells, cellOf(fieldByIndex(row, c.index), c.layout))
+	}
+	return cells
+}
+
+// 嵌入的结构体指针为 nil 时返回无效值
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}
+				}
+				v = v.Elem()
+			}
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func cellOf(v reflect.Value, layout string) cell {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return cell{}
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return cell{}
+	}
+
+	switch x := v.Interface().(type) {
+	case time.Time:
+		if x.IsZero() {
+			return cell{}
+		}
+		return cell{value: x.Format(layout)}
+	case fmt.Stringer:
+		return cell{value: x.String()}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return cell{value: v.String()}
+	case reflect.Bool:
+		return cell{value: strconv.FormatBool(v.Bool())}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := v.Int()
+		return cell{value: strconv.FormatInt(n, 10), num: n <= 1e15 && n >= -1e15}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := v.Uint()
+		return cell{value: strconv.FormatUint(n, 10), num: n <= 1e15}
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return cell{value: strconv.FormatFloat(f, 'f', -1, 64), num: !math.IsNaN(f) && !math.IsInf(f, 0)}
+	}
+	return cell{value: fmt.Sprint(v.Interface())}
+}
+
+type writer interface {
+	write(cells []cell) error
+	// 写入结尾，不关闭底层的文件
+	close() error
+}
+
+func newWriter(format Format, w io.Writer) (writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatXlsx:
+		return newXlsxWriter(w)
+	}
+	return nil, fmt.Errorf("export: unknown format %s", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+	s []string
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, "\xEF\xBB\xBF"); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: csv.NewWriter(w)}, nil
+}
+
+// 文本以 = + - @ 开头时加 '，避免 Excel 当作公式执行
+func (c *csvWriter) write(cells []cell) error {
+	c.s = c.s[:0]
+	for _, v := range cells {
+		s := v.value
+		if !v.num && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+			s = "'" + s
+		}
+		c.s = append(c.s, s)
+	}
+	return c.w.Write(c.s)
+}
+
+func (c *csvWriter) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetHead = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetTail = `</sheetData></worksheet>`
+)
+
+type xlsxWriter struct {
+	zw  *zip.Writer
+	buf *bufio.Writer
+	row int
+}
+
+// 先写入固定的部分，工作表最后写入，写入时不需要回写
+func newXlsxWriter(w io.Writer) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, data string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.data); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{zw: zw, buf: bufio.NewWriterSize(sheet, 64*1024)}
+	if _, err := x.buf.WriteString(xlsxSheetHead); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) write(cells []cell) error {
+	if x.row >= xlsxMaxRows {
+		return ErrTooManyRows
+	}
+	x.row++
+
+	r := strconv.Itoa(x.row)
+	x.buf.WriteString(`<row r="` + r + `">`)
+	for i, c := range cells {
+		if c.value == "" {
+			continue
+		}
+		ref := columnName(i) + r
+		if c.num {
+			x.buf.WriteString(`<c r="` + ref + `"><v>` + c.value + `</v></c>`)
+			continue
+		}
+
+		s := c.value
+		if len(s) > xlsxMaxCellChar {
+			s = truncateRunes(s, xlsxMaxCellChar)
+		}
+		x.buf.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		if err := xml.EscapeText(x.buf, []byte(s)); err != nil {
+			return err
+		}
+		x.buf.WriteString(`</t></is></c>`)
+	}
+	_, err := x.buf.WriteString(`</row>`)
+	return err
+}
+
+func (x *xlsxWriter) close() error {
+	if _, err := x.buf.WriteString(xlsxSheetTail); err != nil {
+		return err
+	}
+	if err := x.buf.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// 列号转换为 A、B ... Z、AA、AB ...
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func truncateRunes(s string, n int) string {
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos]
+		}
+		i++
+	}
+	return s
+}
+
+// 按行写入文本的表格，用于列不固定的文件（如导入的错误报告）
+type SheetWriter struct {
+	w     writer
+	cells []cell
+}
+
+func NewSheetWriter(format Format, w io.Writer) (*SheetWriter, error) {
+	fw, err := newWriter(format, w)
+	if err != nil {
+		return nil, err
+	}
+	return &SheetWriter{w: fw}, nil
+}
+
+func (s *SheetWriter) WriteRow(values ...string) error {
+	s.cells = s.cells[:0]
+	for _, v := range values {
+		s.cells = append(s.cells, cell{value: v})
+	}
+	return s.w.write(s.cells)
+}
+
+// 写入结尾，不关闭底层的文件
+func (s *SheetWriter) Close() error {
+	return s.w.close()
+}
@@ -0,0 +1,341 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+  健康检查
+  Liveness：进程是否存活，只有 Critical 的检查失败才认为不存活
+  Readiness：是否可以接收流量，任意检查失败、应用未启动完成或正在退出时都为不可用
+  检查并发执行，每个检查有独立超时，结果缓存 cacheTTL 时间，避免探针频繁访问依赖
+*/
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+
+	defaultCheckTimeout = 3 * time.Second
+	defaultCacheTTL     = 5 * time.Second
+)
+
+// 检查函数，返回nil表示健康
+type Checker func(ctx context.Context) error
+
+type Option func(*checkerEntry)
+
+// 单个检查的超时时间
+func WithTimeout(d time.Duration) Option {
+	return func(e *checkerEntry) {
+		e.timeout = d
+	}
+}
+
+// 检查失败时 Liveness 也失败
+func Critical() Option {
+	return func(e *checkerEntry) {
+		e.critical = true
+	}
+}
+
+// 单个检查的结果
+type CheckResult struct {
+	Status    string  `json:"status"`
+	Error     string  `json:"error,omitempty"`
+	Critical  bool    `json:"critical"`
+	CostMs    float64 `json:"costMs"`
+	CheckTime string  `json:"checkTime"`
+	checkAt   time.Time
+}
+
+// 检查报告
+type Report struct {
+	Status       string                 `json:"status"`
+	Ready        bool                   `json:"ready"`
+	ShuttingDown bool                   `json:"shuttingDown"`
+	Maintenance  bool                   `json:"maintenance"`
+	Checks       map[string]CheckResult `json:"checks"`
+}
+
+type checkerEntry struct {
+	name     string
+	check    Checker
+	timeout  time.Duration
+	critical bool
+	mutex    sync.Mutex
+	result   CheckResult
+}
+
+// 默认检查注册表，包级函数使用
+var defaultRegistry = NewRegistry()
+
+// 检查注册表，每个应用实例一个
+type Registry struct {
+	checkers      map[string]*checkerEntry
+	checkersMutex sync.RWMutex
+	cacheTTL      time.Duration
+	ready         int32
+	shuttingDown  int32
+	// 维护模式：业务接口返回 maintenanceErr，健康检查和运维接口不受影响
+	maintenance      int32
+	maintenanceErr   errcode.AppError
+	maintenanceMutex sync.RWMutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		checkers: make(map[string]*checkerEntry),
+		cacheTTL: defaultCacheTTL,
+	}
+}
+
+// 默认检查注册表
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// 注册检查，同名检查会被替换
+func Register(name string, check Checker, opts ...Option) {
+	defaultRegistry.Register(name, check, opts...)
+}
+
+// 取消检查
+func Unregister(name string) {
+	defaultRegistry.Unregister(name)
+}
+
+// 设置检查结果的缓存时间，0表示不缓存
+func SetCacheTTL(d time.Duration) {
+	defaultRegistry.SetCacheTTL(d)
+}
+
+// 应用启动完成后设置为 true
+func SetReady(r bool) {
+	defaultRegistry.SetReady(r)
+}
+
+// 应用开始退出，Readiness 变为不可用
+func SetShuttingDown() {
+	defaultRegistry.SetShuttingDown()
+}
+
+func IsShuttingDown() bool {
+	return defaultRegistry.IsShuttingDown()
+}
+
+func IsReady() bool {
+	return defaultRegistry.IsReady()
+}
+
+// 开启或关闭维护模式
+func SetMaintenance(on bool, err errcode.AppError) {
+	defaultRegistry.SetMaintenance(on, err)
+}
+
+// 是否在维护模式，以及业务接口返回的错误
+func Maintenance() (bool, errcode.AppError) {
+	return defaultRegistry.Maintenance()
+}
+
+// 存活检查，返回报告和是否存活
+func Liveness(ctx context.Context) (Report, bool) {
+	return defaultRegistry.Liveness(ctx)
+}
+
+// 就绪检查，返回报告和是否就绪
+func Readiness(ctx context.Context) (Report, bool) {
+	return defaultRegistry.Readiness(ctx)
+}
+
+// 注册检查，同名检查会被替换
+func (r *Registry) Register(name string, check Checker, opts ...Option) {
+	entry := &checkerEntry{
+		name:    name,
+		check:   check,
+		timeout: defaultCheckTimeout,
+	}
+	for _, opt := range opts {
+		opt(entry)
+	}
+
+	r.checkersMutex.Lock()
+	r.checkers[name] = entry
+	r.checkersMutex.Unlock()
+}
+
+// 取消检查
+func (r *Registry) Unregister(name string) {
+	r.checkersMutex.Lock()
+	delete(r.checkers, name)
+	r.checkersMutex.Unlock()
+}
+
+// 设置检查结果的缓存时间，0表示不缓存
+func (r *Registry) SetCacheTTL(d time.Duration) {
+	r.checkersMutex.Lock()
+	r.cacheTTL = d
+	r.checkersMutex.Unlock()
+}
+
+// 应用启动完成后设置为 true
+func (r *Registry) SetReady(ready bool) {
+	if ready {
+		atomic.StoreInt32(&r.ready, 1)
+	} else {
+		atomic.StoreInt32(&r.ready, 0)
+	}
+}
+
+// 应用开始退出，Readiness 变为不可用
+func (r *Registry) SetShuttingDown() {
+	atomic.StoreInt32(&r.shuttingDown, 1)
+}
+
+func (r *Registry) IsShuttingDown() bool {
+	return atomic.LoadInt32(&r.shuttingDown) == 1
+}
+
+func (r *Registry) IsReady() bool {
+	return atomic.LoadInt32(&r.ready) == 1 && !r.IsShuttingDown()
+}
+
+// 开启或关闭维护模式
+func (r *Registry) SetMaintenance(on bool, err errcode.AppError) {
+	r.maintenanceMutex.Lock()
+	r.maintenanceErr = err
+	r.maintenanceMutex.Unlock()
+
+	if on {
+		atomic.StoreInt32(&r.maintenance, 1)
+	} else {
+		atomic.StoreInt32(&r.maintenance, 0)
+	}
+}
+
+// 是否在维护模式，以及业务接口返回的错误
+func (r *Registry) Maintenance() (bool, errcode.AppError) {
+	if atomic.LoadInt32(&r.maintenance) == 0 {
+		return false, errcode.SUCCESS
+	}
+
+	r.maintenanceMutex.RLock()
+	defer r.maintenanceMutex.RUnlock()
+	return true, r.maintenanceErr
+}
+
+// 存活检查，返回报告和是否存活
+func (r *Registry) Liveness(ctx context.Context) (Report, bool) {
+	report := r.runChecks(ctx)
+	alive := true
+	for _, result := range report.Checks {
+		if result.Critical && result.Status != StatusOK {
+			alive = false
+		}
+	}
+
+	if !alive {
+		report.Status = StatusFail
+	}
+	return report, alive
+}
+
+// 就绪检查，返回报告和是否就绪
+func (r *Registry) Readiness(ctx context.Context) (Report, bool) {
+	report := r.runChecks(ctx)
+	isReady := report.Ready
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			isReady = false
+		}
+	}
+
+	if !isReady {
+		report.Status = StatusFail
+	}
+	return report, isReady
+}
+
+// 并发执行全部检查
+func (r *Registry) runChecks(ctx context.Context) Report {
+	r.checkersMutex.RLock()
+	entries := make([]*checkerEntry, 0, len(r.checkers))
+	for _, e := range r.checkers {
+		entries = append(entries, e)
+	}
+	ttl := r.cacheTTL
+	r.checkersMutex.RUnlock()
+
+	results := make([]CheckResult, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *checkerEntry) {
+			defer wg.Done()
+			results[i] = e.run(ctx, ttl)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:       StatusOK,
+		Ready:        r.IsReady(),
+		ShuttingDown: r.IsShuttingDown(),
+		Maintenance:  atomic.LoadInt32(&r.maintenance) == 1,
+		Checks:       make(map[string]CheckResult, len(entries)),
+	}
+	for i, e := range entries {
+		report.Checks[e.name] = results[i]
+	}
+	return report
+}
+
+// 执行检查，缓存未过期时直接返回缓存结果
+func (e *checkerEntry) run(ctx context.Context, ttl time.Duration) CheckResult {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.result.checkAt.IsZero() && time.Since(e.result.checkAt) < ttl {
+		return e.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	now := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				done <- fmt.Errorf("panic: %s", errcode.GetSystemPanic(err))
+			}
+		}()
+		done <- e.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timeout after %v", e.timeout)
+	}
+
+	result := CheckResult{
+		Status:    StatusOK,
+		Critical:  e.critical,
+		CostMs:    float64(time.Since(now)) / float64(time.Millisecond),
+		CheckTime: now.Format("2006-01-02 15:04:05"),
+		checkAt:   now,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	e.result = result
+
+	return result
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Readiness(t *testing.T) {
+	var calls int32
+	Register("redis", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	Register("mysql", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	Register("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}, WithTimeout(20*time.Millisecond))
+	defer func() {
+		Unregister("redis")
+		Unregister("mysql")
+		Unregister("slow")
+	}()
+
+	SetReady(true)
+	report, ok := Readiness(context.Background())
+	if ok {
+		t.Error("Readiness() want fail")
+	}
+	if report.Checks["redis"].Status != StatusOK || report.Checks["mysql"].Status != StatusFail ||
+		report.Checks["slow"].Status != StatusFail {
+		t.Errorf("Readiness() report: %+v", report)
+	}
+
+	// 非 Critical 检查失败不影响存活
+	if _, alive := Liveness(context.Background()); !alive {
+		t.Error("Liveness() want ok")
+	}
+
+	// 缓存时间内不重复检查
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("checker calls: %d, want 1", calls)
+	}
+}
+
+func Test_ReadinessShuttingDown(t *testing.T) {
+	SetReady(true)
+	if _, ok := Readiness(context.Background()); !ok {
+		t.Error("Readiness() want ok")
+	}
+
+	SetShuttingDown()
+	defer atomic.StoreInt32(&defaultRegistry.shuttingDown, 0)
+	if report, ok := Readiness(context.Background()); ok || !report.ShuttingDown {
+		t.Errorf("Readiness() want fail when shutting down: %+v", report)
+	}
+}
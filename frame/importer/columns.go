@@ -0,0 +1,200 @@
+package importer
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  列：行结构体中有 import 标签的字段，没有 import 标签时使用 export 标签，同一个结构体可以用于导出模板和导入
+  按表头的列名匹配，列的顺序不限，表头中没有的列为零值（由 validate 标签检查是否必填）
+  ProductId int       `import:"产品ID" validate:"required,gt=0"`
+  Price     string    `import:"价格" validate:"required,price"`
+  OnSale    time.Time `import:"上架时间,layout=2006-01-02"`
+  支持 string、整数、浮点数、bool（1/true/是/y）、time.Time（layout、常用格式或 Excel 日期数字）、
+  实现 encoding.TextUnmarshaler 的类型（如 decimal.Decimal），以及它们的指针
+*/
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	unmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	timeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006/01/02 15:04:05", "2006/01/02", "20060102"}
+	// Excel 1900 日期系统的第 0 天
+	excelEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.Local)
+
+	errFormat = errors.New("格式错误")
+)
+
+type column struct {
+	title  string
+	index  []int
+	layout string
+	// 校验错误中字段路径的最后一段（json 标签或字段名）
+	name string
+}
+
+func parseColumns(t reflect.Type) ([]column, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("importer: row %s not struct", t)
+	}
+
+	var columns []column
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			idx := append(append([]int{}, index...), i)
+			tag, ok := f.Tag.Lookup("import")
+			if !ok {
+				tag, ok = f.Tag.Lookup("export")
+			}
+			if !ok {
+				if f.Anonymous && f.Type.Kind() == reflect.Struct {
+					walk(f.Type, idx)
+				}
+				continue
+			}
+			if tag == "-" || f.PkgPath != "" {
+				continue
+			}
+
+			parts := strings.Split(tag, ",")
+			col := column{title: strings.TrimSpace(parts[0]), index: idx, name: fieldName(f)}
+			if col.title == "" {
+				col.title = f.Name
+			}
+			for _, opt := range parts[1:] {
+				if strings.HasPrefix(opt, "layout=") {
+					col.layout = strings.TrimPrefix(opt, "layout=")
+				}
+			}
+			columns = append(columns, col)
+		}
+	}
+	walk(t, nil)
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("importer: row %s has no import column", t)
+	}
+	return columns, nil
+}
+
+// 和校验器的字段名一致
+func fieldName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+// 设置字段的值，空字符串为零值
+func setField(v reflect.Value, s, layout string) error {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setField(elem.Elem(), s, layout); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	if v.Type() == timeType {
+		t, err := parseTime(s, layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if reflect.PtrTo(v.Type()).Implements(unmarshalType) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return errFormat
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		switch strings.ToLower(s) {
+		case "1", "true", "是", "y", "yes":
+			v.SetBool(true)
+		case "0", "false", "否", "n", "no":
+			v.SetBool(false)
+		default:
+			return errFormat
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			// Excel 的数字单元格可能为 1.0、1E+3
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil || f != math.Trunc(f) {
+				return errFormat
+			}
+			n = int64(f)
+		}
+		if v.OverflowInt(n) {
+			return errFormat
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil || f < 0 || f != math.Trunc(f) {
+				return errFormat
+			}
+			n = uint64(f)
+		}
+		if v.OverflowUint(n) {
+			return errFormat
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || v.OverflowFloat(f) {
+			return errFormat
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+	return nil
+}
+
+func parseTime(s, layout string) (time.Time, error) {
+	if layout != "" {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	for _, l := range timeLayouts {
+		if t, err := time.ParseInLocation(l, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	// Excel 日期单元格
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 && f < 2958466 {
+		days := math.Floor(f)
+		secs := math.Round((f - days) * 86400)
+		return excelEpoch.AddDate(0, 0, int(days)).Add(time.Duration(secs) * time.Second), nil
+	}
+	return time.Time{}, errFormat
+}
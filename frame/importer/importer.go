@@ -0,0 +1,738 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/export"
+	"github.com/mutou1225/go-frame/frame/internal/jobrunner"
+	"github.com/mutou1225/go-frame/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+	"html"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+  批量导入：上传 xlsx 或 csv，按表头把每行转换为结构体，使用和 BindAndValid 相同的校验器校验，
+  校验通过的行按批调用处理函数（设置 DB 时每批在一个事务中执行，出错时整批回滚），
+  失败的行（格式错误、校验失败、所在的批处理失败）写入错误报告，包含行号、错误信息和原始内容
+  导入目录中每个任务有：
+  <id>.upload.xlsx / .csv      上传的文件
+  <id>.errors.xlsx / .csv      错误报告，有失败的行时生成
+  <id>.import.json             进度（Progress），Importer.Get 读取
+  <id>.import.html             进度页面，和导出相同的自动刷新页面
+  任务和导出一样由 internal/jobrunner 执行，进程退出时未完成的任务标记为失败；超过保留时间的任务文件定时删除
+  指标：goframe_import_jobs_total{status}、goframe_import_rows_total{result}
+*/
+
+const (
+	defaultWorkers          = 1
+	defaultQueueSize        = 100
+	defaultBatchSize        = 500
+	defaultMaxRows          = 100000
+	defaultMaxFileSize      = 20 << 20
+	defaultRetention        = 72 * time.Hour
+	defaultCleanupInterval  = time.Hour
+	defaultProgressInterval = time.Second
+)
+
+var (
+	// 等待中的任务太多
+	ErrQueueFull = errors.New("importer: queue full")
+	// 已经停止
+	ErrStopped = errors.New("importer: stopped")
+	// 任务不存在
+	ErrNotFound = errors.New("importer: job not found")
+	// 上传的文件太大
+	ErrFileTooLarge = errors.New("importer: file too large")
+	// 不支持的文件格式
+	ErrFormat = errors.New("importer: only xlsx and csv supported")
+
+	jobsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_import_jobs_total",
+		Help: "Import jobs by status.",
+	}, []string{"status"})
+	rowsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_import_rows_total",
+		Help: "Rows of import jobs by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	prometheus.MustRegister(jobsCounter, rowsCounter)
+}
+
+// 处理一批校验通过的行，rows 为行结构体的切片（[]T）；设置 Job.DB 时 tx 为事务，否则为 nil
+type Handler func(ctx context.Context, tx *gorm.DB, rows interface{}) error
+
+// 导入任务
+type Job struct {
+	// 任务名，只保留字母、数字、_、-
+	Name string
+	// 为空时按文件扩展名
+	Format export.Format
+	// 要导入的文件，EnqueueUpload 时为上传的文件
+	File string
+	// 行结构体（值或指针），import 或 export 标签为表头的列名，validate 标签校验
+	Row interface{}
+	// 每批的行数，默认 500
+	BatchSize int
+	// 每批在 DB 的事务中执行，为 nil 时不使用事务
+	DB      *gorm.DB
+	Handler Handler
+}
+
+// 任务进度
+type Progress struct {
+	Id     string        `json:"id"`
+	Name   string        `json:"name"`
+	Format export.Format `json:"format"`
+	Status string        `json:"status"`
+	// 已读取的行数（不包括表头和空行）、成功和失败的行数
+	Rows      int64 `json:"rows"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	// 错误报告的下载地址，有失败的行时才有
+	ReportURL string `json:"reportUrl,omitempty"`
+	// 进度页面
+	StatusURL string    `json:"statusUrl"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// 上传的文件，清理时删除
+	Upload string `json:"upload,omitempty"`
+}
+
+type Option func(*Importer)
+
+// 导入目录，保存上传的文件、错误报告和进度
+func Dir(dir string) Option {
+	return func(im *Importer) {
+		im.conf.Dir = dir
+	}
+}
+
+// 导入目录对应的下载地址
+func URLPrefix(url string) Option {
+	return func(im *Importer) {
+		im.urlPrefix = url
+	}
+}
+
+// 同时执行的任务数
+func Workers(n int) Option {
+	return func(im *Importer) {
+		im.conf.Workers = n
+	}
+}
+
+// 等待中的任务数上限
+func QueueSize(n int) Option {
+	return func(im *Importer) {
+		im.conf.QueueSize = n
+	}
+}
+
+// 单个文件的最大行数
+func MaxRows(n int64) Option {
+	return func(im *Importer) {
+		im.maxRows = n
+	}
+}
+
+// 上传文件的最大字节数
+func MaxFileSize(n int64) Option {
+	return func(im *Importer) {
+		im.maxFileSize = n
+	}
+}
+
+// 任务文件的保留时间，为 0 时不删除
+func Retention(d time.Duration) Option {
+	return func(im *Importer) {
+		im.conf.Retention = d
+	}
+}
+
+// 检查过期文件的间隔
+func CleanupInterval(d time.Duration) Option {
+	return func(im *Importer) {
+		im.conf.CleanupInterval = d
+	}
+}
+
+// 更新进度的间隔
+func ProgressInterval(d time.Duration) Option {
+	return func(im *Importer) {
+		im.progressInterval = d
+	}
+}
+
+type task struct {
+	im       *Importer
+	job      Job
+	columns  []column
+	rowType  reflect.Type
+	progress Progress
+}
+
+type Importer struct {
+	conf             jobrunner.Config
+	urlPrefix        string
+	maxRows          int64
+	maxFileSize      int64
+	progressInterval time.Duration
+
+	runner *jobrunner.Runner
+}
+
+// 创建导入，需要 Start 后才开始执行任务
+func New(opts ...Option) *Importer {
+	im := &Importer{
+		conf: jobrunner.Config{
+			Name:            "Import",
+			Dir:             app.EXPORT_IMPORT_FILE_PATH,
+			Suffix:          ".import",
+			Workers:         defaultWorkers,
+			QueueSize:       defaultQueueSize,
+			Retention:       defaultRetention,
+			CleanupInterval: defaultCleanupInterval,
+			ErrQueueFull:    ErrQueueFull,
+			ErrStopped:      ErrStopped,
+			ErrNotFound:     ErrNotFound,
+			StopError:       errors.New("服务退出，请重新导入"),
+		},
+		urlPrefix:        app.DOWNLOAD_URL_PATH,
+		maxRows:          defaultMaxRows,
+		maxFileSize:      defaultMaxFileSize,
+		progressInterval: defaultProgressInterval,
+	}
+	for _, opt := range opts {
+		opt(im)
+	}
+	if !strings.HasSuffix(im.urlPrefix, "/") {
+		im.urlPrefix += "/"
+	}
+	im.conf.Remove = im.remove
+	im.runner = jobrunner.New(im.conf)
+	return im
+}
+
+// 保存表单中上传的文件并添加导入任务，格式按文件扩展名（.xlsx、.csv）
+func (im *Importer) EnqueueUpload(c *gin.Context, field string, job Job) (*Progress, error) {
+	fh, err := c.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	if im.maxFileSize > 0 && fh.Size > im.maxFileSize {
+		return nil, ErrFileTooLarge
+	}
+	if job.Format == "" {
+		if job.Format, err = formatOf(fh.Filename); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := im.newTask(job)
+	if err != nil {
+		return nil, err
+	}
+	t.progress.Upload = t.progress.Id + ".upload." + string(t.progress.Format)
+	t.job.File = filepath.Join(im.conf.Dir, t.progress.Upload)
+	if err := c.SaveUploadedFile(fh, t.job.File); err != nil {
+		return nil, err
+	}
+	return im.enqueue(t)
+}
+
+// 添加导入任务，job.File 为已经保存的文件（清理时不删除）
+func (im *Importer) Enqueue(job Job) (*Progress, error) {
+	if job.Format == "" {
+		var err error
+		if job.Format, err = formatOf(job.File); err != nil {
+			return nil, err
+		}
+	}
+	t, err := im.newTask(job)
+	if err != nil {
+		return nil, err
+	}
+	return im.enqueue(t)
+}
+
+func formatOf(file string) (export.Format, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".xlsx":
+		return export.FormatXlsx, nil
+	case ".csv":
+		return export.FormatCSV, nil
+	}
+	return "", ErrFormat
+}
+
+func (im *Importer) newTask(job Job) (*task, error) {
+	if job.Row == nil || job.Handler == nil {
+		return nil, errors.New("importer: job row or handler nil")
+	}
+	if job.Format != export.FormatXlsx && job.Format != export.FormatCSV {
+		return nil, ErrFormat
+	}
+	columns, err := parseColumns(reflect.TypeOf(job.Row))
+	if err != nil {
+		return nil, err
+	}
+	rowType := reflect.TypeOf(job.Row)
+	for rowType.Kind() == reflect.Ptr {
+		rowType = rowType.Elem()
+	}
+	if job.BatchSize <= 0 {
+		job.BatchSize = defaultBatchSize
+	}
+
+	name, id := im.runner.NewID(job.Name, "import", "Import")
+	if err := os.MkdirAll(im.conf.Dir, 0755); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &task{im: im, job: job, columns: columns, rowType: rowType, progress: Progress{
+		Id:        id,
+		Name:      name,
+		Format:    job.Format,
+		Status:    export.StatusPending,
+		StatusURL: im.urlPrefix + id + ".import.html",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}, nil
+}
+
+func (im *Importer) enqueue(t *task) (*Progress, error) {
+	if err := im.writeProgress(&t.progress); err != nil {
+		im.removeFiles(&t.progress)
+		return nil, err
+	}
+	// 放入队列后进度由执行的协程修改
+	p := t.progress
+	if err := im.runner.Enqueue(t); err != nil {
+		im.removeFiles(&p)
+		return nil, err
+	}
+
+	jobsCounter.WithLabelValues(export.StatusPending).Inc()
+	logger.PrintInfo("Import job[%s] enqueued, format[%s]", p.Id, p.Format)
+	return &p, nil
+}
+
+// 读取任务进度，可以读取其他进程添加的任务（共用导入目录）
+func (im *Importer) Get(id string) (*Progress, error) {
+	p := &Progress{}
+	if err := im.runner.ReadProgress(id, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// 启动执行任务和清理过期文件的协程
+func (im *Importer) Start() {
+	im.runner.Start()
+}
+
+// 不再接收任务，等待执行中的任务完成，ctx 结束时取消执行中的任务；等待中的任务标记为失败
+func (im *Importer) Stop(ctx context.Context) error {
+	return im.runner.Stop(ctx)
+}
+
+// 检查导入目录是否可用
+func (im *Importer) Ping(ctx context.Context) error {
+	if err := im.runner.Ping(ctx); err != nil {
+		return fmt.Errorf("importer: %s", err.Error())
+	}
+	return nil
+}
+
+func (t *task) ID() string {
+	return t.progress.Id
+}
+
+func (t *task) Run(ctx context.Context) error {
+	return t.im.importFile(ctx, t)
+}
+
+func (t *task) Finish(err error, cost time.Duration) {
+	if err != nil {
+		t.im.fail(t, err)
+		return
+	}
+
+	t.progress.Status = export.StatusDone
+	if err := t.im.writeProgress(&t.progress); err != nil {
+		logger.PrintError("Import job[%s] progress Err: %s", t.progress.Id, err.Error())
+	}
+	jobsCounter.WithLabelValues(export.StatusDone).Inc()
+	logger.PrintInfo("Import job[%s] done, rows[%d] succeeded[%d] failed[%d], cost[%.3fms]", t.progress.Id,
+		t.progress.Rows, t.progress.Succeeded, t.progress.Failed, float64(cost)/float64(time.Millisecond))
+}
+
+func (im *Importer) fail(t *task, err error) {
+	t.progress.Status = export.StatusFailed
+	t.progress.Error = err.Error()
+	if werr := im.writeProgress(&t.progress); werr != nil {
+		logger.PrintError("Import job[%s] progress Err: %s", t.progress.Id, werr.Error())
+	}
+	jobsCounter.WithLabelValues(export.StatusFailed).Inc()
+	logger.PrintError("Import job[%s] failed, rows[%d] Err: %s", t.progress.Id, t.progress.Rows, err.Error())
+	log.Printf("Import job[%s] failed Err: %s", t.progress.Id, err.Error())
+}
+
+// 执行中的一个导入
+type importRun struct {
+	im      *Importer
+	t       *task
+	header  []string
+	mapping []int // 列对应的表头位置，-1 为表头中没有
+
+	batch  reflect.Value
+	lines  []int
+	raws   [][]string
+	report *errorReport
+}
+
+func (im *Importer) importFile(ctx context.Context, t *task) (err error) {
+	r := &importRun{im: im, t: t}
+	// panic 时也要关闭并删除未完成的错误报告，不能只由 jobrunner recover
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+			logger.PrintError("Import job[%s] panic: %v\n%s", t.progress.Id, p, debug.Stack())
+		}
+		if rerr := r.closeReport(err == nil); err == nil {
+			err = rerr
+		}
+	}()
+
+	t.progress.Status = export.StatusRunning
+	if err := im.writeProgress(&t.progress); err != nil {
+		return err
+	}
+
+	sheet, err := openSheet(t.progress.Format, t.job.File)
+	if err != nil {
+		return err
+	}
+	defer sheet.close()
+
+	line := 0
+	for r.header == nil {
+		row, err := sheet.next()
+		if err == io.EOF {
+			return errors.New("文件为空")
+		} else if err != nil {
+			return err
+		}
+		line++
+		if !emptyRow(row) {
+			if err := r.setHeader(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	r.batch = reflect.MakeSlice(reflect.SliceOf(t.rowType), 0, t.job.BatchSize)
+	last := time.Now()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row, err := sheet.next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		line++
+		if emptyRow(row) {
+			continue
+		}
+		if t.progress.Rows >= im.maxRows {
+			return fmt.Errorf("超过最大行数 %d", im.maxRows)
+		}
+		t.progress.Rows++
+
+		if err := r.addRow(ctx, line, row); err != nil {
+			return err
+		}
+		if time.Since(last) >= im.progressInterval {
+			last = time.Now()
+			if err := im.writeProgress(&t.progress); err != nil {
+				logger.PrintError("Import job[%s] progress Err: %s", t.progress.Id, err.Error())
+			}
+		}
+	}
+	return r.flush(ctx)
+}
+
+// 按列名匹配表头
+func (r *importRun) setHeader(row []string) error {
+	r.header = make([]string, len(row))
+	pos := make(map[string]int, len(row))
+	for i, v := range row {
+		r.header[i] = strings.TrimSpace(v)
+		if _, ok := pos[r.header[i]]; !ok {
+			pos[r.header[i]] = i
+		}
+	}
+
+	matched := 0
+	titles := make([]string, 0, len(r.t.columns))
+	r.mapping = make([]int, len(r.t.columns))
+	for i, c := range r.t.columns {
+		titles = append(titles, c.title)
+		r.mapping[i] = -1
+		if p, ok := pos[c.title]; ok {
+			r.mapping[i] = p
+			matched++
+		}
+	}
+	if matched == 0 {
+		return fmt.Errorf("表头中没有导入的列，需要：%s", strings.Join(titles, "、"))
+	}
+	return nil
+}
+
+// 转换、校验一行，通过时加入当前批
+func (r *importRun) addRow(ctx context.Context, line int, row []string) error {
+	v := reflect.New(r.t.rowType)
+	var msgs []string
+	for i, c := range r.t.columns {
+		p := r.mapping[i]
+		if p < 0 || p >= len(row) {
+			continue
+		}
+		if err := setField(v.Elem().FieldByIndex(c.index), row[p], c.layout); err != nil {
+			msgs = append(msgs, c.title+"："+err.Error())
+		}
+	}
+	if len(msgs) == 0 {
+		msgs = r.validate(v.Interface())
+	}
+	if len(msgs) > 0 {
+		return r.failRows([]int{line}, [][]string{row}, strings.Join(msgs, "；"))
+	}
+
+	r.batch = reflect.Append(r.batch, v.Elem())
+	r.lines = append(r.lines, line)
+	r.raws = append(r.raws, row)
+	if r.batch.Len() >= r.t.job.BatchSize {
+		return r.flush(ctx)
+	}
+	return nil
+}
+
+// 校验错误中的字段名替换为列名
+func (r *importRun) validate(row interface{}) []string {
+	err := app.ValidatorStruct(row)
+	if err == nil {
+		return nil
+	}
+	var verrs app.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return []string{err.Error()}
+	}
+
+	msgs := make([]string, 0, len(verrs))
+	for _, fe := range verrs {
+		msg := fe.Message
+		name := fe.Field[strings.LastIndex(fe.Field, ".")+1:]
+		for _, c := range r.t.columns {
+			if c.name == name {
+				msg = strings.Replace(msg, name, c.title, 1)
+				break
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// 处理当前批，出错时整批失败
+func (r *importRun) flush(ctx context.Context) error {
+	if r.batch.Len() == 0 {
+		return nil
+	}
+	n := int64(r.batch.Len())
+	if err := r.handle(ctx, r.batch.Interface()); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.PrintError("Import job[%s] batch Err: %s", r.t.progress.Id, err.Error())
+		if err := r.failRows(r.lines, r.raws, "处理失败："+err.Error()); err != nil {
+			return err
+		}
+	} else {
+		r.t.progress.Succeeded += n
+		rowsCounter.WithLabelValues("succeeded").Add(float64(n))
+	}
+
+	r.batch = reflect.MakeSlice(r.batch.Type(), 0, r.t.job.BatchSize)
+	r.lines, r.raws = r.lines[:0], r.raws[:0]
+	return nil
+}
+
+// 执行处理函数，panic 时返回错误
+func (r *importRun) handle(ctx context.Context, rows interface{}) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+			logger.PrintError("Import job[%s] handler panic: %v\n%s", r.t.progress.Id, p, debug.Stack())
+		}
+	}()
+
+	if r.t.job.DB == nil {
+		return r.t.job.Handler(ctx, nil, rows)
+	}
+	return r.t.job.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return r.t.job.Handler(ctx, tx, rows)
+	})
+}
+
+// 失败的行写入错误报告
+func (r *importRun) failRows(lines []int, rows [][]string, msg string) error {
+	if r.report == nil {
+		report, err := newErrorReport(r.im.conf.Dir, &r.t.progress, r.header)
+		if err != nil {
+			return err
+		}
+		r.report = report
+	}
+	for i, line := range lines {
+		if err := r.report.write(line, msg, rows[i]); err != nil {
+			return err
+		}
+	}
+	r.t.progress.Failed += int64(len(lines))
+	rowsCounter.WithLabelValues("failed").Add(float64(len(lines)))
+	return nil
+}
+
+func (r *importRun) closeReport(ok bool) error {
+	if r.report == nil {
+		return nil
+	}
+	if err := r.report.close(ok); err != nil || !ok {
+		return err
+	}
+	r.t.progress.ReportURL = r.im.urlPrefix + filepath.Base(r.report.file)
+	return nil
+}
+
+func emptyRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// 错误报告：行号、错误信息、原始内容，写入时为 .part，完成后改名
+type errorReport struct {
+	file string
+	f    *os.File
+	w    *export.SheetWriter
+	buf  []string
+}
+
+func newErrorReport(dir string, p *Progress, header []string) (*errorReport, error) {
+	file := filepath.Join(dir, p.Id+".errors."+string(p.Format))
+	f, err := os.Create(file + ".part")
+	if err != nil {
+		return nil, err
+	}
+	w, err := export.NewSheetWriter(p.Format, f)
+	if err != nil {
+		f.Close()
+		os.Remove(file + ".part")
+		return nil, err
+	}
+	r := &errorReport{file: file, f: f, w: w}
+	if err := w.WriteRow(append([]string{"行号", "错误信息"}, header...)...); err != nil {
+		r.close(false)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *errorReport) write(line int, msg string, row []string) error {
+	r.buf = append(append(r.buf[:0], strconv.Itoa(line), msg), row...)
+	return r.w.WriteRow(r.buf...)
+}
+
+// ok 为 false 时删除
+func (r *errorReport) close(ok bool) error {
+	err := r.w.Close()
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || !ok {
+		os.Remove(r.file + ".part")
+		return err
+	}
+	return os.Rename(r.file+".part", r.file)
+}
+
+// 写入进度和进度页面
+func (im *Importer) writeProgress(p *Progress) error {
+	p.UpdatedAt = time.Now()
+
+	var desc string
+	switch p.Status {
+	case export.StatusPending:
+		desc = "导入任务排队中..."
+	case export.StatusRunning:
+		desc = fmt.Sprintf("正在导入：已读取 %d 行，成功 %d 行，失败 %d 行", p.Rows, p.Succeeded, p.Failed)
+	case export.StatusDone:
+		desc = fmt.Sprintf("导入完成：共 %d 行，成功 %d 行，失败 %d 行", p.Rows, p.Succeeded, p.Failed)
+		if p.ReportURL != "" {
+			desc += fmt.Sprintf(`，<a href="%s">下载错误报告</a>`, html.EscapeString(p.ReportURL))
+		}
+	default:
+		desc = "导入失败：" + html.EscapeString(p.Error)
+	}
+	reload := p.Status == export.StatusPending || p.Status == export.StatusRunning
+	return im.runner.WriteProgress(p.Id, p, desc, reload)
+}
+
+func (im *Importer) removeFiles(p *Progress) {
+	files := []string{p.Id + ".import.json", p.Id + ".import.html",
+		p.Id + ".errors." + string(p.Format), p.Id + ".errors." + string(p.Format) + ".part"}
+	if p.Upload != "" {
+		files = append(files, filepath.Base(p.Upload))
+	}
+	im.runner.RemoveFiles(files...)
+}
+
+// 删除过期任务的文件
+func (im *Importer) remove(id string) bool {
+	p, err := im.Get(id)
+	if err != nil {
+		return false
+	}
+	im.removeFiles(p)
+	return true
+}
+
+// 删除更新时间超过保留时间的任务文件，返回删除的任务数
+func (im *Importer) Cleanup(now time.Time) int {
+	return im.runner.Cleanup(now)
+}
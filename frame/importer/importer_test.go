@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"github.com/mutou1225/go-frame/frame/export"
+	"gorm.io/gorm"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRow struct {
+	Id     int64     `json:"id" import:"产品ID" validate:"required,gt=0"`
+	Name   string    `json:"name" export:"产品名称" validate:"required,lte=10"`
+	Price  string    `json:"price" import:"价格" validate:"omitempty,price"`
+	OnSale time.Time `import:"上架时间,layout=2006-01-02"`
+	Hot    *bool     `import:"热门"`
+}
+
+func writeXlsx(t *testing.T, file string, parts map[string]string) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, data := range parts {
+		w, _ := zw.Create(name)
+		_, _ = io.WriteString(w, data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Reader(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "importer")
+	defer os.RemoveAll(dir)
+
+	// 共享字符串、富文本、缺少的单元格、工作表不是 sheet1.xml
+	file := filepath.Join(dir, "a.xlsx")
+	writeXlsx(t, file, map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="价格" sheetId="1" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId3" Target="worksheets/data.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>产品ID</t></si><si><r><t>产品</t></r><r><t>名称</t></r><rPh><t>x</t></rPh></si><si><t>上架时间</t></si></sst>`,
+		"xl/worksheets/data.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="D1" t="s"><v>2</v></c></row>
+			<row r="2"><c r="A2"><v>12</v></c><c r="B2" t="inlineStr"><is><t>a&amp;b</t></is></c><c r="D2"><v>44470</v></c></row>
+		</sheetData></worksheet>`,
+	})
+	r, err := openSheet(export.FormatXlsx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	header, _ := r.next()
+	row, _ := r.next()
+	if strings.Join(header, ",") != "产品ID,产品名称,,上架时间" || strings.Join(row, ",") != "12,a&b,,44470" {
+		t.Errorf("xlsx: %q %q", header, row)
+	}
+	if _, err := r.next(); err != io.EOF {
+		t.Errorf("xlsx end: %v", err)
+	}
+
+	// 导出的 xlsx 可以直接读取
+	file = filepath.Join(dir, "b.xlsx")
+	f, _ := os.Create(file)
+	w, _ := export.NewSheetWriter(export.FormatXlsx, f)
+	_ = w.WriteRow("产品ID", "", "<名称>")
+	_ = w.Close()
+	f.Close()
+	r2, err := openSheet(export.FormatXlsx, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.close()
+	if row, _ = r2.next(); strings.Join(row, ",") != "产品ID,,<名称>" {
+		t.Errorf("export xlsx: %q", row)
+	}
+
+	columns, _ := parseColumns(reflect.TypeOf(testRow{}))
+	var v testRow
+	rv := reflect.ValueOf(&v).Elem()
+	if err := setField(rv.FieldByIndex(columns[3].index), "44470", columns[3].layout); err != nil || v.OnSale.Format("2006-01-02") != "2021-10-01" {
+		t.Errorf("excel date: %v %v", v.OnSale, err)
+	}
+	if err := setField(rv.FieldByIndex(columns[0].index), "1.5", ""); err == nil {
+		t.Error("int format")
+	}
+	if err := setField(rv.FieldByIndex(columns[4].index), "是", ""); err != nil || v.Hot == nil || !*v.Hot {
+		t.Errorf("bool: %v", err)
+	}
+}
+
+func Test_Importer(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "importer")
+	defer os.RemoveAll(dir)
+
+	// 导出的 csv 可以直接导入
+	file := filepath.Join(dir, "price.csv")
+	f, _ := os.Create(file)
+	w, _ := export.NewSheetWriter(export.FormatCSV, f)
+	_ = w.WriteRow("价格", "产品名称", "产品ID", "备注")
+	_ = w.WriteRow("1.5", "a", "1", "")
+	_ = w.WriteRow("", "", "", "")
+	_ = w.WriteRow("2", "b", "abc", "")
+	_ = w.WriteRow("3.333", "c", "3", "")
+	_ = w.WriteRow("4", "d", "4", "")
+	_ = w.WriteRow("5", "e", "5", "")
+	_ = w.WriteRow("6", "f", "6", "")
+	_ = w.Close()
+	f.Close()
+
+	var got []testRow
+	im := New(Dir(dir), URLPrefix("http://cdn/download/"), ProgressInterval(0))
+	p, err := im.Enqueue(Job{Name: "price", File: file, Row: testRow{}, BatchSize: 2,
+		Handler: func(ctx context.Context, tx *gorm.DB, rows interface{}) error {
+			list := rows.([]testRow)
+			if list[0].Id == 5 {
+				return errors.New("db error")
+			}
+			got = append(got, list...)
+			return nil
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	im.Start()
+	var prog *Progress
+	for i := 0; i < 100; i++ {
+		if prog, err = im.Get(p.Id); err == nil && prog.Status == export.StatusDone {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = im.Stop(context.Background())
+
+	if prog == nil || prog.Status != export.StatusDone || prog.Rows != 6 || prog.Succeeded != 2 || prog.Failed != 4 {
+		t.Fatalf("progress: %+v %v", prog, err)
+	}
+	if len(got) != 2 || got[0].Name != "a" || got[0].Price != "1.5" || got[1].Id != 4 {
+		t.Errorf("rows: %+v", got)
+	}
+	if prog.ReportURL != "http://cdn/download/"+p.Id+".errors.csv" {
+		t.Errorf("report url: %s", prog.ReportURL)
+	}
+	report, _ := ioutil.ReadFile(filepath.Join(dir, p.Id+".errors.csv"))
+	for _, want := range []string{
+		"行号,错误信息,价格,产品名称,产品ID,备注",
+		"4,产品ID：格式错误,2,b,abc,",
+		"5,价格必须是有效的价格，最多两位小数,3.333,c,3,",
+		"7,处理失败：db error,5,e,5,",
+		"8,处理失败：db error,6,f,6,",
+	} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report want %s\n%s", want, report)
+		}
+	}
+
+	// 表头不匹配
+	bad := filepath.Join(dir, "bad.csv")
+	_ = ioutil.WriteFile(bad, []byte("a,b\n1,2\n"), 0644)
+	im = New(Dir(dir), Retention(time.Hour))
+	p, _ = im.Enqueue(Job{File: bad, Row: testRow{}, Handler: func(ctx context.Context, tx *gorm.DB, rows interface{}) error { return nil }})
+	im.Start()
+	for i := 0; i < 100; i++ {
+		if prog, _ = im.Get(p.Id); prog != nil && prog.Status == export.StatusFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = im.Stop(context.Background())
+	if prog == nil || !strings.Contains(prog.Error, "产品ID、产品名称、价格、上架时间、热门") {
+		t.Errorf("header mismatch: %+v", prog)
+	}
+
+	if _, err := im.Enqueue(Job{File: filepath.Join(dir, "a.xls"), Row: testRow{}}); err != ErrFormat {
+		t.Errorf("format: %v", err)
+	}
+	if n := im.Cleanup(time.Now().Add(2 * time.Hour)); n != 2 {
+		t.Errorf("cleanup: %d", n)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 2 {
+		t.Errorf("files after cleanup: %v", files)
+	}
+}
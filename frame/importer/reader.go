@@ -0,0 +1,303 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/frame/export"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// 按行读取表格，结束时返回 io.EOF
+type sheetReader interface {
+	next() ([]string, error)
+	close() error
+}
+
+func openSheet(format export.Format, file string) (sheetReader, error) {
+	switch format {
+	case export.FormatCSV:
+		return openCSV(file)
+	case export.FormatXlsx:
+		return openXlsx(file)
+	}
+	return nil, fmt.Errorf("importer: unknown format %s", format)
+}
+
+type csvReader struct {
+	f *os.File
+	r *csv.Reader
+}
+
+func openCSV(file string) (*csvReader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return &csvReader{f: f, r: r}, nil
+}
+
+func (c *csvReader) next() ([]string, error) {
+	row, err := c.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	// 去掉 UTF-8 BOM
+	if len(row) > 0 {
+		row[0] = strings.TrimPrefix(row[0], "\xEF\xBB\xBF")
+	}
+	return row, nil
+}
+
+func (c *csvReader) close() error {
+	return c.f.Close()
+}
+
+/*
+xlsx 读取第一个工作表，共享字符串读到内存，工作表按行流式解析
+日期单元格为数字（1900 日期系统的天数），转换为 time.Time 时处理
+*/
+type xlsxReader struct {
+	zr     *zip.ReadCloser
+	sheet  io.ReadCloser
+	dec    *xml.Decoder
+	shared []string
+}
+
+func openXlsx(file string) (*xlsxReader, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, errors.New("importer: invalid xlsx file")
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	x := &xlsxReader{zr: zr}
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if x.shared, err = readSharedStrings(f); err != nil {
+			zr.Close()
+			return nil, err
+		}
+	}
+
+	name := firstSheet(files)
+	f, ok := files[name]
+	if !ok {
+		zr.Close()
+		return nil, errors.New("importer: xlsx sheet not found")
+	}
+	if x.sheet, err = f.Open(); err != nil {
+		zr.Close()
+		return nil, err
+	}
+	x.dec = xml.NewDecoder(x.sheet)
+	return x, nil
+}
+
+// workbook.xml 中第一个工作表对应的文件，找不到时为 xl/worksheets/sheet1.xml
+func firstSheet(files map[string]*zip.File) string {
+	def := "xl/worksheets/sheet1.xml"
+	var workbook struct {
+		Sheets []struct {
+			Id string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			Id     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := unmarshalZipFile(files["xl/workbook.xml"], &workbook); err != nil || len(workbook.Sheets) == 0 {
+		return def
+	}
+	if err := unmarshalZipFile(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return def
+	}
+	for _, r := range rels.Relationships {
+		if r.Id == workbook.Sheets[0].Id {
+			if strings.HasPrefix(r.Target, "/") {
+				return strings.TrimPrefix(r.Target, "/")
+			}
+			return path.Join("xl", r.Target)
+		}
+	}
+	return def
+}
+
+func unmarshalZipFile(f *zip.File, v interface{}) error {
+	if f == nil {
+		return os.ErrNotExist
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// <si> 中全部 <t> 的文本，不包括注音 <rPh>
+func readSharedStrings(f *zip.File) ([]string, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var list []string
+	var buf bytes.Buffer
+	inT, inPh := false, false
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				buf.Reset()
+			case "t":
+				inT = true
+			case "rPh":
+				inPh = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				list = append(list, buf.String())
+			case "t":
+				inT = false
+			case "rPh":
+				inPh = false
+			}
+		case xml.CharData:
+			if inT && !inPh {
+				buf.Write(t)
+			}
+		}
+	}
+}
+
+func (x *xlsxReader) next() ([]string, error) {
+	var row []string
+	inRow := false
+	var ref, typ string
+	var value, inline bytes.Buffer
+	inV, inT := false, false
+
+	for {
+		tok, err := x.dec.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				inRow, row = true, row[:0]
+			case "c":
+				ref, typ = "", ""
+				value.Reset()
+				inline.Reset()
+				for _, a := range t.Attr {
+					switch a.Name.Local {
+					case "r":
+						ref = a.Value
+					case "t":
+						typ = a.Value
+					}
+				}
+			case "v":
+				inV = true
+			case "t":
+				inT = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "row":
+				if inRow {
+					return row, nil
+				}
+			case "c":
+				idx := len(row)
+				if ref != "" {
+					if i, ok := columnIndex(ref); ok {
+						idx = i
+					}
+				}
+				for len(row) <= idx {
+					row = append(row, "")
+				}
+				row[idx] = x.cellValue(typ, value.String(), inline.String())
+			case "v":
+				inV = false
+			case "t":
+				inT = false
+			}
+		case xml.CharData:
+			if inV {
+				value.Write(t)
+			} else if inT {
+				inline.Write(t)
+			}
+		}
+	}
+}
+
+func (x *xlsxReader) cellValue(typ, value, inline string) string {
+	switch typ {
+	case "s":
+		if i, err := strconv.Atoi(value); err == nil && i >= 0 && i < len(x.shared) {
+			return x.shared[i]
+		}
+		return ""
+	case "inlineStr":
+		return inline
+	}
+	return value
+}
+
+func (x *xlsxReader) close() error {
+	x.sheet.Close()
+	return x.zr.Close()
+}
+
+// 单元格引用（如 AB12）的列号，从 0 开始
+func columnIndex(ref string) (int, bool) {
+	n := 0
+	i := 0
+	for ; i < len(ref); i++ {
+		c := ref[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		n = n*26 + int(c-'A'+1)
+	}
+	if i == 0 {
+		return 0, false
+	}
+	return n - 1, true
+}
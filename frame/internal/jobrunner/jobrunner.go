@@ -0,0 +1,300 @@
+package jobrunner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/mutou1225/go-frame/implements/toolkit"
+	"github.com/mutou1225/go-frame/logger"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+  文件任务的执行，export、importer 共用：
+  任务在进程内的队列中等待，Start 后按 Workers 并发执行；进度写入目录中的 <id><Suffix>.json 和进度页面 .html
+  Stop 不再接收任务，等待执行中的任务完成，ctx 结束时取消；等待中的任务以 StopError 结束
+  超过保留时间的任务文件定时删除，只删除有进度记录的任务
+*/
+
+// 进度页面的刷新时间（ms）
+const statusReload = 3000
+
+var (
+	idRegexp   = regexp.MustCompile(`^[0-9A-Za-z_\-]+$`)
+	nameRegexp = regexp.MustCompile(`[^0-9A-Za-z_\-]+`)
+)
+
+// 任务，Run、Finish 在执行的协程中调用
+type Task interface {
+	ID() string
+	// 执行任务，ctx 在退出超时后取消
+	Run(ctx context.Context) error
+	// 任务结束，err 为 nil 时成功；cost 为执行时间，没有执行时为 0
+	Finish(err error, cost time.Duration)
+}
+
+type Config struct {
+	// 日志中的名字，如 Export
+	Name string
+	// 任务文件的目录，进度文件为 <id><Suffix>.json、<id><Suffix>.html
+	Dir    string
+	Suffix string
+
+	Workers         int
+	QueueSize       int
+	Retention       time.Duration
+	CleanupInterval time.Duration
+
+	// 队列已满、已经停止、任务不存在时返回的错误
+	ErrQueueFull error
+	ErrStopped   error
+	ErrNotFound  error
+	// 退出时等待中的任务的错误
+	StopError error
+	// 删除过期的任务文件，任务不属于该类型时返回 false
+	Remove func(id string) bool
+}
+
+type Runner struct {
+	conf Config
+
+	tasks   chan Task
+	seq     uint64
+	mutex   sync.Mutex
+	stopped bool
+
+	cancel    context.CancelFunc
+	cancelRun context.CancelFunc
+	done      chan struct{}
+}
+
+func New(conf Config) *Runner {
+	return &Runner{conf: conf, tasks: make(chan Task, conf.QueueSize)}
+}
+
+func (r *Runner) Dir() string {
+	return r.conf.Dir
+}
+
+// 任务名（只保留字母、数字、_、-，为空时使用 defaultName）和任务 id
+func (r *Runner) NewID(name, defaultName string, flags ...string) (string, string) {
+	name = strings.Trim(nameRegexp.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = defaultName
+	}
+	seq := strconv.FormatUint(atomic.AddUint64(&r.seq, 1), 10)
+	id := strings.TrimSuffix(toolkit.GetExportExcelFileName(name, append(flags, seq)...), ".xlsx")
+	return name, id
+}
+
+// 放入队列，已经停止或队列已满时返回错误
+func (r *Runner) Enqueue(t Task) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopped {
+		return r.conf.ErrStopped
+	}
+	select {
+	case r.tasks <- t:
+		return nil
+	default:
+		return r.conf.ErrQueueFull
+	}
+}
+
+// 启动执行任务和清理过期文件的协程
+func (r *Runner) Start() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.cancel != nil || r.stopped {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	r.cancel, r.cancelRun = cancel, cancelRun
+	r.done = make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+	wg.Add(r.conf.Workers + 1)
+	go func() {
+		defer wg.Done()
+		r.cleanupLoop(ctx)
+	}()
+	for i := 0; i < r.conf.Workers; i++ {
+		go func() {
+			defer wg.Done()
+			r.work(ctx, runCtx)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(r.done)
+	}()
+
+	logger.PrintInfo("%s Start, workers[%d], dir[%s]", r.conf.Name, r.conf.Workers, r.conf.Dir)
+}
+
+// 不再接收任务，等待执行中的任务完成，ctx 结束时取消执行中的任务；等待中的任务以 StopError 结束
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	r.stopped = true
+	r.mutex.Unlock()
+
+	var err error
+	if r.cancel != nil {
+		r.cancel()
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+			r.cancelRun()
+			<-r.done
+		}
+		r.cancelRun()
+	}
+
+	for {
+		select {
+		case t := <-r.tasks:
+			t.Finish(r.conf.StopError, 0)
+		default:
+			logger.PrintInfo("%s Stop", r.conf.Name)
+			return err
+		}
+	}
+}
+
+// 检查任务目录是否可用
+func (r *Runner) Ping(ctx context.Context) error {
+	info, err := os.Stat(r.conf.Dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s not dir", r.conf.Dir)
+	}
+	return nil
+}
+
+func (r *Runner) work(ctx, runCtx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-r.tasks:
+			start := time.Now()
+			err := r.run(runCtx, t)
+			t.Finish(err, time.Since(start))
+		}
+	}
+}
+
+// 执行任务，panic 时返回错误
+func (r *Runner) run(ctx context.Context, t Task) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+			logger.PrintError("%s job[%s] panic: %v\n%s", r.conf.Name, t.ID(), p, debug.Stack())
+		}
+	}()
+	return t.Run(ctx)
+}
+
+// 写入进度的 json 和进度页面，json 先写临时文件再改名，读取时不会读到一半
+// desc 为进度页面的内容（HTML），reload 为 true 时页面自动刷新
+func (r *Runner) WriteProgress(id string, p interface{}, desc string, reload bool) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(r.conf.Dir, id+r.conf.Suffix)
+	if err := ioutil.WriteFile(file+".json.tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(file+".json.tmp", file+".json"); err != nil {
+		return err
+	}
+	return toolkit.UpdateImportTaskDescFile(file+".html", "<p>"+desc+"</p>", reload, statusReload)
+}
+
+// 读取进度，可以读取其他进程添加的任务（共用目录）；不存在时返回 ErrNotFound
+func (r *Runner) ReadProgress(id string, p interface{}) error {
+	if !idRegexp.MatchString(id) {
+		return r.conf.ErrNotFound
+	}
+	data, err := ioutil.ReadFile(filepath.Join(r.conf.Dir, id+r.conf.Suffix+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return r.conf.ErrNotFound
+		}
+		return err
+	}
+	return json.Unmarshal(data, p)
+}
+
+// 删除文件，不存在时忽略
+func (r *Runner) RemoveFiles(files ...string) {
+	for _, f := range files {
+		if err := os.Remove(filepath.Join(r.conf.Dir, f)); err != nil && !os.IsNotExist(err) {
+			logger.PrintError("%s remove %s Err: %s", r.conf.Name, f, err.Error())
+		}
+	}
+}
+
+func (r *Runner) cleanupLoop(ctx context.Context) {
+	if r.conf.Retention <= 0 || r.conf.CleanupInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.conf.CleanupInterval)
+	defer ticker.Stop()
+	for {
+		r.Cleanup(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 删除更新时间超过保留时间的任务文件，返回删除的任务数；执行中的任务每次写入都会更新时间，不会被删除
+func (r *Runner) Cleanup(now time.Time) int {
+	if r.conf.Retention <= 0 || r.conf.Remove == nil {
+		return 0
+	}
+	files, err := filepath.Glob(filepath.Join(r.conf.Dir, "*"+r.conf.Suffix+".json"))
+	if err != nil {
+		logger.PrintError("%s cleanup Err: %s", r.conf.Name, err.Error())
+		return 0
+	}
+
+	count := 0
+	for _, file := range files {
+		id := strings.TrimSuffix(filepath.Base(file), r.conf.Suffix+".json")
+		p := struct {
+			Id        string    `json:"id"`
+			UpdatedAt time.Time `json:"updatedAt"`
+		}{}
+		if err := r.ReadProgress(id, &p); err != nil || p.Id != id || now.Sub(p.UpdatedAt) < r.conf.Retention {
+			continue
+		}
+		if r.conf.Remove(id) {
+			count++
+		}
+	}
+	if count > 0 {
+		logger.PrintInfo("%s cleanup %d jobs", r.conf.Name, count)
+	}
+	return count
+}
@@ -0,0 +1,98 @@
+package jobrunner
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+type testTask struct {
+	id       string
+	run      func(ctx context.Context) error
+	finished chan error
+}
+
+func (t *testTask) ID() string {
+	return t.id
+}
+
+func (t *testTask) Run(ctx context.Context) error {
+	return t.run(ctx)
+}
+
+func (t *testTask) Finish(err error, cost time.Duration) {
+	t.finished <- err
+}
+
+func Test_Runner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobrunner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	errStop := errors.New("stop")
+	removed := []string{}
+	r := New(Config{Name: "Test", Dir: dir, Suffix: ".test", Workers: 1, QueueSize: 1, Retention: time.Hour,
+		ErrQueueFull: errors.New("full"), ErrStopped: errors.New("stopped"), ErrNotFound: errors.New("not found"),
+		StopError: errStop, Remove: func(id string) bool {
+			removed = append(removed, id)
+			return true
+		}})
+
+	_, id := r.NewID("a b/c", "test")
+	p := struct {
+		Id        string    `json:"id"`
+		UpdatedAt time.Time `json:"updatedAt"`
+	}{Id: id, UpdatedAt: time.Now()}
+	if err := r.WriteProgress(id, &p, "排队中", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ReadProgress("../"+id, &p); err != r.conf.ErrNotFound {
+		t.Errorf("read invalid id: %v", err)
+	}
+	if n := r.Cleanup(time.Now()); n != 0 {
+		t.Errorf("cleanup before retention: %d", n)
+	}
+	if n := r.Cleanup(time.Now().Add(2 * time.Hour)); n != 1 || len(removed) != 1 || removed[0] != id {
+		t.Errorf("cleanup: %d %v", n, removed)
+	}
+
+	// panic 时任务以错误结束，执行的协程继续执行
+	r.Start()
+	panicTask := &testTask{id: "panic", finished: make(chan error, 1), run: func(ctx context.Context) error {
+		panic("boom")
+	}}
+	if err := r.Enqueue(panicTask); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-panicTask.finished; err == nil {
+		t.Errorf("panic task no error")
+	}
+
+	// 退出超时后取消执行中的任务
+	started := make(chan struct{})
+	block := &testTask{id: "block", finished: make(chan error, 1), run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	if err := r.Enqueue(block); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("stop: %v", err)
+	}
+	if err := <-block.finished; err != context.Canceled {
+		t.Errorf("block task: %v", err)
+	}
+	if err := r.Enqueue(block); err != r.conf.ErrStopped {
+		t.Errorf("enqueue after stop: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/logger"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+  报文编码的协商（protocol.Codec）：
+  请求体：Content-Type 为 application/x-protobuf、application/x-msgpack 时使用对应的编码，其他都按 JSON（兼容不设置 Content-Type 的调用方）
+  响应：按 Accept 中 q 值最大的支持的编码；没有 Accept 或 Accept 中没有支持的编码时和请求体相同
+  业务响应（app.Respond 等）和中间件返回的错误使用同样的规则
+*/
+
+// 请求体的编码
+func RequestCodec(c *gin.Context) protocol.Codec {
+	if codec, ok := protocol.CodecByContentType(c.ContentType()); ok {
+		return codec
+	}
+	return protocol.JSONCodec
+}
+
+// 响应的编码
+func ResponseCodec(c *gin.Context) protocol.Codec {
+	type accepted struct {
+		codec protocol.Codec
+		q     float64
+	}
+	var list []accepted
+	for _, part := range strings.Split(c.GetHeader("Accept"), ",") {
+		fields := strings.Split(part, ";")
+		q := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if codec, ok := protocol.CodecByContentType(fields[0]); ok && q > 0 {
+			list = append(list, accepted{codec, q})
+		}
+	}
+	if len(list) == 0 {
+		return RequestCodec(c)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	return list[0].codec
+}
+
+// 按请求体的编码解析报文，req.Param 为 nil 时 protobuf 只解析报文头
+func DecodeRequest(c *gin.Context, body []byte, req *protocol.SubsysReqBody) error {
+	return RequestCodec(c).Unmarshal(body, req)
+}
+
+// 按响应的编码发送，JSON 和之前一样使用 c.JSON
+func Render(c *gin.Context, httpCode int, body interface{}) {
+	c.Writer.Header().Add("Vary", "Accept")
+	codec := ResponseCodec(c)
+	if codec == protocol.JSONCodec {
+		c.JSON(httpCode, body)
+		return
+	}
+
+	data, err := codec.Marshal(body)
+	if err != nil {
+		logger.PrintError("Render %s Err: %s", codec.Name(), err.Error())
+		c.JSON(httpCode, body)
+		return
+	}
+	c.Data(httpCode, codec.ContentType(), data)
+}
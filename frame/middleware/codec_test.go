@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func Test_CheckCallSignCodec(t *testing.T) {
+	conf, err := config.NewFromData(nil, []byte(`<xml><Caller><id>116006</id><key>k</key></Caller></xml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(configContextKey, conf)
+	}, CheckCallSign())
+	r.POST("/echo", func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		param := &wrapperspb.StringValue{}
+		req := protocol.SubsysReqBody{Param: param}
+		if err := DecodeRequest(c, body, &req); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		Render(c, http.StatusOK, protocol.NewRspBody(protocol.ProtocolV2, &req.Head, 0, "SUCCESS", wrapperspb.String("hi "+param.Value)))
+	})
+
+	head := protocol.SubsysHeader{CallServiceId: "116006", GroupNo: "1", Interface: "echo", InvokeId: "1", MsgType: "request", Timestamp: "1", Version: "0.01"}
+	body, _ := protocol.ProtobufCodec.Marshal(protocol.SubsysReqBody{Head: head, Param: wrapperspb.String("go")})
+	do := func(sign, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(body))
+		req.Header.Set("Content-Type", protocol.MIMEProtobuf)
+		req.Header.Set("HSB-OPENAPI-CALLERSERVICEID", "116006")
+		req.Header.Set("HSB-OPENAPI-SIGNATURE", sign)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 签名按 protobuf 的原始字节计算，响应和请求的编码相同
+	w := do(apiSign(string(body), "k"), "")
+	data := &wrapperspb.StringValue{}
+	rsp := protocol.SubsysRspBody{Rsp: &protocol.SubsysCommonRsp{Data: data}}
+	if err := protocol.ProtobufCodec.Unmarshal(w.Body.Bytes(), &rsp); err != nil || w.Header().Get("Content-Type") != protocol.MIMEProtobuf ||
+		rsp.Head.Interface != "echo" || data.Value != "hi go" {
+		t.Errorf("protobuf: %v %v %+v", err, w.Header(), rsp.Head)
+	}
+
+	// 签名错误，按 Accept 返回 msgpack
+	w = do("bad", "application/x-msgpack, application/json;q=0.5")
+	rsp = protocol.SubsysRspBody{}
+	if err := protocol.MsgPackCodec.Unmarshal(w.Body.Bytes(), &rsp); err != nil || w.Code != http.StatusUnauthorized ||
+		rsp.Rsp.RetCode != strconv.Itoa(errcode.ERROR_SIGN.ErrorCode) || rsp.Head.Interface != "echo" {
+		t.Errorf("sign error: %d %v %+v", w.Code, err, rsp.Rsp)
+	}
+}
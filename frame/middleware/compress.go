@@ -0,0 +1,289 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/*
+  响应压缩：按请求的 Accept-Encoding 使用 gzip 或 deflate（q 值相同时优先 gzip），服务配置 <Compress>：
+  <Compress><Enable>1</Enable><MinSize>1024</MinSize><Level>6</Level><ExcludePath>/export</ExcludePath></Compress>
+  响应体先缓存到 MinSize 再决定是否压缩，小的响应不压缩；处理函数 Flush 时立即开始压缩（流式响应）
+  不压缩：HEAD、204/206/304、已有 Content-Encoding、图片/音视频/压缩包等已压缩的类型、text/event-stream、
+  Upgrade 请求（websocket）、流式路由（Streaming），以及使用路由中间件 WithoutCompress 的路由（app.POST 的 WithoutCompress）
+  只改变写入的响应，不读取请求体，PrintPostData 等中间件不受影响
+*/
+
+const (
+	defaultCompressMinSize = 1024
+	defaultCompressLevel   = 6
+)
+
+var (
+	// 按压缩级别复用，下标为级别
+	gzipPools [gzip.BestCompression + 1]sync.Pool
+	zlibPools [zlib.BestCompression + 1]sync.Pool
+
+	// 已压缩或不适合压缩的类型
+	incompressibleTypes = []string{"image/", "video/", "audio/", "font/woff", "text/event-stream",
+		"application/zip", "application/gzip", "application/x-gzip", "application/x-7z", "application/x-rar",
+		"application/pdf", "application/octet-stream", "application/vnd.openxmlformats"}
+)
+
+// 指定路由的响应不压缩（如文件下载、已压缩的数据）
+func WithoutCompress() gin.HandlerFunc {
+	return withoutCompress
+}
+
+// 指定路由的 GET 响应不计算 ETag
+func WithoutETag() gin.HandlerFunc {
+	return withoutETag
+}
+
+// 响应压缩，配置读取请求所属应用的服务配置，配置重新加载后生效
+func Compress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := configFromContext(c).GetCompressConfig()
+		if !conf.Enabled() || c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" ||
+			hasPathPrefix(c.Request.URL.Path, conf.ExcludePaths) || requestRouteOptions(c).noCompress || IsStreaming(c) {
+			c.Next()
+			return
+		}
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" {
+			c.Writer.Header().Add("Vary", "Accept-Encoding")
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			minSize:        conf.MinSize,
+			level:          conf.Level,
+		}
+		if w.minSize <= 0 {
+			w.minSize = defaultCompressMinSize
+		}
+		if w.level < gzip.BestSpeed || w.level > gzip.BestCompression {
+			w.level = defaultCompressLevel
+		}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+		w.finish()
+	}
+}
+
+// Accept-Encoding 中 q 值最大的 gzip 或 deflate，都不接受时返回空
+func negotiateEncoding(accept string) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		for _, f := range fields[1:] {
+			f = strings.TrimSpace(f)
+			if strings.HasPrefix(f, "q=") {
+				if v, err := strconv.ParseFloat(f[2:], 64); err == nil {
+					weight = v
+				}
+			}
+		}
+		q[name] = weight
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range []string{"gzip", "deflate"} {
+		weight, ok := q[name]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > bestQ {
+			best, bestQ = name, weight
+		}
+	}
+	return best
+}
+
+func hasPathPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if p != "" && strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// 响应是否可以压缩，在写入响应头之前判断
+func compressible(status int, header http.Header) bool {
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		status == http.StatusPartialContent || header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	for _, t := range incompressibleTypes {
+		if strings.HasPrefix(contentType, t) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+先缓存响应体，达到 MinSize 或 Flush 时按 compressible 决定是否压缩，之后直接写入
+gin 的 responseWriter 在第一次写入时才发送响应头，决定之前可以修改响应头
+*/
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	level    int
+
+	buf     []byte
+	decided bool
+	zw      io.WriteCloser
+	size    int
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	w.size += len(b)
+	if w.decided {
+		if w.zw != nil {
+			return w.zw.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// 没有响应体时（如 AbortWithStatus）直接发送响应头，不压缩
+func (w *compressWriter) WriteHeaderNow() {
+	if !w.decided {
+		_ = w.decide(false)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+// 流式响应立即开始压缩，压缩的数据同时刷新到连接
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide(true)
+	}
+	if f, ok := w.zw.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.size > 0 || w.ResponseWriter.Written()
+}
+
+// 写入的未压缩的字节数
+func (w *compressWriter) Size() int {
+	if w.size > 0 {
+		return w.size
+	}
+	return w.ResponseWriter.Size()
+}
+
+// 决定是否压缩，写入缓存的数据
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compressible(w.Status(), header) {
+		header.Add("Vary", "Accept-Encoding")
+	} else {
+		compress = false
+	}
+
+	if compress {
+		// 没有 Content-Type 时 net/http 会按压缩后的数据识别类型
+		if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+			header.Set("Content-Type", http.DetectContentType(w.buf))
+		}
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", w.encoding)
+		// 压缩后内容不再逐字节相同，强 ETag 改为弱 ETag
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.zw = w.newEncoder()
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.zw != nil {
+		_, err = w.zw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) newEncoder() io.WriteCloser {
+	if w.encoding == "gzip" {
+		if zw, ok := gzipPools[w.level].Get().(*gzip.Writer); ok {
+			zw.Reset(w.ResponseWriter)
+			return zw
+		}
+		zw, _ := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+		return zw
+	}
+	if zw, ok := zlibPools[w.level].Get().(*zlib.Writer); ok {
+		zw.Reset(w.ResponseWriter)
+		return zw
+	}
+	zw, _ := zlib.NewWriterLevel(w.ResponseWriter, w.level)
+	return zw
+}
+
+// 处理函数返回后，写入未达到 MinSize 的响应或压缩数据的结尾
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		_ = w.decide(false)
+		return
+	}
+	if w.zw == nil {
+		return
+	}
+	_ = w.zw.Close()
+	switch zw := w.zw.(type) {
+	case *gzip.Writer:
+		gzipPools[w.level].Put(zw)
+	case *zlib.Writer:
+		zlibPools[w.level].Put(zw)
+	}
+	w.zw = nil
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_NegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                          "",
+		"gzip, deflate, br":         "gzip",
+		"deflate;q=1, gzip;q=0.5":   "deflate",
+		"gzip;q=0, deflate":         "deflate",
+		"br, *;q=0.1":               "gzip",
+		"identity":                  "",
+		"GZIP;q=0.8, deflate;q=0.8": "gzip",
+		"*;q=0":                     "",
+	}
+	for accept, want := range tests {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("%q: %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func Test_Compress(t *testing.T) {
+	conf, err := config.NewFromData(nil, []byte(`<xml><Compress><MinSize>100</MinSize><ExcludePath>/skip</ExcludePath></Compress></xml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	large := strings.Repeat(`{"_data":"abc"}`, 100)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(configContextKey, conf)
+	}, Compress(), ETag(), PrintPostData())
+	echo := func(c *gin.Context) {
+		body, _ := ioutil.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", bytes.Repeat(body, 100))
+	}
+	r.POST("/echo", echo)
+	r.POST("/raw", WithoutCompress(), func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/small", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/list", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/skip", func(c *gin.Context) { c.String(http.StatusOK, large) })
+	r.GET("/png", func(c *gin.Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/plain")
+		c.Writer.WriteString("a")
+		c.Writer.Flush()
+		c.Writer.WriteString("b")
+	})
+
+	do := func(method, path, encoding, ifNoneMatch, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if encoding != "" {
+			req.Header.Set("Accept-Encoding", encoding)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 请求体仍然可以读取（PrintPostData 之后），响应按 Accept-Encoding 压缩
+	w := do(http.MethodPost, "/echo", "gzip", "", `{"a":1}`)
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil || w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("gzip: %v %v", err, w.Header())
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != strings.Repeat(`{"a":1}`, 100) {
+		t.Errorf("gzip body: %s", b)
+	}
+	if w.Header().Get("ETag") != "" {
+		t.Errorf("post etag: %s", w.Header().Get("ETag"))
+	}
+
+	w = do(http.MethodGet, "/list", "deflate, gzip;q=0.5", "", "")
+	zl, err := zlib.NewReader(w.Body)
+	if err != nil || w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("deflate: %v %v", err, w.Header())
+	}
+	if b, _ := ioutil.ReadAll(zl); string(b) != large {
+		t.Errorf("deflate body: %d", len(b))
+	}
+
+	// 不压缩
+	for _, path := range []string{"/small", "/skip", "/png"} {
+		if w = do(http.MethodGet, path, "gzip", "", ""); w.Header().Get("Content-Encoding") != "" || w.Body.Len() == 0 {
+			t.Errorf("%s: %v", path, w.Header())
+		}
+	}
+	if w = do(http.MethodPost, "/raw", "gzip", "", ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != large {
+		t.Errorf("raw: %v", w.Header())
+	}
+	if w = do(http.MethodGet, "/list", "", "", ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != large {
+		t.Errorf("identity: %v", w.Header())
+	}
+
+	// 流式响应 Flush 时开始压缩
+	w = do(http.MethodGet, "/stream", "gzip", "", "")
+	if zr, err = gzip.NewReader(w.Body); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != "ab" || w.Header().Get("ETag") != "" {
+		t.Errorf("stream: %s %v", b, w.Header())
+	}
+
+	// ETag 和压缩无关，相同时返回 304
+	etag := do(http.MethodGet, "/list", "gzip", "", "").Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) || do(http.MethodGet, "/list", "", "", "").Header().Get("ETag") != etag {
+		t.Fatalf("etag: %s", etag)
+	}
+	w = do(http.MethodGet, "/list", "gzip", `"x", `+strings.TrimPrefix(etag, "W/"), "")
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 || w.Header().Get("Content-Encoding") != "" || w.Header().Get("ETag") != etag {
+		t.Errorf("304: %d %v %d", w.Code, w.Header(), w.Body.Len())
+	}
+	if w = do(http.MethodGet, "/small", "", `W/"x"`, ""); w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("etag mismatch: %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+)
+
+/*
+  GET 请求的 ETag：缓存 200 的响应体，计算弱 ETag，和 If-None-Match 相同时返回 304（不返回响应体），服务配置 <ETag>：
+  <ETag><Enable>1</Enable><MaxSize>4194304</MaxSize><ExcludePath>/download</ExcludePath></ETag>
+  响应中有每次不同的内容（如报文头的时间戳）时，处理函数使用 SetETagKey 指定计算 ETag 的内容，app.JsonResponse 等已处理
+  不计算：处理函数已设置 ETag/Last-Modified、Cache-Control: no-store、响应超过 MaxSize、Flush 的流式响应，
+  以及使用路由中间件 WithoutETag 的路由（app.POST 的 WithoutETag）
+  在 Compress 之后注册，按未压缩的内容计算，304 不经过压缩
+*/
+
+const (
+	defaultETagMaxSize = 4 << 20
+
+	etagContextKey = "goframe.etag"
+)
+
+// GET 响应的 ETag 和 If-None-Match，配置读取请求所属应用的服务配置
+func ETag() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method != http.MethodGet && method != http.MethodHead {
+			c.Next()
+			return
+		}
+		conf := configFromContext(c).GetETagConfig()
+		if !conf.Enabled() || c.GetHeader("Upgrade") != "" || hasPathPrefix(c.Request.URL.Path, conf.ExcludePaths) ||
+			requestRouteOptions(c).noETag || IsStreaming(c) {
+			c.Next()
+			return
+		}
+
+		w := &etagWriter{ResponseWriter: c.Writer, maxSize: conf.MaxSize}
+		if w.maxSize <= 0 {
+			w.maxSize = defaultETagMaxSize
+		}
+		c.Writer = w
+		c.Set(etagContextKey, w)
+		defer func() {
+			c.Writer = w.ResponseWriter
+		}()
+
+		c.Next()
+		w.finish(c.GetHeader("If-None-Match"))
+	}
+}
+
+// 请求是否会计算 ETag，用于判断是否需要调用 SetETagKey
+func ETagActive(c *gin.Context) bool {
+	w := etagWriterFrom(c)
+	return w != nil && !w.passthrough
+}
+
+// 按 key 计算响应的 ETag，而不是响应体
+func SetETagKey(c *gin.Context, key []byte) {
+	if w := etagWriterFrom(c); w != nil {
+		w.key = etagOf(key)
+	}
+}
+
+func etagWriterFrom(c *gin.Context) *etagWriter {
+	if v, ok := c.Get(etagContextKey); ok {
+		if w, ok := v.(*etagWriter); ok {
+			return w
+		}
+	}
+	return nil
+}
+
+func etagOf(b []byte) string {
+	sum := sha1.Sum(b)
+	return `W/"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// If-None-Match 中是否有 etag，按弱比较
+func etagMatch(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, v := range strings.Split(ifNoneMatch, ",") {
+		v = strings.TrimSpace(v)
+		if v == "*" || strings.TrimPrefix(v, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// 缓存响应体，不满足计算 ETag 的条件时改为直接写入
+type etagWriter struct {
+	gin.ResponseWriter
+	maxSize int
+
+	buf         bytes.Buffer
+	passthrough bool
+	key         string
+}
+
+func (w *etagWriter) Write(b []byte) (int, error) {
+	if !w.passthrough && w.buf.Len() == 0 && !w.cacheable() {
+		_ = w.pass()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() > w.maxSize {
+		if err := w.pass(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *etagWriter) WriteHeaderNow() {
+	_ = w.pass()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *etagWriter) Flush() {
+	_ = w.pass()
+	w.ResponseWriter.Flush()
+}
+
+func (w *etagWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *etagWriter) Size() int {
+	if w.buf.Len() > 0 {
+		return w.buf.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *etagWriter) cacheable() bool {
+	header := w.Header()
+	return w.Status() == http.StatusOK && header.Get("ETag") == "" && header.Get("Last-Modified") == "" &&
+		!strings.Contains(header.Get("Cache-Control"), "no-store")
+}
+
+// 不计算 ETag，写入缓存的数据
+func (w *etagWriter) pass() error {
+	if w.passthrough {
+		return nil
+	}
+	w.passthrough = true
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+// 处理函数返回后计算 ETag，和 If-None-Match 相同时返回 304
+func (w *etagWriter) finish(ifNoneMatch string) {
+	if w.passthrough || (w.buf.Len() == 0 && w.key == "") || !w.cacheable() {
+		_ = w.pass()
+		return
+	}
+
+	etag := w.key
+	if etag == "" {
+		etag = etagOf(w.buf.Bytes())
+	}
+	header := w.Header()
+	header.Set("ETag", etag)
+	if etagMatch(ifNoneMatch, etag) {
+		header.Del("Content-Length")
+		header.Del("Content-Type")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		w.ResponseWriter.WriteHeaderNow()
+		w.passthrough = true
+		w.buf.Reset()
+		return
+	}
+	_ = w.pass()
+}
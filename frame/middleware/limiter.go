@@ -3,7 +3,6 @@ package middleware
 import (
 	"context"
 	"github.com/gin-gonic/gin"
-	jsoniter "github.com/json-iterator/go"
 	"github.com/mutou1225/go-frame/frame/errcode"
 	"github.com/mutou1225/go-frame/frame/protocol"
 	"golang.org/x/time/rate"
@@ -26,11 +25,10 @@ func Limiter(maxBurstSize int) gin.HandlerFunc {
 		reqMsg := protocol.SubsysReqBody{}
 		body, _ := ioutil.ReadAll(c.Request.Body)
 
-		var json = jsoniter.ConfigCompatibleWithStandardLibrary
-		_ = json.Unmarshal(body, &reqMsg)
+		_ = DecodeRequest(c, body, &reqMsg)
 		reqMsg.Head.MsgType = "response"
 		reqMsg.Head.Timestamp = strconv.FormatInt(time.Now().UTC().Unix(), 10)
-		jsonResponsev2(c, http.StatusInternalServerError, errcode.ERROR_LIMINT, reqMsg.Head)
+		jsonResponse(c, http.StatusInternalServerError, errcode.ERROR_LIMINT, &reqMsg.Head)
 		c.Abort()
 		return
 	}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 维护模式：state 返回是否在维护模式和返回的错误，维护模式下直接返回错误，不执行接口
+func Maintenance(state func(c *gin.Context) (bool, errcode.AppError)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		on, appErr := state(c)
+		if !on {
+			c.Next()
+			return
+		}
+
+		reqMsg := protocol.SubsysReqBody{}
+		body, _ := ioutil.ReadAll(c.Request.Body)
+
+		_ = DecodeRequest(c, body, &reqMsg)
+		reqMsg.Head.MsgType = "response"
+		reqMsg.Head.Timestamp = strconv.FormatInt(time.Now().UTC().Unix(), 10)
+		jsonResponse(c, http.StatusServiceUnavailable, appErr, &reqMsg.Head)
+		c.Abort()
+	}
+}
@@ -2,7 +2,6 @@ package middleware
 
 import (
 	"github.com/gin-gonic/gin"
-	jsoniter "github.com/json-iterator/go"
 	"github.com/mutou1225/go-frame/frame/errcode"
 	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/logger"
@@ -21,11 +20,10 @@ func ThrowPanic() gin.HandlerFunc {
 				reqMsg := protocol.SubsysReqBody{}
 				body, _ := ioutil.ReadAll(c.Request.Body)
 
-				var json = jsoniter.ConfigCompatibleWithStandardLibrary
-				_ = json.Unmarshal(body, &reqMsg)
+				_ = DecodeRequest(c, body, &reqMsg)
 				reqMsg.Head.MsgType = "response"
 				reqMsg.Head.Timestamp = strconv.FormatInt(time.Now().UTC().Unix(), 10)
-				jsonResponsev2(c, http.StatusInternalServerError, errcode.ERROR_SERVER_ERROR, reqMsg.Head)
+				jsonResponse(c, http.StatusInternalServerError, errcode.ERROR_SERVER_ERROR, &reqMsg.Head)
 				c.Abort()
 			}
 		}(c)
@@ -3,6 +3,7 @@ package middleware
 import (
 	"bytes"
 	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/implements/opentracing"
 	"github.com/mutou1225/go-frame/logger"
 	"io/ioutil"
@@ -25,10 +26,18 @@ func PrintPostData() gin.HandlerFunc {
 				strTracing = v[0]
 			}
 		}
-		logger.PrintInfo("%spacket:%s %s", logger.Purple, logger.Reset, body)
+		if codec := RequestCodec(c); codec != protocol.JSONCodec {
+			// 二进制报文转换为 JSON 打印，protobuf 只有报文头
+			reqMsg := protocol.SubsysReqBody{}
+			_ = codec.Unmarshal(body, &reqMsg)
+			packet, _ := protocol.JSONCodec.Marshal(reqMsg)
+			logger.PrintInfo("%spacket:%s [%s %d bytes] %s", logger.Purple, logger.Reset, codec.Name(), len(body), packet)
+		} else {
+			logger.PrintInfo("%spacket:%s %s", logger.Purple, logger.Reset, body)
+		}
 
 		// 开启OpenTracing
-		opentracing.GetOpenTracing().FromContextSetName(strTracing, c.Request.URL.Path)
+		opentracing.FromContext(c).FromContextSetName(strTracing, c.Request.URL.Path)
 
 		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
 		c.Next()
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/protocol"
+)
+
+/*
+  响应协议版本的协商，业务响应和中间件返回的错误使用同样的规则：
+  1. 路由指定的版本：路由中间件 Protocol（app.POST 的 WithProtocol）
+  2. 服务配置中调用方的版本：<Caller><id/><key/><protocol>1</protocol></Caller>，调用方为 _callerServiceId 或 HSB-OPENAPI-CALLERSERVICEID
+  3. 请求头 _version：服务配置 <Protocol><Version><Value/><Protocol/></Version></Protocol> 优先，其次 1.0/1.5/2.0 对应 V1/V15/V2
+  4. 服务配置 <Protocol><Default>，默认 ProtocolV2
+*/
+
+// 同 app.ConfigContextKey
+const configContextKey = "goframe.config"
+
+// 指定路由的响应协议版本，中间件在执行到处理函数之前返回的错误也使用该版本
+func Protocol(pType protocol.ProtocolType) gin.HandlerFunc {
+	switch pType {
+	case protocol.ProtocolV1:
+		return protocolV1
+	case protocol.ProtocolV15:
+		return protocolV15
+	case protocol.ProtocolV2:
+		return protocolV2
+	}
+	panic(fmt.Sprintf("middleware.Protocol: invalid protocol %d", pType))
+}
+
+// 请求使用的响应协议版本，head 为 nil 时只按路由和调用方
+func NegotiateProtocol(c *gin.Context, head *protocol.SubsysHeader) protocol.ProtocolType {
+	if pType := requestRouteOptions(c).protocol; pType != 0 {
+		return pType
+	}
+
+	callers := []string{c.GetHeader("HSB-OPENAPI-CALLERSERVICEID")}
+	if head != nil {
+		callers = append([]string{head.CallServiceId}, callers...)
+	}
+	conf := configFromContext(c)
+	for _, caller := range callers {
+		if v, ok := conf.GetCallerProtocol(caller); ok && protocol.ProtocolType(v).Valid() {
+			return protocol.ProtocolType(v)
+		}
+	}
+
+	protoConf := conf.GetProtocolConfig()
+	if head != nil && head.Version != "" {
+		for _, v := range protoConf.Versions {
+			if v.Value == head.Version && protocol.ProtocolType(v.Protocol).Valid() {
+				return protocol.ProtocolType(v.Protocol)
+			}
+		}
+		if pType, ok := protocol.VersionProtocol(head.Version); ok {
+			return pType
+		}
+	}
+
+	if protocol.ProtocolType(protoConf.Default).Valid() {
+		return protocol.ProtocolType(protoConf.Default)
+	}
+	return protocol.ProtocolV2
+}
+
+// 请求所属应用的配置实例（app.ConfigContextKey），没有时使用默认实例
+func configFromContext(c *gin.Context) *config.Config {
+	if v, ok := c.Get(configContextKey); ok {
+		if conf, ok := v.(*config.Config); ok && conf != nil {
+			return conf
+		}
+	}
+	return config.Default()
+}
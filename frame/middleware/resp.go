@@ -3,22 +3,17 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/implements/opentracing"
 	"strconv"
 )
 
-// 接口响应数据结构封装
-func jsonResponsev2(ctx *gin.Context, httpCode int, err errcode.AppError, head interface{}) {
-	ctx.JSON(httpCode, gin.H{
-		"_data": gin.H{
-			"_ret": strconv.Itoa(err.ErrorCode),
-			"_errCode": strconv.Itoa(err.ErrorCode),
-			"_errStr": err.ErrorInfo,
-		},
-		"_head": head,
-	})
+// 接口响应数据结构封装，协议版本按 NegotiateProtocol 协商，编码按 ResponseCodec 协商
+func jsonResponse(ctx *gin.Context, httpCode int, err errcode.AppError, head *protocol.SubsysHeader) {
+	pType := NegotiateProtocol(ctx, head)
+	Render(ctx, httpCode, protocol.NewRspBody(pType, head, err.ErrorCode, err.ErrorInfo, nil))
 
-	ot := opentracing.GetOpenTracing()
+	ot := opentracing.FromContext(ctx)
 	if err.ErrorCode != 0 {
 		ot.SetTag("error", strconv.Itoa(err.ErrorCode))
 		ot.SetTag("error.kind", err.ErrorInfo)
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"reflect"
+	"runtime"
+)
+
+/*
+  路由选项：注册路由时放在处理函数之前的中间件，也可以用于路由组（Group.Use）
+  r.POST("/order/list", middleware.Protocol(protocol.ProtocolV1), middleware.WithoutCompress(), api.OrderListApi)
+  r.GET("/price/progress", middleware.Streaming(), api.ProgressApi)
+  执行时把路由的选项写入请求上下文；签名、超时、压缩、ETag 等全局中间件在它之前执行，按请求处理链的函数名（HandlerNames）读取
+*/
+
+const routeOptionsContextKey = "goframe.route"
+
+// 路由的选项
+type routeOptions struct {
+	protocol   protocol.ProtocolType // 0 时协商
+	noCompress bool
+	noETag     bool
+	streaming  bool
+}
+
+// 路由选项中间件的函数名 -> 设置的选项
+var routeOptionFuncs = make(map[string]func(o *routeOptions))
+
+func init() {
+	addRouteOption(protocolV1, func(o *routeOptions) { o.protocol = protocol.ProtocolV1 })
+	addRouteOption(protocolV15, func(o *routeOptions) { o.protocol = protocol.ProtocolV15 })
+	addRouteOption(protocolV2, func(o *routeOptions) { o.protocol = protocol.ProtocolV2 })
+	addRouteOption(withoutCompress, func(o *routeOptions) { o.noCompress = true })
+	addRouteOption(withoutETag, func(o *routeOptions) { o.noETag = true })
+	addRouteOption(streaming, func(o *routeOptions) { o.streaming = true })
+}
+
+func addRouteOption(f gin.HandlerFunc, set func(o *routeOptions)) {
+	routeOptionFuncs[nameOfFunction(f)] = set
+}
+
+// 同 gin 的 HandlerNames
+func nameOfFunction(f gin.HandlerFunc) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
+// 选项中间件按函数名识别，每个选项是一个独立的函数
+func protocolV1(c *gin.Context)      { requestRouteOptions(c) }
+func protocolV15(c *gin.Context)     { requestRouteOptions(c) }
+func protocolV2(c *gin.Context)      { requestRouteOptions(c) }
+func withoutCompress(c *gin.Context) { requestRouteOptions(c) }
+func withoutETag(c *gin.Context)     { requestRouteOptions(c) }
+func streaming(c *gin.Context)       { requestRouteOptions(c) }
+
+// 请求所属路由的选项，第一次读取时按处理链解析并保存到请求上下文
+func requestRouteOptions(c *gin.Context) *routeOptions {
+	if v, ok := c.Get(routeOptionsContextKey); ok {
+		if o, ok := v.(*routeOptions); ok {
+			return o
+		}
+	}
+	o := parseRouteOptions(c.HandlerNames())
+	c.Set(routeOptionsContextKey, o)
+	return o
+}
+
+func parseRouteOptions(names []string) *routeOptions {
+	o := &routeOptions{}
+	for _, name := range names {
+		if set, ok := routeOptionFuncs[name]; ok {
+			set(o)
+		}
+	}
+	return o
+}
+
+// 处理链（函数名，同 gin 的 HandlerNames）指定的协议版本，用于生成接口文档
+func RouteProtocol(names []string) (protocol.ProtocolType, bool) {
+	pType := parseRouteOptions(names).protocol
+	return pType, pType != 0
+}
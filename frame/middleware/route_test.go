@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_RouteOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	// 全局中间件在路由中间件之前执行，也能读取路由的选项
+	var pType protocol.ProtocolType
+	var streaming bool
+	r.Use(func(c *gin.Context) {
+		pType, streaming = NegotiateProtocol(c, nil), IsStreaming(c)
+	})
+	r.GET("/v1", Protocol(protocol.ProtocolV1), Streaming(), func(c *gin.Context) {})
+	r.GET("/default", func(c *gin.Context) {})
+
+	for path, want := range map[string]protocol.ProtocolType{"/v1": protocol.ProtocolV1, "/default": protocol.ProtocolV2} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if pType != want || streaming != (path == "/v1") {
+			t.Errorf("%s: protocol %d streaming %t", path, pType, streaming)
+		}
+	}
+
+	if p, ok := RouteProtocol([]string{nameOfFunction(Protocol(protocol.ProtocolV15))}); !ok || p != protocol.ProtocolV15 {
+		t.Errorf("RouteProtocol: %d %t", p, ok)
+	}
+}
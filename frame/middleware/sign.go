@@ -5,8 +5,6 @@ import (
 	"crypto/md5"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	jsoniter "github.com/json-iterator/go"
-	"github.com/mutou1225/go-frame/config"
 	"github.com/mutou1225/go-frame/frame/errcode"
 	"github.com/mutou1225/go-frame/frame/protocol"
 	"github.com/mutou1225/go-frame/logger"
@@ -26,14 +24,20 @@ func apiSign(req string, key string) string {
 // 签名校验
 func CheckCallSign() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if IsStreaming(c) {
+			checkStreamSign(c)
+			return
+		}
+
 		reqMsg := protocol.SubsysReqBody{}
 		body, _ := ioutil.ReadAll(c.Request.Body)
 
-		var json = jsoniter.ConfigCompatibleWithStandardLibrary
-		err := json.Unmarshal(body, &reqMsg)
+		// 签名按原始字节计算，报文头按请求体的编码解析
+		err := DecodeRequest(c, body, &reqMsg)
 		if err != nil {
 			logger.PrintInfo("请求参数解析失败")
-			jsonResponsev2(c, http.StatusBadRequest, errcode.INVALID_PARAMS, protocol.SubsysGetBadHeader())
+			head := protocol.SubsysGetBadHeader()
+			jsonResponse(c, http.StatusBadRequest, errcode.INVALID_PARAMS, &head)
 			c.Abort()
 			return
 		}
@@ -60,7 +64,7 @@ func CheckCallSign() gin.HandlerFunc {
 				break
 			}
 
-			callerKey, ok := config.GetCallerKey(callerId)
+			callerKey, ok := configFromContext(c).GetCallerKey(callerId)
 			if !ok {
 				logger.PrintInfo("非法的ServerId!")
 				errCode = errcode.ERROR_DENY_SERVICE_ID
@@ -81,7 +85,7 @@ func CheckCallSign() gin.HandlerFunc {
 		if errCode.ErrorCode != 0 {
 			reqMsg.Head.MsgType = "response"
 			reqMsg.Head.Timestamp = strconv.FormatInt(time.Now().UTC().Unix(), 10)
-			jsonResponsev2(c, http.StatusUnauthorized, errCode, reqMsg.Head)
+			jsonResponse(c, http.StatusUnauthorized, errCode, &reqMsg.Head)
 			c.Abort()
 		} else {
 			c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(body))
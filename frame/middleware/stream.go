@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/logger"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+/*
+  流式路由（路由中间件 Streaming，frame/stream 的 SSE、WebSocket 已经加入）：
+  GET 请求没有报文，浏览器的 EventSource、WebSocket 不能设置请求头，签名和 token 可以放在 URL 参数中
+  签名：_callerServiceId、_timestamp、_signature（或请求头 HSB-OPENAPI-CALLERSERVICEID、HSB-OPENAPI-SIGNATURE），
+    按去掉 _signature 后排序的 URL 参数计算：md5(a=1&_callerServiceId=116006&_timestamp=1700000000_key)，_timestamp 前后 5 分钟内有效
+  token：请求头 token 或 URL 参数 token（CheckUserToken）
+  不使用超时（TimeoutMiddleware）、压缩、ETag
+*/
+
+const (
+	streamSignSkew = 5 * time.Minute
+
+	streamCallerParam    = "_callerServiceId"
+	streamTimestampParam = "_timestamp"
+	streamSignatureParam = "_signature"
+)
+
+// 标记为流式路由，frame/stream 的 SSE、WebSocket 已经加入
+func Streaming() gin.HandlerFunc {
+	return streaming
+}
+
+// 是否为流式路由
+func IsStreaming(c *gin.Context) bool {
+	return requestRouteOptions(c).streaming
+}
+
+// 流式路由的签名校验，签名按 URL 参数计算
+func checkStreamSign(c *gin.Context) {
+	query := c.Request.URL.Query()
+	callerId := c.GetHeader("HSB-OPENAPI-CALLERSERVICEID")
+	if callerId == "" {
+		callerId = query.Get(streamCallerParam)
+	}
+	sign := c.GetHeader("HSB-OPENAPI-SIGNATURE")
+	if sign == "" {
+		sign = query.Get(streamSignatureParam)
+	}
+	query.Del(streamSignatureParam)
+
+	errCode := errcode.SUCCESS
+	for i := 0; i < 1; i++ {
+		if callerId == "" {
+			logger.PrintInfo("stream 调用方为空: %s", c.Request.URL.Path)
+			errCode = errcode.ERRO_SERVICE_ID_FIELD_NO_EXIST
+			break
+		}
+		if sign == "" {
+			logger.PrintInfo("stream 签名为空: %s", c.Request.URL.Path)
+			errCode = errcode.ERROR_SIGN_FIELD_NO_EXIST
+			break
+		}
+
+		callerKey, ok := configFromContext(c).GetCallerKey(callerId)
+		if !ok {
+			logger.PrintInfo("非法的ServerId!")
+			errCode = errcode.ERROR_DENY_SERVICE_ID
+			break
+		}
+
+		timestamp, err := strconv.ParseInt(query.Get(streamTimestampParam), 10, 64)
+		if err != nil || time.Since(time.Unix(timestamp, 0)) > streamSignSkew || time.Until(time.Unix(timestamp, 0)) > streamSignSkew {
+			logger.PrintInfo("stream _timestamp 无效: %s", query.Get(streamTimestampParam))
+			errCode = errcode.ERROR_SIGN
+			break
+		}
+
+		if localSign := apiSign(query.Encode(), callerKey); localSign != sign {
+			logger.PrintInfo("签名检验失败 ")
+			logger.PrintInfo("Request:%s", sign)
+			logger.PrintInfo("Local:%s", localSign)
+			errCode = errcode.ERROR_SIGN
+			break
+		}
+	}
+
+	if errCode.ErrorCode != 0 {
+		head := streamHeader(c, callerId)
+		jsonResponse(c, http.StatusUnauthorized, errCode, &head)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// 流式路由的错误响应使用的报文头
+func streamHeader(c *gin.Context, callerId string) protocol.SubsysHeader {
+	head := protocol.SubsysGetBadHeader()
+	head.Interface = c.Request.URL.Path
+	if callerId != "" {
+		head.CallServiceId = callerId
+	}
+	return head
+}
+
+// 流式路由的签名参数，调用方生成 SSE、WebSocket 的地址时使用
+func StreamSignQuery(query url.Values, callerId, key string) url.Values {
+	signed := url.Values{}
+	for k, v := range query {
+		signed[k] = append([]string(nil), v...)
+	}
+	signed.Del(streamSignatureParam)
+	signed.Set(streamCallerParam, callerId)
+	signed.Set(streamTimestampParam, strconv.FormatInt(time.Now().Unix(), 10))
+	signed.Set(streamSignatureParam, apiSign(signed.Encode(), key))
+	return signed
+}
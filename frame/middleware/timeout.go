@@ -7,9 +7,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// TimeoutMiddleware  超时控制
+// TimeoutMiddleware  超时控制，流式路由（SSE、WebSocket）不超时
 func TimeoutMiddleware(timeout time.Duration) func(c *gin.Context) {
 	return func(c *gin.Context) {
+		if IsStreaming(c) {
+			c.Next()
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
 		defer cancel()
 
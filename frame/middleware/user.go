@@ -11,19 +11,23 @@ var auth = NewJWT()
 func CheckUserToken() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Request.Header.Get("token")
+		if token == "" && IsStreaming(c) {
+			// EventSource、WebSocket 不能设置请求头
+			token = c.Query("token")
+		}
 		if token == "" {
-			jsonResponsev2(c, http.StatusUnauthorized, errcode.ERROR_TOKEN_EMPTY, "")
+			jsonResponse(c, http.StatusUnauthorized, errcode.ERROR_TOKEN_EMPTY, nil)
 			c.Abort()
 			return
 		}
 		claims, err := auth.ParseToken(token)
 		if err != nil {
-			jsonResponsev2(c, http.StatusUnauthorized, errcode.ERROR_TOKEN_INVALID, "")
+			jsonResponse(c, http.StatusUnauthorized, errcode.ERROR_TOKEN_INVALID, nil)
 			c.Abort()
 			return
 		}
 		if claims == nil || claims.UID == 0 {
-			jsonResponsev2(c, http.StatusUnauthorized, errcode.ERROR_TOKEN_INVALID, "")
+			jsonResponse(c, http.StatusUnauthorized, errcode.ERROR_TOKEN_INVALID, nil)
 			c.Abort()
 			return
 		}
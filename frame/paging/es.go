@@ -0,0 +1,178 @@
+package paging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/elastic/go-elasticsearch/v6"
+	"io/ioutil"
+	"reflect"
+)
+
+type esResult struct {
+	Hits struct {
+		Total json.RawMessage `json:"total"`
+		Hits  []struct {
+			Source json.RawMessage `json:"_source"`
+			Sort   []interface{}   `json:"sort"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// 页码模式：body 为查询语句（query、sort、_source 等），from、size 由分页设置；dest 的元素按 _source 解析
+func ES(ctx context.Context, client *elasticsearch.Client, index string, body map[string]interface{}, req Request, dest interface{}) (*Page, error) {
+	query := copyBody(body)
+	query["from"] = req.Offset()
+	query["size"] = req.Limit()
+
+	result, err := esSearch(ctx, client, index, query)
+	if err != nil {
+		return nil, err
+	}
+	total, err := esTotal(result.Hits.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	page := newPage(req, total)
+	hits := make([]json.RawMessage, 0, len(result.Hits.Hits))
+	for _, v := range result.Hits.Hits {
+		hits = append(hits, v.Source)
+	}
+	return page, esDecode(hits, dest)
+}
+
+// 游标模式：使用 search_after，body 中需要有 sort，最后一个排序字段的值需要唯一（如 _id 之外的主键）
+func ESSearchAfter(ctx context.Context, client *elasticsearch.Client, index string, body map[string]interface{}, req Request, dest interface{}) (*Page, error) {
+	if _, ok := body["sort"]; !ok {
+		return nil, errors.New("paging: search_after needs sort")
+	}
+
+	query := copyBody(body)
+	query["size"] = req.Limit() + 1
+	if req.Cursor != "" {
+		values, err := DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query["search_after"] = values
+	}
+
+	result, err := esSearch(ctx, client, index, query)
+	if err != nil {
+		return nil, err
+	}
+	total, err := esTotal(result.Hits.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &Page{PageSize: req.Limit(), Total: total}
+	list := result.Hits.Hits
+	if len(list) > req.Limit() {
+		list = list[:req.Limit()]
+		page.HasMore = true
+		if page.NextCursor, err = EncodeCursor(list[len(list)-1].Sort...); err != nil {
+			return nil, err
+		}
+	}
+
+	hits := make([]json.RawMessage, 0, len(list))
+	for _, v := range list {
+		hits = append(hits, v.Source)
+	}
+	return page, esDecode(hits, dest)
+}
+
+func copyBody(body map[string]interface{}) map[string]interface{} {
+	query := make(map[string]interface{}, len(body)+2)
+	for k, v := range body {
+		query[k] = v
+	}
+	return query
+}
+
+func esSearch(ctx context.Context, client *elasticsearch.Client, index string, query map[string]interface{}) (*esResult, error) {
+	if client == nil {
+		return nil, errors.New("paging: es client nil")
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, err
+	}
+
+	res, err := client.Search(
+		client.Search.WithContext(ctx),
+		client.Search.WithIndex(index),
+		client.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("paging: es search status %d: %s", res.StatusCode, data)
+	}
+
+	result := &esResult{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ES6 的 total 为数字，ES7 为 {"value": n}
+func esTotal(raw json.RawMessage) (int64, error) {
+	if len(raw) == 0 {
+		return 0, nil
+	}
+	var total int64
+	if err := json.Unmarshal(raw, &total); err == nil {
+		return total, nil
+	}
+	var obj struct {
+		Value int64 `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return 0, err
+	}
+	return obj.Value, nil
+}
+
+// _source 解析到 dest 的元素
+func esDecode(hits []json.RawMessage, dest interface{}) error {
+	slice, err := sliceOf(dest)
+	if err != nil {
+		return err
+	}
+
+	elemType := slice.Type().Elem()
+	ret := reflect.MakeSlice(slice.Type(), 0, len(hits))
+	for _, v := range hits {
+		if elemType.Kind() == reflect.Ptr {
+			elem := reflect.New(elemType.Elem())
+			if err := json.Unmarshal(v, elem.Interface()); err != nil {
+				return err
+			}
+			ret = reflect.Append(ret, elem)
+		} else {
+			elem := reflect.New(elemType)
+			if err := json.Unmarshal(v, elem.Interface()); err != nil {
+				return err
+			}
+			ret = reflect.Append(ret, elem.Elem())
+		}
+	}
+	slice.Set(ret)
+	return nil
+}
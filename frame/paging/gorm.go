@@ -0,0 +1,45 @@
+package paging
+
+import (
+	"gorm.io/gorm"
+)
+
+// 页码模式：先查总数，再按 offset、limit 查询；db 为已经加上条件的查询
+func Gorm(db *gorm.DB, req Request, dest interface{}) (*Page, error) {
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	page := newPage(req, total)
+	if total == 0 || int64(req.Offset()) >= total {
+		return page, nil
+	}
+
+	if err := db.Offset(req.Offset()).Limit(req.Limit()).Find(dest).Error; err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// 游标模式：按 ks.Column 排序，取游标之后的一页；Total 为不加游标条件的总数
+func GormKeyset(db *gorm.DB, req Request, ks Keyset, dest interface{}) (*Page, error) {
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	op, order := ks.after()
+	query := db.Order(ks.Column + " " + order).Limit(req.Limit() + 1)
+	if req.Cursor != "" {
+		values, err := DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(ks.Column+" "+op+" ?", values[0])
+	}
+
+	if err := query.Find(dest).Error; err != nil {
+		return nil, err
+	}
+	return keysetPage(req, ks, total, dest)
+}
@@ -0,0 +1,58 @@
+package paging
+
+import (
+	"github.com/qiniu/qmgo"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 页码模式：先查总数，再按 skip、limit 查询；q 为已经加上条件、排序、字段的查询
+func Qmgo(q qmgo.QueryI, req Request, dest interface{}) (*Page, error) {
+	total, err := q.Count()
+	if err != nil {
+		return nil, err
+	}
+	page := newPage(req, total)
+	if total == 0 || int64(req.Offset()) >= total {
+		return page, nil
+	}
+
+	if err := q.Skip(int64(req.Offset())).Limit(int64(req.Limit())).All(dest); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// 游标模式：find 按条件创建查询（可以加上 Select），按 ks.Column 排序，取游标之后的一页
+// find := func(filter bson.M) qmgo.QueryI { return coll.Find(ctx, filter).Select(field) }
+func QmgoKeyset(find func(filter bson.M) qmgo.QueryI, filter bson.M, req Request, ks Keyset, dest interface{}) (*Page, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	total, err := find(filter).Count()
+	if err != nil {
+		return nil, err
+	}
+
+	sort, op := ks.Column, "$gt"
+	if ks.Desc {
+		sort, op = "-"+ks.Column, "$lt"
+	}
+	query := filter
+	if req.Cursor != "" {
+		values, err := DecodeCursor(req.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cond := bson.M{ks.Column: bson.M{op: values[0]}}
+		if len(filter) == 0 {
+			query = cond
+		} else {
+			query = bson.M{"$and": []bson.M{filter, cond}}
+		}
+	}
+
+	if err := find(query).Sort(sort).Limit(int64(req.Limit() + 1)).All(dest); err != nil {
+		return nil, err
+	}
+	return keysetPage(req, ks, total, dest)
+}
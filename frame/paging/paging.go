@@ -0,0 +1,233 @@
+package paging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/*
+  分页：MySQL（gorm）、Mongo（qmgo）、Elasticsearch 使用同样的请求和结果
+  页码模式：pageIndex 从 0 开始，offset = pageIndex * pageSize
+  游标模式：按唯一的排序字段（如主键）取下一页，不用 offset，深分页不变慢；ES 使用 search_after
+  查询结果写入 dest（切片的指针），返回的 Page 转换为 protocol.SubsysTotal
+  req := paging.Parse(params.PageIndex, params.PageSize)
+  var list []TProduct
+  page, err := paging.Gorm(db.Table(TTProduct).Where(...), req, &list)
+  retData.Total = page.SubsysTotal()
+*/
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 500
+)
+
+var ErrInvalidCursor = errors.New("paging: invalid cursor")
+
+// 游标中 ObjectID、time.Time 的类型标记，同 Mongo Extended JSON
+const (
+	cursorObjectId = "$oid"
+	cursorDate     = "$date"
+)
+
+// 分页请求
+type Request struct {
+	PageIndex int
+	PageSize  int
+	// 游标模式的游标，第一页为空
+	Cursor string
+}
+
+// 解析字符串的页码、每页数量：页码错误时为 0，每页数量错误时为 DefaultPageSize，超过 MaxPageSize 时为 MaxPageSize
+func Parse(pageIndex, pageSize string) Request {
+	index, err := strconv.Atoi(pageIndex)
+	if err != nil || index < 0 {
+		index = 0
+	}
+	return Request{PageIndex: index, PageSize: parseSize(pageSize)}
+}
+
+// 游标模式的请求
+func ParseCursor(cursor, pageSize string) Request {
+	return Request{Cursor: cursor, PageSize: parseSize(pageSize)}
+}
+
+func parseSize(pageSize string) int {
+	size, err := strconv.Atoi(pageSize)
+	if err != nil || size <= 0 {
+		return DefaultPageSize
+	} else if size > MaxPageSize {
+		return MaxPageSize
+	}
+	return size
+}
+
+func (r Request) Offset() int {
+	return r.PageIndex * r.Limit()
+}
+
+func (r Request) Limit() int {
+	if r.PageSize <= 0 {
+		return DefaultPageSize
+	}
+	return r.PageSize
+}
+
+// 游标模式的排序字段，值需要唯一
+type Keyset struct {
+	// 排序的列名（MySQL）、字段名（Mongo）
+	Column string
+	// dest 元素中对应的 Go 字段名，用于生成下一页的游标
+	Field string
+	Desc  bool
+}
+
+// 分页结果
+type Page struct {
+	PageIndex int   `json:"pageIndex"`
+	PageSize  int   `json:"pageSize"`
+	Total     int64 `json:"total"`
+	// 游标模式下一页的游标，没有下一页时为空
+	NextCursor string `json:"nextCursor,omitempty"`
+	HasMore    bool   `json:"hasMore"`
+}
+
+func newPage(req Request, total int64) *Page {
+	page := &Page{PageIndex: req.PageIndex, PageSize: req.Limit(), Total: total}
+	page.HasMore = int64(req.Offset()+req.Limit()) < total
+	return page
+}
+
+// 转换为接口的分页信息
+func (p *Page) SubsysTotal() protocol.SubsysTotal {
+	return protocol.SubsysTotal{
+		PageIndex: strconv.Itoa(p.PageIndex),
+		PageSize:  strconv.Itoa(p.PageSize),
+		Total:     strconv.FormatInt(p.Total, 10),
+	}
+}
+
+// 游标为排序值的 JSON 数组，base64 编码
+// ObjectID、time.Time 编码为 {"$oid":"..."}、{"$date":"..."}，解析时还原类型
+func EncodeCursor(values ...interface{}) (string, error) {
+	tagged := make([]interface{}, len(values))
+	for i, v := range values {
+		switch x := v.(type) {
+		case primitive.ObjectID:
+			tagged[i] = map[string]string{cursorObjectId: x.Hex()}
+		case time.Time:
+			tagged[i] = map[string]string{cursorDate: x.Format(time.RFC3339Nano)}
+		default:
+			tagged[i] = v
+		}
+	}
+	data, err := json.Marshal(tagged)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// 解析游标，整数为 int64，其他数字为 float64，标记的类型为 primitive.ObjectID、time.Time
+func DecodeCursor(cursor string) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var values []interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil || len(values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	for i, v := range values {
+		switch x := v.(type) {
+		case json.Number:
+			if iv, err := x.Int64(); err == nil {
+				values[i] = iv
+			} else if fv, err := x.Float64(); err == nil {
+				values[i] = fv
+			}
+		case map[string]interface{}:
+			if values[i], err = decodeCursorType(x); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return values, nil
+}
+
+func decodeCursorType(m map[string]interface{}) (interface{}, error) {
+	if len(m) != 1 {
+		return nil, ErrInvalidCursor
+	}
+	if s, ok := m[cursorObjectId].(string); ok {
+		id, err := primitive.ObjectIDFromHex(s)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return id, nil
+	}
+	if s, ok := m[cursorDate].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return t, nil
+	}
+	return nil, ErrInvalidCursor
+}
+
+// 游标模式多取一条判断是否有下一页，多取的一条从 dest 中去掉，用最后一条生成游标
+func keysetPage(req Request, ks Keyset, total int64, dest interface{}) (*Page, error) {
+	page := &Page{PageSize: req.Limit(), Total: total}
+
+	slice, err := sliceOf(dest)
+	if err != nil {
+		return nil, err
+	}
+	if slice.Len() > req.Limit() {
+		slice.Set(slice.Slice(0, req.Limit()))
+		page.HasMore = true
+	}
+	if !page.HasMore || slice.Len() == 0 {
+		return page, nil
+	}
+
+	last := reflect.Indirect(slice.Index(slice.Len() - 1))
+	if last.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("paging: dest element %s not struct", last.Type())
+	}
+	field := last.FieldByName(ks.Field)
+	if !field.IsValid() {
+		return nil, fmt.Errorf("paging: field %s not found in %s", ks.Field, last.Type())
+	}
+	if page.NextCursor, err = EncodeCursor(field.Interface()); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func sliceOf(dest interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, errors.New("paging: dest must be a pointer to slice")
+	}
+	return v.Elem(), nil
+}
+
+// 游标条件的比较方向
+func (ks Keyset) after() (op, order string) {
+	if ks.Desc {
+		return "<", "DESC"
+	}
+	return ">", "ASC"
+}
@@ -0,0 +1,143 @@
+package paging
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/elastic/go-elasticsearch/v6"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testItem struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func Test_Paging(t *testing.T) {
+	req := Parse("2", "20")
+	if req.Offset() != 40 || req.Limit() != 20 {
+		t.Errorf("Parse: %+v", req)
+	}
+	if req = Parse("-1", "abc"); req.PageIndex != 0 || req.PageSize != DefaultPageSize {
+		t.Errorf("Parse invalid: %+v", req)
+	}
+	if req = Parse("0", "10000"); req.PageSize != MaxPageSize {
+		t.Errorf("Parse max: %+v", req)
+	}
+
+	page := newPage(Parse("1", "10"), 25)
+	if total := page.SubsysTotal(); !page.HasMore || total.PageIndex != "1" || total.PageSize != "10" || total.Total != "25" {
+		t.Errorf("SubsysTotal: %+v %+v", page, total)
+	}
+	if page = newPage(Parse("2", "10"), 25); page.HasMore {
+		t.Errorf("last page HasMore")
+	}
+
+	// 游标
+	cursor, _ := EncodeCursor(int64(1633017600000), "abc", 1.5)
+	values, err := DecodeCursor(cursor)
+	if err != nil || values[0] != int64(1633017600000) || values[1] != "abc" || values[2] != 1.5 {
+		t.Errorf("DecodeCursor: %v %v", values, err)
+	}
+	if _, err := DecodeCursor("!!"); err != ErrInvalidCursor {
+		t.Errorf("DecodeCursor invalid: %v", err)
+	}
+
+	// 多取的一条去掉，用最后一条生成游标
+	list := []testItem{{Id: 9}, {Id: 8}, {Id: 7}}
+	page, err = keysetPage(ParseCursor("", "2"), Keyset{Column: "id", Field: "Id", Desc: true}, 3, &list)
+	if err != nil || len(list) != 2 || !page.HasMore {
+		t.Fatalf("keysetPage: %+v %v %v", page, list, err)
+	}
+	if values, _ = DecodeCursor(page.NextCursor); values[0] != int64(8) {
+		t.Errorf("NextCursor: %v", values)
+	}
+	list = list[:1]
+	if page, _ = keysetPage(ParseCursor(page.NextCursor, "2"), Keyset{Field: "Id"}, 3, &list); page.HasMore || page.NextCursor != "" {
+		t.Errorf("keysetPage last: %+v", page)
+	}
+}
+
+// Mongo 的 ObjectID、时间游标解析后类型不变
+func Test_CursorTypes(t *testing.T) {
+	type mgoItem struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}
+	id := primitive.NewObjectID()
+	list := []mgoItem{{Id: id}, {Id: primitive.NewObjectID()}}
+	page, err := keysetPage(ParseCursor("", "1"), Keyset{Column: "_id", Field: "Id"}, 2, &list)
+	if err != nil || !page.HasMore {
+		t.Fatalf("keysetPage: %+v %v", page, err)
+	}
+	values, err := DecodeCursor(page.NextCursor)
+	if err != nil || values[0] != id {
+		t.Errorf("ObjectID cursor: %#v %v", values, err)
+	}
+
+	now := time.Now()
+	cursor, _ := EncodeCursor(now, "abc")
+	values, err = DecodeCursor(cursor)
+	if err != nil || len(values) != 2 || values[1] != "abc" {
+		t.Fatalf("time cursor: %#v %v", values, err)
+	}
+	if v, ok := values[0].(time.Time); !ok || !v.Equal(now) {
+		t.Errorf("time cursor: %#v", values[0])
+	}
+
+	cursor, _ = EncodeCursor(map[string]string{"$oid": "x"})
+	if _, err := DecodeCursor(cursor); err != ErrInvalidCursor {
+		t.Errorf("invalid ObjectID: %v", err)
+	}
+}
+
+func Test_ESSearchAfter(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = nil
+		_ = json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"hits":{"total":{"value":5},"hits":[
+			{"_source":{"id":5,"name":"a"},"sort":[5]},
+			{"_source":{"id":4,"name":"b"},"sort":[4]},
+			{"_source":{"id":3,"name":"c"},"sort":[3]}]}}`))
+	}))
+	defer srv.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := map[string]interface{}{"sort": []interface{}{map[string]string{"id": "desc"}}}
+
+	var list []testItem
+	page, err := ESSearchAfter(context.Background(), client, "test", query, ParseCursor("", "2"), &list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[1].Name != "b" || page.Total != 5 || !page.HasMore {
+		t.Errorf("ESSearchAfter: %+v %+v", page, list)
+	}
+	if body["size"] != float64(3) || body["search_after"] != nil {
+		t.Errorf("first page body: %v", body)
+	}
+
+	var next []*testItem
+	if _, err = ESSearchAfter(context.Background(), client, "test", query, ParseCursor(page.NextCursor, "2"), &next); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := body["search_after"].([]interface{}); len(after) != 1 || after[0] != float64(4) {
+		t.Errorf("search_after: %v", body["search_after"])
+	}
+	if _, ok := query["size"]; ok {
+		t.Error("body modified")
+	}
+
+	if _, err = ES(context.Background(), client, "test", query, Parse("1", "2"), &list); err != nil || body["from"] != float64(2) {
+		t.Errorf("ES: %v %v", body, err)
+	}
+}
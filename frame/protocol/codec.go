@@ -0,0 +1,333 @@
+package protocol
+
+import (
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/encoding/protowire"
+	"reflect"
+	"strings"
+)
+
+/*
+  报文编码：JSON（默认）、protobuf、msgpack，请求体按 Content-Type 解析，响应按 Accept 协商（middleware.ResponseCodec）
+  msgpack：和 JSON 相同的结构和字段名（_head、_param、_data ...），协议版本的区别同 JSON
+  protobuf：外层报文固定（subsys.proto），_param、_data 为业务定义的 message 编码后的字节，三个协议版本的外层报文相同
+    SubsysRequest  { SubsysHeader head = 1; bytes param = 2; }
+    SubsysResponse { SubsysHeader head = 1; string ret = 2; string code = 3; string msg = 4; bytes data = 5; bytes data_json = 6; }
+    _data 不是 proto.Message 时（如参数错误的字段列表）按 JSON 编码写入 data_json
+  签名按请求体的原始字节计算，和编码无关
+*/
+
+const (
+	MIMEJSON     = "application/json"
+	MIMEProtobuf = "application/x-protobuf"
+	MIMEMsgPack  = "application/x-msgpack"
+)
+
+// 报文的编码
+type Codec interface {
+	// json、protobuf、msgpack
+	Name() string
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var (
+	JSONCodec     Codec = jsonCodec{}
+	ProtobufCodec Codec = protobufCodec{}
+	MsgPackCodec  Codec = msgpackCodec{}
+
+	msgpackHandle = newMsgpackHandle()
+)
+
+// Content-Type 对应的编码，不是 JSON、protobuf、msgpack 时返回 false
+func CodecByContentType(contentType string) (Codec, bool) {
+	switch strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])) {
+	case MIMEJSON, "text/json":
+		return JSONCodec, true
+	case MIMEProtobuf, "application/protobuf":
+		return ProtobufCodec, true
+	case MIMEMsgPack, "application/msgpack", "application/vnd.msgpack":
+		return MsgPackCodec, true
+	}
+	return nil, false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string        { return "json" }
+func (jsonCodec) ContentType() string { return MIMEJSON }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, v)
+}
+
+// 字符串使用新的 str8 格式，解析到 interface{} 的 map 为 map[string]interface{}（可以再转换为 JSON）
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return h
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string        { return "msgpack" }
+func (msgpackCodec) ContentType() string { return MIMEMsgPack }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	err := codec.NewEncoderBytes(&b, msgpackHandle).Encode(v)
+	return b, err
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoderBytes(data, msgpackHandle).Decode(v)
+}
+
+// 报文头、请求、响应的字段号，同 subsys.proto
+const (
+	fieldHead     protowire.Number = 1
+	fieldParam    protowire.Number = 2
+	fieldRet      protowire.Number = 2
+	fieldCode     protowire.Number = 3
+	fieldMsg      protowire.Number = 4
+	fieldData     protowire.Number = 5
+	fieldDataJSON protowire.Number = 6
+)
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string        { return "protobuf" }
+func (protobufCodec) ContentType() string { return MIMEProtobuf }
+
+// 支持请求、响应报文和 proto.Message
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	switch x := v.(type) {
+	case SubsysReqBody:
+		return marshalPbRequest(&x)
+	case *SubsysReqBody:
+		return marshalPbRequest(x)
+	case SubsysRspBody:
+		return ProtobufCodec.Marshal(&x)
+	case SubsysRspBodyV1:
+		return ProtobufCodec.Marshal(&x)
+	case SubsysRspBodyV15:
+		return ProtobufCodec.Marshal(&x)
+	case *SubsysRspBody:
+		if x.Rsp == nil {
+			return marshalPbResponse(x.Head, "", "", "", nil)
+		}
+		return marshalPbResponse(x.Head, x.Rsp.Ret, x.Rsp.RetCode, x.Rsp.RetMsg, x.Rsp.Data)
+	case *SubsysRspBodyV1:
+		if x.Rsp == nil {
+			return marshalPbResponse(x.Head, "", "", "", nil)
+		}
+		return marshalPbResponse(x.Head, x.Rsp.Ret, x.Rsp.RetCode, x.Rsp.RetMsg, x.Rsp.Data)
+	case *SubsysRspBodyV15:
+		if x.Rsp == nil {
+			return marshalPbResponse(x.Head, "", "", "", nil)
+		}
+		return marshalPbResponse(x.Head, x.Rsp.Ret, x.Rsp.RetCode, x.Rsp.RetMsg, x.Rsp.Data)
+	case proto.Message:
+		return proto.Marshal(x)
+	}
+	return nil, fmt.Errorf("protocol: protobuf can not marshal %T", v)
+}
+
+// 请求报文的 Param 为 nil 时只解析报文头；响应报文的 Rsp.Data 为 proto.Message 时解析 data，否则解析 data_json
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	switch x := v.(type) {
+	case *SubsysReqBody:
+		return unmarshalPbRequest(data, x)
+	case *SubsysRspBody:
+		if x.Rsp == nil {
+			x.Rsp = &SubsysCommonRsp{}
+		}
+		return unmarshalPbResponse(data, &x.Head, &x.Rsp.Ret, &x.Rsp.RetCode, &x.Rsp.RetMsg, &x.Rsp.Data)
+	case *SubsysRspBodyV1:
+		if x.Rsp == nil {
+			x.Rsp = &SubsysCommonRspV1{}
+		}
+		return unmarshalPbResponse(data, &x.Head, &x.Rsp.Ret, &x.Rsp.RetCode, &x.Rsp.RetMsg, &x.Rsp.Data)
+	case *SubsysRspBodyV15:
+		if x.Rsp == nil {
+			x.Rsp = &SubsysCommonRspV15{}
+		}
+		return unmarshalPbResponse(data, &x.Head, &x.Rsp.Ret, &x.Rsp.RetCode, &x.Rsp.RetMsg, &x.Rsp.Data)
+	case proto.Message:
+		return proto.Unmarshal(data, x)
+	}
+	return fmt.Errorf("protocol: protobuf can not unmarshal into %T", v)
+}
+
+func marshalPbRequest(req *SubsysReqBody) ([]byte, error) {
+	b := appendPbHeader(nil, &req.Head)
+	if req.Param == nil {
+		return b, nil
+	}
+	m, ok := req.Param.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protocol: _param %T is not proto.Message", req.Param)
+	}
+	param, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, fieldParam, protowire.BytesType)
+	return protowire.AppendBytes(b, param), nil
+}
+
+func unmarshalPbRequest(data []byte, req *SubsysReqBody) error {
+	var param []byte
+	err := consumePbFields(data, func(num protowire.Number, v []byte) error {
+		switch num {
+		case fieldHead:
+			return unmarshalPbHeader(v, &req.Head)
+		case fieldParam:
+			param = v
+		}
+		return nil
+	})
+	if err != nil || req.Param == nil {
+		return err
+	}
+	m, ok := req.Param.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protocol: _param %T is not proto.Message", req.Param)
+	}
+	return proto.Unmarshal(param, m)
+}
+
+func marshalPbResponse(head *SubsysHeader, ret, code, msg string, data interface{}) ([]byte, error) {
+	var b []byte
+	if head != nil {
+		b = appendPbHeader(b, head)
+	}
+	b = appendPbString(b, fieldRet, ret)
+	b = appendPbString(b, fieldCode, code)
+	b = appendPbString(b, fieldMsg, msg)
+	if data == nil {
+		return b, nil
+	}
+
+	num := fieldData
+	var body []byte
+	var err error
+	if m, ok := data.(proto.Message); ok {
+		body, err = proto.Marshal(m)
+	} else {
+		num = fieldDataJSON
+		body, err = JSONCodec.Marshal(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, body), nil
+}
+
+func unmarshalPbResponse(data []byte, head **SubsysHeader, ret, code, msg *string, dest *interface{}) error {
+	var body, bodyJSON []byte
+	err := consumePbFields(data, func(num protowire.Number, v []byte) error {
+		switch num {
+		case fieldHead:
+			if *head == nil {
+				*head = &SubsysHeader{}
+			}
+			return unmarshalPbHeader(v, *head)
+		case fieldRet:
+			*ret = string(v)
+		case fieldCode:
+			*code = string(v)
+		case fieldMsg:
+			*msg = string(v)
+		case fieldData:
+			body = v
+		case fieldDataJSON:
+			bodyJSON = v
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if m, ok := (*dest).(proto.Message); ok {
+		return proto.Unmarshal(body, m)
+	}
+	if len(bodyJSON) == 0 {
+		return nil
+	}
+	if *dest != nil {
+		return JSONCodec.Unmarshal(bodyJSON, *dest)
+	}
+	return JSONCodec.Unmarshal(bodyJSON, dest)
+}
+
+// 报文头的字段按 subsys.proto 的顺序
+func pbHeaderFields(h *SubsysHeader) []*string {
+	return []*string{&h.CallServiceId, &h.GroupNo, &h.Interface, &h.InvokeId, &h.MsgType, &h.Remark, &h.Timestamp, &h.Version}
+}
+
+func appendPbHeader(b []byte, h *SubsysHeader) []byte {
+	var head []byte
+	for i, f := range pbHeaderFields(h) {
+		head = appendPbString(head, protowire.Number(i+1), *f)
+	}
+	b = protowire.AppendTag(b, fieldHead, protowire.BytesType)
+	return protowire.AppendBytes(b, head)
+}
+
+func unmarshalPbHeader(data []byte, h *SubsysHeader) error {
+	fields := pbHeaderFields(h)
+	return consumePbFields(data, func(num protowire.Number, v []byte) error {
+		if num >= 1 && int(num) <= len(fields) {
+			*fields[num-1] = string(v)
+		}
+		return nil
+	})
+}
+
+// proto3 的空字符串不写入
+func appendPbString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// 逐个读取 bytes 类型（string、bytes、message）的字段，其他类型的字段跳过
+func consumePbFields(data []byte, f func(num protowire.Number, v []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType {
+			if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := f(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
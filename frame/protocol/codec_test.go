@@ -0,0 +1,81 @@
+package protocol
+
+import (
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+type testParam struct {
+	Name  string   `json:"name"`
+	Items []string `json:"items"`
+}
+
+func Test_Codec(t *testing.T) {
+	head := SubsysHeader{CallServiceId: "116006", GroupNo: "1", Interface: "test", InvokeId: "x", MsgType: "request", Timestamp: "1", Version: "0.01"}
+
+	// protobuf：报文头按字段号编码，_param 为业务的 message
+	b, err := ProtobufCodec.Marshal(SubsysReqBody{Head: head, Param: wrapperspb.String("go")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var onlyHead SubsysReqBody
+	if err := ProtobufCodec.Unmarshal(b, &onlyHead); err != nil || onlyHead.Head != head || onlyHead.Param != nil {
+		t.Errorf("protobuf head: %+v %v", onlyHead, err)
+	}
+	param := &wrapperspb.StringValue{}
+	req := SubsysReqBody{Param: param}
+	if err := ProtobufCodec.Unmarshal(b, &req); err != nil || req.Head != head || param.Value != "go" {
+		t.Errorf("protobuf request: %+v %v", req, err)
+	}
+	if err := ProtobufCodec.Unmarshal(b, &SubsysReqBody{Param: &testParam{}}); err == nil {
+		t.Error("protobuf param not message")
+	}
+
+	// 响应：message 写入 data，其他写入 data_json
+	b, err = ProtobufCodec.Marshal(NewRspBody(ProtocolV1, &head, 0, "SUCCESS", wrapperspb.Int64(7)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &wrapperspb.Int64Value{}
+	rsp := SubsysRspBody{Rsp: &SubsysCommonRsp{Data: data}}
+	if err := ProtobufCodec.Unmarshal(b, &rsp); err != nil || rsp.Head.Interface != "test" || rsp.Rsp.Ret != "0" || rsp.Rsp.RetMsg != "SUCCESS" || data.Value != 7 {
+		t.Errorf("protobuf response: %+v %+v %v", rsp.Head, rsp.Rsp, err)
+	}
+	b, _ = ProtobufCodec.Marshal(NewRspBody(ProtocolV2, &head, 1001, "参数错误", testParam{Name: "a"}))
+	var errData testParam
+	rspV15 := SubsysRspBodyV15{Rsp: &SubsysCommonRspV15{Data: &errData}}
+	if err := ProtobufCodec.Unmarshal(b, &rspV15); err != nil || rspV15.Rsp.RetCode != "1001" || errData.Name != "a" {
+		t.Errorf("protobuf data_json: %+v %v", rspV15.Rsp, err)
+	}
+
+	// msgpack：和 JSON 相同的结构
+	b, err = MsgPackCodec.Marshal(SubsysReqBody{Head: head, Param: testParam{Name: "go", Items: []string{"a"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p testParam
+	req = SubsysReqBody{Param: &p}
+	if err := MsgPackCodec.Unmarshal(b, &req); err != nil || req.Head != head || p.Name != "go" || len(p.Items) != 1 {
+		t.Errorf("msgpack request: %+v %+v %v", req, p, err)
+	}
+	var generic SubsysReqBody
+	if err := MsgPackCodec.Unmarshal(b, &generic); err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := generic.Param.(map[string]interface{}); !ok || m["name"] != "go" {
+		t.Errorf("msgpack generic: %#v", generic.Param)
+	}
+
+	for contentType, want := range map[string]Codec{
+		"application/json; charset=utf-8": JSONCodec,
+		"application/x-protobuf":          ProtobufCodec,
+		"Application/MsgPack":             MsgPackCodec,
+	} {
+		if c, ok := CodecByContentType(contentType); !ok || c != want {
+			t.Errorf("%s: %v", contentType, c)
+		}
+	}
+	if _, ok := CodecByContentType("text/plain"); ok {
+		t.Error("text/plain")
+	}
+}
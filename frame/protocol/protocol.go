@@ -16,6 +16,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -92,6 +93,48 @@ type SubsysTotal struct {
 	Total     string `json:"total"`
 }
 
+// 是否为支持的协议版本
+func (p ProtocolType) Valid() bool {
+	return p == ProtocolV1 || p == ProtocolV15 || p == ProtocolV2
+}
+
+func (p ProtocolType) String() string {
+	switch p {
+	case ProtocolV1:
+		return "V1"
+	case ProtocolV15:
+		return "V1.5"
+	case ProtocolV2:
+		return "V2"
+	}
+	return "V" + strconv.Itoa(int(p))
+}
+
+// 请求头 _version 对应的协议版本：1、1.0、v1 -> V1；1.5、v1.5 -> V15；2、2.0、v2 -> V2
+func VersionProtocol(version string) (ProtocolType, bool) {
+	switch strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v") {
+	case "1", "1.0":
+		return ProtocolV1, true
+	case "1.5", "15":
+		return ProtocolV15, true
+	case "2", "2.0":
+		return ProtocolV2, true
+	}
+	return 0, false
+}
+
+// 按协议版本组装响应，不支持的版本使用 ProtocolV2
+func NewRspBody(pType ProtocolType, head *SubsysHeader, code int, msg string, data interface{}) interface{} {
+	ret := strconv.Itoa(code)
+	switch pType {
+	case ProtocolV1:
+		return SubsysRspBodyV1{Head: head, Rsp: &SubsysCommonRspV1{Data: data, RetCode: ret, Ret: ret, RetMsg: msg}}
+	case ProtocolV15:
+		return SubsysRspBodyV15{Head: head, Rsp: &SubsysCommonRspV15{Data: data, RetCode: ret, Ret: ret, RetMsg: msg}}
+	}
+	return SubsysRspBody{Head: head, Rsp: &SubsysCommonRsp{RetMsg: msg, Data: data, RetCode: ret, Ret: ret}}
+}
+
 // 空返回
 func SubsysGetBadHeader() SubsysHeader {
 	return SubsysHeader{
@@ -192,6 +235,7 @@ type RequestCgiHandle struct {
 	Interface  string        //被调方Interface
 	MsgBody    interface{}   //请求参数 (Param部分)
 	Timeout    time.Duration //请求超时时间
+	Codec      Codec         //请求体的编码，nil 时为 JSON；响应按 Content-Type 解析
 }
 
 // 返回空的Handle
@@ -206,12 +250,12 @@ func NewCgiHandleDef(pType ProtocolType, timeOut time.Duration) *RequestCgiHandl
 func NewCgiHandle(pType ProtocolType, calleeName, strUrl, strInterface string,
 	msgBody interface{}, timeOut time.Duration) *RequestCgiHandle {
 	return &RequestCgiHandle{
-		pType,
-		calleeName,
-		strUrl,
-		strInterface,
-		msgBody,
-		timeOut,
+		PolType:    pType,
+		CalleeName: calleeName,
+		Url:        strUrl,
+		Interface:  strInterface,
+		MsgBody:    msgBody,
+		Timeout:    timeOut,
 	}
 }
 
@@ -227,6 +271,11 @@ func (h *RequestCgiHandle) SetRequestBody(msgBody interface{}) {
 	h.MsgBody = msgBody
 }
 
+// 设置请求体的编码（protobuf 的 MsgBody、response 需要是 proto.Message），同时请求被调方使用该编码响应
+func (h *RequestCgiHandle) SetCodec(codec Codec) {
+	h.Codec = codec
+}
+
 // 请求服务
 // response 为返回结果，指针类型
 func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
@@ -261,14 +310,18 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 		Param: h.MsgBody,
 	}
 
-	var json = jsoniter.ConfigCompatibleWithStandardLibrary
-	jsonStr, err := json.Marshal(request)
+	codec := h.Codec
+	if codec == nil {
+		codec = JSONCodec
+	}
+	jsonStr, err := codec.Marshal(request)
 	if err != nil {
-		logger.PrintError("json.Marshal() Err: %s", err.Error())
+		logger.PrintError("%s Marshal() Err: %s", codec.Name(), err.Error())
 		retCode.ErrorInfo = err.Error()
 		return retCode
 	}
 
+	// 签名按请求体的原始字节计算
 	signStr := toolkit.ApiSign(string(jsonStr), callee.ServerKey)
 
 	// opentracing
@@ -296,10 +349,13 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 	req.Header["OPENTRACER-INFO"] = []string{spanContext}
 	req.Header["HSB-OPENAPI-CALLERSERVICEID"] = []string{callerServiceId}
 	req.Header["HSB-OPENAPI-SIGNATURE"] = []string{signStr}
-	req.Header.Set("content-type", "application/json")
+	req.Header.Set("content-type", codec.ContentType())
+	if codec != JSONCodec {
+		req.Header.Set("Accept", codec.ContentType())
+	}
 
-	logger.PrintInfo("curl -H'HSB-OPENAPI-CALLERSERVICEID:%s' -H'HSB-OPENAPI-SIGNATURE:%s' -H'OPENTRACER-INFO:%s' -d'%s' %s",
-		callerServiceId, signStr, spanContext, string(jsonStr), h.Url)
+	logger.PrintInfo("curl -H'HSB-OPENAPI-CALLERSERVICEID:%s' -H'HSB-OPENAPI-SIGNATURE:%s' -H'OPENTRACER-INFO:%s' -H'Content-Type:%s' -d'%s' %s",
+		callerServiceId, signStr, spanContext, codec.ContentType(), packetString(codec, jsonStr), h.Url)
 
 	client := apphttp.CreateHTTPClient()
 	if client == nil {
@@ -329,7 +385,12 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 		return retCode
 	}
 
-	logger.PrintInfo("Response: %s", string(body))
+	// 被调方不支持请求的编码时可能返回 JSON
+	rspCodec, ok := CodecByContentType(rsp.Header.Get("Content-Type"))
+	if !ok {
+		rspCodec = JSONCodec
+	}
+	logger.PrintInfo("Response: %s", packetString(rspCodec, body))
 
 	if h.PolType == ProtocolV2 {
 		retData := SubsysRspBody{
@@ -338,7 +399,7 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 			},
 		}
 
-		err = json.Unmarshal(body, &retData)
+		err = rspCodec.Unmarshal(body, &retData)
 		if err != nil {
 			retCode.ErrorInfo = err.Error()
 			return retCode
@@ -355,7 +416,7 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 			},
 		}
 
-		err = json.Unmarshal(body, &retData)
+		err = rspCodec.Unmarshal(body, &retData)
 		if err != nil {
 			retCode.ErrorInfo = err.Error()
 			return retCode
@@ -372,7 +433,7 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 			},
 		}
 
-		err = json.Unmarshal(body, &retData)
+		err = rspCodec.Unmarshal(body, &retData)
 		if err != nil {
 			retCode.ErrorInfo = err.Error()
 			return retCode
@@ -390,3 +451,11 @@ func (h *RequestCgiHandle) RequestCgiModel(response interface{}) error {
 
 	return nil
 }
+
+// 打印的报文，二进制编码只打印长度
+func packetString(codec Codec, body []byte) string {
+	if codec == JSONCodec {
+		return string(body)
+	}
+	return fmt.Sprintf("[%s %d bytes]", codec.Name(), len(body))
+}
@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSE 路由（GET），subscribe 返回连接订阅的 topic，为 nil 时只接收 Broadcast
+// 返回流式路由的中间件和处理函数：r.GET(path, hub.SSE(subscribe)...)
+func (h *Hub) SSE(subscribe SubscribeFunc) gin.HandlersChain {
+	return gin.HandlersChain{middleware.Streaming(), func(c *gin.Context) {
+		h.serve(c, TypeSSE, subscribe, func(cl *Client) {
+			h.runSSE(c, cl)
+		})
+	}}
+}
+
+func (h *Hub) runSSE(c *gin.Context, cl *Client) {
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// nginx 不缓存响应
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// 连接建立时发送一次，浏览器的 EventSource 收到后才触发 open
+	var buf bytes.Buffer
+	if h.retry > 0 {
+		buf.WriteString("retry: " + strconv.FormatInt(int64(h.retry/time.Millisecond), 10) + "\n")
+	}
+	buf.WriteString(": connected\n\n")
+	if !h.writeSSE(c, cl, buf.Bytes()) {
+		return
+	}
+
+	var heartbeat <-chan time.Time
+	if h.heartbeat > 0 {
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-cl.Done():
+			return
+		case <-heartbeat:
+			if !h.writeSSE(c, cl, []byte(": ping\n\n")) {
+				return
+			}
+		case ev := <-cl.send:
+			data, err := encodeSSE(ev)
+			if err != nil {
+				logger.PrintError("Stream hub[%s] client[%s] encode event[%s] Err: %s", h.name, cl.Id, ev.Event, err.Error())
+				continue
+			}
+			if !h.writeSSE(c, cl, data) {
+				return
+			}
+		}
+	}
+}
+
+// 写入并 Flush，超时或失败时返回 false
+func (h *Hub) writeSSE(c *gin.Context, cl *Client, data []byte) bool {
+	// 客户端不读取时写入会阻塞，HTTP/1.x 在连接上设置写超时
+	if conn := app.RequestConn(c.Request); conn != nil && h.writeTimeout > 0 {
+		_ = conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	if _, err := c.Writer.Write(data); err != nil {
+		logger.PrintInfo("Stream hub[%s] client[%s] write Err: %s", h.name, cl.Id, err.Error())
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// 按 SSE 的格式编码，data 有多行时每行一个 data:
+func encodeSSE(ev Event) ([]byte, error) {
+	var data string
+	switch v := ev.Data.(type) {
+	case string:
+		data = v
+	case []byte:
+		data = string(v)
+	default:
+		b, err := protocol.JSONCodec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		data = string(b)
+	}
+
+	var buf bytes.Buffer
+	if ev.Id != "" {
+		buf.WriteString("id: " + singleLine(ev.Id) + "\n")
+	}
+	if ev.Event != "" {
+		buf.WriteString("event: " + singleLine(ev.Event) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// id、event 中不能有换行
+func singleLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
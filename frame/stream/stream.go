@@ -0,0 +1,393 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+  推送：SSE（text/event-stream）和 WebSocket 路由，定时任务、MQ 消费者等通过 Hub 推送事件到连接的客户端
+  hub := stream.NewHub("price")
+  r.GET("/price/progress", hub.SSE(func(c *gin.Context) []string { return []string{"task:" + c.Query("id")} })...)
+  hub.Publish("task:1", stream.Event{Event: "progress", Data: progress})
+  每个连接在处理函数的协程中发送事件（WebSocket 另有一个读取协程），事件先写入连接的发送队列，队列满时断开该连接（客户端重连）
+  心跳：SSE 发送注释行，WebSocket 发送 ping，写入失败或超时时断开连接
+  认证使用框架的签名（URL 参数签名，见 middleware.StreamSignQuery）或 middleware.CheckUserToken（URL 参数 token）
+  退出：Hub.Close 断开全部连接，不再接受新连接；使用 appengine.RegisterHub 时在 http 服务 Shutdown 开始时断开，
+    客户端重连到其他实例（PhaseStopAccepting 阶段已经摘除流量），PhaseDrainHTTP 阶段等待连接的协程结束
+  指标：goframe_stream_connections{hub,type}、goframe_stream_dropped_total{hub}
+*/
+
+const (
+	TypeSSE       = "sse"
+	TypeWebSocket = "websocket"
+
+	defaultHeartbeat    = 25 * time.Second
+	defaultSendBuffer   = 64
+	defaultWriteTimeout = 10 * time.Second
+)
+
+var (
+	// 已经关闭
+	ErrClosed = errors.New("stream: hub closed")
+
+	connGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "goframe_stream_connections",
+		Help: "Open SSE and WebSocket connections.",
+	}, []string{"hub", "type"})
+	droppedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_stream_dropped_total",
+		Help: "Connections closed because the send queue is full.",
+	}, []string{"hub"})
+)
+
+func init() {
+	prometheus.MustRegister(connGauge, droppedCounter)
+}
+
+// 推送的事件
+type Event struct {
+	// SSE 的 id，客户端重连时在 Last-Event-ID 中返回
+	Id string `json:"id,omitempty"`
+	// 事件名，SSE 为空时客户端按 message 处理
+	Event string `json:"event,omitempty"`
+	// string、[]byte 原样发送（WebSocket 中为 JSON 字符串），其他按 JSON
+	Data interface{} `json:"data"`
+}
+
+// 连接订阅的 topic，返回前已经响应（如参数错误）或 Abort 时不建立连接
+type SubscribeFunc func(c *gin.Context) []string
+
+type Option func(*Hub)
+
+// 心跳间隔，为 0 时不发送
+func Heartbeat(d time.Duration) Option {
+	return func(h *Hub) {
+		h.heartbeat = d
+	}
+}
+
+// 每个连接等待发送的事件数上限
+func SendBuffer(n int) Option {
+	return func(h *Hub) {
+		h.sendBuffer = n
+	}
+}
+
+// 每次写入的超时时间
+func WriteTimeout(d time.Duration) Option {
+	return func(h *Hub) {
+		h.writeTimeout = d
+	}
+}
+
+// SSE 客户端断开后的重连间隔
+func Retry(d time.Duration) Option {
+	return func(h *Hub) {
+		h.retry = d
+	}
+}
+
+// WebSocket 允许的 Origin，* 为全部；不设置时只允许和请求的 Host 相同，没有 Origin 的请求（非浏览器）都允许
+func AllowOrigins(origins ...string) Option {
+	return func(h *Hub) {
+		h.origins = append(h.origins, origins...)
+	}
+}
+
+// 连接建立、断开时调用
+func OnConnect(f func(*Client)) Option {
+	return func(h *Hub) {
+		h.onConnect = f
+	}
+}
+
+func OnDisconnect(f func(*Client)) Option {
+	return func(h *Hub) {
+		h.onDisconnect = f
+	}
+}
+
+type Hub struct {
+	name         string
+	heartbeat    time.Duration
+	sendBuffer   int
+	writeTimeout time.Duration
+	retry        time.Duration
+	origins      []string
+	onConnect    func(*Client)
+	onDisconnect func(*Client)
+
+	mutex   sync.RWMutex
+	clients map[*Client]bool
+	topics  map[string]map[*Client]bool
+	closed  bool
+	wg      sync.WaitGroup
+	seq     uint64
+}
+
+// 创建 Hub，name 用于日志、指标和组件名
+func NewHub(name string, opts ...Option) *Hub {
+	h := &Hub{
+		name:         name,
+		heartbeat:    defaultHeartbeat,
+		sendBuffer:   defaultSendBuffer,
+		writeTimeout: defaultWriteTimeout,
+		clients:      make(map[*Client]bool),
+		topics:       make(map[string]map[*Client]bool),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.sendBuffer <= 0 {
+		h.sendBuffer = defaultSendBuffer
+	}
+	return h
+}
+
+func (h *Hub) Name() string {
+	return h.name
+}
+
+// 推送到订阅了 topic 的连接，返回推送的连接数
+func (h *Hub) Publish(topic string, ev Event) int {
+	h.mutex.RLock()
+	list := make([]*Client, 0, len(h.topics[topic]))
+	for cl := range h.topics[topic] {
+		list = append(list, cl)
+	}
+	h.mutex.RUnlock()
+	return h.send(list, ev)
+}
+
+// 推送到全部连接，返回推送的连接数
+func (h *Hub) Broadcast(ev Event) int {
+	h.mutex.RLock()
+	list := make([]*Client, 0, len(h.clients))
+	for cl := range h.clients {
+		list = append(list, cl)
+	}
+	h.mutex.RUnlock()
+	return h.send(list, ev)
+}
+
+func (h *Hub) send(list []*Client, ev Event) int {
+	n := 0
+	for _, cl := range list {
+		if cl.Send(ev) {
+			n++
+		}
+	}
+	return n
+}
+
+// 连接数
+func (h *Hub) Count() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.clients)
+}
+
+// 订阅了 topic 的连接数
+func (h *Hub) Subscribers(topic string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.topics[topic])
+}
+
+// 不再接受新连接，断开全部连接，不等待连接的协程结束
+func (h *Hub) Shutdown() {
+	h.mutex.Lock()
+	h.closed = true
+	list := make([]*Client, 0, len(h.clients))
+	for cl := range h.clients {
+		list = append(list, cl)
+	}
+	h.mutex.Unlock()
+
+	for _, cl := range list {
+		cl.Close()
+	}
+	if len(list) > 0 {
+		logger.PrintInfo("Stream hub[%s] Shutdown, disconnect[%d]", h.name, len(list))
+	}
+}
+
+// Shutdown 并等待连接的协程结束，ctx 结束时不再等待
+func (h *Hub) Close(ctx context.Context) error {
+	h.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 检查是否已经关闭
+func (h *Hub) Ping(ctx context.Context) error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if h.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// 添加连接，已经关闭时返回 ErrClosed
+func (h *Hub) register(c *gin.Context, typ string, topics []string) (*Client, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		return nil, ErrClosed
+	}
+
+	h.seq++
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	cl := &Client{
+		Id:          fmt.Sprintf("%s-%d", h.name, h.seq),
+		Type:        typ,
+		Topics:      topics,
+		LastEventId: c.GetHeader("Last-Event-ID"),
+		Keys:        make(map[string]interface{}, len(c.Keys)),
+		hub:         h,
+		send:        make(chan Event, h.sendBuffer),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+	for k, v := range c.Keys {
+		cl.Keys[k] = v
+	}
+
+	h.clients[cl] = true
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Client]bool)
+		}
+		h.topics[topic][cl] = true
+	}
+	h.wg.Add(1)
+	connGauge.WithLabelValues(h.name, typ).Inc()
+	return cl, nil
+}
+
+func (h *Hub) unregister(cl *Client) {
+	cl.Close()
+
+	h.mutex.Lock()
+	delete(h.clients, cl)
+	for _, topic := range cl.Topics {
+		delete(h.topics[topic], cl)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	h.mutex.Unlock()
+
+	connGauge.WithLabelValues(h.name, cl.Type).Dec()
+	if h.onDisconnect != nil {
+		h.onDisconnect(cl)
+	}
+	h.wg.Done()
+}
+
+// 连接建立后在处理函数的协程中运行，run 返回时断开连接
+func (h *Hub) serve(c *gin.Context, typ string, subscribe SubscribeFunc, run func(cl *Client)) {
+	var topics []string
+	if subscribe != nil {
+		topics = subscribe(c)
+	}
+	if c.IsAborted() || c.Writer.Written() {
+		return
+	}
+
+	cl, err := h.register(c, typ, topics)
+	if err != nil {
+		// 退出中，客户端稍后重连到其他实例
+		head := protocol.SubsysGetBadHeader()
+		head.Interface = c.Request.URL.Path
+		c.Header("Retry-After", "1")
+		middleware.Render(c, http.StatusServiceUnavailable, protocol.NewRspBody(middleware.NegotiateProtocol(c, &head), &head,
+			errcode.ERROR_MAINTENANCE.ErrorCode, errcode.ERROR_MAINTENANCE.ErrorInfo, nil))
+		return
+	}
+	defer h.unregister(cl)
+
+	logger.PrintInfo("Stream hub[%s] %s[%s] connect, topics%v", h.name, typ, cl.Id, topics)
+	if h.onConnect != nil {
+		h.onConnect(cl)
+	}
+	run(cl)
+	logger.PrintInfo("Stream hub[%s] %s[%s] disconnect", h.name, typ, cl.Id)
+}
+
+// 一个 SSE 或 WebSocket 连接
+type Client struct {
+	Id   string
+	Type string
+	// 订阅的 topic
+	Topics []string
+	// SSE 重连时的 Last-Event-ID，用于补发断开期间的事件
+	LastEventId string
+	// 连接时请求上下文中的值（如 CheckUserToken 的 uid）
+	Keys map[string]interface{}
+
+	hub    *Hub
+	send   chan Event
+	ctx    context.Context
+	cancel context.CancelFunc
+	// 发送队列满时断开
+	dropped int32
+}
+
+// 发送事件，连接已断开或发送队列满时返回 false，队列满时断开连接
+func (cl *Client) Send(ev Event) bool {
+	select {
+	case <-cl.ctx.Done():
+		return false
+	default:
+	}
+
+	select {
+	case cl.send <- ev:
+		return true
+	default:
+		if atomic.CompareAndSwapInt32(&cl.dropped, 0, 1) {
+			logger.PrintError("Stream hub[%s] client[%s] send queue full, disconnect", cl.hub.name, cl.Id)
+			droppedCounter.WithLabelValues(cl.hub.name).Inc()
+		}
+		cl.Close()
+		return false
+	}
+}
+
+// 断开连接
+func (cl *Client) Close() {
+	cl.cancel()
+}
+
+// 连接断开时结束
+func (cl *Client) Context() context.Context {
+	return cl.ctx
+}
+
+func (cl *Client) Done() <-chan struct{} {
+	return cl.ctx.Done()
+}
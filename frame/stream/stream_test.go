@@ -0,0 +1,195 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/frame/appengine/app"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testProgress struct {
+	Rows int `json:"rows"`
+}
+
+func newTestServer(t *testing.T, h *Hub) *httptest.Server {
+	conf, err := config.NewFromData(nil, []byte(`<xml><Caller><id>116006</id><key>k</key></Caller></xml>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(app.ConfigContextKey, conf)
+	}, middleware.Compress(), middleware.ETag(), middleware.TimeoutMiddleware(50*time.Millisecond), middleware.CheckCallSign())
+	r.GET("/sse", h.SSE(func(c *gin.Context) []string {
+		if c.Query("task") == "" {
+			c.String(http.StatusBadRequest, "task")
+			return nil
+		}
+		return []string{"task:" + c.Query("task")}
+	})...)
+	r.GET("/ws", h.WebSocket(nil, func(cl *Client, msg []byte) {
+		cl.Send(Event{Event: "echo", Data: string(msg)})
+	})...)
+
+	// 使用框架 http 服务的连接上下文（SSE 的写超时）
+	server, err := app.NewHttpServer(r, 0, conf.GetTLSConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(r)
+	ts.Config.ConnContext = server.ConnContext
+	ts.Start()
+	return ts
+}
+
+// 等待连接建立
+func waitFor(t *testing.T, f func() bool) {
+	for i := 0; i < 200; i++ {
+		if f() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("timeout")
+}
+
+func signedURL(ts *httptest.Server, path string, query url.Values) string {
+	return ts.URL + path + "?" + middleware.StreamSignQuery(query, "116006", "k").Encode()
+}
+
+func Test_SSE(t *testing.T) {
+	h := NewHub("test", Heartbeat(20*time.Millisecond), Retry(time.Second))
+	ts := newTestServer(t, h)
+	defer ts.Close()
+
+	// 签名错误、参数错误时不建立连接
+	rsp, err := http.Get(ts.URL + "/sse?task=1&_callerServiceId=116006&_timestamp=1&_signature=x")
+	if err != nil || rsp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("sign: %v %v", err, rsp)
+	}
+	rsp.Body.Close()
+	if rsp, err = http.Get(signedURL(ts, "/sse", nil)); err != nil || rsp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("subscribe: %v %v", err, rsp)
+	}
+	rsp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, signedURL(ts, "/sse", url.Values{"task": {"1"}}), nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rsp, err = http.DefaultTransport.RoundTrip(req)
+	if err != nil || rsp.StatusCode != http.StatusOK || rsp.Header.Get("Content-Type") != "text/event-stream; charset=utf-8" ||
+		rsp.Header.Get("Content-Encoding") != "" {
+		t.Fatalf("connect: %v %v", err, rsp)
+	}
+	defer rsp.Body.Close()
+	body := bufio.NewReader(rsp.Body)
+	readEvent := func() string {
+		var lines []string
+		for {
+			line, err := body.ReadString('\n')
+			if err != nil {
+				return strings.Join(append(lines, "EOF"), "|")
+			}
+			if line == "\n" {
+				return strings.Join(lines, "|")
+			}
+			lines = append(lines, strings.TrimSuffix(line, "\n"))
+		}
+	}
+	if ev := readEvent(); ev != "retry: 1000|: connected" {
+		t.Fatalf("connected: %s", ev)
+	}
+	waitFor(t, func() bool { return h.Subscribers("task:1") == 1 })
+
+	// 超过 TimeoutMiddleware 的时间仍然可以推送，推送之间有心跳
+	time.Sleep(60 * time.Millisecond)
+	if n := h.Publish("task:1", Event{Id: "1", Event: "progress", Data: testProgress{Rows: 10}}); n != 1 {
+		t.Fatalf("publish: %d", n)
+	}
+	h.Publish("task:2", Event{Data: "other"})
+	h.Broadcast(Event{Data: "a\nb"})
+	var events []string
+	for len(events) < 2 {
+		if ev := readEvent(); ev != ": ping" {
+			events = append(events, ev)
+		}
+	}
+	if events[0] != `id: 1|event: progress|data: {"rows":10}` || events[1] != "data: a|data: b" {
+		t.Errorf("events: %q", events)
+	}
+
+	// 退出时断开连接，不再接受新连接
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.Close(ctx); err != nil || h.Count() != 0 {
+		t.Fatalf("close: %v %d", err, h.Count())
+	}
+	for ev := readEvent(); !strings.HasSuffix(ev, "EOF"); ev = readEvent() {
+	}
+	if rsp, err = http.Get(signedURL(ts, "/sse", url.Values{"task": {"1"}})); err != nil || rsp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("closed: %v %v", err, rsp)
+	}
+	rsp.Body.Close()
+}
+
+func Test_WebSocket(t *testing.T) {
+	h := NewHub("test-ws")
+	ts := newTestServer(t, h)
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(signedURL(ts, "/ws", nil), "http")
+	if _, err := websocket.Dial(wsURL, "", "http://evil.example.com"); err == nil {
+		t.Fatal("origin")
+	}
+	ws, err := websocket.Dial(wsURL, "", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	waitFor(t, func() bool { return h.Count() == 1 })
+
+	receive := func() Event {
+		var ev Event
+		var msg string
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(msg), &ev); err != nil {
+			t.Fatal(err)
+		}
+		return ev
+	}
+	if n := h.Broadcast(Event{Event: "progress", Data: testProgress{Rows: 3}}); n != 1 {
+		t.Fatalf("broadcast: %d", n)
+	}
+	if ev := receive(); ev.Event != "progress" || ev.Data.(map[string]interface{})["rows"] != float64(3) {
+		t.Errorf("progress: %+v", ev)
+	}
+	if err := websocket.Message.Send(ws, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if ev := receive(); ev.Event != "echo" || ev.Data != "hi" {
+		t.Errorf("echo: %+v", ev)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := h.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var msg string
+	if err := websocket.Message.Receive(ws, &msg); err == nil {
+		t.Errorf("closed: %s", msg)
+	}
+}
@@ -0,0 +1,130 @@
+package stream
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/mutou1225/go-frame/frame/errcode"
+	"github.com/mutou1225/go-frame/frame/middleware"
+	"github.com/mutou1225/go-frame/frame/protocol"
+	"github.com/mutou1225/go-frame/logger"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 客户端消息的大小上限
+const maxMessageSize = 64 << 10
+
+var pingCodec = websocket.Codec{Marshal: func(v interface{}) ([]byte, byte, error) {
+	return nil, websocket.PingFrame, nil
+}}
+
+// WebSocket 路由（GET），事件按 JSON 文本帧发送：{"id":"","event":"","data":...}
+// onMessage 在连接的读取协程中处理客户端的消息，为 nil 时忽略客户端的消息
+// 同 SSE 返回流式路由的中间件和处理函数：r.GET(path, hub.WebSocket(subscribe, onMessage)...)
+func (h *Hub) WebSocket(subscribe SubscribeFunc, onMessage func(cl *Client, msg []byte)) gin.HandlersChain {
+	return gin.HandlersChain{middleware.Streaming(), func(c *gin.Context) {
+		h.serve(c, TypeWebSocket, subscribe, func(cl *Client) {
+			server := websocket.Server{
+				Handshake: func(conf *websocket.Config, r *http.Request) error {
+					return h.checkOrigin(r)
+				},
+				Handler: func(ws *websocket.Conn) {
+					h.runWebSocket(ws, cl, onMessage)
+				},
+			}
+			server.ServeHTTP(c.Writer, c.Request)
+		})
+	}}
+}
+
+// Origin 为空（非浏览器）或在 AllowOrigins 中，没有设置 AllowOrigins 时和请求的 Host 相同
+func (h *Hub) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if len(h.origins) == 0 {
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return nil
+		}
+	}
+	for _, o := range h.origins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return nil
+		}
+	}
+	logger.PrintInfo("Stream hub[%s] websocket origin[%s] not allowed", h.name, origin)
+	return fmt.Errorf("origin %s not allowed", origin)
+}
+
+func (h *Hub) runWebSocket(ws *websocket.Conn, cl *Client, onMessage func(cl *Client, msg []byte)) {
+	ws.MaxPayloadBytes = maxMessageSize
+
+	// 读取协程：客户端断开或发送的消息错误时断开连接
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		defer cl.Close()
+		defer func() {
+			if err := recover(); err != nil {
+				logger.PrintError("Stream hub[%s] client[%s] onMessage Err: %s", h.name, cl.Id, errcode.GetSystemPanic(err))
+			}
+		}()
+		for {
+			var msg []byte
+			if err := websocket.Message.Receive(ws, &msg); err != nil {
+				return
+			}
+			if onMessage != nil {
+				onMessage(cl, msg)
+			}
+		}
+	}()
+
+	// 关闭连接后读取协程结束
+	defer func() {
+		_ = ws.Close()
+		<-readDone
+	}()
+
+	var heartbeat <-chan time.Time
+	if h.heartbeat > 0 {
+		ticker := time.NewTicker(h.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-cl.Done():
+			return
+		case <-heartbeat:
+			if !h.writeWebSocket(ws, cl, pingCodec, nil) {
+				return
+			}
+		case ev := <-cl.send:
+			data, err := protocol.JSONCodec.Marshal(ev)
+			if err != nil {
+				logger.PrintError("Stream hub[%s] client[%s] encode event[%s] Err: %s", h.name, cl.Id, ev.Event, err.Error())
+				continue
+			}
+			if !h.writeWebSocket(ws, cl, websocket.Message, string(data)) {
+				return
+			}
+		}
+	}
+}
+
+func (h *Hub) writeWebSocket(ws *websocket.Conn, cl *Client, codec websocket.Codec, v interface{}) bool {
+	if h.writeTimeout > 0 {
+		_ = ws.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+	}
+	if err := codec.Send(ws, v); err != nil {
+		logger.PrintInfo("Stream hub[%s] client[%s] write Err: %s", h.name, cl.Id, err.Error())
+		return false
+	}
+	return true
+}
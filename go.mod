@@ -6,6 +6,7 @@ require (
 	gitee.com/cristiane/go-common v1.0.1
 	github.com/Shopify/sarama v1.30.0
 	github.com/StackExchange/wmi v1.2.1 // indirect
+	github.com/alicebob/miniredis/v2 v2.17.0
 	github.com/deckarep/golang-set v1.7.1 // indirect
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/elastic/go-elasticsearch/v6 v6.8.10
@@ -15,6 +16,7 @@ require (
 	github.com/go-playground/universal-translator v0.18.0
 	github.com/go-playground/validator/v10 v10.9.0
 	github.com/go-redis/redis/v8 v8.11.4
+	github.com/golang/protobuf v1.5.2
 	github.com/jordan-wright/email v4.0.1-0.20210109023952-943e75fe5223+incompatible // indirect
 	github.com/json-iterator/go v1.1.11
 	github.com/opentracing/opentracing-go v1.2.0 // indirect
@@ -31,12 +33,15 @@ require (
 	github.com/streadway/amqp v1.0.0
 	github.com/tealeg/xlsx v1.0.5 // indirect
 	github.com/tklauser/go-sysconf v0.3.2 // indirect
+	github.com/ugorji/go/codec v1.1.7
 	github.com/uber/jaeger-client-go v2.29.1+incompatible // indirect
 	github.com/uber/jaeger-lib v2.4.1+incompatible // indirect
 	go.elastic.co/apm v1.14.0 // indirect
 	go.mongodb.org/mongo-driver v1.7.3
 	go.uber.org/atomic v1.9.0 // indirect
+	golang.org/x/net v0.0.0-20210917221730-978cfadd31cf
 	golang.org/x/time v0.0.0-20210723032227-1f47c861a9ac
+	google.golang.org/protobuf v1.26.0
 	gorm.io/driver/mysql v1.2.0
 	gorm.io/gorm v1.22.3
 )
@@ -2,6 +2,7 @@ package elasticsearch
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"github.com/elastic/go-elasticsearch/v6"
 	"github.com/elastic/go-elasticsearch/v6/esapi"
@@ -100,6 +101,27 @@ func CloseEsClient(host string) {
 	}
 }
 
+// 检测es连接是否可用
+func PingEsClient(ctx context.Context, host string) error {
+	esmutex.Lock()
+	client, ok := esClient[host]
+	esmutex.Unlock()
+	if !ok || client == nil {
+		return errors.New("client uninitialized")
+	}
+
+	res, err := client.Ping(client.Ping.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return errors.New(res.String())
+	}
+	return nil
+}
+
 // 获取es连接，操作完后无需close
 func GetEsClient(host string) (*elasticsearch.Client, error) {
 	if host == "" {
@@ -0,0 +1,487 @@
+package jobqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"github.com/mutou1225/go-frame/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+  基于 Redis 的延迟、重试任务队列，任务保存在 Redis 中，进程退出、重启不丢失
+  goframe:jobs:{<queue>}:scheduled  有序集合，延迟和等待重试的任务，score 为执行时间（ms）
+  goframe:jobs:{<queue>}:ready      列表，可以执行的任务
+  goframe:jobs:{<queue>}:inflight   有序集合，执行中的任务，score 为可见性超时的时间（ms），执行期间续期
+  goframe:jobs:{<queue>}:dead       有序集合，重试次数用完的任务，score 为失败的时间（ms），超过 DeadLimit 时删除最早的
+  goframe:jobs:{<queue>}:job:<id>   任务内容（json）
+  goframe:jobs:{<queue>}:unique:<k> 唯一键，未执行完成前同一个键不能重复添加
+  {<queue>} 为 Redis Cluster 的 hash tag，同一个队列的 key 在同一个 slot，脚本中按前缀拼接的任务 key 也可以访问
+  失败后按退避时间重试（从 BackoffMin 开始翻倍，最大 BackoffMax），可见性超时的任务（进程退出、卡死）重新执行，
+  任务至少执行一次，处理函数需要幂等
+  指标：goframe_jobqueue_jobs_total{queue,type,status}、goframe_jobqueue_duration_seconds{queue,type}
+*/
+
+const (
+	keyPrefix = "goframe:jobs:"
+
+	defaultWorkers      = 1
+	defaultVisibility   = 5 * time.Minute
+	defaultMaxRetries   = 5
+	defaultBackoffMin   = 10 * time.Second
+	defaultBackoffMax   = time.Hour
+	defaultPollInterval = time.Second
+	defaultDeadLimit    = 1000
+	defaultUniqueTTL    = 24 * time.Hour
+
+	// 每次转移到 ready 的任务数
+	promoteBatch = 100
+)
+
+// 任务状态（指标）
+const (
+	StatusEnqueued = "enqueued"
+	StatusOK       = "ok"
+	StatusRetry    = "retry"
+	StatusDead     = "dead"
+	StatusRequeued = "requeued"
+)
+
+var (
+	// 唯一键已经存在
+	ErrDuplicate = errors.New("jobqueue: duplicate unique key")
+	// 没有注册任务类型的处理函数，任务直接进入 dead
+	ErrNoHandler = errors.New("jobqueue: no handler")
+	// 任务不存在
+	ErrNotFound = errors.New("jobqueue: job not found")
+
+	jobsCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "goframe_jobqueue_jobs_total",
+		Help: "Jobs of job queues by status.",
+	}, []string{"queue", "type", "status"})
+	jobsDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "goframe_jobqueue_duration_seconds",
+		Help:    "Duration of job handlers.",
+		Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300},
+	}, []string{"queue", "type"})
+)
+
+func init() {
+	prometheus.MustRegister(jobsCounter, jobsDuration)
+}
+
+// 任务
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	UniqueKey  string          `json:"uniqueKey,omitempty"`
+	MaxRetries int             `json:"maxRetries"`
+	Attempts   int             `json:"attempts"` // 已经失败的次数
+	LastError  string          `json:"lastError,omitempty"`
+	CreatedAt  int64           `json:"createdAt"` // ms
+	RunAt      int64           `json:"runAt"`     // 计划执行的时间，ms
+}
+
+// 解析任务参数
+func (j *Job) Bind(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// 任务的处理函数，返回错误时按退避时间重试；ctx 在应用退出时取消
+type Handler func(ctx context.Context, job *Job) error
+
+// 队列参数
+type Option func(*Queue)
+
+// 执行任务的协程数，0 时只添加任务不执行
+func Workers(n int) Option {
+	return func(q *Queue) {
+		q.workers = n
+	}
+}
+
+// 可见性超时：执行中的任务超过该时间没有续期时重新执行，执行期间每 1/3 续期
+func VisibilityTimeout(d time.Duration) Option {
+	return func(q *Queue) {
+		q.visibility = d
+	}
+}
+
+// 默认的重试次数，Enqueue 时可以用 Retries 修改
+func MaxRetries(n int) Option {
+	return func(q *Queue) {
+		q.maxRetries = n
+	}
+}
+
+// 重试的退避时间，从 min 开始每次翻倍，最大 max
+func Backoff(min, max time.Duration) Option {
+	return func(q *Queue) {
+		q.backoffMin = min
+		q.backoffMax = max
+	}
+}
+
+// 没有任务时的查询间隔，也是延迟任务的精度
+func PollInterval(d time.Duration) Option {
+	return func(q *Queue) {
+		q.pollInterval = d
+	}
+}
+
+// 保存的 dead 任务数
+func DeadLimit(n int) Option {
+	return func(q *Queue) {
+		q.deadLimit = n
+	}
+}
+
+// 添加任务的参数
+type EnqueueOption func(*enqueueOptions)
+
+type enqueueOptions struct {
+	runAt      time.Time
+	maxRetries int
+	uniqueKey  string
+	uniqueTTL  time.Duration
+}
+
+// 延迟执行
+func Delay(d time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = time.Now().Add(d)
+	}
+}
+
+// 在指定时间执行
+func At(t time.Time) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.runAt = t
+	}
+}
+
+// 重试次数，0 不重试
+func Retries(n int) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.maxRetries = n
+	}
+}
+
+// 唯一键：任务完成或进入 dead 前，同一个键不能重复添加（返回 ErrDuplicate）；ttl 为 0 时默认 24h
+func Unique(key string, ttl time.Duration) EnqueueOption {
+	return func(o *enqueueOptions) {
+		o.uniqueKey = key
+		o.uniqueTTL = ttl
+	}
+}
+
+// 任务队列
+type Queue struct {
+	name  string
+	redis func() (*storage.RedisOpt, error)
+
+	workers      int
+	visibility   time.Duration
+	maxRetries   int
+	backoffMin   time.Duration
+	backoffMax   time.Duration
+	pollInterval time.Duration
+	deadLimit    int
+
+	handlers     map[string]Handler
+	handlerMutex sync.RWMutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// 创建队列，redis 如 storage.GetRedisCon；同名队列的多个实例共同执行任务
+func New(name string, redis func() (*storage.RedisOpt, error), opts ...Option) *Queue {
+	q := &Queue{
+		name:         name,
+		redis:        redis,
+		workers:      defaultWorkers,
+		visibility:   defaultVisibility,
+		maxRetries:   defaultMaxRetries,
+		backoffMin:   defaultBackoffMin,
+		backoffMax:   defaultBackoffMax,
+		pollInterval: defaultPollInterval,
+		deadLimit:    defaultDeadLimit,
+		handlers:     make(map[string]Handler),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// 队列名
+func (q *Queue) Name() string {
+	return q.name
+}
+
+// 注册任务类型的处理函数
+func (q *Queue) Register(jobType string, h Handler) {
+	q.handlerMutex.Lock()
+	defer q.handlerMutex.Unlock()
+	q.handlers[jobType] = h
+}
+
+func (q *Queue) handler(jobType string) Handler {
+	q.handlerMutex.RLock()
+	defer q.handlerMutex.RUnlock()
+	return q.handlers[jobType]
+}
+
+func (q *Queue) key(name string) string {
+	return keyPrefix + "{" + q.name + "}:" + name
+}
+
+func (q *Queue) jobKey(id string) string {
+	return q.key("job:" + id)
+}
+
+func (q *Queue) uniqueKey(key string) string {
+	return q.key("unique:" + key)
+}
+
+func (q *Queue) client() (*redis.Client, error) {
+	if q.redis == nil {
+		return nil, errors.New("jobqueue: redis nil")
+	}
+	r, err := q.redis()
+	if err != nil {
+		return nil, err
+	}
+	if r.Client() == nil {
+		return nil, errors.New("RedisOpt client Is nil!")
+	}
+	return r.Client(), nil
+}
+
+// KEYS: job, scheduled, ready, unique; ARGV: id, data, runAt, now, uniqueTTL(ms，0 表示没有唯一键)
+var enqueueScript = redis.NewScript(`
+if tonumber(ARGV[5]) > 0 then
+	if not redis.call('set', KEYS[4], ARGV[1], 'NX', 'PX', ARGV[5]) then
+		return 0
+	end
+end
+redis.call('set', KEYS[1], ARGV[2])
+if tonumber(ARGV[3]) > tonumber(ARGV[4]) then
+	redis.call('zadd', KEYS[2], ARGV[3], ARGV[1])
+else
+	redis.call('lpush', KEYS[3], ARGV[1])
+end
+return 1
+`)
+
+// 添加任务，payload 序列化为 json
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	o := enqueueOptions{maxRetries: q.maxRetries}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:         id,
+		Type:       jobType,
+		Payload:    data,
+		UniqueKey:  o.uniqueKey,
+		MaxRetries: o.maxRetries,
+		CreatedAt:  msOf(now),
+		RunAt:      msOf(now),
+	}
+	if o.runAt.After(now) {
+		job.RunAt = msOf(o.runAt)
+	}
+
+	var uniqueTTL int64
+	if o.uniqueKey != "" {
+		uniqueTTL = o.uniqueTTL.Milliseconds()
+		if uniqueTTL <= 0 {
+			uniqueTTL = defaultUniqueTTL.Milliseconds()
+		}
+	}
+
+	client, err := q.client()
+	if err != nil {
+		return nil, err
+	}
+	jobData, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{q.jobKey(id), q.key("scheduled"), q.key("ready"), q.uniqueKey(o.uniqueKey)}
+	ret, err := enqueueScript.Run(ctx, client, keys, id, jobData, job.RunAt, job.CreatedAt, uniqueTTL).Int64()
+	if err != nil {
+		logger.PrintError("JobQueue[%s] Enqueue[%s] Err: %s", q.name, jobType, err.Error())
+		return nil, err
+	}
+	if ret == 0 {
+		return nil, ErrDuplicate
+	}
+
+	jobsCounter.WithLabelValues(q.name, jobType, StatusEnqueued).Inc()
+	logger.PrintInfo("JobQueue[%s] Enqueue[%s] id[%s] runAt[%d]", q.name, jobType, id, job.RunAt)
+	return job, nil
+}
+
+// 队列的任务数
+type Stats struct {
+	Ready     int64 `json:"ready"`
+	Scheduled int64 `json:"scheduled"`
+	Inflight  int64 `json:"inflight"`
+	Dead      int64 `json:"dead"`
+}
+
+// 各状态的任务数
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	client, err := q.client()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	pipe := client.Pipeline()
+	ready := pipe.LLen(ctx, q.key("ready"))
+	scheduled := pipe.ZCard(ctx, q.key("scheduled"))
+	inflight := pipe.ZCard(ctx, q.key("inflight"))
+	dead := pipe.ZCard(ctx, q.key("dead"))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return Stats{}, err
+	}
+	return Stats{Ready: ready.Val(), Scheduled: scheduled.Val(), Inflight: inflight.Val(), Dead: dead.Val()}, nil
+}
+
+// dead 任务，最近的在前
+func (q *Queue) DeadJobs(ctx context.Context, offset, limit int64) ([]*Job, error) {
+	client, err := q.client()
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := client.ZRevRange(ctx, q.key("dead"), offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := q.getJob(ctx, client, id)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// KEYS: dead, job, ready; ARGV: id, data
+var retryDeadScript = redis.NewScript(`
+if redis.call('zrem', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('set', KEYS[2], ARGV[2])
+redis.call('lpush', KEYS[3], ARGV[1])
+return 1
+`)
+
+// 重新执行 dead 任务，重试次数清零
+func (q *Queue) RetryDead(ctx context.Context, id string) error {
+	client, err := q.client()
+	if err != nil {
+		return err
+	}
+	job, err := q.getJob(ctx, client, id)
+	if err != nil {
+		return err
+	}
+
+	job.Attempts, job.LastError, job.RunAt = 0, "", msOf(time.Now())
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	ret, err := retryDeadScript.Run(ctx, client, []string{q.key("dead"), q.jobKey(id), q.key("ready")}, id, data).Int64()
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return ErrNotFound
+	}
+	logger.PrintInfo("JobQueue[%s] RetryDead[%s] id[%s]", q.name, job.Type, id)
+	return nil
+}
+
+// 删除 dead 任务
+func (q *Queue) DeleteDead(ctx context.Context, id string) error {
+	client, err := q.client()
+	if err != nil {
+		return err
+	}
+	n, err := client.ZRem(ctx, q.key("dead"), id).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return client.Del(ctx, q.jobKey(id)).Err()
+}
+
+func (q *Queue) getJob(ctx context.Context, client *redis.Client, id string) (*Job, error) {
+	data, err := client.Get(ctx, q.jobKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	job := &Job{}
+	if err := json.Unmarshal(data, job); err != nil {
+		return nil, fmt.Errorf("job[%s] %s", id, err.Error())
+	}
+	return job, nil
+}
+
+// 第 attempts 次失败后的重试等待时间
+func (q *Queue) backoff(attempts int) time.Duration {
+	d := q.backoffMin
+	for i := 1; i < attempts && d < q.backoffMax; i++ {
+		d *= 2
+	}
+	if d > q.backoffMax {
+		d = q.backoffMax
+	}
+	return d
+}
+
+func newJobID() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(time.Now().Unix(), 36) + hex.EncodeToString(buf), nil
+}
+
+func msOf(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
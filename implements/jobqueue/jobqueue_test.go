@@ -0,0 +1,148 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mutou1225/go-frame/implements/storage"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 使用 miniredis 的队列，Workers 为 0，测试中手动 fetch、process
+func newTestQueue(t *testing.T, opts ...Option) (*Queue, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	port, _ := strconv.Atoi(mr.Port())
+	s := storage.NewStorage(nil, nil, nil)
+	if err := s.NewRedisConByInfo(mr.Host(), "", port, 0, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.CloseRedisCon)
+	return New("test", s.GetRedisCon, append([]Option{Workers(0)}, opts...)...), mr
+}
+
+func checkStats(t *testing.T, q *Queue, want Stats) {
+	t.Helper()
+	if stats, err := q.Stats(context.Background()); err != nil || stats != want {
+		t.Errorf("Stats: %+v %v, want %+v", stats, err, want)
+	}
+}
+
+func Test_Backoff(t *testing.T) {
+	q := New("test", nil, Backoff(time.Second, 10*time.Second))
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for i, d := range want {
+		if got := q.backoff(i + 1); got != d {
+			t.Errorf("backoff(%d) = %s, want %s", i+1, got, d)
+		}
+	}
+}
+
+func Test_Call(t *testing.T) {
+	q := New("test", func() (*storage.RedisOpt, error) {
+		return nil, errors.New("Redis Con <nil>")
+	})
+	if _, err := q.Enqueue(context.Background(), "sms", nil); err == nil {
+		t.Error("Enqueue without redis want error")
+	}
+
+	type payload struct {
+		Phone string `json:"phone"`
+	}
+	q.Register("sms", func(ctx context.Context, job *Job) error {
+		p := payload{}
+		if err := job.Bind(&p); err != nil {
+			return err
+		}
+		if p.Phone != "10086" {
+			return errors.New("phone " + p.Phone)
+		}
+		return nil
+	})
+	q.Register("panic", func(ctx context.Context, job *Job) error {
+		panic("test")
+	})
+
+	ctx := context.Background()
+	if err := q.call(ctx, &Job{Type: "sms", Payload: []byte(`{"phone":"10086"}`)}); err != nil {
+		t.Errorf("call sms Err: %s", err.Error())
+	}
+	if err := q.call(ctx, &Job{Type: "panic"}); err == nil {
+		t.Error("call panic want error")
+	}
+	if err := q.call(ctx, &Job{Type: "none"}); err != ErrNoHandler {
+		t.Errorf("call none: %v, want ErrNoHandler", err)
+	}
+}
+
+func Test_Enqueue(t *testing.T) {
+	q, mr := newTestQueue(t)
+	ctx := context.Background()
+
+	job, err := q.Enqueue(ctx, "sms", map[string]string{"phone": "10086"}, Unique("10086", time.Minute))
+	if err != nil {
+		t.Fatalf("Enqueue Err: %s", err.Error())
+	}
+	if _, err := q.Enqueue(ctx, "sms", nil, Unique("10086", time.Minute)); err != ErrDuplicate {
+		t.Errorf("Enqueue duplicate: %v, want ErrDuplicate", err)
+	}
+	if _, err := q.Enqueue(ctx, "sms", nil, Delay(time.Hour)); err != nil {
+		t.Fatalf("Enqueue Delay Err: %s", err.Error())
+	}
+	checkStats(t, q, Stats{Ready: 1, Scheduled: 1})
+
+	// 同一个队列的 key 使用同一个 hash tag
+	for _, key := range mr.Keys() {
+		if !strings.HasPrefix(key, keyPrefix+"{test}:") {
+			t.Errorf("key: %s", key)
+		}
+	}
+	if got, _ := mr.Get(q.uniqueKey("10086")); got != job.ID {
+		t.Errorf("unique key: %s, want %s", got, job.ID)
+	}
+	if ttl := mr.TTL(q.uniqueKey("10086")); ttl != time.Minute {
+		t.Errorf("unique key TTL: %s", ttl)
+	}
+}
+
+func Test_RetryDead(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ctx := context.Background()
+
+	job, _ := q.Enqueue(ctx, "none", nil, Unique("none", 0))
+	fetched, err := q.fetch(ctx)
+	if err != nil || fetched == nil || fetched.ID != job.ID {
+		t.Fatalf("fetch: %+v %v", fetched, err)
+	}
+	// 没有处理函数直接进入 dead，释放唯一键
+	q.process(ctx, fetched)
+	checkStats(t, q, Stats{Dead: 1})
+	if _, err := q.Enqueue(ctx, "none", nil, Unique("none", 0)); err != nil {
+		t.Errorf("Enqueue after dead Err: %v", err)
+	}
+
+	dead, err := q.DeadJobs(ctx, 0, 10)
+	if err != nil || len(dead) != 1 || dead[0].Attempts != 1 || dead[0].LastError != ErrNoHandler.Error() {
+		t.Fatalf("DeadJobs: %+v %v", dead, err)
+	}
+	if err := q.RetryDead(ctx, job.ID); err != nil {
+		t.Fatalf("RetryDead Err: %s", err.Error())
+	}
+	checkStats(t, q, Stats{Ready: 2})
+	if err := q.RetryDead(ctx, job.ID); err != ErrNotFound {
+		t.Errorf("RetryDead again: %v, want ErrNotFound", err)
+	}
+
+	// 重新执行的任务重试次数清零
+	for {
+		fetched, _ = q.fetch(ctx)
+		if fetched == nil || fetched.ID == job.ID {
+			break
+		}
+	}
+	if fetched == nil || fetched.Attempts != 0 || fetched.LastError != "" {
+		t.Errorf("retried job: %+v", fetched)
+	}
+}
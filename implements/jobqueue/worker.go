@@ -0,0 +1,332 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/mutou1225/go-frame/logger"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+/*
+  执行任务：Workers 个协程从 ready 取任务执行，一个协程把到期的延迟任务、可见性超时的任务转移到 ready
+  Stop 后不再取任务，等待执行中的任务结束；ctx 取消导致失败的任务重新放回 ready，不计入重试次数
+*/
+
+// KEYS: scheduled, inflight, ready; ARGV: now, limit
+var promoteScript = redis.NewScript(`
+local n = 0
+for _, key in ipairs({KEYS[1], KEYS[2]}) do
+	local ids = redis.call('zrangebyscore', key, '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+	for _, id in ipairs(ids) do
+		redis.call('zrem', key, id)
+		redis.call('lpush', KEYS[3], id)
+		n = n + 1
+	end
+end
+return n
+`)
+
+// KEYS: ready, inflight; ARGV: deadline, job key prefix
+var fetchScript = redis.NewScript(`
+local id = redis.call('rpop', KEYS[1])
+if not id then
+	return false
+end
+local data = redis.call('get', ARGV[2] .. id)
+if not data then
+	return {id, ''}
+end
+redis.call('zadd', KEYS[2], ARGV[1], id)
+return {id, data}
+`)
+
+// KEYS: inflight, job, unique; ARGV: id
+var ackScript = redis.NewScript(`
+if redis.call('zrem', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('del', KEYS[2])
+if redis.call('get', KEYS[3]) == ARGV[1] then
+	redis.call('del', KEYS[3])
+end
+return 1
+`)
+
+// KEYS: inflight, job, scheduled; ARGV: id, data, runAt
+var retryScript = redis.NewScript(`
+if redis.call('zrem', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('set', KEYS[2], ARGV[2])
+redis.call('zadd', KEYS[3], ARGV[3], ARGV[1])
+return 1
+`)
+
+// KEYS: inflight, job, dead, unique; ARGV: id, data, now, limit, job key prefix
+var deadScript = redis.NewScript(`
+if redis.call('zrem', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('set', KEYS[2], ARGV[2])
+redis.call('zadd', KEYS[3], ARGV[3], ARGV[1])
+if redis.call('get', KEYS[4]) == ARGV[1] then
+	redis.call('del', KEYS[4])
+end
+local over = redis.call('zcard', KEYS[3]) - tonumber(ARGV[4])
+if over > 0 then
+	local ids = redis.call('zrange', KEYS[3], 0, over - 1)
+	for _, v in ipairs(ids) do
+		redis.call('del', ARGV[5] .. v)
+	end
+	redis.call('zremrangebyrank', KEYS[3], 0, over - 1)
+end
+return 1
+`)
+
+// 启动执行任务的协程，Workers 为 0 时不执行
+func (q *Queue) Start() {
+	if q.workers <= 0 || q.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+	q.done = make(chan struct{})
+
+	wg := &sync.WaitGroup{}
+	wg.Add(q.workers + 1)
+	go func() {
+		defer wg.Done()
+		q.promote(ctx)
+	}()
+	for i := 0; i < q.workers; i++ {
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(q.done)
+	}()
+
+	logger.PrintInfo("JobQueue[%s] Start, workers[%d]", q.name, q.workers)
+}
+
+// 停止取任务，等待执行中的任务结束，ctx 结束时返回错误
+func (q *Queue) Stop(ctx context.Context) error {
+	if q.cancel == nil {
+		return nil
+	}
+	q.cancel()
+
+	select {
+	case <-q.done:
+		logger.PrintInfo("JobQueue[%s] Stop", q.name)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// 检查 Redis 是否可用
+func (q *Queue) Ping(ctx context.Context) error {
+	client, err := q.client()
+	if err != nil {
+		return err
+	}
+	return client.Ping(ctx).Err()
+}
+
+// 把到期的延迟任务、可见性超时的任务转移到 ready
+func (q *Queue) promote(ctx context.Context) {
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		client, err := q.client()
+		if err != nil {
+			logger.PrintError("JobQueue[%s] promote Err: %s", q.name, err.Error())
+			continue
+		}
+		keys := []string{q.key("scheduled"), q.key("inflight"), q.key("ready")}
+		n, err := promoteScript.Run(ctx, client, keys, msOf(time.Now()), promoteBatch).Int64()
+		if err != nil && ctx.Err() == nil {
+			logger.PrintError("JobQueue[%s] promote Err: %s", q.name, err.Error())
+		} else if n > 0 {
+			logger.PrintInfo("JobQueue[%s] promote[%d]", q.name, n)
+		}
+	}
+}
+
+// 取任务执行，没有任务时等待 PollInterval
+func (q *Queue) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := q.fetch(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.PrintError("JobQueue[%s] fetch Err: %s", q.name, err.Error())
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(q.pollInterval):
+			}
+			continue
+		}
+		q.process(ctx, job)
+	}
+}
+
+func (q *Queue) fetch(ctx context.Context) (*Job, error) {
+	client, err := q.client()
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := msOf(time.Now().Add(q.visibility))
+	ret, err := fetchScript.Run(ctx, client, []string{q.key("ready"), q.key("inflight")}, deadline, q.jobKey("")).Slice()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	id, _ := ret[0].(string)
+	data, _ := ret[1].(string)
+	if data == "" {
+		// 任务已删除
+		logger.PrintError("JobQueue[%s] job[%s] not found", q.name, id)
+		return nil, nil
+	}
+	job := &Job{}
+	if err := json.Unmarshal([]byte(data), job); err != nil {
+		return nil, fmt.Errorf("job[%s] %s", id, err.Error())
+	}
+	return job, nil
+}
+
+// 执行任务并根据结果确认、重试或放入 dead
+func (q *Queue) process(ctx context.Context, job *Job) {
+	stop := make(chan struct{})
+	go q.keepVisible(job.ID, stop)
+
+	start := time.Now()
+	err := q.call(ctx, job)
+	cost := time.Since(start)
+	close(stop)
+	jobsDuration.WithLabelValues(q.name, job.Type).Observe(cost.Seconds())
+
+	client, cerr := q.client()
+	if cerr != nil {
+		// 可见性超时后重新执行
+		logger.PrintError("JobQueue[%s] job[%s] result Err: %s", q.name, job.ID, cerr.Error())
+		return
+	}
+	bg := context.Background()
+
+	if err == nil {
+		keys := []string{q.key("inflight"), q.jobKey(job.ID), q.uniqueKey(job.UniqueKey)}
+		if _, err := ackScript.Run(bg, client, keys, job.ID).Result(); err != nil {
+			logger.PrintError("JobQueue[%s] job[%s] ack Err: %s", q.name, job.ID, err.Error())
+			return
+		}
+		jobsCounter.WithLabelValues(q.name, job.Type, StatusOK).Inc()
+		logger.PrintInfo("JobQueue[%s] job[%s] type[%s] ok, cost[%.3fms]", q.name, job.ID, job.Type,
+			float64(cost)/float64(time.Millisecond))
+		return
+	}
+
+	status := StatusRetry
+	runAt := time.Now()
+	switch {
+	case ctx.Err() != nil:
+		// 应用退出导致的失败，重新执行，不计入重试次数
+		status = StatusRequeued
+	case err == ErrNoHandler:
+		job.Attempts++
+		status = StatusDead
+	default:
+		job.Attempts++
+		if job.Attempts > job.MaxRetries {
+			status = StatusDead
+		} else {
+			runAt = runAt.Add(q.backoff(job.Attempts))
+		}
+	}
+	job.LastError = err.Error()
+	job.RunAt = msOf(runAt)
+
+	data, merr := json.Marshal(job)
+	if merr != nil {
+		logger.PrintError("JobQueue[%s] job[%s] json.Marshal Err: %s", q.name, job.ID, merr.Error())
+		return
+	}
+	if status == StatusDead {
+		keys := []string{q.key("inflight"), q.jobKey(job.ID), q.key("dead"), q.uniqueKey(job.UniqueKey)}
+		_, err = deadScript.Run(bg, client, keys, job.ID, data, msOf(time.Now()), q.deadLimit, q.jobKey("")).Result()
+	} else {
+		keys := []string{q.key("inflight"), q.jobKey(job.ID), q.key("scheduled")}
+		_, err = retryScript.Run(bg, client, keys, job.ID, data, job.RunAt).Result()
+	}
+	if err != nil {
+		logger.PrintError("JobQueue[%s] job[%s] %s Err: %s", q.name, job.ID, status, err.Error())
+		return
+	}
+
+	jobsCounter.WithLabelValues(q.name, job.Type, status).Inc()
+	logger.PrintError("JobQueue[%s] job[%s] type[%s] %s, attempts[%d/%d] Err: %s", q.name, job.ID, job.Type, status,
+		job.Attempts, job.MaxRetries, job.LastError)
+	if status == StatusDead {
+		log.Printf("JobQueue[%s] job[%s] type[%s] dead Err: %s", q.name, job.ID, job.Type, job.LastError)
+	}
+}
+
+// 执行处理函数，panic 时返回错误
+func (q *Queue) call(ctx context.Context, job *Job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+			logger.PrintError("JobQueue[%s] job[%s] panic: %v\n%s", q.name, job.ID, p, debug.Stack())
+		}
+	}()
+
+	h := q.handler(job.Type)
+	if h == nil {
+		return ErrNoHandler
+	}
+	return h(ctx, job)
+}
+
+// 执行期间续期可见性超时
+func (q *Queue) keepVisible(id string, stop chan struct{}) {
+	ticker := time.NewTicker(q.visibility / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		client, err := q.client()
+		if err == nil {
+			deadline := float64(msOf(time.Now().Add(q.visibility)))
+			err = client.ZAddXX(context.Background(), q.key("inflight"), &redis.Z{Score: deadline, Member: id}).Err()
+		}
+		if err != nil {
+			logger.PrintError("JobQueue[%s] job[%s] keepVisible Err: %s", q.name, id, err.Error())
+		}
+	}
+}
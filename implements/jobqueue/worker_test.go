@@ -0,0 +1,122 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+func Test_Process(t *testing.T) {
+	q, mr := newTestQueue(t, MaxRetries(1), Backoff(time.Minute, time.Hour))
+	ctx := context.Background()
+
+	fail := true
+	q.Register("sms", func(ctx context.Context, job *Job) error {
+		if fail {
+			return errors.New("test")
+		}
+		return nil
+	})
+	job, _ := q.Enqueue(ctx, "sms", nil, Unique("10086", 0))
+
+	// 取出后在 inflight 中
+	fetched, err := q.fetch(ctx)
+	if err != nil || fetched == nil || fetched.ID != job.ID {
+		t.Fatalf("fetch: %+v %v", fetched, err)
+	}
+	checkStats(t, q, Stats{Inflight: 1})
+	if empty, err := q.fetch(ctx); empty != nil || err != nil {
+		t.Errorf("fetch empty: %+v %v", empty, err)
+	}
+
+	// 失败后按退避时间放入 scheduled，到期后转移到 ready
+	q.process(ctx, fetched)
+	checkStats(t, q, Stats{Scheduled: 1})
+	retry, _ := q.getJob(ctx, mustClient(t, q), job.ID)
+	if retry.Attempts != 1 || retry.LastError != "test" || retry.RunAt < msOf(time.Now().Add(59*time.Second)) {
+		t.Errorf("retry job: %+v", retry)
+	}
+	promote(t, q, time.Now())
+	checkStats(t, q, Stats{Scheduled: 1})
+	promote(t, q, time.Now().Add(time.Minute))
+	checkStats(t, q, Stats{Ready: 1})
+
+	// 成功后删除任务和唯一键
+	fail = false
+	fetched, _ = q.fetch(ctx)
+	q.process(ctx, fetched)
+	checkStats(t, q, Stats{})
+	if mr.Exists(q.jobKey(job.ID)) || mr.Exists(q.uniqueKey("10086")) {
+		t.Errorf("keys after ack: %v", mr.Keys())
+	}
+}
+
+// 可见性超时的任务重新执行；应用退出导致的失败不计入重试次数
+func Test_Requeue(t *testing.T) {
+	q, _ := newTestQueue(t, VisibilityTimeout(time.Minute))
+	ctx := context.Background()
+
+	job, _ := q.Enqueue(ctx, "sms", nil)
+	if fetched, _ := q.fetch(ctx); fetched == nil {
+		t.Fatal("fetch nil")
+	}
+	promote(t, q, time.Now())
+	checkStats(t, q, Stats{Inflight: 1})
+	promote(t, q, time.Now().Add(time.Minute+time.Second))
+	checkStats(t, q, Stats{Ready: 1})
+
+	cctx, cancel := context.WithCancel(ctx)
+	q.Register("sms", func(ctx context.Context, job *Job) error {
+		cancel()
+		return ctx.Err()
+	})
+	fetched, _ := q.fetch(cctx)
+	q.process(cctx, fetched)
+	checkStats(t, q, Stats{Scheduled: 1})
+	if requeued, _ := q.getJob(ctx, mustClient(t, q), job.ID); requeued.Attempts != 0 {
+		t.Errorf("requeued job: %+v", requeued)
+	}
+}
+
+// dead 超过 DeadLimit 时删除最早的任务
+func Test_DeadLimit(t *testing.T) {
+	q, mr := newTestQueue(t, DeadLimit(2))
+	ctx := context.Background()
+
+	ids := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		job, _ := q.Enqueue(ctx, "none", nil)
+		ids = append(ids, job.ID)
+		fetched, _ := q.fetch(ctx)
+		q.process(ctx, fetched)
+		// dead 的 score 为毫秒
+		time.Sleep(2 * time.Millisecond)
+	}
+	checkStats(t, q, Stats{Dead: 2})
+	if mr.Exists(q.jobKey(ids[0])) || !mr.Exists(q.jobKey(ids[2])) {
+		t.Errorf("keys after trim: %v", mr.Keys())
+	}
+	if dead, _ := q.DeadJobs(ctx, 0, 10); len(dead) != 2 || dead[0].ID != ids[2] || dead[1].ID != ids[1] {
+		t.Errorf("DeadJobs: %+v", dead)
+	}
+}
+
+func mustClient(t *testing.T, q *Queue) *redis.Client {
+	t.Helper()
+	client, err := q.client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// 按 now 转移到期的延迟任务和可见性超时的任务
+func promote(t *testing.T, q *Queue, now time.Time) {
+	t.Helper()
+	keys := []string{q.key("scheduled"), q.key("inflight"), q.key("ready")}
+	if err := promoteScript.Run(context.Background(), mustClient(t, q), keys, msOf(now), promoteBatch).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Shopify/sarama"
@@ -252,28 +253,46 @@ func GetConsumePartition(host string, topic string, partition int32, offset int6
 	return consumer, nil
 }
 
+// 消费组消费，阻塞直到出错
 func ConsumeGroupMessage(host string, groupID string, handler ConsumerHandler, topics ...string) error {
-	/*
-		client, err := GetConsumeClient(host)
-		if err != nil {
-			return err
+	return ConsumeGroupContext(context.Background(), host, groupID, handler, topics...)
+}
+
+// 消费组消费，阻塞直到 ctx 结束（返回nil）或出错
+func ConsumeGroupContext(ctx context.Context, host string, groupID string, handler sarama.ConsumerGroupHandler, topics ...string) error {
+	if host == "" {
+		return errors.New("host empty")
+	}
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V0_10_2_0
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
+
+	group, err := sarama.NewConsumerGroup([]string{host}, groupID, config)
+	if err != nil {
+		logger.PrintError("sarama.NewConsumerGroup() Err: %s", err.Error())
+		return err
+	}
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			logger.PrintError("ConsumerGroup[%s] Err: %s", groupID, err.Error())
 		}
+	}()
 
-		group, err := sarama.NewConsumerGroupFromClient(groupID, client)
+	for {
+		// rebalance 后 Consume 会返回，需要重新加入消费组
+		err := group.Consume(ctx, topics, handler)
+		if ctx.Err() != nil {
+			return nil
+		}
 		if err != nil {
+			logger.PrintError("group.Consume() Err: %s", err.Error())
 			return err
 		}
-		defer group.Close()
-
-		for {
-			err := group.Consume(context.Background(), topics, handler)
-			if err != nil {
-				logger.PrintError("group.Consume() Err: %s", err.Error())
-			}
-		}
-	*/
-
-	return nil
+	}
 }
 
 func (ConsumerHandler) Setup(_ sarama.ConsumerGroupSession) error   { return nil }
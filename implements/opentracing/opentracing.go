@@ -2,10 +2,13 @@ package opentracing
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	jsoniter "github.com/json-iterator/go"
+	"github.com/mutou1225/go-frame/implements/http"
 	"github.com/mutou1225/go-frame/logger"
 	"runtime"
+	"time"
 )
 
 var (
@@ -15,11 +18,14 @@ var (
 
 type OpenTracing struct {
 	ServerName string
+	ipv4       string
+	log        *logger.Logger
 }
 
+// 默认追踪实例，GetOpenTracing 返回
 func NewOpenTracing(name string) *OpenTracing {
 	ZipkinInit(name)
-	gOpenTracing = &OpenTracing{name}
+	gOpenTracing = New(name, nil)
 	return gOpenTracing
 }
 
@@ -30,11 +36,56 @@ func GetOpenTracing() *OpenTracing {
 	return gOpenTracing
 }
 
+// 请求上下文中保存追踪实例的 key
+const ContextKey = "goframe.opentracing"
+
+// 获取请求上下文（可以是 *gin.Context）中的追踪实例，没有时返回默认实例
+func FromContext(ctx context.Context) *OpenTracing {
+	if ctx != nil {
+		if ot, ok := ctx.Value(ContextKey).(*OpenTracing); ok && ot != nil {
+			return ot
+		}
+	}
+	return GetOpenTracing()
+}
+
+// 创建追踪实例，Zipkin 日志打印到 log，log 为 nil 时使用默认日志
+func New(name string, log *logger.Logger) *OpenTracing {
+	if name == "" {
+		name = "unknown"
+	}
+	ip, err := http.ExternalIP()
+	if err != nil {
+		ip = "127.0.0.1"
+	}
+	return &OpenTracing{ServerName: name, ipv4: ip, log: log}
+}
+
 func (ot *OpenTracing) SetServerName(name string) {
-	ZipkinInit(name)
+	if ot == gOpenTracing {
+		ZipkinInit(name)
+	}
 	ot.ServerName = name
 }
 
+// 当前协程的 Zipkin，不存在时创建
+func (ot *OpenTracing) getZipkin(logId string) *Zipkin {
+	if z, ok := logZipkinCache.Get(logId); ok {
+		return z.(*Zipkin)
+	}
+
+	zipkin := &Zipkin{serverName: ot.ServerName, ipv4: ot.ipv4}
+	logZipkinCache.Set(logId, zipkin, time.Minute*3)
+	return zipkin
+}
+
+func (ot *OpenTracing) getLogger() *logger.Logger {
+	if ot.log != nil {
+		return ot.log
+	}
+	return logger.Default()
+}
+
 func (ot *OpenTracing) getGoroutineId() string {
 	b := make([]byte, 64)
 	runtime.Stack(b, false)
@@ -49,7 +100,7 @@ func (ot *OpenTracing) FromContextSetName(strContext, name string) {
 		return
 	}
 
-	zipkin := NewZipkin(logId)
+	zipkin := ot.getZipkin(logId)
 	zipkin.SetFromContext(strContext)
 	zipkin.SetName(name)
 }
@@ -59,7 +110,7 @@ func (ot *OpenTracing) SetTag(k, v string) {
 	if logId == "" {
 		return
 	}
-	NewZipkin(logId).SetTag(k, v)
+	ot.getZipkin(logId).SetTag(k, v)
 }
 
 func (ot *OpenTracing) Dump() {
@@ -68,14 +119,14 @@ func (ot *OpenTracing) Dump() {
 		return
 	}
 
-	zipkin := NewZipkin(logId)
+	zipkin := ot.getZipkin(logId)
 	defer DelZipkin(logId)
 
 	if m, err := zipkin.Dump(); err == nil {
 		var json = jsoniter.ConfigCompatibleWithStandardLibrary
 		jsonBytes, err := json.Marshal(m)
 		if err == nil {
-			logger.PrintZipkin(string(jsonBytes))
+			ot.getLogger().PrintZipkin(string(jsonBytes))
 		}
 	}
 }
@@ -87,7 +138,7 @@ func (ot *OpenTracing) StartChildSpan(name string) (int, error) {
 		return -1, errors.New("OpenTracing logId empty")
 	}
 
-	return NewZipkin(logId).StartChildSpan(name)
+	return ot.getZipkin(logId).StartChildSpan(name)
 }
 
 func (ot *OpenTracing) SetChildTag(index int, k, v string) {
@@ -100,7 +151,7 @@ func (ot *OpenTracing) SetChildTag(index int, k, v string) {
 		return
 	}
 
-	NewZipkin(logId).SetChildTag(k, v, index)
+	ot.getZipkin(logId).SetChildTag(k, v, index)
 }
 
 func (ot *OpenTracing) GetChildSpanContext(index int) (string, error) {
@@ -113,7 +164,7 @@ func (ot *OpenTracing) GetChildSpanContext(index int) (string, error) {
 		return "", nil
 	}
 
-	return NewZipkin(logId).GetChildSpanContextString(index)
+	return ot.getZipkin(logId).GetChildSpanContextString(index)
 }
 
 func (ot *OpenTracing) EndChildSpan(index int) {
@@ -126,7 +177,7 @@ func (ot *OpenTracing) EndChildSpan(index int) {
 		return
 	}
 
-	NewZipkin(logId).EndChildSpan(index)
+	ot.getZipkin(logId).EndChildSpan(index)
 }
 
 func (ot *OpenTracing) EndChildSpanByDuration(index int, duration int64) {
@@ -139,5 +190,5 @@ func (ot *OpenTracing) EndChildSpanByDuration(index int, duration int64) {
 		return
 	}
 
-	NewZipkin(logId).EndChildSpanByDuration(index, duration)
+	ot.getZipkin(logId).EndChildSpanByDuration(index, duration)
 }
@@ -1,6 +1,7 @@
 package rabbitmq
 
 import (
+	"context"
 	"fmt"
 	"github.com/streadway/amqp"
 	"sync"
@@ -27,6 +28,7 @@ type MQOperation interface {
 	DeleteQueue(*amqp.Channel, string) error                                   // 删除一个queue队列
 	PublishQueue(*amqp.Channel, string, string, string) error                  // 发布消息到队列
 	ConsumeQueue(*amqp.Channel, MQConsume, int) error                          // 取出消息消费
+	ConsumeQueueContext(context.Context, *amqp.Channel, MQConsume, int) error  // 取出消息消费，ctx结束后退出
 	EndConsumeQueue()                                                          // 退出消息消费
 	ReConsume(string, string, string) error                                    // 退回消息
 	GetReadyCount(*amqp.Channel, string) (int, error)                          // 统计正在队列中准备且还未消费的数据
@@ -74,6 +76,11 @@ func (r *RabbitMQ) Close() {
 	}
 }
 
+// 连接是否已经关闭
+func (r *RabbitMQ) IsClosed() bool {
+	return r.Conn == nil || r.Conn.IsClosed()
+}
+
 // 获取一个可用的Channel，使用完需要Close
 // 建议对 Publish 和 Consume 使用单独的连接
 func (r *RabbitMQ) ConnAndChannel() (channel *amqp.Channel, err error) {
@@ -194,8 +201,24 @@ func (r *RabbitMQ) PublishQueueByte(channel *amqp.Channel, exchange string, rout
 	return
 }
 
-// 消费队列信息
+// 消费队列信息，调用 EndConsumeQueue 后退出
 func (r *RabbitMQ) ConsumeQueue(channel *amqp.Channel, receiver MQConsume, processCnt int) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-r.endConsumeChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return r.ConsumeQueueContext(ctx, channel, receiver, processCnt)
+}
+
+// 消费队列信息，ctx 结束后退出并返回nil，channel 被关闭时返回错误
+func (r *RabbitMQ) ConsumeQueueContext(ctx context.Context, channel *amqp.Channel, receiver MQConsume, processCnt int) error {
 	err := channel.Qos(
 		3,     // prefetch count
 		0,     // prefetch size
@@ -242,7 +265,16 @@ func (r *RabbitMQ) ConsumeQueue(channel *amqp.Channel, receiver MQConsume, proce
 		}(i)
 	}
 
-	<-r.endConsumeChan
+	closeChan := channel.NotifyClose(make(chan *amqp.Error, 1))
+	select {
+	case <-ctx.Done():
+	case amqpErr := <-closeChan:
+		err = fmt.Errorf("channel closed: %v", amqpErr)
+		receiver.OnError(err)
+		wg.Wait()
+		return err
+	}
+
 	endConsume = true
 	if err := channel.Cancel(receiver.ConsumeName(), true); err != nil {
 		receiver.OnError(err)
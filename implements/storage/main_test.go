@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"os"
+	"testing"
+)
+
+// 同一个包只能有一个 TestMain，连接全部测试使用的存储
+func TestMain(m *testing.M) {
+	setupMysql()
+	setupMongo()
+	setupRedis()
+	code := m.Run()
+	teardownRedis()
+	teardownMongo()
+	teardownMysql()
+	os.Exit(code)
+}
@@ -170,6 +170,21 @@ func CloseMgo() {
 	}
 }
 
+// 检测Mgo连接是否可用
+func PingMongo(ctx context.Context) error {
+	if mgoClient == nil {
+		return errors.New("Mgo Client is Nil")
+	}
+
+	timeout := int64(mgoConnectTimeout)
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := int64(time.Until(deadline) / time.Second); left > 0 {
+			timeout = left
+		}
+	}
+	return mgoClient.Ping(timeout)
+}
+
 // 获取 MgoSession
 func GetMgoSession() (*qmgo.Session, error) {
 	if mgoClient == nil {
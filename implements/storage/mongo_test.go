@@ -4,7 +4,6 @@ import (
 	"context"
 	"go.mongodb.org/mongo-driver/bson"
 	"log"
-	"os"
 	"testing"
 	"time"
 )
@@ -105,10 +104,3 @@ func Benchmark_MongoFind(b *testing.B) {
 		}
 	}
 }
-
-func TestMain(m *testing.M) {
-	setupMongo()
-	code := m.Run()
-	teardownMongo()
-	os.Exit(code)
-}
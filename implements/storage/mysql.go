@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/mutou1225/go-frame/implements/opentracing"
@@ -9,7 +10,6 @@ import (
 	"gorm.io/gorm"
 	glogger "gorm.io/gorm/logger"
 	"strconv"
-	"sync"
 	"time"
 )
 
@@ -27,12 +27,6 @@ const (
 	mysqlConnTimeout = 3
 )
 
-var (
-	gDbHandle  = make(map[MysqlType]*gorm.DB)
-	gDdCfgMap  = make(map[MysqlType]*dbCfgInfo)
-	mysqlmutex sync.Mutex
-)
-
 type dbCfgInfo struct {
 	connStr                    string
 	maxOpen, maxIdle, idleTime int
@@ -41,13 +35,38 @@ type dbCfgInfo struct {
 
 // dbName: 数据库实例名称，用于获取数据库句柄
 func NewMysqlDB(myType MysqlType, host, user, pwd, database string, port, maxOpen, maxIdle, idleTime, connTime int, debug bool) error {
-	logger.PrintInfo("NewMysqlDB Start")
+	return defaultStorage.NewMysqlDB(myType, host, user, pwd, database, port, maxOpen, maxIdle, idleTime, connTime, debug)
+}
+
+// 获取一个连接
+func GetDBHandle(mysqlType MysqlType) *gorm.DB {
+	return defaultStorage.GetDBHandle(mysqlType)
+}
+
+// 关闭连接
+func ExitDB() {
+	defaultStorage.ExitDB()
+}
+
+// 关闭指定实例的连接
+func CloseDB(mysqlType MysqlType) {
+	defaultStorage.CloseDB(mysqlType)
+}
+
+// 检测指定实例的连接是否可用
+func PingDB(ctx context.Context, mysqlType MysqlType) error {
+	return defaultStorage.PingDB(ctx, mysqlType)
+}
 
-	mysqlmutex.Lock()
-	defer mysqlmutex.Unlock()
+// dbName: 数据库实例名称，用于获取数据库句柄
+func (s *Storage) NewMysqlDB(myType MysqlType, host, user, pwd, database string, port, maxOpen, maxIdle, idleTime, connTime int, debug bool) error {
+	s.getLogger().PrintInfo("NewMysqlDB Start")
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
 	// 是否存在
-	if conn, ok := gDbHandle[myType]; ok && conn != nil {
+	if conn, ok := s.dbHandle[myType]; ok && conn != nil {
 		return nil
 	}
 
@@ -59,7 +78,7 @@ func NewMysqlDB(myType MysqlType, host, user, pwd, database string, port, maxOpe
 		user, pwd, host, port, database, connTimeout)
 
 	// 配置
-	gDdCfgMap[myType] = &dbCfgInfo{
+	s.dbCfgMap[myType] = &dbCfgInfo{
 		connStr:  connStr,
 		maxOpen:  maxOpen,
 		maxIdle:  maxIdle,
@@ -67,20 +86,21 @@ func NewMysqlDB(myType MysqlType, host, user, pwd, database string, port, maxOpe
 		debug:    debug,
 	}
 
-	dbHandle, err := gDdCfgMap[myType].newConn()
-	gDbHandle[myType] = dbHandle
+	dbHandle, err := s.newConn(s.dbCfgMap[myType])
+	s.dbHandle[myType] = dbHandle
 	if err != nil {
-		gDbHandle[myType] = nil
+		s.dbHandle[myType] = nil
 		return err
 	}
 
 	return nil
 }
 
-func (c *dbCfgInfo) newConn() (*gorm.DB, error) {
-	logger.PrintInfo("Mysql connStr: %v", c.connStr)
+func (s *Storage) newConn(c *dbCfgInfo) (*gorm.DB, error) {
+	l := s.getLogger()
+	l.PrintInfo("Mysql connStr: %v", c.connStr)
 	dbHandle, err := gorm.Open(mysql.Open(c.connStr), &gorm.Config{
-		Logger: glogger.New(DbLogger{}, glogger.Config{
+		Logger: glogger.New(DbLogger{log: s.log, tracer: s.tracer}, glogger.Config{
 			SlowThreshold:             200 * time.Millisecond,
 			LogLevel:                  glogger.Info,
 			IgnoreRecordNotFoundError: false,
@@ -88,13 +108,13 @@ func (c *dbCfgInfo) newConn() (*gorm.DB, error) {
 		}),
 	})
 	if err != nil {
-		logger.PrintError("gorm.Open() Err: %s", err.Error())
+		l.PrintError("gorm.Open() Err: %s", err.Error())
 		return nil, err
 	}
 
 	sqlDB, err := dbHandle.DB()
 	if err != nil {
-		logger.PrintError("gorm.DB() Err: %s", err.Error())
+		l.PrintError("gorm.DB() Err: %s", err.Error())
 		return nil, err
 	}
 
@@ -104,54 +124,86 @@ func (c *dbCfgInfo) newConn() (*gorm.DB, error) {
 	return dbHandle, nil
 }
 
-func reconnMysqlDB(mysqlType MysqlType) (*gorm.DB, error) {
-	logger.PrintInfo("reconnMysqlDB Start")
+func (s *Storage) reconnMysqlDB(mysqlType MysqlType) (*gorm.DB, error) {
+	s.getLogger().PrintInfo("reconnMysqlDB Start")
 
-	mysqlmutex.Lock()
-	defer mysqlmutex.Unlock()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 
-	if conn, ok := gDbHandle[mysqlType]; ok && conn != nil {
+	if conn, ok := s.dbHandle[mysqlType]; ok && conn != nil {
 		return conn, nil
 	}
 
 	var dbCfg *dbCfgInfo
-	dbCfg, ok := gDdCfgMap[mysqlType]
+	dbCfg, ok := s.dbCfgMap[mysqlType]
 	if !ok {
-		logger.PrintInfo("reconnection() Err: cfg empty")
+		s.getLogger().PrintInfo("reconnection() Err: cfg empty")
 		return nil, errors.New("Err: cfg empty")
 	}
 
-	dbHandle, err := dbCfg.newConn()
-	gDbHandle[mysqlType] = dbHandle
+	dbHandle, err := s.newConn(dbCfg)
+	s.dbHandle[mysqlType] = dbHandle
 	if err != nil {
-		gDbHandle[mysqlType] = nil
+		s.dbHandle[mysqlType] = nil
 		return nil, err
 	}
 	return dbHandle, nil
 }
 
 // 获取一个连接
-func GetDBHandle(mysqlType MysqlType) *gorm.DB {
-	dbHandle, ok := gDbHandle[mysqlType]
+func (s *Storage) GetDBHandle(mysqlType MysqlType) *gorm.DB {
+	s.mutex.RLock()
+	dbHandle, ok := s.dbHandle[mysqlType]
+	s.mutex.RUnlock()
 	if ok && dbHandle != nil {
 		return dbHandle
-	} else {
-		conn, _ := reconnMysqlDB(mysqlType)
-		return conn
 	}
-	return nil
+
+	conn, _ := s.reconnMysqlDB(mysqlType)
+	return conn
 }
 
 // 关闭连接
-func ExitDB() {
-	for _, dbHandle := range gDbHandle {
-		if dbHandle != nil {
-			if sqlDB, err := dbHandle.DB(); err != nil {
-				_ = sqlDB.Close()
-			}
-			dbHandle = nil
+func (s *Storage) ExitDB() {
+	s.mutex.RLock()
+	myTypes := make([]MysqlType, 0, len(s.dbHandle))
+	for myType := range s.dbHandle {
+		myTypes = append(myTypes, myType)
+	}
+	s.mutex.RUnlock()
+
+	for _, myType := range myTypes {
+		s.CloseDB(myType)
+	}
+}
+
+// 关闭指定实例的连接
+func (s *Storage) CloseDB(mysqlType MysqlType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if dbHandle, ok := s.dbHandle[mysqlType]; ok && dbHandle != nil {
+		if sqlDB, err := dbHandle.DB(); err == nil {
+			_ = sqlDB.Close()
 		}
 	}
+	delete(s.dbHandle, mysqlType)
+}
+
+// 检测指定实例的连接是否可用
+func (s *Storage) PingDB(ctx context.Context, mysqlType MysqlType) error {
+	s.mutex.RLock()
+	dbHandle, ok := s.dbHandle[mysqlType]
+	s.mutex.RUnlock()
+	if !ok || dbHandle == nil {
+		return errors.New("mysql uninitialized")
+	}
+
+	sqlDB, err := dbHandle.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
 }
 
 // 获取mysql执行的错误信息
@@ -159,29 +211,39 @@ func GetDBError(db *gorm.DB) error {
 	return db.Error
 }
 
-type DbLogger struct{}
+// gorm 日志，log、tracer 为 nil 时使用默认实例
+type DbLogger struct {
+	log    *logger.Logger
+	tracer *opentracing.OpenTracing
+}
 
 func (log DbLogger) Printf(s string, v ...interface{}) {
+	l, ot := log.log, log.tracer
+	if l == nil {
+		l = logger.Default()
+	}
+	if ot == nil {
+		ot = opentracing.GetOpenTracing()
+	}
+
 	vLen := len(v)
 	if vLen == 4 {
-		logger.PrintInfoCalldepth(5, "%s %s[%.3fms rows:%v]%s", v[3], logger.Green, v[1], v[2], logger.Reset)
+		l.PrintInfoCalldepth(5, "%s %s[%.3fms rows:%v]%s", v[3], logger.Green, v[1], v[2], logger.Reset)
 
 		f, _ := strconv.ParseFloat(fmt.Sprintf("%s", v[1]), 32)
-		ot := opentracing.GetOpenTracing()
 		spanId, _ := ot.StartChildSpan("Mysql")
 		ot.SetChildTag(spanId, "db.type", "mysql")
 		ot.SetChildTag(spanId, "db.statement", fmt.Sprintf("%s", v[3]))
 		ot.EndChildSpanByDuration(spanId, int64(f * 1000))
 	} else if vLen == 5 {
-		logger.PrintErrorCalldepth(5, "%s %s%s%s %s[%.3fms rows:%v]%s", v[4], logger.Red, v[1], logger.Reset, logger.Yellow, v[2], v[3], logger.Reset)
+		l.PrintErrorCalldepth(5, "%s %s%s%s %s[%.3fms rows:%v]%s", v[4], logger.Red, v[1], logger.Reset, logger.Yellow, v[2], v[3], logger.Reset)
 
 		f, _ := strconv.ParseFloat(fmt.Sprintf("%s", v[2]), 32)
-		ot := opentracing.GetOpenTracing()
 		spanId, _ := ot.StartChildSpan("Mysql")
 		ot.SetChildTag(spanId, "db.type", "mysql")
 		ot.SetChildTag(spanId, "db.statement", fmt.Sprintf("%s", v[4]))
 		ot.EndChildSpanByDuration(spanId, int64(f * 1000))
 	} else {
-		logger.PrintInfoCalldepth(5, s, v...)
+		l.PrintInfoCalldepth(5, s, v...)
 	}
 }
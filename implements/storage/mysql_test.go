@@ -3,7 +3,6 @@ package storage
 import (
 	"fmt"
 	"log"
-	"os"
 	"strconv"
 	"testing"
 	"time"
@@ -101,10 +100,3 @@ func Benchmark_Create(b *testing.B) {
 		}
 	}
 }
-
-func TestMain(m *testing.M) {
-	setupMysql()
-	code := m.Run()
-	teardownMysql()
-	os.Exit(code)
-}
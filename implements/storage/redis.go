@@ -6,7 +6,6 @@ import (
 	"errors"
 	"github.com/go-redis/redis/v8"
 	jsoniter "github.com/json-iterator/go"
-	"github.com/mutou1225/go-frame/config"
 	"github.com/mutou1225/go-frame/implements/opentracing"
 	"github.com/mutou1225/go-frame/implements/toolkit"
 	"github.com/mutou1225/go-frame/logger"
@@ -19,13 +18,10 @@ import (
 type RedisOpt struct {
 	client    *redis.Client
 	startTime int64
+	log       *logger.Logger
+	tracer    *opentracing.OpenTracing
 }
 
-var (
-	pRedisOpt *RedisOpt
-	//pRedisOptConf *redis.Options
-)
-
 type RediGoInterface interface {
 	IsConnect() error               // 判断redis是否连接
 	IsRedisValueNil(err error) bool // redis返回数据是否是nil
@@ -62,44 +58,53 @@ func redisOptions() *redis.Options {
 	}
 }
 
-func updatePoolStates() {
+func updatePoolStates(client *redis.Client) {
 	ticker := time.NewTicker(5 * time.Minute)
 	for range ticker.C {
-		stats := pRedisOpt.client.PoolStats()
+		stats := client.PoolStats()
 		log.Printf("Redis PoolStats: %+v", stats)
 	}
 }
 
 // 正常redis应该只会使用一个实例，所以暂时不做多实例应用
 func NewRedisCon() error {
+	return defaultStorage.NewRedisCon()
+}
+
+func NewRedisConByInfo(host, passwd string, port, dbIndex, poolMax, minIdle int) error {
+	return defaultStorage.NewRedisConByInfo(host, passwd, port, dbIndex, poolMax, minIdle)
+}
+
+func GetRedisCon() (*RedisOpt, error) {
+	return defaultStorage.GetRedisCon()
+}
+
+// 关闭redis
+func CloseRedisCon() {
+	defaultStorage.CloseRedisCon()
+}
+
+// 使用存储实例的配置连接redis
+func (s *Storage) NewRedisCon() error {
+	conf := s.getConfig()
 	opt := redisOptions()
-	opt.Addr = config.GetRedisHost() + ":" + strconv.Itoa(config.GetRedisPort())
-	opt.Password = config.GetRedisAuth()
+	opt.Addr = conf.GetRedisHost() + ":" + strconv.Itoa(conf.GetRedisPort())
+	opt.Password = conf.GetRedisAuth()
 	opt.DB = 0
 
-	if config.GetRedisPoolMax() != 0 {
-		opt.PoolSize = config.GetRedisPoolMax()
+	if conf.GetRedisPoolMax() != 0 {
+		opt.PoolSize = conf.GetRedisPoolMax()
 	}
 
 	// 不配0，新连接建立会比较慢
-	if config.GetRedisPoolMin() != 0 {
-		opt.MinIdleConns = config.GetRedisPoolMin()
-	}
-
-	client := redis.NewClient(opt)
-	if client == nil {
-		return errors.New("NewRedisCon Fail!")
+	if conf.GetRedisPoolMin() != 0 {
+		opt.MinIdleConns = conf.GetRedisPoolMin()
 	}
 
-	//pRedisOptConf = opt // 用于更新
-	pRedisOpt = &RedisOpt{client: client}
-
-	go updatePoolStates()
-
-	return nil
+	return s.newRedisClient(opt)
 }
 
-func NewRedisConByInfo(host, passwd string, port, dbIndex, poolMax, minIdle int) error {
+func (s *Storage) NewRedisConByInfo(host, passwd string, port, dbIndex, poolMax, minIdle int) error {
 	opt := redisOptions()
 	opt.Addr = host + ":" + strconv.Itoa(port)
 	opt.Password = passwd
@@ -112,40 +117,77 @@ func NewRedisConByInfo(host, passwd string, port, dbIndex, poolMax, minIdle int)
 		opt.MinIdleConns = 1
 	}
 
+	return s.newRedisClient(opt)
+}
+
+func (s *Storage) newRedisClient(opt *redis.Options) error {
 	client := redis.NewClient(opt)
 	if client == nil {
 		return errors.New("NewRedisCon Fail!")
 	}
 
-	//pRedisOptConf = opt // 用于更新
-	pRedisOpt = &RedisOpt{client: client}
+	s.mutex.Lock()
+	s.redisOpt = &RedisOpt{client: client, log: s.log, tracer: s.tracer}
+	s.mutex.Unlock()
 
-	go updatePoolStates()
+	go updatePoolStates(client)
 
 	return nil
 }
 
-func GetRedisCon() (*RedisOpt, error) {
-	if pRedisOpt != nil && pRedisOpt.client != nil {
-		return pRedisOpt, nil
+func (s *Storage) GetRedisCon() (*RedisOpt, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.redisOpt != nil && s.redisOpt.client != nil {
+		return s.redisOpt, nil
 	}
 	return nil, errors.New("Redis Con <nil>")
 }
 
 // 关闭redis
-func CloseRedisCon() {
-	if pRedisOpt != nil {
-		if pRedisOpt.client != nil {
-			_ = pRedisOpt.client.Close()
+func (s *Storage) CloseRedisCon() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.redisOpt != nil {
+		if s.redisOpt.client != nil {
+			_ = s.redisOpt.client.Close()
 		}
 	}
 }
 
+func (r *RedisOpt) getLogger() *logger.Logger {
+	if r.log != nil {
+		return r.log
+	}
+	return logger.Default()
+}
+
+// 原生客户端，用于 RediGoInterface 没有的命令（脚本、有序集合等）
+func (r *RedisOpt) Client() *redis.Client {
+	if r == nil {
+		return nil
+	}
+	return r.client
+}
+
+// 检测redis连接是否可用
+func (r *RedisOpt) Ping(ctx context.Context) error {
+	if r == nil || r.client == nil {
+		return errors.New("RedisOpt client Is nil!")
+	}
+	return r.client.Ping(ctx).Err()
+}
+
 func (r *RedisOpt) OpenTracing(cmd string) {
 	// opentracing
 	duration := (time.Now().UTC().UnixNano() - r.startTime) / int64(time.Microsecond)
 	cmdList := strings.Split(cmd, ":")
-	ot := opentracing.GetOpenTracing()
+	ot := r.tracer
+	if ot == nil {
+		ot = opentracing.GetOpenTracing()
+	}
 	spanId, _ := ot.StartChildSpan("Redis")
 	ot.SetChildTag(spanId, "db.type", "redis")
 	ot.SetChildTag(spanId, "db.statement", cmdList[0])
@@ -177,10 +219,10 @@ func (r *RedisOpt) Del(key ...string) error {
 
 	rComd := r.client.Del(context.Background(), key...)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	if rComd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
 		return rComd.Err()
 	}
 
@@ -195,11 +237,11 @@ func (r *RedisOpt) Expire(key string, expiration time.Duration) (bool, error) {
 
 	rComd := r.client.Expire(context.Background(), key, expiration)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	vaule, err := rComd.Result()
 	if err != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
 		return false, err
 	}
 	return vaule, nil
@@ -213,17 +255,17 @@ func (r *RedisOpt) Get(key string, value interface{}) error {
 
 	rComd := r.client.Get(context.Background(), key)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	if tmp, err := rComd.Bytes(); err != nil {
 		if !r.IsRedisValueNil(err) {
-			logger.PrintErrorCalldepth(3, "Redis Get Error: %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "Redis Get Error: %s", err.Error())
 		}
 		return err
 	} else {
 		var json = jsoniter.ConfigCompatibleWithStandardLibrary
 		if err = json.Unmarshal(tmp[:], value); err != nil {
-			logger.PrintErrorCalldepth(3, "json.Unmarshal() %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "json.Unmarshal() %s", err.Error())
 			return err
 		}
 	}
@@ -241,16 +283,16 @@ func (r *RedisOpt) Set(key string, value interface{}, expiration time.Duration)
 	var json = jsoniter.ConfigCompatibleWithStandardLibrary
 	jsonBytes, err := json.Marshal(value)
 	if err != nil {
-		logger.PrintErrorCalldepth(3, "RedisOpt::Set() json.Marshal() Err: %s", err.Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisOpt::Set() json.Marshal() Err: %s", err.Error())
 		return err
 	}
 
 	rComd := r.client.Set(context.Background(), key, jsonBytes, expiration)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	if rComd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
 		return rComd.Err()
 	}
 
@@ -265,17 +307,17 @@ func (r *RedisOpt) HGet(key string, fields interface{}, value interface{}) error
 
 	rComd := r.client.HGet(context.Background(), key, toolkit.ConvertToString(fields))
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	if tmp, err := rComd.Bytes(); err != nil {
 		if !r.IsRedisValueNil(err) {
-			logger.PrintErrorCalldepth(3, "Redis HGet Error: %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "Redis HGet Error: %s", err.Error())
 		}
 		return err
 	} else {
 		var json = jsoniter.ConfigCompatibleWithStandardLibrary
 		if err = json.Unmarshal(tmp[:], value); err != nil {
-			logger.PrintErrorCalldepth(3, "json.Unmarshal() %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "json.Unmarshal() %s", err.Error())
 			return err
 		}
 	}
@@ -292,16 +334,16 @@ func (r *RedisOpt) HSet(key string, fields interface{}, value interface{}) error
 	var json = jsoniter.ConfigCompatibleWithStandardLibrary
 	jsonBytes, err := json.Marshal(value)
 	if err != nil {
-		logger.PrintErrorCalldepth(3, "RedisOpt::Set() json.Marshal() Err: %s", err.Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisOpt::Set() json.Marshal() Err: %s", err.Error())
 		return err
 	}
 
 	rComd := r.client.HSet(context.Background(), key, toolkit.ConvertToString(fields), jsonBytes)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	if rComd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
 		return rComd.Err()
 	}
 
@@ -316,10 +358,10 @@ func (r *RedisOpt) HDel(key string, fields ...string) error {
 
 	rComd := r.client.HDel(context.Background(), key, fields...)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	if rComd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
 		return rComd.Err()
 	}
 
@@ -334,11 +376,11 @@ func (r *RedisOpt) Incr(key string) (int64, error) {
 
 	rComd := r.client.Incr(context.Background(), key)
 	r.OpenTracing(rComd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", rComd.String())
 
 	vaule, err := rComd.Result()
 	if err != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", rComd.Err().Error())
 		return -1, err
 	}
 	return vaule, nil
@@ -352,12 +394,12 @@ func (r *RedisOpt) MGet(key []string, value map[string]interface{}, retNil bool)
 
 	sliceCmd := r.client.MGet(context.Background(), key[:]...)
 	r.OpenTracing(sliceCmd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", sliceCmd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", sliceCmd.String())
 
 	vaule, err := sliceCmd.Result()
 	if err != nil {
 		if !r.IsRedisValueNil(err) {
-			logger.PrintErrorCalldepth(3, "Redis MGet Error: %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "Redis MGet Error: %s", err.Error())
 		}
 		return err
 	}
@@ -372,7 +414,7 @@ func (r *RedisOpt) MGet(key []string, value map[string]interface{}, retNil bool)
 		}
 
 		if err = json.Unmarshal([]byte(k.(string)), value[key[i]]); err != nil {
-			logger.PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
 			return err
 		}
 	}
@@ -390,7 +432,7 @@ func (r *RedisOpt) MSet(value map[string]interface{}) error {
 	tmpMap := make(map[string]interface{})
 	for k, v := range value {
 		if jsonBytes, err := json.Marshal(v); err != nil {
-			logger.PrintErrorCalldepth(3, "RedisOpt::MSet() json.Marshal() Err: %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "RedisOpt::MSet() json.Marshal() Err: %s", err.Error())
 			return err
 		} else {
 			tmpMap[k] = jsonBytes
@@ -399,10 +441,10 @@ func (r *RedisOpt) MSet(value map[string]interface{}) error {
 
 	statusCmd := r.client.MSet(context.Background(), tmpMap)
 	r.OpenTracing(statusCmd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", statusCmd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", statusCmd.String())
 
 	if statusCmd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", statusCmd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", statusCmd.Err().Error())
 		return statusCmd.Err()
 	}
 
@@ -418,11 +460,11 @@ func (r *RedisOpt) HMGet(key string, fields []string, value map[string]interface
 
 	sliceCmd := r.client.HMGet(context.Background(), key, fields[:]...)
 	r.OpenTracing(sliceCmd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", sliceCmd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", sliceCmd.String())
 
 	result, err := sliceCmd.Result()
 	if err != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", sliceCmd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", sliceCmd.Err().Error())
 		return err
 	}
 
@@ -436,7 +478,7 @@ func (r *RedisOpt) HMGet(key string, fields []string, value map[string]interface
 		}
 
 		if err = json.Unmarshal([]byte(k.(string)), value[fields[i]]); err != nil {
-			logger.PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
 			return err
 		}
 
@@ -446,14 +488,14 @@ func (r *RedisOpt) HMGet(key string, fields []string, value map[string]interface
 		case reflect.Int,reflect.Int8,reflect.Int16,reflect.Int32,reflect.Int64,reflect.Uint,reflect.Uint8,reflect.Uint16,reflect.Uint32,reflect.Uint64:
 			var tem int
 			if err = json.Unmarshal([]byte(k.(string)), &tem); err != nil {
-				logger.PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
+				r.getLogger().PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
 				return err
 			}
 			value[fields[i]] = tem
 		default:
 			tem := value[fields[i]]
 			if err = json.Unmarshal([]byte(k.(string)), &tem); err != nil {
-				logger.PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
+				r.getLogger().PrintErrorCalldepth(3, "json.Unmarshal(%v) %s", k, err.Error())
 				return err
 			}
 			value[fields[i]] = tem
@@ -474,7 +516,7 @@ func (r *RedisOpt) HMSet(key string, value map[string]interface{}) error {
 	tmpMap := make(map[string]interface{})
 	for k, v := range value {
 		if jsonBytes, err := json.Marshal(v); err != nil {
-			logger.PrintErrorCalldepth(3, "RedisOpt::MSet() json.Marshal() Err: %s", err.Error())
+			r.getLogger().PrintErrorCalldepth(3, "RedisOpt::MSet() json.Marshal() Err: %s", err.Error())
 			return err
 		} else {
 			tmpMap[k] = jsonBytes
@@ -483,10 +525,10 @@ func (r *RedisOpt) HMSet(key string, value map[string]interface{}) error {
 
 	statusCmd := r.client.HMSet(context.Background(), key, tmpMap)
 	r.OpenTracing(statusCmd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", statusCmd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", statusCmd.String())
 
 	if statusCmd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", statusCmd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", statusCmd.Err().Error())
 		return statusCmd.Err()
 	}
 
@@ -501,11 +543,11 @@ func (r *RedisOpt) HIncr(key, field string, incr int) (int64, error) {
 
 	intCmd := r.client.HIncrBy(context.Background(), key, field, int64(incr))
 	r.OpenTracing(intCmd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", intCmd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", intCmd.String())
 
 	vaule, err := intCmd.Result()
 	if err != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", intCmd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", intCmd.Err().Error())
 		return -1, err
 	}
 	return vaule, nil
@@ -518,10 +560,10 @@ func (r *RedisOpt) ZRange(key string, start, stop int64) ([]string, error) {
 
 	sliceCmd := r.client.ZRange(context.Background(), key, start, stop)
 	r.OpenTracing(sliceCmd.String())
-	logger.PrintInfoCalldepth(3, "rrdisCmd: %s", sliceCmd.String())
+	r.getLogger().PrintInfoCalldepth(3, "rrdisCmd: %s", sliceCmd.String())
 
 	if sliceCmd.Err() != nil {
-		logger.PrintErrorCalldepth(3, "RedisError: %s", sliceCmd.Err().Error())
+		r.getLogger().PrintErrorCalldepth(3, "RedisError: %s", sliceCmd.Err().Error())
 		return nil, sliceCmd.Err()
 	}
 
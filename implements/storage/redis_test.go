@@ -1,8 +1,8 @@
 package storage
 
 import (
+	"context"
 	"log"
-	"os"
 	"testing"
 	"time"
 )
@@ -182,9 +182,52 @@ func TestRedisApi(t *testing.T) {
 	}
 }
 
-func TestMain(m *testing.M) {
-	setupRedis()
-	code := m.Run()
-	teardownRedis()
-	os.Exit(code)
+func TestRedisLock(t *testing.T) {
+	redisPool, err := GetRedisCon()
+	if err != nil {
+		t.Fatalf("GetRedisCon() Err: %s", err.Error())
+	}
+	ctx := context.Background()
+	_ = redisPool.Del("testLock", "testLock:tick")
+
+	lock, err := redisPool.ObtainLock(ctx, "testLock", time.Second)
+	if err != nil {
+		t.Fatalf("ObtainLock() Err: %s", err.Error())
+	}
+	if _, err := redisPool.ObtainLock(ctx, "testLock", time.Second); err != ErrLockNotObtained {
+		t.Errorf("ObtainLock() held want ErrLockNotObtained, got %v", err)
+	}
+	if err := lock.Refresh(ctx); err != nil {
+		t.Errorf("Refresh() Err: %s", err.Error())
+	}
+
+	// 过期后被其他实例获得，fence 变大，原持有者续期失败
+	time.Sleep(1100 * time.Millisecond)
+	lock2, err := redisPool.ObtainLock(ctx, "testLock", time.Second)
+	if err != nil {
+		t.Fatalf("ObtainLock() after expire Err: %s", err.Error())
+	}
+	if lock2.Fence() <= lock.Fence() {
+		t.Errorf("Fence() %d <= %d", lock2.Fence(), lock.Fence())
+	}
+	if err := lock.Refresh(ctx); err != ErrLockLost {
+		t.Errorf("Refresh() lost want ErrLockLost, got %v", err)
+	}
+	if err := lock.Release(ctx); err != ErrLockLost {
+		t.Errorf("Release() lost want ErrLockLost, got %v", err)
+	}
+	if err := lock2.Release(ctx); err != nil {
+		t.Errorf("Release() Err: %s", err.Error())
+	}
+
+	// 同一个 tick 只能加锁一次
+	tick := time.Now().Unix()
+	lock3, err := redisPool.ObtainTickLock(ctx, "testLock", time.Second, tick)
+	if err != nil {
+		t.Fatalf("ObtainTickLock() Err: %s", err.Error())
+	}
+	_ = lock3.Release(ctx)
+	if _, err := redisPool.ObtainTickLock(ctx, "testLock", time.Second, tick); err != ErrLockNotObtained {
+		t.Errorf("ObtainTickLock() same tick want ErrLockNotObtained, got %v", err)
+	}
 }
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+/*
+  Redis 分布式锁
+  加锁：SET NX PX，成功后 fence 加一，fence 是递增的序号，锁的持有者变化后一定变大，
+  下游写入时可以用 fence 拒绝过期持有者的请求
+  续期和释放都校验 token，锁过期被其他实例获得后，原持有者续期返回 ErrLockLost，释放不影响新持有者
+  tick 不为 0 时，同一个 tick 只能加锁一次（定时任务每次触发只在一个实例执行）
+*/
+
+var (
+	ErrLockNotObtained = errors.New("redis lock not obtained")
+	ErrLockLost        = errors.New("redis lock lost")
+)
+
+// KEYS: lock, fence, tick; ARGV: token, ttl(ms), tick
+// 返回 fence；0 表示锁被其他实例持有，-1 表示这个 tick 已经执行过
+var obtainLockScript = redis.NewScript(`
+if tonumber(ARGV[3]) > 0 then
+	local last = tonumber(redis.call('get', KEYS[3]) or '0')
+	if last >= tonumber(ARGV[3]) then
+		return -1
+	end
+end
+if not redis.call('set', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return 0
+end
+if tonumber(ARGV[3]) > 0 then
+	redis.call('set', KEYS[3], ARGV[3], 'PX', 86400000)
+end
+return redis.call('incr', KEYS[2])
+`)
+
+var refreshLockScript = redis.NewScript(`
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('pexpire', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var releaseLockScript = redis.NewScript(`
+if redis.call('get', KEYS[1]) == ARGV[1] then
+	return redis.call('del', KEYS[1])
+end
+return 0
+`)
+
+// 分布式锁
+type RedisLock struct {
+	r     *RedisOpt
+	key   string
+	token string
+	ttl   time.Duration
+	fence int64
+}
+
+// 加锁，锁被持有时返回 ErrLockNotObtained
+func (r *RedisOpt) ObtainLock(ctx context.Context, key string, ttl time.Duration) (*RedisLock, error) {
+	return r.ObtainTickLock(ctx, key, ttl, 0)
+}
+
+// 加锁，同一个 tick（递增，如定时任务触发时间的秒数）只能加锁成功一次
+func (r *RedisOpt) ObtainTickLock(ctx context.Context, key string, ttl time.Duration, tick int64) (*RedisLock, error) {
+	if r == nil || r.client == nil {
+		return nil, errors.New("RedisOpt client Is nil!")
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(buf)
+
+	keys := []string{key, key + ":fence", key + ":tick"}
+	fence, err := obtainLockScript.Run(ctx, r.client, keys, token, ttl.Milliseconds(), tick).Int64()
+	if err != nil {
+		r.getLogger().PrintError("ObtainLock[%s] Err: %s", key, err.Error())
+		return nil, err
+	}
+	if fence <= 0 {
+		return nil, ErrLockNotObtained
+	}
+
+	return &RedisLock{r: r, key: key, token: token, ttl: ttl, fence: fence}, nil
+}
+
+// 锁名
+func (l *RedisLock) Key() string {
+	return l.key
+}
+
+// 加锁的序号，每次加锁成功递增
+func (l *RedisLock) Fence() int64 {
+	return l.fence
+}
+
+// 续期为 ttl，锁已经过期或被其他实例持有时返回 ErrLockLost
+func (l *RedisLock) Refresh(ctx context.Context) error {
+	ret, err := refreshLockScript.Run(ctx, l.r.client, []string{l.key}, l.token, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// 释放锁，锁已经不属于自己时返回 ErrLockLost
+func (l *RedisLock) Release(ctx context.Context) error {
+	ret, err := releaseLockScript.Run(ctx, l.r.client, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if ret == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
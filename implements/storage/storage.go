@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"github.com/mutou1225/go-frame/config"
+	"github.com/mutou1225/go-frame/implements/opentracing"
+	"github.com/mutou1225/go-frame/logger"
+	"gorm.io/gorm"
+	"sync"
+)
+
+/*
+  存储实例：Mysql 连接、Redis 连接
+  包级的 NewMysqlDB、GetDBHandle、NewRedisCon、GetRedisCon 等函数使用默认实例
+  appengine.New 创建的应用使用自己的存储实例，日志和追踪使用应用的实例
+*/
+
+var defaultStorage = NewStorage(nil, nil, nil)
+
+type Storage struct {
+	mutex    sync.RWMutex
+	dbHandle map[MysqlType]*gorm.DB
+	dbCfgMap map[MysqlType]*dbCfgInfo
+	redisOpt *RedisOpt
+	conf     *config.Config
+	log      *logger.Logger
+	tracer   *opentracing.OpenTracing
+}
+
+// 创建存储实例，参数为 nil 时使用默认的配置、日志、追踪实例
+func NewStorage(conf *config.Config, log *logger.Logger, tracer *opentracing.OpenTracing) *Storage {
+	return &Storage{
+		dbHandle: make(map[MysqlType]*gorm.DB),
+		dbCfgMap: make(map[MysqlType]*dbCfgInfo),
+		conf:     conf,
+		log:      log,
+		tracer:   tracer,
+	}
+}
+
+// 默认存储实例
+func DefaultStorage() *Storage {
+	return defaultStorage
+}
+
+func (s *Storage) getConfig() *config.Config {
+	if s.conf != nil {
+		return s.conf
+	}
+	return config.Default()
+}
+
+func (s *Storage) getLogger() *logger.Logger {
+	if s.log != nil {
+		return s.log
+	}
+	return logger.Default()
+}
+
+func (s *Storage) getTracer() *opentracing.OpenTracing {
+	if s.tracer != nil {
+		return s.tracer
+	}
+	return opentracing.GetOpenTracing()
+}
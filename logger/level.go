@@ -0,0 +1,258 @@
+package logger
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"log"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+  运行时修改日志级别
+  全局级别和包级别，到期后自动恢复为配置文件的级别；d 为 0 时不恢复
+  包级别按调用方的包路径匹配，包含子包，多个匹配时使用最长的包路径
+  没有包级别时由 logrus 过滤，不影响打印性能
+*/
+
+// 日志级别的当前设置
+type LevelInfo struct {
+	Level    string         `json:"level"`
+	Default  string         `json:"default"`
+	Expire   string         `json:"expire,omitempty"`
+	Packages []PackageLevel `json:"packages"`
+}
+
+type PackageLevel struct {
+	Package string `json:"package"`
+	Level   string `json:"level"`
+	Expire  string `json:"expire,omitempty"`
+}
+
+type levelOverride struct {
+	level  logrus.Level
+	expire time.Time
+	seq    uint64
+}
+
+type levelControl struct {
+	mutex       sync.RWMutex
+	main        *MyLogger
+	base        logrus.Level
+	global      *levelOverride
+	packages    map[string]*levelOverride
+	hasPackages int32
+	seq         uint64
+}
+
+func newLevelControl(main *MyLogger, base logrus.Level) *levelControl {
+	return &levelControl{
+		main:     main,
+		base:     base,
+		packages: make(map[string]*levelOverride),
+	}
+}
+
+// 设置级别，pkg 为空时为全局级别
+func (c *levelControl) set(pkg string, level logrus.Level, d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.seq++
+	o := &levelOverride{level: level, seq: c.seq}
+	if d > 0 {
+		o.expire = time.Now().Add(d)
+		seq := c.seq
+		time.AfterFunc(d, func() {
+			c.expire(pkg, seq)
+		})
+	}
+
+	if pkg == "" {
+		c.global = o
+	} else {
+		c.packages[pkg] = o
+	}
+	c.apply()
+}
+
+// 恢复为配置文件的级别，pkg 为空时恢复全局级别
+func (c *levelControl) reset(pkg string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if pkg == "" {
+		c.global = nil
+	} else {
+		delete(c.packages, pkg)
+	}
+	c.apply()
+}
+
+// 到期恢复，期间重新设置过的不恢复
+func (c *levelControl) expire(pkg string, seq uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	o := c.global
+	if pkg != "" {
+		o = c.packages[pkg]
+	}
+	if o == nil || o.seq != seq {
+		return
+	}
+
+	if pkg == "" {
+		c.global = nil
+	} else {
+		delete(c.packages, pkg)
+	}
+	c.apply()
+	log.Printf("log level of [%s] reverted", pkg)
+}
+
+// logrus 使用最详细的级别，包级别的过滤在 enabled 中
+func (c *levelControl) apply() {
+	level := c.globalLevel()
+	for _, o := range c.packages {
+		if o.level > level {
+			level = o.level
+		}
+	}
+	c.main.SetLevel(level)
+
+	if len(c.packages) > 0 {
+		atomic.StoreInt32(&c.hasPackages, 1)
+	} else {
+		atomic.StoreInt32(&c.hasPackages, 0)
+	}
+}
+
+func (c *levelControl) globalLevel() logrus.Level {
+	if c.global != nil {
+		return c.global.level
+	}
+	return c.base
+}
+
+// 调用方的包是否打印 level 级别的日志，calldepth 和 GetContextInfo 一致
+func (c *levelControl) enabled(level logrus.Level, calldepth int) bool {
+	if c == nil || atomic.LoadInt32(&c.hasPackages) == 0 {
+		return true
+	}
+
+	pc, _, _, ok := runtime.Caller(calldepth)
+	if !ok {
+		return true
+	}
+	pkg := funcPackage(runtime.FuncForPC(pc).Name())
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	effective, matched := c.globalLevel(), ""
+	for name, o := range c.packages {
+		if (pkg == name || strings.HasPrefix(pkg, name+"/")) && len(name) > len(matched) {
+			effective, matched = o.level, name
+		}
+	}
+	return level <= effective
+}
+
+func (c *levelControl) info() LevelInfo {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	info := LevelInfo{
+		Level:    c.globalLevel().String(),
+		Default:  c.base.String(),
+		Packages: make([]PackageLevel, 0, len(c.packages)),
+	}
+	if c.global != nil {
+		info.Expire = formatExpire(c.global.expire)
+	}
+	for name, o := range c.packages {
+		info.Packages = append(info.Packages, PackageLevel{Package: name, Level: o.level.String(), Expire: formatExpire(o.expire)})
+	}
+	sort.Slice(info.Packages, func(i, j int) bool {
+		return info.Packages[i].Package < info.Packages[j].Package
+	})
+	return info
+}
+
+func formatExpire(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
+
+// 函数名中的包路径：github.com/a/b.(*T).F -> github.com/a/b
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// 设置全局日志级别，d 后恢复为配置文件的级别，d 为 0 时不恢复
+func (l *Logger) SetLevel(level string, d time.Duration) error {
+	return l.SetPackageLevel("", level, d)
+}
+
+// 设置包的日志级别（包含子包），pkg 为包路径，如 github.com/mutou1225/go-frame/implements/storage
+func (l *Logger) SetPackageLevel(pkg, level string, d time.Duration) error {
+	if l == nil || l.levels == nil {
+		return errors.New("logger not initialized")
+	}
+
+	lv, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	l.levels.set(strings.TrimSpace(pkg), lv, d)
+	return nil
+}
+
+// 恢复为配置文件的级别，pkg 为空时恢复全局级别
+func (l *Logger) ResetLevel(pkg string) error {
+	if l == nil || l.levels == nil {
+		return errors.New("logger not initialized")
+	}
+
+	l.levels.reset(strings.TrimSpace(pkg))
+	return nil
+}
+
+// 当前的日志级别
+func (l *Logger) Levels() LevelInfo {
+	if l == nil || l.levels == nil {
+		return LevelInfo{Packages: make([]PackageLevel, 0)}
+	}
+	return l.levels.info()
+}
+
+// 设置默认日志实例的全局日志级别
+func SetLevel(level string, d time.Duration) error {
+	return std.SetLevel(level, d)
+}
+
+// 设置默认日志实例的包日志级别
+func SetPackageLevel(pkg, level string, d time.Duration) error {
+	return std.SetPackageLevel(pkg, level, d)
+}
+
+// 恢复默认日志实例的日志级别
+func ResetLevel(pkg string) error {
+	return std.ResetLevel(pkg)
+}
+
+// 默认日志实例的日志级别
+func Levels() LevelInfo {
+	return std.Levels()
+}
@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_SetLevel(t *testing.T) {
+	main := newTestLogger()
+	defer closeTestLogger(main)
+	buf := &bytes.Buffer{}
+	main.SetOutput(buf)
+	main.SetLevel(InfoLevel)
+	l := &Logger{main: main, levels: newLevelControl(main, InfoLevel)}
+
+	printed := func(f func(format string, v ...interface{}), msg string) bool {
+		buf.Reset()
+		f(msg)
+		return strings.Contains(buf.String(), msg)
+	}
+
+	if printed(l.PrintDebug, "debug-1") || !printed(l.PrintInfo, "info-1") {
+		t.Error("default level want info")
+	}
+
+	// 包级别：只影响匹配的包
+	if err := l.SetPackageLevel("github.com/mutou1225/go-frame/logger", "debug", 0); err != nil {
+		t.Fatalf("SetPackageLevel() Err: %s", err.Error())
+	}
+	if !printed(l.PrintDebug, "debug-2") {
+		t.Error("package level debug not printed")
+	}
+	if err := l.SetPackageLevel("github.com/mutou1225/go-frame/logger", "error", 0); err != nil {
+		t.Fatalf("SetPackageLevel() Err: %s", err.Error())
+	}
+	if printed(l.PrintInfo, "info-2") || !printed(l.PrintError, "error-2") {
+		t.Error("package level error want only error")
+	}
+	_ = l.SetPackageLevel("github.com/other/pkg", "debug", 0)
+	if printed(l.PrintInfo, "info-3") {
+		t.Error("other package level affects logger package")
+	}
+	_ = l.ResetLevel("github.com/mutou1225/go-frame/logger")
+	_ = l.ResetLevel("github.com/other/pkg")
+
+	// 全局级别到期恢复
+	if err := l.SetLevel("debug", 50*time.Millisecond); err != nil {
+		t.Fatalf("SetLevel() Err: %s", err.Error())
+	}
+	if info := l.Levels(); info.Level != "debug" || info.Default != "info" || info.Expire == "" {
+		t.Errorf("Levels(): %+v", info)
+	}
+	if !printed(l.PrintDebug, "debug-4") {
+		t.Error("global level debug not printed")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if printed(l.PrintDebug, "debug-5") || l.Levels().Level != "info" {
+		t.Error("global level not reverted")
+	}
+
+	if err := l.SetLevel("verbose", 0); err == nil {
+		t.Error("SetLevel(verbose) want error")
+	}
+	if err := (*Logger)(nil).SetLevel("debug", 0); err == nil {
+		t.Error("nil logger SetLevel() want error")
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -43,6 +44,12 @@ type LogrusConfig struct {
 type MyLogger struct {
 	*logrus.Logger
 	fileHandle *os.File
+	// 日志回滚和 Close 切换 fileHandle
+	mutex sync.Mutex
+	// 关闭时停止日志回滚，并等待回滚的协程退出
+	done      chan struct{}
+	rotated   chan struct{}
+	closeOnce sync.Once
 }
 
 func NewLogrus(logConf LogrusConfig) *MyLogger {
@@ -75,7 +82,7 @@ func NewLogrus(logConf LogrusConfig) *MyLogger {
 	newLogrus.SetFormatter(&lConfig)
 	newLogrus.SetLevel(lConfig.LogLevel)
 	newLogrus.ExitFunc = func(i int) {}
-	newLogger := &MyLogger{newLogrus, nil}
+	newLogger := &MyLogger{Logger: newLogrus}
 
 	if err := os.MkdirAll(fmt.Sprintf("%s/%s", lConfig.LogFilePath, lConfig.ProgramName), os.ModePerm); err != nil {
 		log.Printf("~~~~~~ os.MkdirAll() Err: %s", err.Error())
@@ -96,18 +103,36 @@ func NewLogrus(logConf LogrusConfig) *MyLogger {
 	}
 
 	// 启动日志回滚
-	go lConfig.rotateLogs(newLogger, filePath)
+	newLogger.done = make(chan struct{})
+	newLogger.rotated = make(chan struct{})
+	go func() {
+		defer close(newLogger.rotated)
+		lConfig.rotateLogs(newLogger, filePath)
+	}()
 
 	return newLogger
 }
 
+// 先停止日志回滚再关闭文件，可以重复调用
 func (l *MyLogger) Close() {
-	//l.Close()
-
-	if l.fileHandle != nil {
-		_ = l.fileHandle.Close()
-		l.fileHandle = nil
+	if l == nil {
+		return
 	}
+
+	l.closeOnce.Do(func() {
+		if l.done != nil {
+			close(l.done)
+			<-l.rotated
+		}
+
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if l.fileHandle != nil {
+			_ = l.fileHandle.Sync()
+			_ = l.fileHandle.Close()
+			l.fileHandle = nil
+		}
+	})
 }
 
 // 获取日志打印内容的文件信息
@@ -224,22 +249,29 @@ func (f *LogrusConfig) getLogFilePath() string {
 	return strLogPath
 }
 
+// 每分钟检查日志文件（按天、按大小），Close 时退出
 func (f *LogrusConfig) rotateLogs(newLogger *MyLogger, logFilePath string) {
-	if newLogger.fileHandle == nil {
-		return
-	}
-
 	ticker := time.NewTicker(time.Minute)
-	for range ticker.C {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-newLogger.done:
+			return
+		case <-ticker.C:
+		}
+
 		strLogPathNew := f.getLogFilePath()
 		if strLogPathNew != logFilePath {
 			newHandle, err := os.OpenFile(strLogPathNew, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.ModePerm)
 			if err == nil {
 				logFilePath = strLogPathNew
 
+				newLogger.mutex.Lock()
 				newLogger.SetOutput(newHandle)
 				_ = newLogger.fileHandle.Close()
 				newLogger.fileHandle = newHandle
+				newLogger.mutex.Unlock()
 			}
 		}
 	}
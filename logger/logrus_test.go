@@ -67,3 +67,19 @@ func Benchmark_Prinf(b *testing.B) {
 		*/
 	}
 }
+
+// Close 停止日志回滚后关闭文件，可以重复调用
+func Test_LoggerClose(t *testing.T) {
+	testLogger := newTestLogger()
+	testLogger.Close()
+	testLogger.Close()
+
+	select {
+	case <-testLogger.rotated:
+	default:
+		t.Error("rotateLogs not stopped")
+	}
+	if testLogger.fileHandle != nil {
+		t.Error("fileHandle not closed")
+	}
+}
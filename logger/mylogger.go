@@ -10,21 +10,40 @@ import (
 	"time"
 )
 
-var (
-	myLogger       *MyLogger
-	myLoggerReport *MyLogger
-	myLoggerZipkin *MyLogger
-	hostname       string
-)
-
 const (
 	knownFrames = 3
 )
 
+// 默认日志实例，InitLogger 初始化，包级的 PrintXxx 函数使用
+var std *Logger
+
+// 日志实例：业务日志、接口上报日志、Zipkin上报日志
+type Logger struct {
+	main     *MyLogger
+	report   *MyLogger
+	zipkin   *MyLogger
+	hostname string
+	conf     *config.Config
+	// 运行时修改的日志级别
+	levels *levelControl
+}
+
 // 初始化日志系统
 func InitLogger(appName, fileName string) {
-	hostname, _ = http.ExternalIP()
-	logLevel, err := logrus.ParseLevel(config.GLogConfig.LogConfig.LogLevel)
+	std = NewLogger(appName, fileName, config.Default())
+}
+
+// 默认日志实例，未初始化时为 nil（nil 实例打印到标准输出）
+func Default() *Logger {
+	return std
+}
+
+// 使用 conf 的日志配置创建日志实例
+func NewLogger(appName, fileName string, conf *config.Config) *Logger {
+	logConfig := conf.GetLogConfig()
+	l := &Logger{conf: conf}
+	l.hostname, _ = http.ExternalIP()
+	logLevel, err := logrus.ParseLevel(logConfig.LogConfig.LogLevel)
 	if err != nil {
 		logLevel = InfoLevel
 	}
@@ -32,78 +51,94 @@ func InitLogger(appName, fileName string) {
 	logConf := LogrusConfig{
 		ProgramName: appName,
 		LogFileName: fileName,
-		LogFilePath: config.GLogConfig.LogConfig.LogFilePath,
-		Suffix:      config.GLogConfig.LogConfig.Suffix,
+		LogFilePath: logConfig.LogConfig.LogFilePath,
+		Suffix:      logConfig.LogConfig.Suffix,
 		LogLevel:    logLevel,
 		IsFormat:    true,
-		PrLogMaxLen: config.GLogConfig.LogConfig.LineSize,
-		MaxSize:     config.GLogConfig.LogConfig.MaxSize,
+		PrLogMaxLen: logConfig.LogConfig.LineSize,
+		MaxSize:     logConfig.LogConfig.MaxSize,
 	}
-	myLogger = NewLogrus(logConf)
-	myLogger.InitColor()
+	l.main = NewLogrus(logConf)
+	l.main.InitColor()
+	l.levels = newLevelControl(l.main, logConf.LogLevel)
 
 	// 接口上报
-	logConf.Suffix = config.GLogConfig.ReportConfig.Suffix
+	logConf.Suffix = logConfig.ReportConfig.Suffix
 	logConf.LogLevel = DebugLevel
 	logConf.IsFormat = false
 	log.Printf("logConf: %+v", logConf)
-	myLoggerReport = NewLogrus(logConf)
+	l.report = NewLogrus(logConf)
 
 	// Zipkin上报
-	logConf.LogFilePath = config.GLogConfig.ZipkinConfig.LogFilePath
-	logConf.Suffix = config.GLogConfig.ZipkinConfig.Suffix
+	logConf.LogFilePath = logConfig.ZipkinConfig.LogFilePath
+	logConf.Suffix = logConfig.ZipkinConfig.Suffix
 	logConf.LogLevel = DebugLevel
 	logConf.IsFormat = false
-	myLoggerZipkin = NewLogrus(logConf)
+	l.zipkin = NewLogrus(logConf)
+	return l
 }
 
 func ExitLogger() {
-	myLoggerReport.Close()
-	myLoggerZipkin.Close()
-	myLogger.Close()
+	std.Close()
 }
 
-// 打印 Debug 日志
-func PrintDebug(format string, v ...interface{}) {
-	PrintDebugCalldepth(knownFrames, format, v...)
+// 关闭日志文件
+func (l *Logger) Close() {
+	if l == nil {
+		return
+	}
+	l.report.Close()
+	l.zipkin.Close()
+	l.main.Close()
 }
 
-func PrintDebugCalldepth(calldepth int, format string, v ...interface{}) {
-	var strLog string
+func formatLog(format string, v ...interface{}) string {
 	if len(v) > 0 {
-		strLog = fmt.Sprintf(format, v...)
-	} else {
-		strLog = format
+		return fmt.Sprintf(format, v...)
+	}
+	return format
+}
+
+// 打印 Debug 日志
+func (l *Logger) PrintDebug(format string, v ...interface{}) {
+	l.PrintDebugCalldepth(knownFrames, format, v...)
+}
+
+func (l *Logger) PrintDebugCalldepth(calldepth int, format string, v ...interface{}) {
+	if l == nil || l.main == nil {
+		return
+	}
+
+	if !l.levels.enabled(DebugLevel, calldepth) {
+		return
 	}
 
-	funcName, fileName, lineNo := myLogger.GetContextInfo(calldepth)
-	myLogger.WithFields(logrus.Fields{
+	funcName, fileName, lineNo := l.main.GetContextInfo(calldepth)
+	l.main.WithFields(logrus.Fields{
 		"funcName": funcName,
 		"fileName": fileName,
 		"lineNo":   lineNo,
-	}).Debug(strLog)
+	}).Debug(formatLog(format, v...))
 }
 
 // 打印 Info 日志
-func PrintInfo(format string, v ...interface{}) {
-	PrintInfoCalldepth(knownFrames, format, v...)
+func (l *Logger) PrintInfo(format string, v ...interface{}) {
+	l.PrintInfoCalldepth(knownFrames, format, v...)
 }
 
-func PrintInfoCalldepth(calldepth int, format string, v ...interface{}) {
-	var strLog string
-	if len(v) > 0 {
-		strLog = fmt.Sprintf(format, v...)
-	} else {
-		strLog = format
+func (l *Logger) PrintInfoCalldepth(calldepth int, format string, v ...interface{}) {
+	strLog := formatLog(format, v...)
+	if l == nil || l.main == nil {
+		log.Println(strLog)
+		return
 	}
 
-	if myLogger == nil {
-		log.Println(strLog)
+	if !l.levels.enabled(InfoLevel, calldepth) {
 		return
 	}
 
-	funcName, fileName, lineNo := myLogger.GetContextInfo(calldepth)
-	myLogger.WithFields(logrus.Fields{
+	funcName, fileName, lineNo := l.main.GetContextInfo(calldepth)
+	l.main.WithFields(logrus.Fields{
 		"funcName": funcName,
 		"fileName": fileName,
 		"lineNo":   lineNo,
@@ -111,25 +146,23 @@ func PrintInfoCalldepth(calldepth int, format string, v ...interface{}) {
 }
 
 // 打印 Error 日志
-func PrintError(format string, v ...interface{}) {
-	PrintErrorCalldepth(knownFrames, format, v...)
+func (l *Logger) PrintError(format string, v ...interface{}) {
+	l.PrintErrorCalldepth(knownFrames, format, v...)
 }
 
-func PrintErrorCalldepth(calldepth int, format string, v ...interface{}) {
-	var strLog string
-	if len(v) > 0 {
-		strLog = fmt.Sprintf(format, v...)
-	} else {
-		strLog = format
+func (l *Logger) PrintErrorCalldepth(calldepth int, format string, v ...interface{}) {
+	strLog := formatLog(format, v...)
+	if l == nil || l.main == nil {
+		log.Println(strLog)
+		return
 	}
 
-	if myLogger == nil {
-		log.Println(strLog)
+	if !l.levels.enabled(ErrorLevel, calldepth) {
 		return
 	}
 
-	funcName, fileName, lineNo := myLogger.GetContextInfo(calldepth)
-	myLogger.WithFields(logrus.Fields{
+	funcName, fileName, lineNo := l.main.GetContextInfo(calldepth)
+	l.main.WithFields(logrus.Fields{
 		"funcName": funcName,
 		"fileName": fileName,
 		"lineNo":   lineNo,
@@ -137,21 +170,19 @@ func PrintErrorCalldepth(calldepth int, format string, v ...interface{}) {
 }
 
 // 打印 Panic 日志，并抛出 Panic
-func PrintPanic(format string, v ...interface{}) {
-	var strLog string
-	if len(v) > 0 {
-		strLog = fmt.Sprintf(format, v...)
-	} else {
-		strLog = format
-	}
+func (l *Logger) PrintPanic(format string, v ...interface{}) {
+	l.printPanicCalldepth(knownFrames, format, v...)
+}
 
-	if myLogger == nil {
+func (l *Logger) printPanicCalldepth(calldepth int, format string, v ...interface{}) {
+	strLog := formatLog(format, v...)
+	if l == nil || l.main == nil {
 		log.Panic(strLog)
 		return
 	}
 
-	funcName, fileName, lineNo := myLogger.GetContextInfo(knownFrames)
-	myLogger.WithFields(logrus.Fields{
+	funcName, fileName, lineNo := l.main.GetContextInfo(calldepth)
+	l.main.WithFields(logrus.Fields{
 		"funcName": funcName,
 		"fileName": fileName,
 		"lineNo":   lineNo,
@@ -159,32 +190,85 @@ func PrintPanic(format string, v ...interface{}) {
 }
 
 // 打印 Report 日志
-func PrintReport(callerName, calleeName, calleeNode, methods string, errCode int, timeConsume float64) {
-	if myLoggerReport == nil {
+func (l *Logger) PrintReport(callerName, calleeName, calleeNode, methods string, errCode int, timeConsume float64) {
+	if l == nil || l.report == nil {
 		log.Println("PrintReport() <nil>")
 		return
 	}
 
-	myLoggerReport.Info(fmt.Sprintf("1|%d|%s|%s|%s|%s|%s|%d|%0.3f",
-		time.Now().UTC().Unix(), callerName, hostname,
+	l.report.Info(fmt.Sprintf("1|%d|%s|%s|%s|%s|%s|%d|%0.3f",
+		time.Now().UTC().Unix(), callerName, l.hostname,
 		calleeName, calleeNode, methods, errCode, timeConsume))
 }
 
 // 打印 Report 日志
-func PrintReportByTime(calleeName, calleeNode, methods string, errCode int, startTimeNano int64) {
+func (l *Logger) PrintReportByTime(calleeName, calleeNode, methods string, errCode int, startTimeNano int64) {
 	nowTime := time.Now().UTC().UnixNano()
 	diffTime := (float64(nowTime) - float64(startTimeNano)) / float64(time.Millisecond)
-	PrintReport(config.GetServerName(), calleeName, calleeNode, methods, errCode, diffTime)
+	conf := config.Default()
+	if l != nil && l.conf != nil {
+		conf = l.conf
+	}
+	l.PrintReport(conf.GetServerName(), calleeName, calleeNode, methods, errCode, diffTime)
 }
 
 // 打印 Zipkin 日志
-func PrintZipkin(s string) {
-	if myLoggerZipkin == nil {
+func (l *Logger) PrintZipkin(s string) {
+	if l == nil || l.zipkin == nil {
 		log.Println("PrintZipkin() <nil>")
 		return
 	}
 
-	myLoggerZipkin.Info(s)
+	l.zipkin.Info(s)
+}
+
+// 以下函数使用默认日志实例
+
+// 打印 Debug 日志
+func PrintDebug(format string, v ...interface{}) {
+	PrintDebugCalldepth(knownFrames, format, v...)
+}
+
+func PrintDebugCalldepth(calldepth int, format string, v ...interface{}) {
+	std.PrintDebugCalldepth(calldepth+1, format, v...)
+}
+
+// 打印 Info 日志
+func PrintInfo(format string, v ...interface{}) {
+	PrintInfoCalldepth(knownFrames, format, v...)
+}
+
+func PrintInfoCalldepth(calldepth int, format string, v ...interface{}) {
+	std.PrintInfoCalldepth(calldepth+1, format, v...)
+}
+
+// 打印 Error 日志
+func PrintError(format string, v ...interface{}) {
+	PrintErrorCalldepth(knownFrames, format, v...)
+}
+
+func PrintErrorCalldepth(calldepth int, format string, v ...interface{}) {
+	std.PrintErrorCalldepth(calldepth+1, format, v...)
+}
+
+// 打印 Panic 日志，并抛出 Panic
+func PrintPanic(format string, v ...interface{}) {
+	std.printPanicCalldepth(knownFrames+1, format, v...)
+}
+
+// 打印 Report 日志
+func PrintReport(callerName, calleeName, calleeNode, methods string, errCode int, timeConsume float64) {
+	std.PrintReport(callerName, calleeName, calleeNode, methods, errCode, timeConsume)
+}
+
+// 打印 Report 日志
+func PrintReportByTime(calleeName, calleeNode, methods string, errCode int, startTimeNano int64) {
+	std.PrintReportByTime(calleeName, calleeNode, methods, errCode, startTimeNano)
+}
+
+// 打印 Zipkin 日志
+func PrintZipkin(s string) {
+	std.PrintZipkin(s)
 }
 
 // 打印各种类型数据